@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"github.com/sean-d/sloth/token"
+	"math/big"
 	"strings"
 )
 
@@ -34,9 +35,23 @@ Every valid program is a series of statements. These statements are contained in
 a slice of AST nodes that implement the Statement interface.
 */
 
+/*
+Every node also knows the stretch of source it was parsed from: Pos is the position of its first character and End
+the position just past its last one. Nodes built by hand rather than parsed have zero positions.
+*/
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position
+	End() token.Position
+}
+
+// after returns the position just past the single character closing token at pos, like the } of a block.
+func after(pos token.Position) token.Position {
+	if !pos.IsValid() {
+		return pos
+	}
+	return token.Position{Offset: pos.Offset + 1, Line: pos.Line, Column: pos.Column + 1}
 }
 
 type Statement interface {
@@ -61,6 +76,20 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -75,10 +104,17 @@ func (p *Program) String() string {
 
 // LetStatement has the fields we need: Name to hold the identifier of the binding and Value for the expression that produces the value.
 // The two methods statementNode and TokenLiteral satisfy the Statement and Node interfaces respectively.
+// const bindings are LetStatements too, told apart by their token.
 type LetStatement struct {
-	Token token.Token // the token.LET token
+	Token token.Token // the token.LET or token.CONST token
 	Name  *Identifier
 	Value Expression
+	Doc   string // the /// comment lines directly above the statement, joined by newlines
+}
+
+// IsConst reports whether the statement is a const rather than a let binding.
+func (ls *LetStatement) IsConst() bool {
+	return ls.Token.Type == token.CONST
 }
 
 func (ls *LetStatement) String() string {
@@ -102,6 +138,13 @@ func (ls *LetStatement) statementNode() {}
 func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
+func (ls *LetStatement) Pos() token.Position { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	return ls.Name.End()
+}
 
 // Return statement section
 type ReturnStatement struct {
@@ -125,6 +168,108 @@ func (rs *ReturnStatement) String() string {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Position {
+	if rs.ReturnValue != nil {
+		return rs.ReturnValue.End()
+	}
+	return rs.Token.End()
+}
+
+// ThrowStatement is throw Value;. Unlike return, a value is required.
+type ThrowStatement struct {
+	Token token.Token // the 'throw' token
+	Value Expression
+}
+
+func (ts *ThrowStatement) String() string {
+	return ts.TokenLiteral() + " " + ts.Value.String() + ";"
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) Pos() token.Position  { return ts.Token.Pos }
+func (ts *ThrowStatement) End() token.Position  { return ts.Value.End() }
+
+/*
+ImportStatement is import Path as Alias, which binds Alias to the module at Path, or from Path import Names, which
+binds each of Names to the module's binding of the same name. Exactly one of Alias and Names is set.
+*/
+type ImportStatement struct {
+	Token token.Token // the 'import' or 'from' token
+	Path  *StringLiteral
+	Alias *Identifier
+	Names []*Identifier
+}
+
+func (is *ImportStatement) String() string {
+	if is.Alias != nil {
+		return `import "` + is.Path.Value + `" as ` + is.Alias.String() + ";"
+	}
+
+	names := make([]string, len(is.Names))
+	for i, name := range is.Names {
+		names[i] = name.String()
+	}
+	return `from "` + is.Path.Value + `" import ` + strings.Join(names, ", ") + ";"
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) Pos() token.Position  { return is.Token.Pos }
+func (is *ImportStatement) End() token.Position {
+	if is.Alias != nil {
+		return is.Alias.End()
+	}
+	return is.Names[len(is.Names)-1].End()
+}
+
+/*
+ExportStatement is export followed by a let, const, fn or class declaration, or export { Names }. Either way it adds names
+to the module the file defines, which then holds only the names its file exports. Exactly one of Declaration and
+Names is set.
+*/
+type ExportStatement struct {
+	Token       token.Token // the 'export' token
+	Declaration Statement
+	Names       []*Identifier
+	Rbrace      token.Position // position of the } closing Names
+}
+
+// Exported returns the names the statement exports: the one its declaration binds, or Names.
+func (es *ExportStatement) Exported() []*Identifier {
+	switch decl := es.Declaration.(type) {
+	case *LetStatement:
+		return []*Identifier{decl.Name}
+	case *FunctionStatement:
+		return []*Identifier{decl.Name}
+	case *ClassStatement:
+		return []*Identifier{decl.Name}
+	}
+	return es.Names
+}
+
+func (es *ExportStatement) String() string {
+	if es.Declaration != nil {
+		return "export " + es.Declaration.String()
+	}
+
+	names := make([]string, len(es.Names))
+	for i, name := range es.Names {
+		names[i] = name.String()
+	}
+	return "export { " + strings.Join(names, ", ") + " };"
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExportStatement) End() token.Position {
+	if es.Declaration != nil {
+		return es.Declaration.End()
+	}
+	return after(es.Rbrace)
+}
 
 // Expression statement stuff
 
@@ -150,12 +295,136 @@ func (es *ExpressionStatement) String() string {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position {
+	if es.Expression != nil {
+		return es.Expression.Pos()
+	}
+	return es.Token.Pos
+}
+func (es *ExpressionStatement) End() token.Position {
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+	return es.Token.End()
+}
+
+// Function statement stuff
+
+// FunctionStatement is a named function declaration, fn name(params) { ... }. It binds Function to Name in the scope
+// it appears in, like let name = fn(params) { ... }; would, and also inside the function itself so that it can always
+// call itself by its name.
+type FunctionStatement struct {
+	Token    token.Token // the 'fn' token
+	Name     *Identifier
+	Function *FunctionLiteral
+	Doc      string // the /// comment lines directly above the statement, joined by newlines
+}
+
+func (fs *FunctionStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(fs.TokenLiteral() + " ")
+	out.WriteString(fs.Name.String())
+	out.WriteString("(")
+	out.WriteString(ParameterList(fs.Function.Parameters, fs.Function.Defaults, fs.Function.Rest))
+	out.WriteString(") ")
+	out.WriteString(fs.Function.Body.String())
+
+	return out.String()
+}
+
+func (fs *FunctionStatement) statementNode()       {}
+func (fs *FunctionStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *FunctionStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *FunctionStatement) End() token.Position  { return fs.Function.End() }
+
+// Class statement stuff
+
+/*
+ClassStatement is class Name { field; other = default; fn method() { ... } }. It binds Name to a constructor: a function
+taking the fields as its parameters, in the order they are declared, that returns a hash holding the fields and the
+methods. Fields with a default can be left out of a call, so like parameters they can't be followed by fields without
+one. Defaults is either nil or as long as Fields.
+
+Methods are called through the dot, instance.method(), which binds self to the instance.
+*/
+type ClassStatement struct {
+	Token    token.Token // the 'class' token
+	Name     *Identifier
+	Fields   []*Identifier
+	Defaults []Expression
+	Methods  []*FunctionStatement
+	Doc      string         // the /// comment lines directly above the statement, joined by newlines
+	Rbrace   token.Position // position of the closing }
+}
+
+func (cs *ClassStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(cs.TokenLiteral() + " ")
+	out.WriteString(cs.Name.String())
+	out.WriteString(" { ")
+	for i, field := range cs.Fields {
+		out.WriteString(field.String())
+		if i < len(cs.Defaults) && cs.Defaults[i] != nil {
+			out.WriteString(" = " + cs.Defaults[i].String())
+		}
+		out.WriteString("; ")
+	}
+	for _, method := range cs.Methods {
+		out.WriteString(method.String() + " ")
+	}
+	out.WriteString("}")
+
+	return out.String()
+}
+
+/*
+Constructor returns the function the statement binds its name to, the way it would be written by hand:
+
+	fn(x, y = 0) { {"x": x, "y": y, "norm": fn() { ... }} }
+
+It is built anew on every call, and the nodes it is built from are shared with the statement, not copied.
+*/
+func (cs *ClassStatement) Constructor() *FunctionLiteral {
+	hash := &HashLiteral{Token: token.Token{Type: token.LBRACE, Literal: "{", Pos: cs.Token.Pos}, Pairs: map[Expression]Expression{}}
+
+	add := func(name *Identifier, value Expression) {
+		key := &StringLiteral{Token: token.Token{Type: token.STRING, Literal: name.Value, Pos: name.Token.Pos}, Value: name.Value}
+		hash.Keys = append(hash.Keys, key)
+		hash.Pairs[key] = value
+	}
+	for _, field := range cs.Fields {
+		add(field, field)
+	}
+	for _, method := range cs.Methods {
+		add(method.Name, method.Function)
+	}
+
+	body := &BlockStatement{
+		Token:      hash.Token,
+		Statements: []Statement{&ExpressionStatement{Token: hash.Token, Expression: hash}},
+	}
+
+	return &FunctionLiteral{
+		Token:      token.Token{Type: token.FUNCTION, Literal: "fn", Pos: cs.Token.Pos},
+		Parameters: cs.Fields,
+		Defaults:   cs.Defaults,
+		Body:       body,
+	}
+}
+
+func (cs *ClassStatement) statementNode()       {}
+func (cs *ClassStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ClassStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ClassStatement) End() token.Position  { return after(cs.Rbrace) }
 
 // Block statement stuff
 
 type BlockStatement struct {
 	Token      token.Token // the { token
 	Statements []Statement
+	Rbrace     token.Position // position of the closing }
 }
 
 func (bs *BlockStatement) String() string {
@@ -170,6 +439,8 @@ func (bs *BlockStatement) String() string {
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position  { return after(bs.Rbrace) }
 
 // Identifier expression stuff
 
@@ -192,6 +463,8 @@ func (i *Identifier) expressionNode() {}
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
+func (i *Identifier) Pos() token.Position { return i.Token.Pos }
+func (i *Identifier) End() token.Position { return i.Token.End() }
 
 // Boolean expression stuff
 // Boolean: The Value field can hold values of the type bool, which means that we’re going to save
@@ -204,6 +477,8 @@ type Boolean struct {
 func (b *Boolean) String() string       { return b.Token.Literal }
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
+func (b *Boolean) End() token.Position  { return b.Token.End() }
 
 // Integer literal stuff
 
@@ -211,14 +486,18 @@ func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 // to ast.Identifier in the structure itself: Value is an int64 and not a string. This is the field that’s going to
 // contain the actual value the integer literal represents in the source code. When we build an *ast.IntegerLiteral
 // we have to convert the string in *ast.IntegerLiteral.Token.Literal (which is something like "5") to an int64.
+// Literals too large for an int64 are kept in Big instead and Value is left at 0.
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int
 }
 
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position  { return il.Token.End() }
 
 // StringLiteral fulfills the ast.Expression interface, just like *ast.Identifier does
 type StringLiteral struct {
@@ -229,14 +508,19 @@ type StringLiteral struct {
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position  { return sl.Token.End() }
 
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
+	Rbracket token.Position // position of the closing ]
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position  { return after(al.Rbracket) }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -252,6 +536,20 @@ func (al *ArrayLiteral) String() string {
 	return out.String()
 }
 
+// SpreadExpression is ...Value inside the arguments of a call or the elements of an array literal. It stands for the
+// elements of the array Value evaluates to, so f(...args) passes them as positional arguments and [1, ...rest] splices
+// them into the array. It's not an expression anywhere else.
+type SpreadExpression struct {
+	Token token.Token // The '...' token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) Pos() token.Position  { return se.Token.Pos }
+func (se *SpreadExpression) End() token.Position  { return se.Value.End() }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
 // PrefixExpression stuff
 // PrefixExpression node has two noteworthy fields: Operator and Right. Operator is a string that’s going to contain
 // either "-" or "!". The Right field contains the expression to the right of the operator.
@@ -276,6 +574,8 @@ func (pe *PrefixExpression) String() string {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position  { return pe.Right.End() }
 
 // InfixExpression stuff
 // InfixExpression fulfills the ast.Expression and ast.Node interfaces, by defining the expressionNode(), TokenLiteral() and String() methods.
@@ -301,6 +601,8 @@ func (ie *InfixExpression) String() string {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position  { return ie.Left.Pos() }
+func (ie *InfixExpression) End() token.Position  { return ie.Right.End() }
 
 // IfExpression fulfills the ast.Expression interface and has three fields that can represent an if-else-conditional.
 // Condition holds the condition, which can be any expression, and Consequence and Alternative point to the consequence
@@ -330,40 +632,161 @@ func (ie *IfExpression) String() string {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	return ie.Consequence.End()
+}
+
+// TryExpression is try { Body } catch (Param) { Catch }. Catch is evaluated with Param bound to the error if evaluating
+// Body fails.
+type TryExpression struct {
+	Token token.Token // The 'try' token
+	Body  *BlockStatement
+	Param *Identifier
+	Catch *BlockStatement
+}
+
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(te.Body.String())
+	out.WriteString(" catch (")
+	out.WriteString(te.Param.String())
+	out.WriteString(") ")
+	out.WriteString(te.Catch.String())
+
+	return out.String()
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) Pos() token.Position  { return te.Token.Pos }
+func (te *TryExpression) End() token.Position  { return te.Catch.End() }
+
+// SpawnExpression is spawn Function. Function is any expression evaluating to something callable without arguments.
+type SpawnExpression struct {
+	Token    token.Token // The 'spawn' token
+	Function Expression
+}
+
+func (se *SpawnExpression) String() string {
+	return "spawn " + se.Function.String()
+}
+
+func (se *SpawnExpression) expressionNode()      {}
+func (se *SpawnExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpawnExpression) Pos() token.Position  { return se.Token.Pos }
+func (se *SpawnExpression) End() token.Position  { return se.Function.End() }
+
+// ImportExpression is import Path. It evaluates to the module the file at Path defines.
+type ImportExpression struct {
+	Token token.Token // The 'import' token
+	Path  *StringLiteral
+}
+
+func (ie *ImportExpression) String() string {
+	return `import "` + ie.Path.Value + `"`
+}
+
+func (ie *ImportExpression) expressionNode()      {}
+func (ie *ImportExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *ImportExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *ImportExpression) End() token.Position  { return ie.Path.End() }
 
 // Function literal stuff
+
+// FunctionLiteral is fn(a, b = 10, ...rest) { ... }. Defaults is either nil or as long as Parameters, holding the
+// default value expression of each parameter or nil for parameters without one. Rest is the parameter that collects any
+// extra arguments into an array, if there is one.
+//
+// The short form |a, b| a + b is a FunctionLiteral too, with the opening pipe as its token and a body holding a single
+// return statement.
 type FunctionLiteral struct {
-	Token      token.Token // The 'fn' token
+	Token      token.Token // The 'fn' token, or the '|' of the short form
 	Parameters []*Identifier
+	Defaults   []Expression
+	Rest       *Identifier
 	Body       *BlockStatement
 }
 
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+	if body := fl.ShortBody(); body != nil {
+		out.WriteString("|")
+		out.WriteString(ParameterList(fl.Parameters, fl.Defaults, fl.Rest))
+		out.WriteString("| ")
+		out.WriteString(body.String())
+		return out.String()
 	}
 
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ParameterList(fl.Parameters, fl.Defaults, fl.Rest))
 	out.WriteString(") ")
 	out.WriteString(fl.Body.String())
 
 	return out.String()
 }
 
+// ParameterList renders a parameter list the way it is written in source, without the surrounding parentheses.
+func ParameterList(params []*Identifier, defaults []Expression, rest *Identifier) string {
+	list := []string{}
+	for i, p := range params {
+		if i < len(defaults) && defaults[i] != nil {
+			list = append(list, p.String()+" = "+defaults[i].String())
+		} else {
+			list = append(list, p.String())
+		}
+	}
+
+	if rest != nil {
+		list = append(list, "..."+rest.String())
+	}
+
+	return strings.Join(list, ", ")
+}
+
+// ShortBody returns the expression a function written in the short form returns, or nil if the function was written
+// with fn or its body is no longer a single return statement.
+func (fl *FunctionLiteral) ShortBody() Expression {
+	if fl.Token.Type != token.PIPE || fl.Body == nil || len(fl.Body.Statements) != 1 {
+		return nil
+	}
+	if ret, ok := fl.Body.Statements[0].(*ReturnStatement); ok {
+		return ret.ReturnValue
+	}
+	return nil
+}
+
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
+
+func (fl *FunctionLiteral) End() token.Position {
+	if body := fl.ShortBody(); body != nil {
+		return body.End()
+	}
+	return fl.Body.End()
+}
 
 // CallExpression consists of an expression that results in a function when evaluated and a list of expressions
 // that are the arguments to this function call.
 type CallExpression struct {
-	Token     token.Token // The '(' token
+	Token     token.Token // The '(' token, or the |> of a pipe
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Rparen    token.Position // position of the closing ), if there is one
+}
+
+// Piped reports whether the call was written as a pipe, value |> f or value |> f(args), where the value piped in is
+// the first of the Arguments.
+func (ce *CallExpression) Piped() bool {
+	return ce.Token.Type == token.PIPELINE && len(ce.Arguments) > 0
 }
 
 func (ce *CallExpression) String() string {
@@ -391,20 +814,29 @@ any expression. Syntactically it doesn’t make a difference which one it is, bu
 
 The fact that both Left and Index are expressions makes the parsing process easier, because we can use our parseExpression
 method to parse them.
+
+Optional marks a null-safe index, left?[index], which evaluates to null rather than an error when Left is null.
 */
 type IndexExpression struct {
-	Token token.Token // The [ token
-	Left  Expression
-	Index Expression
+	Token    token.Token // The [ or ?[ token
+	Left     Expression
+	Index    Expression
+	Optional bool
+	Rbracket token.Position // position of the closing ]
 }
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Left.Pos() }
+func (ie *IndexExpression) End() token.Position  { return after(ie.Rbracket) }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
+	if ie.Optional {
+		out.WriteString("?")
+	}
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
 	out.WriteString("])")
@@ -412,20 +844,82 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+/*
+DotExpression is left.name, a shorter way of writing left["name"] for hashes whose keys are names. Name is an identifier
+rather than an expression: what follows the dot is never evaluated, it is the key.
+
+Calling a DotExpression, left.name(), is a method call. The function found under the key is called with self bound to
+the hash it was found in.
+*/
+type DotExpression struct {
+	Token token.Token // The . token
+	Left  Expression
+	Name  *Identifier
+}
+
+func (de *DotExpression) expressionNode()      {}
+func (de *DotExpression) TokenLiteral() string { return de.Token.Literal }
+func (de *DotExpression) Pos() token.Position  { return de.Left.Pos() }
+func (de *DotExpression) End() token.Position  { return de.Name.End() }
+func (de *DotExpression) String() string {
+	return "(" + de.Left.String() + "." + de.Name.String() + ")"
+}
+
+// SliceExpression is left[low:high]. Either bound may be left out, in which case Low or High is nil.
+type SliceExpression struct {
+	Token    token.Token // The [ or ?[ token
+	Left     Expression
+	Low      Expression
+	High     Expression
+	Optional bool
+	Rbracket token.Position // position of the closing ]
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.Position  { return se.Left.Pos() }
+func (se *SliceExpression) End() token.Position  { return after(se.Rbracket) }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	if se.Optional {
+		out.WriteString("?")
+	}
+	out.WriteString("[")
+	if se.Low != nil {
+		out.WriteString(se.Low.String())
+	}
+	out.WriteString(":")
+	if se.High != nil {
+		out.WriteString(se.High.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
 // HashLiteral allows any expression as a key and value in the parsing stage.
+// Pairs is a Go map and so has no order of its own; Keys holds the same keys in the order they appear in the source
+// so that printing the literal back out is stable.
 type HashLiteral struct {
-	Token token.Token // the '{' token
-	Pairs map[Expression]Expression
+	Token  token.Token // the '{' token
+	Pairs  map[Expression]Expression
+	Keys   []Expression
+	Rbrace token.Position // position of the closing }
 }
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position  { return after(hl.Rbrace) }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.OrderedKeys() {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -435,5 +929,32 @@ func (hl *HashLiteral) String() string {
 	return out.String()
 }
 
+// OrderedKeys returns the keys of the literal in source order. Hash literals built by hand without Keys fall back to
+// the map's own iteration order.
+func (hl *HashLiteral) OrderedKeys() []Expression {
+	if len(hl.Keys) == len(hl.Pairs) {
+		return hl.Keys
+	}
+
+	keys := []Expression{}
+	for key := range hl.Pairs {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position {
+	if ce.Piped() {
+		return ce.Arguments[0].Pos()
+	}
+	return ce.Function.Pos()
+}
+func (ce *CallExpression) End() token.Position {
+	if ce.Piped() && !ce.Rparen.IsValid() {
+		return ce.Function.End()
+	}
+	return after(ce.Rparen)
+}
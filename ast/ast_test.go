@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"fmt"
 	"github.com/sean-d/sloth/token"
 	"testing"
 )
@@ -35,3 +36,72 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got %q", program.String())
 	}
 }
+
+func TestRewrite(t *testing.T) {
+	integer := func(value int64) *IntegerLiteral {
+		return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: fmt.Sprint(value)}, Value: value}
+	}
+	infix := func(left Expression, operator string, right Expression) *InfixExpression {
+		return &InfixExpression{Token: token.Token{Type: token.TokenType(operator), Literal: operator}, Left: left, Operator: operator, Right: right}
+	}
+	ident := func(name string) *Identifier {
+		return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+	}
+
+	// let x = (1 + 2) * 3; x;
+	program := &Program{Statements: []Statement{
+		&LetStatement{Token: token.Token{Type: token.LET, Literal: "let"}, Name: ident("x"), Value: infix(infix(integer(1), "+", integer(2)), "*", integer(3))},
+		&ExpressionStatement{Token: token.Token{Type: token.IDENT, Literal: "x"}, Expression: ident("x")},
+	}}
+	original := program.String()
+
+	// folding bottom-up turns the inner sum into 3 before the product is looked at
+	fold := func(node Node) Node {
+		exp, ok := node.(*InfixExpression)
+		if !ok {
+			return node
+		}
+		left, leftOk := exp.Left.(*IntegerLiteral)
+		right, rightOk := exp.Right.(*IntegerLiteral)
+		if !leftOk || !rightOk {
+			return node
+		}
+		switch exp.Operator {
+		case "+":
+			return integer(left.Value + right.Value)
+		case "*":
+			return integer(left.Value * right.Value)
+		}
+		return node
+	}
+
+	folded := Rewrite(program, fold)
+	if folded.String() != "let x = 9;x" {
+		t.Errorf("folded program wrong. got=%q", folded.String())
+	}
+	if program.String() != original {
+		t.Errorf("Rewrite modified the original tree. got=%q, want=%q", program.String(), original)
+	}
+
+	removed := Rewrite(program, func(node Node) Node {
+		if _, ok := node.(*ExpressionStatement); ok {
+			return nil
+		}
+		return node
+	})
+	if removed.String() != "let x = ((1 + 2) * 3);" {
+		t.Errorf("statement not removed. got=%q", removed.String())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic when an identifier is replaced by an integer in a let statement")
+		}
+	}()
+	Rewrite(program, func(node Node) Node {
+		if ident, ok := node.(*Identifier); ok && ident.Value == "x" {
+			return integer(1)
+		}
+		return node
+	})
+}
@@ -0,0 +1,317 @@
+package ast
+
+import "fmt"
+
+/*
+Rewrite returns a copy of the tree rooted at node with fn applied to every node in it, bottom-up: the children of a
+node are rewritten first and fn is then called on a copy of the node holding the rewritten children. Whatever fn
+returns takes the node's place in the new tree, so returning its argument unchanged keeps the node as it is. The tree
+passed in is never modified.
+
+Returning nil for a statement removes it from the program or block it is in. Anywhere else nil simply leaves the slot
+empty, which is only valid where the slot is optional, like the alternative of an if.
+
+A replacement has to fit where it goes: an expression where an expression was, a block where a block was and so on.
+Rewrite panics if it doesn't, since the tree it would build couldn't be evaluated or printed.
+*/
+func Rewrite(node Node, fn func(Node) Node) Node {
+	if IsNil(node) {
+		return nil
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		n := *node
+		n.Statements = rewriteStatements(node.Statements, fn)
+		return fn(&n)
+
+	case *LetStatement:
+		n := *node
+		n.Name = rewriteIdentifier(node.Name, fn)
+		n.Value = rewriteExpression(node.Value, fn)
+		return fn(&n)
+
+	case *ReturnStatement:
+		n := *node
+		n.ReturnValue = rewriteExpression(node.ReturnValue, fn)
+		return fn(&n)
+
+	case *ThrowStatement:
+		n := *node
+		n.Value = rewriteExpression(node.Value, fn)
+		return fn(&n)
+
+	case *ExpressionStatement:
+		n := *node
+		n.Expression = rewriteExpression(node.Expression, fn)
+		return fn(&n)
+
+	case *FunctionStatement:
+		n := *node
+		n.Name = rewriteIdentifier(node.Name, fn)
+		switch function := rewriteExpression(node.Function, fn).(type) {
+		case nil:
+			n.Function = nil
+		case *FunctionLiteral:
+			n.Function = function
+		default:
+			misfit(function, "*ast.FunctionLiteral")
+		}
+		return fn(&n)
+
+	case *ImportStatement:
+		n := *node
+		n.Alias = rewriteIdentifier(node.Alias, fn)
+		n.Names = nil
+		for _, name := range node.Names {
+			n.Names = append(n.Names, rewriteIdentifier(name, fn))
+		}
+		return fn(&n)
+
+	case *ExportStatement:
+		n := *node
+		if node.Declaration != nil {
+			switch decl := Rewrite(node.Declaration, fn).(type) {
+			case nil:
+				n.Declaration = nil
+			case Statement:
+				n.Declaration = decl
+			default:
+				misfit(decl, "ast.Statement")
+			}
+		}
+		n.Names = nil
+		for _, name := range node.Names {
+			n.Names = append(n.Names, rewriteIdentifier(name, fn))
+		}
+		return fn(&n)
+
+	case *ClassStatement:
+		n := *node
+		n.Name = rewriteIdentifier(node.Name, fn)
+		n.Fields = nil
+		for _, field := range node.Fields {
+			n.Fields = append(n.Fields, rewriteIdentifier(field, fn))
+		}
+		n.Defaults = rewriteExpressions(node.Defaults, fn)
+		n.Methods = nil
+		for _, method := range node.Methods {
+			switch method := Rewrite(method, fn).(type) {
+			case nil:
+			case *FunctionStatement:
+				n.Methods = append(n.Methods, method)
+			default:
+				misfit(method, "*ast.FunctionStatement")
+			}
+		}
+		return fn(&n)
+
+	case *BlockStatement:
+		n := *node
+		n.Statements = rewriteStatements(node.Statements, fn)
+		return fn(&n)
+
+	case *PrefixExpression:
+		n := *node
+		n.Right = rewriteExpression(node.Right, fn)
+		return fn(&n)
+
+	case *InfixExpression:
+		n := *node
+		n.Left = rewriteExpression(node.Left, fn)
+		n.Right = rewriteExpression(node.Right, fn)
+		return fn(&n)
+
+	case *IfExpression:
+		n := *node
+		n.Condition = rewriteExpression(node.Condition, fn)
+		n.Consequence = rewriteBlock(node.Consequence, fn)
+		n.Alternative = rewriteBlock(node.Alternative, fn)
+		return fn(&n)
+
+	case *TryExpression:
+		n := *node
+		n.Body = rewriteBlock(node.Body, fn)
+		n.Param = rewriteIdentifier(node.Param, fn)
+		n.Catch = rewriteBlock(node.Catch, fn)
+		return fn(&n)
+
+	case *SpawnExpression:
+		n := *node
+		n.Function = rewriteExpression(node.Function, fn)
+		return fn(&n)
+
+	case *FunctionLiteral:
+		n := *node
+		n.Parameters = nil
+		for _, param := range node.Parameters {
+			n.Parameters = append(n.Parameters, rewriteIdentifier(param, fn))
+		}
+		n.Defaults = rewriteExpressions(node.Defaults, fn)
+		n.Rest = rewriteIdentifier(node.Rest, fn)
+		n.Body = rewriteBlock(node.Body, fn)
+		return fn(&n)
+
+	case *CallExpression:
+		n := *node
+		n.Function = rewriteExpression(node.Function, fn)
+		n.Arguments = rewriteExpressions(node.Arguments, fn)
+		return fn(&n)
+
+	case *ArrayLiteral:
+		n := *node
+		n.Elements = rewriteExpressions(node.Elements, fn)
+		return fn(&n)
+
+	case *SpreadExpression:
+		n := *node
+		n.Value = rewriteExpression(node.Value, fn)
+		return fn(&n)
+
+	case *IndexExpression:
+		n := *node
+		n.Left = rewriteExpression(node.Left, fn)
+		n.Index = rewriteExpression(node.Index, fn)
+		return fn(&n)
+
+	case *DotExpression:
+		n := *node
+		n.Left = rewriteExpression(node.Left, fn)
+		n.Name = rewriteIdentifier(node.Name, fn)
+		return fn(&n)
+
+	case *SliceExpression:
+		n := *node
+		n.Left = rewriteExpression(node.Left, fn)
+		n.Low = rewriteExpression(node.Low, fn)
+		n.High = rewriteExpression(node.High, fn)
+		return fn(&n)
+
+	case *HashLiteral:
+		n := *node
+		n.Keys = nil
+		n.Pairs = make(map[Expression]Expression, len(node.Pairs))
+		for _, key := range node.OrderedKeys() {
+			value := rewriteExpression(node.Pairs[key], fn)
+			key = rewriteExpression(key, fn)
+			n.Keys = append(n.Keys, key)
+			n.Pairs[key] = value
+		}
+		return fn(&n)
+
+	case *Identifier:
+		n := *node
+		return fn(&n)
+
+	case *Boolean:
+		n := *node
+		return fn(&n)
+
+	case *IntegerLiteral:
+		n := *node
+		return fn(&n)
+
+	case *StringLiteral:
+		n := *node
+		return fn(&n)
+
+	case *ImportExpression:
+		// the path is part of the import rather than an expression of its own, so it isn't rewritten separately
+		n := *node
+		return fn(&n)
+	}
+
+	// a node type Rewrite doesn't know about has no children it could reach, so only the node itself is rewritten
+	return fn(node)
+}
+
+func rewriteStatements(statements []Statement, fn func(Node) Node) []Statement {
+	rewritten := []Statement{}
+	for _, stmt := range statements {
+		node := Rewrite(stmt, fn)
+		if IsNil(node) {
+			continue
+		}
+
+		s, ok := node.(Statement)
+		if !ok {
+			misfit(node, "a statement")
+		}
+		rewritten = append(rewritten, s)
+	}
+	return rewritten
+}
+
+func rewriteExpressions(expressions []Expression, fn func(Node) Node) []Expression {
+	if expressions == nil {
+		return nil
+	}
+
+	rewritten := make([]Expression, len(expressions))
+	for i, exp := range expressions {
+		rewritten[i] = rewriteExpression(exp, fn)
+	}
+	return rewritten
+}
+
+func rewriteExpression(exp Expression, fn func(Node) Node) Expression {
+	node := Rewrite(exp, fn)
+	if IsNil(node) {
+		return nil
+	}
+
+	e, ok := node.(Expression)
+	if !ok {
+		misfit(node, "an expression")
+	}
+	return e
+}
+
+func rewriteIdentifier(ident *Identifier, fn func(Node) Node) *Identifier {
+	node := Rewrite(ident, fn)
+	if IsNil(node) {
+		return nil
+	}
+
+	i, ok := node.(*Identifier)
+	if !ok {
+		misfit(node, "*ast.Identifier")
+	}
+	return i
+}
+
+func rewriteBlock(block *BlockStatement, fn func(Node) Node) *BlockStatement {
+	node := Rewrite(block, fn)
+	if IsNil(node) {
+		return nil
+	}
+
+	b, ok := node.(*BlockStatement)
+	if !ok {
+		misfit(node, "*ast.BlockStatement")
+	}
+	return b
+}
+
+func misfit(node Node, want string) {
+	panic(fmt.Sprintf("ast.Rewrite: cannot put %T where %s goes", node, want))
+}
+
+// isNil reports whether node is nil, including a nil pointer of one of the node types.
+func IsNil(node Node) bool {
+	switch node := node.(type) {
+	case nil:
+		return true
+	case *Identifier:
+		return node == nil
+	case *BlockStatement:
+		return node == nil
+	case *FunctionLiteral:
+		return node == nil
+	case *Program:
+		return node == nil
+	case *SpreadExpression:
+		return node == nil
+	}
+	return false
+}
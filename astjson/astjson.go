@@ -0,0 +1,693 @@
+/*
+Package astjson converts ASTs to and from JSON, so tools that aren't written in Go can work with sloth programs and
+tests can compare trees against golden files.
+
+Every node is encoded as an object whose "type" member names the node, e.g. "InfixExpression", followed by the token
+the node was built from and then its fields, always in the same order:
+
+	{"type":"Identifier","token":{"type":"IDENT","literal":"x","line":1,"column":5,"offset":4},"value":"x"}
+
+Nodes that end in a closing bracket, brace or parenthesis also record where it is, e.g. "rbrace" for a block.
+Missing children, like the alternative of an if without an else, are encoded as null. Unmarshal reverses Marshal
+exactly, positions included.
+*/
+package astjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/sean-d/sloth/ast"
+	"github.com/sean-d/sloth/token"
+	"math/big"
+)
+
+// Marshal returns the JSON encoding of node.
+func Marshal(node ast.Node) ([]byte, error) {
+	return json.Marshal(encode(node))
+}
+
+// MarshalIndent is like Marshal but indents the output the way json.MarshalIndent does.
+func MarshalIndent(node ast.Node, prefix string, indent string) ([]byte, error) {
+	return json.MarshalIndent(encode(node), prefix, indent)
+}
+
+// Unmarshal decodes a node encoded by Marshal.
+func Unmarshal(data []byte) (ast.Node, error) {
+	var raw json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	d := &decoder{}
+	node := d.node(raw)
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	return node, nil
+}
+
+// field is one member of an encoded node. Nodes are encoded as lists of fields rather than maps so that the members
+// always come out in the order they were written in.
+type field struct {
+	name  string
+	value any
+}
+
+type fields []field
+
+func (f fields) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+
+	out.WriteByte('{')
+	for i, member := range f {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+
+		name, err := json.Marshal(member.name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(member.value)
+		if err != nil {
+			return nil, err
+		}
+
+		out.Write(name)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+	out.WriteByte('}')
+
+	return out.Bytes(), nil
+}
+
+// jsonToken is the encoding of a token.Token. The position is left out when it isn't known.
+type jsonToken struct {
+	Type    token.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+	Line    int             `json:"line,omitempty"`
+	Column  int             `json:"column,omitempty"`
+	Offset  int             `json:"offset,omitempty"`
+}
+
+func encodeToken(tok token.Token) jsonToken {
+	return jsonToken{Type: tok.Type, Literal: tok.Literal, Line: tok.Pos.Line, Column: tok.Pos.Column, Offset: tok.Pos.Offset}
+}
+
+// jsonPosition is the encoding of a token.Position on its own, like the closing } of a block.
+type jsonPosition struct {
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+	Offset int `json:"offset,omitempty"`
+}
+
+func encodePosition(pos token.Position) jsonPosition {
+	return jsonPosition{Line: pos.Line, Column: pos.Column, Offset: pos.Offset}
+}
+
+// encode returns the value that is marshalled for node, or nil for a nil node.
+func encode(node ast.Node) any {
+	switch node := node.(type) {
+	case *ast.Program:
+		return fields{{"type", "Program"}, {"statements", encodeStatements(node.Statements)}}
+
+	case *ast.LetStatement:
+		if node == nil {
+			return nil
+		}
+		return fields{
+			{"type", "LetStatement"},
+			{"token", encodeToken(node.Token)},
+			{"name", encode(node.Name)},
+			{"value", encode(node.Value)},
+			{"doc", node.Doc},
+		}
+
+	case *ast.ReturnStatement:
+		if node == nil {
+			return nil
+		}
+		return fields{{"type", "ReturnStatement"}, {"token", encodeToken(node.Token)}, {"returnValue", encode(node.ReturnValue)}}
+
+	case *ast.ThrowStatement:
+		if node == nil {
+			return nil
+		}
+		return fields{{"type", "ThrowStatement"}, {"token", encodeToken(node.Token)}, {"value", encode(node.Value)}}
+
+	case *ast.ExpressionStatement:
+		if node == nil {
+			return nil
+		}
+		return fields{{"type", "ExpressionStatement"}, {"token", encodeToken(node.Token)}, {"expression", encode(node.Expression)}}
+
+	case *ast.FunctionStatement:
+		if node == nil {
+			return nil
+		}
+		return fields{
+			{"type", "FunctionStatement"},
+			{"token", encodeToken(node.Token)},
+			{"name", encode(node.Name)},
+			{"function", encode(node.Function)},
+			{"doc", node.Doc},
+		}
+
+	case *ast.ImportStatement:
+		names := make([]any, len(node.Names))
+		for i, name := range node.Names {
+			names[i] = encode(name)
+		}
+		return fields{
+			{"type", "ImportStatement"},
+			{"token", encodeToken(node.Token)},
+			{"path", encode(node.Path)},
+			{"alias", encode(node.Alias)},
+			{"names", names},
+		}
+
+	case *ast.ExportStatement:
+		names := make([]any, len(node.Names))
+		for i, name := range node.Names {
+			names[i] = encode(name)
+		}
+		return fields{
+			{"type", "ExportStatement"},
+			{"token", encodeToken(node.Token)},
+			{"declaration", encode(node.Declaration)},
+			{"names", names},
+			{"rbrace", encodePosition(node.Rbrace)},
+		}
+
+	case *ast.ClassStatement:
+		names := make([]any, len(node.Fields))
+		for i, name := range node.Fields {
+			names[i] = encode(name)
+		}
+		methods := make([]any, len(node.Methods))
+		for i, method := range node.Methods {
+			methods[i] = encode(method)
+		}
+		return fields{
+			{"type", "ClassStatement"},
+			{"token", encodeToken(node.Token)},
+			{"name", encode(node.Name)},
+			{"fields", names},
+			{"defaults", encodeExpressions(node.Defaults)},
+			{"methods", methods},
+			{"doc", node.Doc},
+			{"rbrace", encodePosition(node.Rbrace)},
+		}
+
+	case *ast.BlockStatement:
+		if node == nil {
+			return nil
+		}
+		return fields{
+			{"type", "BlockStatement"},
+			{"token", encodeToken(node.Token)},
+			{"statements", encodeStatements(node.Statements)},
+			{"rbrace", encodePosition(node.Rbrace)},
+		}
+
+	case *ast.Identifier:
+		if node == nil {
+			return nil
+		}
+		return fields{{"type", "Identifier"}, {"token", encodeToken(node.Token)}, {"value", node.Value}}
+
+	case *ast.Boolean:
+		return fields{{"type", "Boolean"}, {"token", encodeToken(node.Token)}, {"value", node.Value}}
+
+	case *ast.IntegerLiteral:
+		// integers too big for an int64 are kept as decimal strings, which JSON numbers can't be trusted with
+		var bigValue any
+		if node.Big != nil {
+			bigValue = node.Big.String()
+		}
+		return fields{{"type", "IntegerLiteral"}, {"token", encodeToken(node.Token)}, {"value", node.Value}, {"big", bigValue}}
+
+	case *ast.StringLiteral:
+		return fields{{"type", "StringLiteral"}, {"token", encodeToken(node.Token)}, {"value", node.Value}}
+
+	case *ast.ArrayLiteral:
+		return fields{
+			{"type", "ArrayLiteral"},
+			{"token", encodeToken(node.Token)},
+			{"elements", encodeExpressions(node.Elements)},
+			{"rbracket", encodePosition(node.Rbracket)},
+		}
+
+	case *ast.SpreadExpression:
+		return fields{
+			{"type", "SpreadExpression"},
+			{"token", encodeToken(node.Token)},
+			{"value", encode(node.Value)},
+		}
+
+	case *ast.ImportExpression:
+		return fields{
+			{"type", "ImportExpression"},
+			{"token", encodeToken(node.Token)},
+			{"path", encode(node.Path)},
+		}
+
+	case *ast.PrefixExpression:
+		return fields{
+			{"type", "PrefixExpression"},
+			{"token", encodeToken(node.Token)},
+			{"operator", node.Operator},
+			{"right", encode(node.Right)},
+		}
+
+	case *ast.InfixExpression:
+		return fields{
+			{"type", "InfixExpression"},
+			{"token", encodeToken(node.Token)},
+			{"left", encode(node.Left)},
+			{"operator", node.Operator},
+			{"right", encode(node.Right)},
+		}
+
+	case *ast.IfExpression:
+		return fields{
+			{"type", "IfExpression"},
+			{"token", encodeToken(node.Token)},
+			{"condition", encode(node.Condition)},
+			{"consequence", encode(node.Consequence)},
+			{"alternative", encode(node.Alternative)},
+		}
+
+	case *ast.TryExpression:
+		return fields{
+			{"type", "TryExpression"},
+			{"token", encodeToken(node.Token)},
+			{"body", encode(node.Body)},
+			{"param", encode(node.Param)},
+			{"catch", encode(node.Catch)},
+		}
+
+	case *ast.SpawnExpression:
+		return fields{{"type", "SpawnExpression"}, {"token", encodeToken(node.Token)}, {"function", encode(node.Function)}}
+
+	case *ast.FunctionLiteral:
+		if node == nil {
+			return nil
+		}
+		params := make([]any, len(node.Parameters))
+		for i, param := range node.Parameters {
+			params[i] = encode(param)
+		}
+		return fields{
+			{"type", "FunctionLiteral"},
+			{"token", encodeToken(node.Token)},
+			{"parameters", params},
+			{"defaults", encodeExpressions(node.Defaults)},
+			{"rest", encode(node.Rest)},
+			{"body", encode(node.Body)},
+		}
+
+	case *ast.CallExpression:
+		return fields{
+			{"type", "CallExpression"},
+			{"token", encodeToken(node.Token)},
+			{"function", encode(node.Function)},
+			{"arguments", encodeExpressions(node.Arguments)},
+			{"rparen", encodePosition(node.Rparen)},
+		}
+
+	case *ast.IndexExpression:
+		return fields{
+			{"type", "IndexExpression"},
+			{"token", encodeToken(node.Token)},
+			{"left", encode(node.Left)},
+			{"index", encode(node.Index)},
+			{"optional", node.Optional},
+			{"rbracket", encodePosition(node.Rbracket)},
+		}
+
+	case *ast.DotExpression:
+		return fields{{"type", "DotExpression"}, {"token", encodeToken(node.Token)}, {"left", encode(node.Left)}, {"name", encode(node.Name)}}
+
+	case *ast.SliceExpression:
+		return fields{
+			{"type", "SliceExpression"},
+			{"token", encodeToken(node.Token)},
+			{"left", encode(node.Left)},
+			{"low", encode(node.Low)},
+			{"high", encode(node.High)},
+			{"optional", node.Optional},
+			{"rbracket", encodePosition(node.Rbracket)},
+		}
+
+	case *ast.HashLiteral:
+		// pairs are written in source order, as [key, value] arrays, since keys are expressions and not strings
+		pairs := make([]any, len(node.Keys))
+		for i, key := range node.Keys {
+			pairs[i] = []any{encode(key), encode(node.Pairs[key])}
+		}
+		return fields{{"type", "HashLiteral"}, {"token", encodeToken(node.Token)}, {"pairs", pairs}, {"rbrace", encodePosition(node.Rbrace)}}
+	}
+
+	return nil
+}
+
+func encodeStatements(statements []ast.Statement) []any {
+	encoded := make([]any, len(statements))
+	for i, stmt := range statements {
+		encoded[i] = encode(stmt)
+	}
+	return encoded
+}
+
+func encodeExpressions(expressions []ast.Expression) []any {
+	if expressions == nil {
+		return nil
+	}
+
+	encoded := make([]any, len(expressions))
+	for i, exp := range expressions {
+		encoded[i] = encode(exp)
+	}
+	return encoded
+}
+
+/*
+decoder turns encoded nodes back into ast nodes. The first error it runs into is kept in err and from then on every
+method returns zero values, so decoding a node can read all of its fields without checking for an error after each
+one.
+*/
+type decoder struct {
+	err error
+}
+
+func (d *decoder) fail(format string, a ...any) {
+	if d.err == nil {
+		d.err = fmt.Errorf("astjson: "+format, a...)
+	}
+}
+
+// node decodes raw into the node its "type" member names, or returns nil if raw is null.
+func (d *decoder) node(raw json.RawMessage) ast.Node {
+	if d.err != nil || isNull(raw) {
+		return nil
+	}
+
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &m); err != nil {
+		d.fail("%s", err)
+		return nil
+	}
+
+	var kind string
+	d.value(m, "type", &kind)
+
+	switch kind {
+	case "Program":
+		return &ast.Program{Statements: d.statements(m, "statements")}
+	case "LetStatement":
+		return &ast.LetStatement{Token: d.token(m), Name: d.identifier(m, "name"), Value: d.expression(m, "value"), Doc: d.string(m, "doc")}
+	case "ReturnStatement":
+		return &ast.ReturnStatement{Token: d.token(m), ReturnValue: d.expression(m, "returnValue")}
+	case "ThrowStatement":
+		return &ast.ThrowStatement{Token: d.token(m), Value: d.expression(m, "value")}
+	case "ExpressionStatement":
+		return &ast.ExpressionStatement{Token: d.token(m), Expression: d.expression(m, "expression")}
+	case "FunctionStatement":
+		stmt := &ast.FunctionStatement{Token: d.token(m), Name: d.identifier(m, "name"), Doc: d.string(m, "doc")}
+		if fn, ok := d.expression(m, "function").(*ast.FunctionLiteral); ok {
+			stmt.Function = fn
+		} else {
+			d.fail("FunctionStatement function must be a FunctionLiteral")
+		}
+		return stmt
+	case "ClassStatement":
+		stmt := &ast.ClassStatement{
+			Token:    d.token(m),
+			Name:     d.identifier(m, "name"),
+			Defaults: d.expressions(m, "defaults"),
+			Doc:      d.string(m, "doc"),
+			Rbrace:   d.position(m, "rbrace"),
+		}
+		var names, methods []json.RawMessage
+		d.value(m, "fields", &names)
+		for _, raw := range names {
+			stmt.Fields = append(stmt.Fields, d.identifierNode(raw))
+		}
+		d.value(m, "methods", &methods)
+		for _, raw := range methods {
+			if method, ok := d.node(raw).(*ast.FunctionStatement); ok {
+				stmt.Methods = append(stmt.Methods, method)
+			} else {
+				d.fail("ClassStatement methods must be FunctionStatements")
+			}
+		}
+		return stmt
+	case "ImportStatement":
+		stmt := &ast.ImportStatement{Token: d.token(m), Alias: d.identifier(m, "alias")}
+		if path, ok := d.expression(m, "path").(*ast.StringLiteral); ok {
+			stmt.Path = path
+		} else {
+			d.fail("ImportStatement path must be a StringLiteral")
+		}
+		var names []json.RawMessage
+		d.value(m, "names", &names)
+		for _, raw := range names {
+			stmt.Names = append(stmt.Names, d.identifierNode(raw))
+		}
+		return stmt
+	case "ExportStatement":
+		stmt := &ast.ExportStatement{Token: d.token(m), Rbrace: d.position(m, "rbrace")}
+		if node := d.node(m["declaration"]); node != nil {
+			if decl, ok := node.(ast.Statement); ok {
+				stmt.Declaration = decl
+			} else {
+				d.fail("ExportStatement declaration must be a statement")
+			}
+		}
+		var names []json.RawMessage
+		d.value(m, "names", &names)
+		for _, raw := range names {
+			stmt.Names = append(stmt.Names, d.identifierNode(raw))
+		}
+		return stmt
+	case "BlockStatement":
+		return &ast.BlockStatement{Token: d.token(m), Statements: d.statements(m, "statements"), Rbrace: d.position(m, "rbrace")}
+	case "Identifier":
+		return &ast.Identifier{Token: d.token(m), Value: d.string(m, "value")}
+	case "Boolean":
+		var value bool
+		d.value(m, "value", &value)
+		return &ast.Boolean{Token: d.token(m), Value: value}
+	case "IntegerLiteral":
+		lit := &ast.IntegerLiteral{Token: d.token(m)}
+		d.value(m, "value", &lit.Value)
+		var bigValue *string
+		d.value(m, "big", &bigValue)
+		if bigValue != nil {
+			var ok bool
+			if lit.Big, ok = new(big.Int).SetString(*bigValue, 10); !ok {
+				d.fail("could not parse %q as integer", *bigValue)
+			}
+		}
+		return lit
+	case "StringLiteral":
+		return &ast.StringLiteral{Token: d.token(m), Value: d.string(m, "value")}
+	case "ArrayLiteral":
+		return &ast.ArrayLiteral{Token: d.token(m), Elements: d.expressions(m, "elements"), Rbracket: d.position(m, "rbracket")}
+	case "SpreadExpression":
+		return &ast.SpreadExpression{Token: d.token(m), Value: d.expression(m, "value")}
+	case "ImportExpression":
+		expression := &ast.ImportExpression{Token: d.token(m)}
+		if path, ok := d.expression(m, "path").(*ast.StringLiteral); ok {
+			expression.Path = path
+		} else {
+			d.fail("ImportExpression path must be a StringLiteral")
+		}
+		return expression
+	case "PrefixExpression":
+		return &ast.PrefixExpression{Token: d.token(m), Operator: d.string(m, "operator"), Right: d.expression(m, "right")}
+	case "InfixExpression":
+		return &ast.InfixExpression{
+			Token:    d.token(m),
+			Left:     d.expression(m, "left"),
+			Operator: d.string(m, "operator"),
+			Right:    d.expression(m, "right"),
+		}
+	case "IfExpression":
+		return &ast.IfExpression{
+			Token:       d.token(m),
+			Condition:   d.expression(m, "condition"),
+			Consequence: d.block(m, "consequence"),
+			Alternative: d.block(m, "alternative"),
+		}
+	case "TryExpression":
+		return &ast.TryExpression{Token: d.token(m), Body: d.block(m, "body"), Param: d.identifier(m, "param"), Catch: d.block(m, "catch")}
+	case "SpawnExpression":
+		return &ast.SpawnExpression{Token: d.token(m), Function: d.expression(m, "function")}
+	case "FunctionLiteral":
+		lit := &ast.FunctionLiteral{Token: d.token(m), Defaults: d.expressions(m, "defaults"), Rest: d.identifier(m, "rest")}
+		var params []json.RawMessage
+		d.value(m, "parameters", &params)
+		for _, raw := range params {
+			lit.Parameters = append(lit.Parameters, d.identifierNode(raw))
+		}
+		lit.Body = d.block(m, "body")
+		return lit
+	case "CallExpression":
+		return &ast.CallExpression{
+			Token:     d.token(m),
+			Function:  d.expression(m, "function"),
+			Arguments: d.expressions(m, "arguments"),
+			Rparen:    d.position(m, "rparen"),
+		}
+	case "IndexExpression":
+		exp := &ast.IndexExpression{Token: d.token(m), Left: d.expression(m, "left"), Index: d.expression(m, "index"), Rbracket: d.position(m, "rbracket")}
+		d.value(m, "optional", &exp.Optional)
+		return exp
+	case "DotExpression":
+		return &ast.DotExpression{Token: d.token(m), Left: d.expression(m, "left"), Name: d.identifier(m, "name")}
+	case "SliceExpression":
+		exp := &ast.SliceExpression{
+			Token:    d.token(m),
+			Left:     d.expression(m, "left"),
+			Low:      d.expression(m, "low"),
+			High:     d.expression(m, "high"),
+			Rbracket: d.position(m, "rbracket"),
+		}
+		d.value(m, "optional", &exp.Optional)
+		return exp
+	case "HashLiteral":
+		hash := &ast.HashLiteral{Token: d.token(m), Pairs: make(map[ast.Expression]ast.Expression), Rbrace: d.position(m, "rbrace")}
+		var pairs [][2]json.RawMessage
+		d.value(m, "pairs", &pairs)
+		for _, pair := range pairs {
+			key, value := d.expressionNode(pair[0]), d.expressionNode(pair[1])
+			hash.Keys = append(hash.Keys, key)
+			hash.Pairs[key] = value
+		}
+		return hash
+	}
+
+	d.fail("unknown node type %q", kind)
+	return nil
+}
+
+// value unmarshals member name of m into v. A missing member leaves v as it is.
+func (d *decoder) value(m map[string]json.RawMessage, name string, v any) {
+	raw, ok := m[name]
+	if d.err != nil || !ok {
+		return
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		d.fail("%s: %s", name, err)
+	}
+}
+
+func (d *decoder) string(m map[string]json.RawMessage, name string) string {
+	var s string
+	d.value(m, name, &s)
+	return s
+}
+
+func (d *decoder) token(m map[string]json.RawMessage) token.Token {
+	var tok jsonToken
+	d.value(m, "token", &tok)
+	return token.Token{
+		Type:    tok.Type,
+		Literal: tok.Literal,
+		Pos:     token.Position{Offset: tok.Offset, Line: tok.Line, Column: tok.Column},
+	}
+}
+
+func (d *decoder) position(m map[string]json.RawMessage, name string) token.Position {
+	var pos jsonPosition
+	d.value(m, name, &pos)
+	return token.Position{Offset: pos.Offset, Line: pos.Line, Column: pos.Column}
+}
+
+func (d *decoder) statements(m map[string]json.RawMessage, name string) []ast.Statement {
+	var raws []json.RawMessage
+	d.value(m, name, &raws)
+
+	var statements []ast.Statement
+	for _, raw := range raws {
+		stmt, ok := d.node(raw).(ast.Statement)
+		if !ok {
+			d.fail("%s must hold statements", name)
+			return nil
+		}
+		statements = append(statements, stmt)
+	}
+	return statements
+}
+
+func (d *decoder) expressions(m map[string]json.RawMessage, name string) []ast.Expression {
+	var raws []json.RawMessage
+	d.value(m, name, &raws)
+	if raws == nil {
+		return nil
+	}
+
+	expressions := make([]ast.Expression, len(raws))
+	for i, raw := range raws {
+		expressions[i] = d.expressionNode(raw)
+	}
+	return expressions
+}
+
+func (d *decoder) expression(m map[string]json.RawMessage, name string) ast.Expression {
+	return d.expressionNode(m[name])
+}
+
+// expressionNode decodes raw as an expression. null decodes to a nil Expression rather than a typed nil pointer.
+func (d *decoder) expressionNode(raw json.RawMessage) ast.Expression {
+	node := d.node(raw)
+	if node == nil {
+		return nil
+	}
+
+	exp, ok := node.(ast.Expression)
+	if !ok {
+		d.fail("%T is not an expression", node)
+	}
+	return exp
+}
+
+func (d *decoder) identifier(m map[string]json.RawMessage, name string) *ast.Identifier {
+	return d.identifierNode(m[name])
+}
+
+func (d *decoder) identifierNode(raw json.RawMessage) *ast.Identifier {
+	node := d.node(raw)
+	if node == nil {
+		return nil
+	}
+
+	ident, ok := node.(*ast.Identifier)
+	if !ok {
+		d.fail("expected an Identifier, got %T", node)
+	}
+	return ident
+}
+
+func (d *decoder) block(m map[string]json.RawMessage, name string) *ast.BlockStatement {
+	node := d.node(m[name])
+	if node == nil {
+		return nil
+	}
+
+	block, ok := node.(*ast.BlockStatement)
+	if !ok {
+		d.fail("%s must be a BlockStatement, got %T", name, node)
+	}
+	return block
+}
+
+func isNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(bytes.TrimSpace(raw)) == "null"
+}
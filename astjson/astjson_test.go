@@ -0,0 +1,108 @@
+package astjson
+
+import (
+	"github.com/sean-d/sloth/ast"
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/parser"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	expected := `{"type":"Program","statements":[{"type":"LetStatement",` +
+		`"token":{"type":"LET","literal":"let","line":1,"column":1},` +
+		`"name":{"type":"Identifier","token":{"type":"IDENT","literal":"x","line":1,"column":5,"offset":4},"value":"x"},` +
+		`"value":{"type":"InfixExpression","token":{"type":"+","literal":"+","line":1,"column":11,"offset":10},` +
+		`"left":{"type":"IntegerLiteral","token":{"type":"INT","literal":"1","line":1,"column":9,"offset":8},"value":1,"big":null},` +
+		`"operator":"+",` +
+		`"right":{"type":"Identifier","token":{"type":"IDENT","literal":"y","line":1,"column":13,"offset":12},"value":"y"}},` +
+		`"doc":""}]}`
+
+	data, err := Marshal(parse(t, "let x = 1 + y;"))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %s", err)
+	}
+
+	if string(data) != expected {
+		t.Errorf("wrong encoding.\nwant=%s\ngot= %s", expected, data)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []string{
+		"let x = 5; const y = -x; return x * (y + 2);",
+		`/// adds
+fn add(a, b = 1, ...rest) { a + b }
+add(1)[0:2]; add(2)?[1:]; [1, 2][-1]; {"a": 1, true: [2]}?["a"];`,
+		`if (x < 1) { "small" } else { "big" }; if (x) { 1 };`,
+		`let r = try { throw "no"; } catch (e) { e["message"] }; let c = spawn f(1) ?? 2;`,
+		"fn() { 99999999999999999999999 != !false }",
+		"map(xs, |x, y = 2| x * y);",
+		"f(1, ...xs); [0, ...f(...[1]), 2];",
+		"p.name; p.greet(1).x;",
+		`let m = import "lib.sloth"; m.f(); import "a.sloth" as a; from "b.sloth" import x, y;`,
+		"/// Doubles.\nexport fn double(x) { x * 2 } export let y = 1; export { double, y };",
+		"/// A point.\nclass Point { x; y = 0; fn norm() { self.x * self.y } } class Empty {}",
+	}
+
+	for _, input := range tests {
+		program := parse(t, input)
+
+		data, err := Marshal(program)
+		if err != nil {
+			t.Fatalf("Marshal(%q) returned error: %s", input, err)
+		}
+
+		node, err := Unmarshal(data)
+		if err != nil {
+			t.Fatalf("Unmarshal of %q returned error: %s", input, err)
+		}
+
+		decoded, ok := node.(*ast.Program)
+		if !ok {
+			t.Fatalf("Unmarshal of %q returned %T, want *ast.Program", input, node)
+		}
+		if decoded.String() != program.String() {
+			t.Errorf("decoded program wrong.\nwant=%q\ngot= %q", program.String(), decoded.String())
+		}
+
+		again, err := Marshal(decoded)
+		if err != nil {
+			t.Fatalf("Marshal of decoded %q returned error: %s", input, err)
+		}
+		if string(again) != string(data) {
+			t.Errorf("encoding of %q changed after a round trip.\nwant=%s\ngot= %s", input, data, again)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"type":"Nonsense"}`, `astjson: unknown node type "Nonsense"`},
+		{`{"type":"Program","statements":[{"type":"Identifier","value":"x"}]}`, "astjson: statements must hold statements"},
+		{`{"type":"IfExpression","consequence":{"type":"Identifier"}}`, "astjson: consequence must be a BlockStatement, got *ast.Identifier"},
+		{`{"type":"IntegerLiteral","big":"12x"}`, `astjson: could not parse "12x" as integer`},
+	}
+
+	for _, tt := range tests {
+		_, err := Unmarshal([]byte(tt.input))
+		if err == nil {
+			t.Errorf("expected an error for %s", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error for %s. want=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/sean-d/sloth/astjson"
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/parser"
+	"os"
+)
+
+// astCommand implements `sloth ast file...`. It prints the syntax tree of each file as indented JSON, in the encoding
+// described by the astjson package, one document per file.
+func astCommand(args []string) int {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: sloth ast file...")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	exitCode := 0
+	for _, path := range flags.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sloth: %s\n", err)
+			exitCode = 1
+			continue
+		}
+
+		p := parser.New(lexer.New(string(source)))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			printParseErrors(os.Stderr, path, string(source), p.ParseErrors())
+			exitCode = 1
+			continue
+		}
+
+		data, err := astjson.MarshalIndent(program, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			exitCode = 1
+			continue
+		}
+		os.Stdout.Write(append(data, '\n'))
+	}
+
+	return exitCode
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/sean-d/sloth/ast"
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/parser"
+	"github.com/sean-d/sloth/transpile"
+	"os"
+	"strings"
+)
+
+// targets are the languages `sloth build` can write a script out in, by the name --target takes.
+var targets = map[string]func(*ast.Program) ([]byte, error){
+	"go": transpile.Go,
+	"js": transpile.JavaScript,
+}
+
+// buildCommand implements `sloth build [--target=go|js] [-o out] file`. It writes the script at file out in the target
+// language, see the transpile package, to out or to stdout.
+func buildCommand(args []string) int {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	target := flags.String("target", "go", "the language to write the script in: `go or js`")
+	output := flags.String("o", "", "write the source to `file` instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: sloth build [--target=go|js] [-o out] file")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	compile, ok := targets[*target]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "sloth: unknown target %q, want go or js\n", *target)
+		return 2
+	}
+
+	path := flags.Arg(0)
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sloth: %s\n", err)
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParseErrors(os.Stderr, path, string(source), p.ParseErrors())
+		return 1
+	}
+
+	transpiled, err := compile(program)
+	if err != nil {
+		// every line is an error at a position in the script
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", path, line)
+		}
+		return 1
+	}
+
+	if *output == "" {
+		os.Stdout.Write(transpiled)
+		return 0
+	}
+	if err := os.WriteFile(*output, transpiled, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "sloth: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/parser"
+	"os"
+)
+
+// checkCommand implements `sloth check file...`. It parses the given files without running them, prints every syntax
+// error in them with its position, and exits with 1 when any file has errors or can't be read, which makes it cheap
+// enough to run from a pre-commit hook.
+func checkCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: sloth check file...")
+		return 2
+	}
+
+	exitCode := 0
+	for _, path := range args {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sloth: %s\n", err)
+			exitCode = 1
+			continue
+		}
+
+		p := parser.New(lexer.New(string(source)))
+		p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			printParseErrors(os.Stderr, path, string(source), p.ParseErrors())
+			exitCode = 1
+		}
+	}
+
+	return exitCode
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/sean-d/sloth/doc"
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/parser"
+	"os"
+)
+
+// docCommand implements `sloth doc [-html] file...`. It prints the documentation of the declarations in each file with
+// a /// doc comment, as Markdown by default or as an HTML page with -html.
+func docCommand(args []string) int {
+	flags := flag.NewFlagSet("doc", flag.ContinueOnError)
+	asHTML := flags.Bool("html", false, "print HTML instead of Markdown")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: sloth doc [-html] file...")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	exitCode := 0
+	for _, path := range flags.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sloth: %s\n", err)
+			exitCode = 1
+			continue
+		}
+
+		p := parser.New(lexer.New(string(source)))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			printParseErrors(os.Stderr, path, string(source), p.ParseErrors())
+			exitCode = 1
+			continue
+		}
+
+		entries := doc.Program(program)
+		if *asHTML {
+			fmt.Print(doc.HTML(path, entries))
+		} else {
+			fmt.Print(doc.Markdown(path, entries))
+		}
+	}
+
+	return exitCode
+}
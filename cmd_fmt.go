@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/sean-d/sloth/format"
+	"os"
+)
+
+// fmtCommand implements `sloth fmt [-w | -d] file...`. By default the formatted source of each file is printed to
+// stdout. -w rewrites files in place and -d prints a diff between each file and its formatted form instead.
+func fmtCommand(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write the formatted source back to each file")
+	diff := flags.Bool("d", false, "print a diff instead of the formatted source")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: sloth fmt [-w | -d] file...")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	exitCode := 0
+	for _, path := range flags.Args() {
+		if err := formatFile(path, *write, *diff); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			exitCode = 1
+		}
+	}
+
+	return exitCode
+}
+
+// formatFile formats the file at path and either rewrites it, prints a diff, or prints the result to stdout.
+// Files that are already formatted are left untouched when writing.
+func formatFile(path string, write bool, diff bool) error {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	formatted, err := format.Source(source)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case write:
+		if bytes.Equal(source, formatted) {
+			return nil
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(path, formatted, info.Mode().Perm())
+	case diff:
+		if !bytes.Equal(source, formatted) {
+			fmt.Print(unifiedDiff(path, string(source), string(formatted)))
+		}
+	default:
+		os.Stdout.Write(formatted)
+	}
+
+	return nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/sean-d/sloth/evaluator"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// lockFile is the file `sloth get` records the commit of every module it fetched in, in the directory it is run in.
+const lockFile = "sloth.lock"
+
+// lockEntry is a module in the lock file: the version it was asked for at, HEAD if none was, and the commit that was.
+type lockEntry struct {
+	version string
+	commit  string
+}
+
+/*
+getCommand implements `sloth get [module[@version]...]`. Each module is a repository path like github.com/user/lib,
+fetched with git over https into the vendor directory, where imports of it find it, at version, a tag, branch or
+commit, or the repository's default branch. The commit every module was fetched at is recorded in sloth.lock.
+
+Without modules, every module in sloth.lock is fetched again at the commit recorded for it, which puts the vendor
+directory back the way it was when the lock file was written.
+*/
+func getCommand(args []string) int {
+	flags := flag.NewFlagSet("get", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: sloth get [module[@version]...]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	locked, err := readLockFile(lockFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sloth: %s\n", err)
+		return 1
+	}
+
+	if flags.NArg() == 0 {
+		if len(locked) == 0 {
+			flags.Usage()
+			return 2
+		}
+
+		exitCode := 0
+		for _, path := range sortedModules(locked) {
+			if _, err := fetchModule(path, locked[path].commit); err != nil {
+				fmt.Fprintf(os.Stderr, "sloth: %s: %s\n", path, err)
+				exitCode = 1
+			}
+		}
+		return exitCode
+	}
+
+	exitCode, fetched := 0, false
+	for _, arg := range flags.Args() {
+		path, version, _ := strings.Cut(arg, "@")
+		if version == "" {
+			version = "HEAD"
+		}
+
+		commit, err := fetchModule(path, version)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sloth: %s: %s\n", arg, err)
+			exitCode = 1
+			continue
+		}
+		locked[path] = lockEntry{version: version, commit: commit}
+		fetched = true
+		fmt.Printf("%s %s %s\n", path, version, commit)
+	}
+
+	if !fetched {
+		return exitCode
+	}
+	if err := writeLockFile(lockFile, locked); err != nil {
+		fmt.Fprintf(os.Stderr, "sloth: %s\n", err)
+		return 1
+	}
+
+	return exitCode
+}
+
+// checkRef returns an error if ref, a version or a commit, is one git could take for an option.
+func checkRef(ref string) error {
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("malformed version %q", ref)
+	}
+
+	return nil
+}
+
+// checkModulePath returns an error if path isn't a repository path like github.com/user/lib, which has to start with a
+// host name and can't climb out of the vendor directory.
+func checkModulePath(path string) error {
+	host, _, _ := strings.Cut(path, "/")
+	if !strings.Contains(host, ".") || !strings.Contains(path, "/") {
+		return errors.New("module path must start with a host name, like github.com/user/lib")
+	}
+	for _, element := range strings.Split(path, "/") {
+		if element == "" || element == "." || element == ".." || strings.HasPrefix(element, "-") {
+			return fmt.Errorf("malformed module path %q", path)
+		}
+	}
+
+	return nil
+}
+
+/*
+fetchModule fetches the repository at https://path at ref into the vendor directory and returns the commit it got.
+Only that commit is fetched and its history is left behind, so what ends up in the vendor directory is just the files
+of the module, replacing whatever was there for it before.
+
+path and ref are checked before anything is done with them, wherever they came from: the lock file is no more to be
+trusted than the command line, and what is removed to make way for the module has to be inside the vendor directory.
+*/
+func fetchModule(path string, ref string) (string, error) {
+	if err := checkModulePath(path); err != nil {
+		return "", err
+	}
+	if err := checkRef(ref); err != nil {
+		return "", err
+	}
+	dest, err := vendorPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", err
+	}
+	// the temporary directory is next to where the module goes, so it can be renamed into place
+	tmp, err := os.MkdirTemp(filepath.Dir(dest), ".get-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	git := func(args ...string) (string, error) {
+		var stderr bytes.Buffer
+		cmd := exec.Command("git", append([]string{"-C", tmp}, args...)...)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return "", errors.New(msg)
+			}
+			return "", fmt.Errorf("git %s: %s", args[0], err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	if _, err := git("init", "--quiet"); err != nil {
+		return "", err
+	}
+	if _, err := git("fetch", "--quiet", "--depth", "1", "--", "https://"+path, ref); err != nil {
+		return "", err
+	}
+	if _, err := git("checkout", "--quiet", "FETCH_HEAD"); err != nil {
+		return "", err
+	}
+	commit, err := git("rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.RemoveAll(filepath.Join(tmp, ".git")); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp, 0755); err != nil {
+		return "", err
+	}
+	if err := os.RemoveAll(dest); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return "", err
+	}
+
+	return commit, nil
+}
+
+// vendorPath returns the directory in the vendor directory the module at path goes in, or an error if it wouldn't be
+// inside the vendor directory.
+func vendorPath(path string) (string, error) {
+	dest := filepath.Clean(filepath.Join(evaluator.VendorDir, filepath.FromSlash(path)))
+	rel, err := filepath.Rel(evaluator.VendorDir, dest)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) ||
+		filepath.IsAbs(rel) {
+		return "", fmt.Errorf("module path %q is outside of the vendor directory", path)
+	}
+
+	return dest, nil
+}
+
+// readLockFile reads the lock file at path, which holds a line of module path, version and commit for every module
+// fetched. A lock file that doesn't exist yet has no modules in it. Every line is checked the way the modules given to
+// `sloth get` are, so a corrupted lock file is an error rather than a path or a git option.
+func readLockFile(path string) (map[string]lockEntry, error) {
+	locked := map[string]lockEntry{}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return locked, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: want module, version and commit, got %q", path, line, scanner.Text())
+		}
+		if err := checkModulePath(fields[0]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, line, err)
+		}
+		for _, ref := range fields[1:] {
+			if err := checkRef(ref); err != nil {
+				return nil, fmt.Errorf("%s:%d: %s", path, line, err)
+			}
+		}
+		locked[fields[0]] = lockEntry{version: fields[1], commit: fields[2]}
+	}
+
+	return locked, nil
+}
+
+// writeLockFile writes locked to the lock file at path, sorted by module path so that it diffs well.
+func writeLockFile(path string, locked map[string]lockEntry) error {
+	var out strings.Builder
+	for _, module := range sortedModules(locked) {
+		fmt.Fprintf(&out, "%s %s %s\n", module, locked[module].version, locked[module].commit)
+	}
+
+	return os.WriteFile(path, []byte(out.String()), 0644)
+}
+
+func sortedModules(locked map[string]lockEntry) []string {
+	modules := make([]string, 0, len(locked))
+	for module := range locked {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+
+	return modules
+}
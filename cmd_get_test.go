@@ -0,0 +1,174 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckModulePath(t *testing.T) {
+	tests := []struct {
+		path string
+		ok   bool
+	}{
+		{"github.com/user/lib", true},
+		{"example.org/a/b/c", true},
+		{"github.com", false},
+		{"lib/user", false},
+		{"/etc/passwd", false},
+		{"github.com/../../etc", false},
+		{"github.com/user/..", false},
+		{"github.com/./lib", false},
+		{"github.com//lib", false},
+		{"github.com/-user/lib", false},
+	}
+
+	for _, tt := range tests {
+		err := checkModulePath(tt.path)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkModulePath(%q) = %v, want ok=%t", tt.path, err, tt.ok)
+		}
+	}
+}
+
+func TestCheckRef(t *testing.T) {
+	tests := []struct {
+		ref string
+		ok  bool
+	}{
+		{"HEAD", true},
+		{"v1.2.0", true},
+		{"0123456789abcdef0123456789abcdef01234567", true},
+		{"", false},
+		{"-", false},
+		{"--upload-pack=touch pwned", false},
+	}
+
+	for _, tt := range tests {
+		err := checkRef(tt.ref)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkRef(%q) = %v, want ok=%t", tt.ref, err, tt.ok)
+		}
+	}
+}
+
+func TestVendorPath(t *testing.T) {
+	dest, err := vendorPath("github.com/user/lib")
+	if err != nil {
+		t.Fatalf("vendorPath failed: %s", err)
+	}
+	if want := filepath.Join("vendor", "github.com", "user", "lib"); dest != want {
+		t.Errorf("vendorPath = %q, want %q", dest, want)
+	}
+
+	for _, path := range []string{"..", "../x", "a/../..", "a/../../b", ""} {
+		if dest, err := vendorPath(path); err == nil {
+			t.Errorf("vendorPath(%q) = %q, want an error", path, dest)
+		}
+	}
+}
+
+func TestReadLockFile(t *testing.T) {
+	tests := []struct {
+		contents string
+		err      string
+	}{
+		{"github.com/user/lib HEAD abc123\n\ngithub.com/user/other v1 def456\n", ""},
+		{"github.com/user/lib HEAD\n", "want module, version and commit"},
+		{"github.com/../../tmp/x HEAD abc123\n", `sloth.lock:1: malformed module path`},
+		{"github.com/user/lib HEAD abc123\n../outside HEAD abc123\n", "sloth.lock:2: malformed module path"},
+		{"github.com/user/lib --upload-pack=x abc123\n", `sloth.lock:1: malformed version "--upload-pack=x"`},
+		{"github.com/user/lib HEAD -abc\n", `sloth.lock:1: malformed version "-abc"`},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "sloth.lock")
+		if err := os.WriteFile(path, []byte(tt.contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		locked, err := readLockFile(path)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("readLockFile(%q) failed: %s", tt.contents, err)
+			} else if len(locked) != 2 || locked["github.com/user/other"] != (lockEntry{version: "v1", commit: "def456"}) {
+				t.Errorf("readLockFile(%q) = %v", tt.contents, locked)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("readLockFile(%q) error = %v, want one containing %q", tt.contents, err, tt.err)
+		}
+	}
+
+	locked, err := readLockFile(filepath.Join(t.TempDir(), "missing.lock"))
+	if err != nil || len(locked) != 0 {
+		t.Errorf("readLockFile of a missing file = %v, %v, want no modules", locked, err)
+	}
+}
+
+// TestFetchModuleChecks checks that fetchModule refuses what it is given before it runs git or touches the file system,
+// so it has to be run in a directory of its own.
+func TestFetchModuleChecks(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	tests := []struct {
+		path string
+		ref  string
+		err  string
+	}{
+		{"github.com/../../outside", "HEAD", "malformed module path"},
+		{"outside/lib", "HEAD", "module path must start"},
+		{"github.com/user/lib", "--upload-pack=touch pwned", "malformed version"},
+		{"github.com/user/lib", "", "malformed version"},
+	}
+
+	for _, tt := range tests {
+		_, err := fetchModule(tt.path, tt.ref)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("fetchModule(%q, %q) error = %v, want one containing %q", tt.path, tt.ref, err, tt.err)
+		}
+	}
+
+	if entries, _ := os.ReadDir("."); len(entries) != 0 {
+		t.Errorf("fetchModule left %d entries behind", len(entries))
+	}
+}
+
+func TestGetCommandHostileLockFile(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+
+	victim := filepath.Join(dir, "keep")
+	if err := os.Mkdir(victim, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(lockFile, []byte("github.com/../../keep HEAD abc123\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := getCommand(nil); code != 1 {
+		t.Errorf("getCommand returned %d, want 1", code)
+	}
+	if _, err := os.Stat(victim); err != nil {
+		t.Errorf("directory outside of vendor was touched: %s", err)
+	}
+	if _, err := os.Stat("vendor"); err == nil {
+		t.Errorf("vendor directory was created")
+	}
+}
+
+// chdir changes into dir for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+/*
+testCommand implements `sloth test file...`. Each file is a test: it is run as a script of its own and passes when it
+runs to completion. A failing assert or assert_eq, or any other error, fails it, and so does a non-zero exit code.
+Failures are reported on stderr as they happen, and the command exits with 1 if any test failed.
+
+The file is named cmd_tests.go rather than cmd_test.go, which go would take for a file of Go tests.
+*/
+func testCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: sloth test file...")
+		return 2
+	}
+
+	failed := 0
+	for _, path := range args {
+		if runFile(path, nil, os.Stderr) != 0 {
+			fmt.Printf("FAIL\t%s\n", path)
+			failed++
+			continue
+		}
+
+		fmt.Printf("ok\t%s\n", path)
+	}
+
+	if failed > 0 {
+		fmt.Printf("%d of %d tests failed\n", failed, len(args))
+		return 1
+	}
+
+	return 0
+}
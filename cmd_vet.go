@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/lint"
+	"github.com/sean-d/sloth/parser"
+	"os"
+)
+
+// vetCommand implements `sloth vet file...`. It reports every lint issue found in the given files and exits with 1
+// when any file has issues or doesn't parse.
+func vetCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: sloth vet file...")
+		return 2
+	}
+
+	exitCode := 0
+	for _, path := range args {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sloth: %s\n", err)
+			exitCode = 1
+			continue
+		}
+
+		p := parser.New(lexer.New(string(source)))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			printParseErrors(os.Stderr, path, string(source), p.ParseErrors())
+			exitCode = 1
+			continue
+		}
+
+		for _, issue := range lint.Program(program) {
+			fmt.Fprintf(os.Stderr, "%s:%s: %s\n", path, issue.Node.Pos(), issue)
+			exitCode = 1
+		}
+	}
+
+	return exitCode
+}
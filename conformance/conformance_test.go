@@ -0,0 +1,447 @@
+/*
+Package conformance checks that every way of running a sloth program agrees on what it does. Each case is run through
+the evaluator and through every transpile target, and each of them has to print the same output, fail with the same
+error and exit with the same code. A new backend only needs adding to backends to be held to the same cases.
+*/
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sean-d/sloth/ast"
+	"github.com/sean-d/sloth/evaluator"
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/object"
+	"github.com/sean-d/sloth/optimize"
+	"github.com/sean-d/sloth/parser"
+	"github.com/sean-d/sloth/transpile"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// result is what running a program did: what it printed, the error nothing caught, if any, and its exit code.
+type result struct {
+	output string
+	err    string
+	code   int
+}
+
+/*
+cases are the programs every backend runs. err is the message of the error that ends the program, as the "ERROR: ..."
+line every backend prints for it, and code defaults to 1 when there is one. except names the backends that are known to
+do something else for a case, with the reason, so the difference is written down rather than left out.
+*/
+var cases = []struct {
+	name   string
+	input  string
+	output string
+	err    string
+	code   int
+	except map[string]string
+}{
+	{name: "integers", input: "puts(1 + 2 * 3, (1 + 2) * 3, 7 / 2, -7 / 2, 10 - -5);", output: "7\n9\n3\n-3\n15\n"},
+	{
+		name:   "big integers",
+		input:  "puts(9223372036854775807 + 1, 99999999999999999999 * 99999999999999999999);",
+		output: "9223372036854775808\n9999999999999999999800000000000000000001\n",
+	},
+	{
+		name:   "comparisons",
+		input:  `puts(1 < 2, 2 > 3, 1 == 1, 1 != 1, "a" == "a", 1 == "1", [1, [2]] == [1, [2]], {"a": 1} == {"a": 1});`,
+		output: "true\nfalse\ntrue\nfalse\ntrue\nfalse\ntrue\ntrue\n",
+	},
+	{name: "truthiness", input: "puts(!true, !false, !5, !!0, !!\"\");", output: "false\ntrue\nfalse\ntrue\ntrue\n"},
+	{name: "strings", input: `puts("hello" + " " + "world", len("héllo"));`, output: "hello world\n5\n"},
+	{
+		name:   "arrays",
+		input:  "let a = [1, 2, 3]; puts(a, a[0], a[-1], a[3], len(a), first(a), last(a), rest(a), push(a, 4), a);",
+		output: "[1, 2, 3]\n1\n3\nnull\n3\n1\n3\n[2, 3]\n[1, 2, 3, 4]\n[1, 2, 3]\n",
+	},
+	{
+		name:   "empty arrays",
+		input:  "puts(first([]), last([]), rest([]), []);",
+		output: "null\nnull\nnull\n[]\n",
+	},
+	{
+		name:   "hashes keep their order",
+		input:  `let h = {"b": 2, "a": 1, 3: "three", true: [1]}; puts(h, h["a"], h[3], h[true], h["missing"]);`,
+		output: "{b: 2, a: 1, 3: three, true: [1]}\n1\nthree\n[1]\nnull\n",
+	},
+	{
+		name:   "null-safe indexing",
+		input:  `let h = {"a": {"b": 1}}; puts(h?["a"]?["b"], h["x"]?["b"], h["x"] ?? "default", h["a"]["b"] ?? 2);`,
+		output: "1\nnull\ndefault\n1\n",
+	},
+	{
+		name:   "if expressions",
+		input:  "let f = fn(x) { if (x > 0) { \"pos\" } else { if (x < 0) { \"neg\" } } }; puts(f(1), f(-1), f(0));",
+		output: "pos\nneg\nnull\n",
+	},
+	{
+		name:   "early return",
+		input:  "fn sign(x) { if (x < 0) { return -1; } if (x == 0) { return 0; } 1 } puts(sign(-5), sign(0), sign(5));",
+		output: "-1\n0\n1\n",
+	},
+	{
+		name:   "recursion",
+		input:  "let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; puts(fib(20));",
+		output: "6765\n",
+	},
+	{
+		name: "closures",
+		input: `
+let adder = fn(x) { fn(y) { x + y } };
+let add2 = adder(2);
+let curry = |a| |b| |c| a * b + c;
+puts(add2(3), adder(10)(5), curry(2)(3)(4));
+`,
+		output: "5\n15\n10\n",
+	},
+	{
+		name: "closures see later bindings",
+		input: `
+fn even(n) { if (n == 0) { true } else { odd(n - 1) } }
+fn odd(n) { if (n == 0) { false } else { even(n - 1) } }
+puts(even(10), odd(7));
+`,
+		output: "true\ntrue\n",
+	},
+	{
+		name:   "default and rest parameters",
+		input:  `fn f(a, b = a * 2, ...rest) { [a, b, rest] } puts(f(1), f(1, 5), f(1, 5, 6, 7));`,
+		output: "[1, 2, []]\n[1, 5, []]\n[1, 5, [6, 7]]\n",
+	},
+	{
+		name:   "spreading arrays",
+		input:  `let xs = [2, 3]; fn f(a, ...rest) { [a, rest] } puts(f(...xs), f(1, ...xs), [1, ...xs, ...[], 9]);`,
+		output: "[2, [3]]\n[1, [2, 3]]\n[1, 2, 3, 9]\n",
+	},
+	{
+		name:   "higher-order builtins",
+		input:  `puts(map([1, 2, 3], |x| x * x), filter([1, 2, 3], |x| x > 1), map("ab", |c| c + c), map({"k": 1}, |k| k));`,
+		output: "[1, 4, 9]\n[2, 3]\n[aa, bb]\n[k]\n",
+	},
+	{
+		name:   "characters",
+		input:  `puts(chars("hé"), ord("a"), chr(955), deep_equal([1, {"a": 2}], [1, {"a": 2}]));`,
+		output: "[h, é]\n97\nλ\ntrue\n",
+	},
+	{
+		name: "try and throw",
+		input: `
+puts(try { throw "boom"; } catch (e) { "caught " + e });
+puts(try { throw {"code": 7}; } catch (e) { e["code"] });
+puts(try { 1 / 0 } catch (e) { e["message"] });
+puts(try { 5 } catch (e) { 6 });
+`,
+		output: "caught boom\n7\ndivision by zero\n5\n",
+	},
+	{
+		name:   "a let in a catch block shadows",
+		input:  `let x = 1; let r = try { throw "boom" } catch (e) { let x = 2; x }; puts(x, r);`,
+		output: "1\n2\n",
+	},
+	{
+		name: "lets in try and catch blocks",
+		input: `
+let y = 1;
+let f = fn() { let y = 10; let r = try { let y = 20; throw y } catch (e) { let y = e + 1; y }; [y, r] };
+puts(f(), y);
+let z = try { let y = 3; y } catch (e) { 0 };
+puts(y, z);
+`,
+		output: "[20, 21]\n1\n3\n3\n",
+	},
+	{
+		name:   "format",
+		input:  `puts(format("%d%% of %s: %v", 50, "xs", [1, {"a": "b"}]));`,
+		output: "50% of xs: [1, {a: b}]\n",
+	},
+	{name: "printf", input: `printf("%d-%s", 1, "a"); printf("!"); puts("");`, output: "1-a!\n"},
+	{name: "format with a wrong value", input: `format("%d", "1");`, err: "value for %d in `format` must be INTEGER, got STRING"},
+	{name: "assertions pass", input: `assert(true); assert_eq([1], [1]); puts("ok");`, output: "ok\n"},
+	{name: "exit", input: `puts("before"); exit(3); puts("after");`, output: "before\n", code: 3},
+	{name: "exit without a code", input: `exit(); puts("after");`, code: 0},
+	{name: "exit isn't caught", input: `try { exit(4); } catch (e) { puts("caught"); }`, code: 4},
+	{name: "uncaught throw", input: `puts(1); throw "boom"; puts(2);`, output: "1\n", err: "boom"},
+	{name: "uncaught thrown value", input: `throw [1, 2];`, err: "[1, 2]"},
+	{name: "division by zero", input: "puts(1 / 0);", err: "division by zero"},
+	{name: "type mismatch", input: `1 + true;`, err: "type mismatch: INTEGER + BOOLEAN"},
+	{name: "unknown operator", input: `"a" - "b";`, err: "unknown operator: STRING - STRING"},
+	{name: "string comparison", input: `"a" < "b";`, err: "unknown operator: STRING < STRING"},
+	{name: "negating a string", input: `-"a";`, err: "unknown operator: -STRING"},
+	{name: "unary plus", input: `puts(+5, -+5, +99999999999999999999);`, output: "5\n-5\n99999999999999999999\n"},
+	{name: "unary plus on a string", input: `+"a";`, err: "unknown operator: +STRING"},
+	{name: "bad index", input: `[1][true];`, err: "index operator not supported: ARRAY"},
+	{name: "spreading a non-array", input: `puts(...1);`, err: "cannot spread INTEGER"},
+	{name: "bad hash key", input: `{{}: 2};`, err: "unusable as hash key: HASH"},
+	{name: "array holding a bad hash key", input: `{[1, {}]: 2};`, err: "unusable as hash key: ARRAY"},
+	{name: "array hash key", input: `let h = {[1, "a"]: 2}; puts(h[[1, "a"]], h[["a", 1]]);`, output: "2\nnull\n"},
+	{
+		name:   "pipe",
+		input:  "let add = fn(a, b) { a + b }; puts([1, 2, 3] |> len, 1 + 2 |> add(10), [1, 2] |> map(|n| n * 3) |> push(0));",
+		output: "3\n13\n[3, 6, 0]\n",
+	},
+	{
+		name:   "piping into a non-function",
+		input:  "1 |> 2;",
+		err:    "not a function: INTEGER",
+		except: map[string]string{"js": "calling a non-function fails with JavaScript's own TypeError"},
+	},
+	{
+		name:   "function composition",
+		input:  "let inc = fn(x) { x + 1 }; let double = fn(x) { x * 2 }; puts((inc >> double)(3), (double >> inc >> inc)(3));",
+		output: "8\n8\n",
+	},
+	{
+		name:   "compose",
+		input:  "let inc = fn(x) { x + 1 }; puts(compose(inc, len)([1, 2]), compose(len)(\"abc\"), compose(inc, inc, inc)(0));",
+		output: "3\n3\n3\n",
+	},
+	{name: "compose with a non-function", input: "compose(len, 1);", err: "arguments to `compose` must be FUNCTION, got INTEGER"},
+	{
+		name:   "partial",
+		input:  "let add = fn(a, b) { a + b }; let inc = partial(add, 1); puts(inc(2), partial(push, [1])(2), partial(add, 1, 2)());",
+		output: "3\n[1, 2]\n3\n",
+	},
+	{
+		name: "curry",
+		input: `let sum3 = fn(a, b, c) { a + b + c }; let add3 = curry(sum3);
+			puts(add3(1)(2)(3), add3(1, 2)(3), add3(1)(2, 3), curry(push, 2)([1])(2), curry(partial(sum3, 1))(2)(3));`,
+		output: "6\n6\n6\n[1, 2]\n6\n",
+	},
+	{
+		name:  "currying a builtin",
+		input: "curry(len);",
+		err:   "`curry` can't tell how many arguments a builtin takes, pass it as the second argument",
+	},
+	{name: "composing integers", input: `1 >> 2;`, err: "unknown operator: INTEGER >> INTEGER"},
+	{
+		name: "operator overloading",
+		input: `let v = {"n": 1, "__add": fn(o) { 100 }, "__eq": fn(o) { 1 }, "__lt": fn(o) { false },
+			"__index": fn(i) { i * 2 }};
+			puts(v + v, v == 2, v != 2, v < v, v[21], {"n": 1} == {"n": 1});`,
+		output: "100\ntrue\nfalse\nfalse\n42\ntrue\n",
+	},
+	{name: "builtin argument", input: `len(1);`, err: "argument to `len` not supported, got INTEGER"},
+	{name: "failed assertion", input: `assert_eq(1, 2);`, err: "assertion failed: 1 != 2"},
+	{
+		name:   "coroutines",
+		input:  `let co = coroutine(fn() { yield(1); yield(2); 3 }); puts(resume(co), resume(co), resume(co), status(co));`,
+		output: "1\n2\n3\ndead\n",
+		except: map[string]string{
+			"go": "coroutines can't be transpiled to Go",
+			"js": "coroutines can't be transpiled to JavaScript",
+		},
+	},
+	{
+		name: "yielding from a function a coroutine calls",
+		input: `
+fn count(n) { if (n < 3) { yield(n); count(n + 1) } else { "done" } }
+let co = coroutine(fn() { count(0) });
+puts(resume(co), resume(co), resume(co), resume(co));
+`,
+		output: "0\n1\n2\ndone\n",
+		except: map[string]string{
+			"go": "coroutines can't be transpiled to Go",
+			"js": "coroutines can't be transpiled to JavaScript",
+		},
+	},
+	{
+		name:   "missing arguments",
+		input:  `fn(a) { a }();`,
+		err:    "wrong number of arguments. got=0, want=1",
+		except: map[string]string{"js": "JavaScript calls don't check the number of arguments"},
+	},
+	{
+		name:   "calling a non-function",
+		input:  `5();`,
+		err:    "not a function: INTEGER",
+		except: map[string]string{"js": "calling a non-function fails with JavaScript's own TypeError"},
+	},
+}
+
+/*
+backends are the ways a program can be run. run takes every case's input and returns the result of each, in order, so a
+backend that has to build its programs can build them all at once.
+*/
+var backends = []struct {
+	name string
+	run  func(t *testing.T, inputs []string) []result
+}{
+	{"evaluator", evaluate},
+	{"go", runGo},
+	{"js", runJavaScript},
+}
+
+func TestConformance(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			// a case a backend is known to differ on isn't run on it at all, as it may not even build
+			var inputs []string
+			for _, tt := range cases {
+				if _, ok := tt.except[b.name]; !ok {
+					inputs = append(inputs, tt.input)
+				}
+			}
+			results := b.run(t, inputs)
+
+			i := 0
+			for _, tt := range cases {
+				if reason, ok := tt.except[b.name]; ok {
+					t.Logf("%s: skipped, %s", tt.name, reason)
+					continue
+				}
+				r := results[i]
+				i++
+
+				expected := result{output: tt.output, code: tt.code}
+				if tt.err != "" {
+					expected.err = "ERROR: " + tt.err
+					if expected.code == 0 {
+						expected.code = 1
+					}
+				}
+				if r != expected {
+					t.Errorf("%s: expected=%+v, got=%+v", tt.name, expected, r)
+				}
+			}
+		})
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+
+	return program
+}
+
+// evaluate runs each input the way the sloth command does, with its output written into the result rather than to
+// stdout.
+func evaluate(t *testing.T, inputs []string) []result {
+	var output strings.Builder
+	evaluator.SetOutput(&output)
+	defer evaluator.SetOutput(os.Stdout)
+
+	var results []result
+	for _, input := range inputs {
+		output.Reset()
+		var r result
+		switch evaluated := evaluator.Eval(optimize.Program(parse(t, input)), object.NewEnvironment()).(type) {
+		case *object.Error:
+			r.err, r.code = evaluated.Inspect(), 1
+		case *object.Exit:
+			r.code = int(evaluated.Code)
+		}
+		r.output = output.String()
+		results = append(results, r)
+	}
+
+	return results
+}
+
+// runGo transpiles each input to Go, builds them all with a single go build, and runs the binaries.
+func runGo(t *testing.T, inputs []string) []result {
+	if testing.Short() {
+		t.Skip("builds Go programs")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	// the programs have to be inside the module to import the runtime
+	dir, err := os.MkdirTemp(".", "run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var packages []string
+	for i, input := range inputs {
+		transpiled, err := transpile.Go(parse(t, input))
+		if err != nil {
+			t.Fatalf("Go(%q) failed: %s", input, err)
+		}
+
+		pkg := filepath.Join(dir, fmt.Sprintf("case%02d", i))
+		if err := os.Mkdir(pkg, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(pkg, "main.go"), transpiled, 0644); err != nil {
+			t.Fatal(err)
+		}
+		packages = append(packages, "./"+filepath.ToSlash(pkg))
+	}
+
+	bin := filepath.Join(dir, "bin")
+	build := exec.Command(goTool, append([]string{"build", "-o", bin + string(filepath.Separator)}, packages...)...)
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("transpiled programs don't build: %s\n%s", err, out)
+	}
+
+	var results []result
+	for _, pkg := range packages {
+		results = append(results, run(t, exec.Command(filepath.Join(bin, filepath.Base(pkg)))))
+	}
+
+	return results
+}
+
+// runJavaScript transpiles each input to JavaScript and runs it with node.
+func runJavaScript(t *testing.T, inputs []string) []result {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+
+	dir := t.TempDir()
+	var results []result
+	for i, input := range inputs {
+		transpiled, err := transpile.JavaScript(parse(t, input))
+		if err != nil {
+			t.Fatalf("JavaScript(%q) failed: %s", input, err)
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("case%02d.js", i))
+		if err := os.WriteFile(path, transpiled, 0644); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, run(t, exec.Command(node, path)))
+	}
+
+	return results
+}
+
+// run runs a built program, taking the error it ended with, if any, from the last line it wrote to stderr.
+func run(t *testing.T, cmd *exec.Cmd) result {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	r := result{}
+	if err := cmd.Run(); err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			t.Fatalf("running %s: %s", cmd.Path, err)
+		}
+		r.code = exitErr.ExitCode()
+	}
+
+	r.output = stdout.String()
+	if lines := strings.Split(strings.TrimSpace(stderr.String()), "\n"); lines[len(lines)-1] != "" {
+		r.err = lines[len(lines)-1]
+	}
+
+	return r
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// DIFF_CONTEXT is how many unchanged lines are shown around each change in a diff.
+const DIFF_CONTEXT = 3
+
+// diffLine is one line of an edit script: ' ' for a line both sides share, '-' for a removed and '+' for an added line.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+/*
+unifiedDiff returns a unified diff turning before into after, labelled with path. It computes the longest common
+subsequence of lines, which is quadratic but more than fast enough for the size of scripts sloth deals with, and then
+groups the changes into hunks with DIFF_CONTEXT lines of context around them.
+*/
+func unifiedDiff(path string, before string, after string) string {
+	a := strings.Split(strings.TrimSuffix(before, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(after, "\n"), "\n")
+	lines := editScript(a, b)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s (formatted)\n", path, path)
+
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+
+		// widen the hunk until there are more than 2*DIFF_CONTEXT unchanged lines between two changes
+		end := start
+		for i := start; i < len(lines); i++ {
+			if lines[i].op != ' ' {
+				end = i + 1
+			} else if i-end >= 2*DIFF_CONTEXT {
+				break
+			}
+		}
+
+		from := max(start-DIFF_CONTEXT, 0)
+		to := min(end+DIFF_CONTEXT, len(lines))
+		writeHunk(&out, lines, from, to)
+		start = to
+	}
+
+	return out.String()
+}
+
+// writeHunk writes lines[from:to] with a @@ header giving the line ranges it covers on each side.
+func writeHunk(out *bytes.Buffer, lines []diffLine, from int, to int) {
+	aStart, bStart := 1, 1
+	for _, l := range lines[:from] {
+		if l.op != '+' {
+			aStart++
+		}
+		if l.op != '-' {
+			bStart++
+		}
+	}
+
+	aLen, bLen := 0, 0
+	for _, l := range lines[from:to] {
+		if l.op != '+' {
+			aLen++
+		}
+		if l.op != '-' {
+			bLen++
+		}
+	}
+
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+	for _, l := range lines[from:to] {
+		out.WriteByte(l.op)
+		out.WriteString(l.text)
+		out.WriteByte('\n')
+	}
+}
+
+// editScript returns the lines of a and b merged into a single sequence of kept, removed, and added lines.
+func editScript(a []string, b []string) []diffLine {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := []diffLine{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, diffLine{'+', b[j]})
+	}
+
+	return lines
+}
@@ -0,0 +1,94 @@
+package doc
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sean-d/sloth/ast"
+	"html"
+	"strings"
+)
+
+// Entry documents a single top level declaration. Signature is how the declaration is used: name(params) for
+// functions, class name(fields) for classes, and the let or const binding otherwise.
+type Entry struct {
+	Name      string
+	Signature string
+	Doc       string
+}
+
+/*
+Program collects an Entry for every top level let, const, fn and class declaration in program that has a doc comment, in the
+order they are declared. Declarations without one are taken to be internal to the file and are left out.
+*/
+func Program(program *ast.Program) []Entry {
+	entries := []Entry{}
+
+	for _, s := range program.Statements {
+		if export, ok := s.(*ast.ExportStatement); ok && export.Declaration != nil {
+			s = export.Declaration
+		}
+		switch s := s.(type) {
+		case *ast.LetStatement:
+			if s.Doc == "" {
+				continue
+			}
+			entry := Entry{Name: s.Name.Value, Doc: s.Doc}
+			if fl, ok := s.Value.(*ast.FunctionLiteral); ok {
+				entry.Signature = signature(s.Name.Value, fl)
+			} else if s.IsConst() {
+				entry.Signature = "const " + s.Name.Value
+			} else {
+				entry.Signature = "let " + s.Name.Value
+			}
+			entries = append(entries, entry)
+		case *ast.FunctionStatement:
+			if s.Doc == "" {
+				continue
+			}
+			entries = append(entries, Entry{Name: s.Name.Value, Signature: signature(s.Name.Value, s.Function), Doc: s.Doc})
+		case *ast.ClassStatement:
+			if s.Doc == "" {
+				continue
+			}
+			entries = append(entries, Entry{Name: s.Name.Value, Signature: "class " + signature(s.Name.Value, s.Constructor()), Doc: s.Doc})
+		}
+	}
+
+	return entries
+}
+
+func signature(name string, fl *ast.FunctionLiteral) string {
+	return name + "(" + ast.ParameterList(fl.Parameters, fl.Defaults, fl.Rest) + ")"
+}
+
+// Markdown renders entries as a Markdown document titled title, one section per entry.
+func Markdown(title string, entries []Entry) string {
+	var out bytes.Buffer
+
+	fmt.Fprintf(&out, "# %s\n", title)
+	for _, e := range entries {
+		fmt.Fprintf(&out, "\n## `%s`\n\n%s\n", e.Signature, e.Doc)
+	}
+
+	return out.String()
+}
+
+// HTML renders entries as a standalone HTML page titled title. Blank lines in a doc comment separate paragraphs.
+func HTML(title string, entries []Entry) string {
+	var out bytes.Buffer
+
+	escaped := html.EscapeString(title)
+	fmt.Fprintf(&out, "<!DOCTYPE html>\n<html>\n<head>\n<title>%s</title>\n</head>\n<body>\n<h1>%s</h1>\n", escaped, escaped)
+	for _, e := range entries {
+		fmt.Fprintf(&out, "<h2 id=\"%s\"><code>%s</code></h2>\n", html.EscapeString(e.Name), html.EscapeString(e.Signature))
+		for _, paragraph := range strings.Split(e.Doc, "\n\n") {
+			if strings.TrimSpace(paragraph) == "" {
+				continue
+			}
+			fmt.Fprintf(&out, "<p>%s</p>\n", html.EscapeString(paragraph))
+		}
+	}
+	out.WriteString("</body>\n</html>\n")
+
+	return out.String()
+}
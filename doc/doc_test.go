@@ -0,0 +1,84 @@
+package doc
+
+import (
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/parser"
+	"testing"
+)
+
+const source = `
+/// Adds a and b.
+///
+/// Both <must> be integers.
+fn add(a, b = 1, ...rest) { a + b }
+
+/// Doubles x.
+let double = fn(x) { x * 2 };
+
+/// The answer.
+export const answer = 42;
+
+/// The greeting.
+let greeting = "hi";
+
+let undocumented = fn() { 1 };
+
+/// A point on a plane.
+export class Point {
+  x;
+  y = 0;
+}
+`
+
+func parse(t *testing.T) []Entry {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return Program(program)
+}
+
+func TestProgram(t *testing.T) {
+	expected := []Entry{
+		{"add", "add(a, b = 1, ...rest)", "Adds a and b.\n\nBoth <must> be integers."},
+		{"double", "double(x)", "Doubles x."},
+		{"answer", "const answer", "The answer."},
+		{"greeting", "let greeting", "The greeting."},
+		{"Point", "class Point(x, y = 0)", "A point on a plane."},
+	}
+
+	entries := parse(t)
+	if len(entries) != len(expected) {
+		t.Fatalf("wrong number of entries. expected=%d, got=%d (%v)", len(expected), len(entries), entries)
+	}
+
+	for i, e := range expected {
+		if entries[i] != e {
+			t.Errorf("entries[%d] wrong. expected=%+v, got=%+v", i, e, entries[i])
+		}
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	expected := "# lib.sloth\n\n## `add(a, b = 1, ...rest)`\n\nAdds a and b.\n\nBoth <must> be integers.\n\n" +
+		"## `double(x)`\n\nDoubles x.\n\n## `const answer`\n\nThe answer.\n\n## `let greeting`\n\nThe greeting.\n" +
+		"\n## `class Point(x, y = 0)`\n\nA point on a plane.\n"
+
+	got := Markdown("lib.sloth", parse(t))
+	if got != expected {
+		t.Errorf("Markdown wrong.\nexpected=%q\ngot=%q", expected, got)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	got := HTML("lib.sloth", parse(t)[:1])
+
+	expected := "<!DOCTYPE html>\n<html>\n<head>\n<title>lib.sloth</title>\n</head>\n<body>\n<h1>lib.sloth</h1>\n" +
+		"<h2 id=\"add\"><code>add(a, b = 1, ...rest)</code></h2>\n<p>Adds a and b.</p>\n" +
+		"<p>Both &lt;must&gt; be integers.</p>\n</body>\n</html>\n"
+	if got != expected {
+		t.Errorf("HTML wrong.\nexpected=%q\ngot=%q", expected, got)
+	}
+}
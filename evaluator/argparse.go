@@ -0,0 +1,249 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/sean-d/sloth/object"
+	"io"
+	"math/big"
+	"strings"
+)
+
+func init() {
+	builtins.Register("argparse", &object.Builtin{
+		Bind: func(registry *object.Builtins) object.BuiltinFunction {
+			return func(args ...object.Object) object.Object {
+				return argparse(registry.Output(), args...)
+			}
+		},
+	})
+}
+
+// argOption is an option argparse was told to look for.
+type argOption struct {
+	name     string
+	kind     object.ObjectType // INTEGER_OBJ, STRING_OBJ or BOOLEAN_OBJ
+	value    object.Object     // the default until the option is given
+	help     string
+	required bool
+}
+
+// argTypes maps the type names a spec can give an option to the kind of value it takes.
+var argTypes = map[string]object.ObjectType{
+	"int":    object.INTEGER_OBJ,
+	"string": object.STRING_OBJ,
+	"bool":   object.BOOLEAN_OBJ,
+}
+
+/*
+argparse parses a script's command line arguments against a spec, a hash from option names to either a default value,
+whose type is the type of the option, or a hash of the option's "type" (int, string or bool), "default", "help" and
+whether it is "required". It returns a hash of every option's value, in the order of the spec, and the arguments
+that aren't options under "args".
+
+An option is given as --name value or --name=value, and a bool option as just --name, or --name=false. -- ends the
+options, and anything after it is an argument even if it starts with --. --help or -h prints what options there are
+and ends the program, the way exit(0) does, having printed them to output.
+*/
+func argparse(output io.Writer, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	spec, ok := args[0].(*object.Hash)
+	if !ok {
+		return newError("argument to `argparse` must be HASH, got %s", args[0].Type())
+	}
+	argv, ok := args[1].(*object.Array)
+	if !ok {
+		return newError("argument to `argparse` must be ARRAY, got %s", args[1].Type())
+	}
+
+	options, err := argOptions(spec)
+	if err != nil {
+		return err
+	}
+	byName := map[string]*argOption{}
+	for _, opt := range options {
+		byName[opt.name] = opt
+	}
+
+	given := map[string]bool{}
+	positional := []object.Object{}
+	onlyPositional := false
+	for i := 0; i < len(argv.Elements); i++ {
+		arg, ok := argv.Elements[i].(*object.String)
+		if !ok {
+			return newError("arguments given to `argparse` must be STRING, got %s", argv.Elements[i].Type())
+		}
+
+		if onlyPositional || !strings.HasPrefix(arg.Value, "-") || arg.Value == "-" {
+			positional = append(positional, arg)
+			continue
+		}
+		if arg.Value == "--" {
+			onlyPositional = true
+			continue
+		}
+		if arg.Value == "-h" || arg.Value == "--help" {
+			io.WriteString(output, argUsage(options))
+			return &object.Exit{}
+		}
+		if !strings.HasPrefix(arg.Value, "--") {
+			return newError("argparse: unknown option %s", arg.Value)
+		}
+
+		name, value, hasValue := strings.Cut(arg.Value[2:], "=")
+		opt, ok := byName[name]
+		if !ok {
+			return newError("argparse: unknown option --%s", name)
+		}
+		if !hasValue && opt.kind != object.BOOLEAN_OBJ {
+			if i+1 == len(argv.Elements) {
+				return newError("argparse: --%s needs a value", name)
+			}
+			next, ok := argv.Elements[i+1].(*object.String)
+			if !ok {
+				return newError("arguments given to `argparse` must be STRING, got %s", argv.Elements[i+1].Type())
+			}
+			value = next.Value
+			i++
+		}
+
+		switch opt.kind {
+		case object.BOOLEAN_OBJ:
+			switch {
+			case !hasValue || value == "true":
+				opt.value = TRUE
+			case value == "false":
+				opt.value = FALSE
+			default:
+				return newError("argparse: --%s takes true or false, got %q", name, value)
+			}
+		case object.INTEGER_OBJ:
+			n, ok := new(big.Int).SetString(value, 10)
+			if !ok {
+				return newError("argparse: --%s takes an integer, got %q", name, value)
+			}
+			opt.value = object.NewInteger(n)
+		default:
+			opt.value = &object.String{Value: value}
+		}
+		given[name] = true
+	}
+
+	result := object.NewHash()
+	for _, opt := range options {
+		if opt.required && !given[opt.name] {
+			return newError("argparse: missing required option --%s", opt.name)
+		}
+		key := &object.String{Value: opt.name}
+		result.Set(key.HashKey(), object.HashPair{Key: key, Value: opt.value})
+	}
+	key := &object.String{Value: "args"}
+	result.Set(key.HashKey(), object.HashPair{Key: key, Value: &object.Array{Elements: positional}})
+
+	return result
+}
+
+// argOptions reads the options out of an argparse spec, in the order they were written.
+func argOptions(spec *object.Hash) ([]*argOption, *object.Error) {
+	var options []*argOption
+	for _, pair := range spec.Ordered() {
+		name, ok := pair.Key.(*object.String)
+		if !ok {
+			return nil, newError("option names given to `argparse` must be STRING, got %s", pair.Key.Type())
+		}
+		if name.Value == "args" || name.Value == "help" {
+			return nil, newError("argparse: option name %s is reserved", name.Value)
+		}
+
+		opt := &argOption{name: name.Value, value: NULL}
+		switch value := pair.Value.(type) {
+		case *object.Integer, *object.BigInteger, *object.String, *object.Boolean:
+			opt.value = value
+		case *object.Hash:
+			fields := map[string]object.Object{}
+			for _, field := range value.Ordered() {
+				fields[field.Key.Inspect()] = field.Value
+			}
+			if def, ok := fields["default"]; ok {
+				opt.value = def
+			}
+			if help, ok := fields["help"].(*object.String); ok {
+				opt.help = help.Value
+			}
+			opt.required = fields["required"] == TRUE
+			if typeName, ok := fields["type"]; ok {
+				kind, ok := argTypes[typeName.Inspect()]
+				if !ok {
+					return nil, newError("argparse: type of --%s must be int, string or bool, got %s",
+						name.Value, typeName.Inspect())
+				}
+				opt.kind = kind
+			}
+		default:
+			return nil, newError("argparse: --%s must be given a default or a HASH, got %s",
+				name.Value, pair.Value.Type())
+		}
+
+		valueKind := opt.value.Type()
+		if isInteger(opt.value) {
+			valueKind = object.INTEGER_OBJ
+		}
+		switch {
+		case opt.kind == "" && opt.value == NULL:
+			return nil, newError("argparse: --%s needs a type or a default", name.Value)
+		case opt.kind == "":
+			if valueKind != object.INTEGER_OBJ && valueKind != object.STRING_OBJ && valueKind != object.BOOLEAN_OBJ {
+				return nil, newError("argparse: default of --%s must be INTEGER, STRING or BOOLEAN, got %s",
+					name.Value, valueKind)
+			}
+			opt.kind = valueKind
+		case opt.value != NULL && valueKind != opt.kind:
+			return nil, newError("argparse: default of --%s must be %s, got %s", name.Value, opt.kind, valueKind)
+		}
+
+		options = append(options, opt)
+	}
+
+	return options, nil
+}
+
+// argUsage returns the help argparse prints for --help: a line for each option with its type, help and default.
+func argUsage(options []*argOption) string {
+	flags := []string{"--help"}
+	for _, opt := range options {
+		flag := "--" + opt.name
+		switch opt.kind {
+		case object.INTEGER_OBJ:
+			flag += " int"
+		case object.STRING_OBJ:
+			flag += " string"
+		}
+		flags = append(flags, flag)
+	}
+	width := 0
+	for _, flag := range flags {
+		width = max(width, len(flag))
+	}
+
+	helps := []string{"show this help"}
+	for _, opt := range options {
+		help := opt.help
+		switch {
+		case opt.required:
+			help += " (required)"
+		case opt.value != NULL && opt.value != FALSE && opt.value.Inspect() != "":
+			help += " (default " + opt.value.Inspect() + ")"
+		}
+		helps = append(helps, strings.TrimSpace(help))
+	}
+
+	var out strings.Builder
+	out.WriteString("options:\n")
+	for i, flag := range flags {
+		line := fmt.Sprintf("  %-*s  %s", width, flag, helps[i])
+		out.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+
+	return out.String()
+}
@@ -1,8 +1,15 @@
 package evaluator
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/sean-d/sloth/object"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"unicode/utf8"
 )
 
 /*
@@ -10,7 +17,7 @@ The most important part of this function is the call to Go’s len and the retur
 Besides that we have error checking that makes sure that we can’t call this function with the wrong number of arguments
 or with an argument of an unsupported type.
 */
-var builtins = map[string]*object.Builtin{
+var builtins = object.NewBuiltins(map[string]*object.Builtin{
 	"len": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
@@ -20,15 +27,107 @@ var builtins = map[string]*object.Builtin{
 
 			switch arg := args[0].(type) {
 			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
+				return object.IntegerOf(int64(len(arg.Elements)))
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return object.IntegerOf(int64(utf8.RuneCountInString(arg.Value)))
 			default:
 				return newError("argument to `len` not supported, got %s",
 					args[0].Type())
 			}
 		},
 	},
+	"byte_len": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != object.STRING_OBJ {
+				return newError("argument to `byte_len` must be STRING, got %s",
+					args[0].Type())
+			}
+
+			return object.IntegerOf(int64(len(args[0].(*object.String).Value)))
+		},
+	},
+	"bytes": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != object.STRING_OBJ {
+				return newError("argument to `bytes` must be STRING, got %s",
+					args[0].Type())
+			}
+
+			str := args[0].(*object.String).Value
+			elements := make([]object.Object, len(str))
+			for i := 0; i < len(str); i++ {
+				elements[i] = object.IntegerOf(int64(str[i]))
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	"chars": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != object.STRING_OBJ {
+				return newError("argument to `chars` must be STRING, got %s",
+					args[0].Type())
+			}
+
+			elements := []object.Object{}
+			for _, r := range args[0].(*object.String).Value {
+				elements = append(elements, &object.String{Value: string(r)})
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	"ord": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != object.STRING_OBJ {
+				return newError("argument to `ord` must be STRING, got %s",
+					args[0].Type())
+			}
+
+			str := args[0].(*object.String).Value
+			r, size := utf8.DecodeRuneInString(str)
+			if size == 0 || size != len(str) || (r == utf8.RuneError && size == 1) {
+				return newError("argument to `ord` must be a single character, got %q", str)
+			}
+
+			return object.IntegerOf(int64(r))
+		},
+	},
+	"chr": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != object.INTEGER_OBJ {
+				return newError("argument to `chr` must be INTEGER, got %s",
+					args[0].Type())
+			}
+
+			code := args[0].(*object.Integer).Value
+			if code < 0 || code > utf8.MaxRune || !utf8.ValidRune(rune(code)) {
+				return newError("%d is not a valid character code", code)
+			}
+
+			return &object.String{Value: string(rune(code))}
+		},
+	},
 	"first": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
@@ -102,6 +201,9 @@ var builtins = map[string]*object.Builtin{
 			}
 
 			arr := args[0].(*object.Array)
+			if arr.IsFrozen() {
+				return frozenError("push", arr)
+			}
 			length := len(arr.Elements)
 
 			newElements := make([]object.Object, length+1, length+1)
@@ -111,13 +213,1042 @@ var builtins = map[string]*object.Builtin{
 			return &object.Array{Elements: newElements}
 		},
 	},
-	"puts": &object.Builtin{
+	"set": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3",
+					len(args))
+			}
+
+			switch collection := args[0].(type) {
+			case *object.Array:
+				if collection.IsFrozen() {
+					return frozenError("set", collection)
+				}
+				index, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("index to `set` an ARRAY must be INTEGER, got %s", args[1].Type())
+				}
+				i := index.Value
+				if i < 0 {
+					i += int64(len(collection.Elements))
+				}
+				if i < 0 || i >= int64(len(collection.Elements)) {
+					return newError("index %d out of range for `set`, the array has %d elements",
+						index.Value, len(collection.Elements))
+				}
+
+				elements := make([]object.Object, len(collection.Elements))
+				copy(elements, collection.Elements)
+				elements[i] = args[2]
+				return &object.Array{Elements: elements}
+			case *object.Hash:
+				if collection.IsFrozen() {
+					return frozenError("set", collection)
+				}
+				key, ok := object.HashKeyOf(args[1])
+				if !ok {
+					return newError("unusable as hash key: %s", args[1].Type())
+				}
+
+				hash := cloneHash(collection)
+				hash.Set(key, object.HashPair{Key: args[1], Value: args[2]})
+				return hash
+			default:
+				return newError("argument to `set` must be ARRAY or HASH, got %s",
+					args[0].Type())
+			}
+		},
+	},
+	"delete": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("argument to `delete` must be HASH, got %s",
+					args[0].Type())
+			}
+			if hash.IsFrozen() {
+				return frozenError("delete", hash)
+			}
+			key, ok := object.HashKeyOf(args[1])
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+
+			deleted := cloneHash(hash)
+			deleted.Delete(key)
+			return deleted
+		},
+	},
+	"range": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want 1 to 3",
+					len(args))
+			}
+
+			bounds := make([]int64, len(args))
+			for i, arg := range args {
+				integer, ok := arg.(*object.Integer)
+				if !ok {
+					return newError("arguments to `range` must be INTEGER, got %s",
+						arg.Type())
+				}
+				bounds[i] = integer.Value
+			}
+
+			r := &object.Range{End: bounds[0], Step: 1}
+			if len(bounds) > 1 {
+				r.Start, r.End = bounds[0], bounds[1]
+			}
+			if len(bounds) > 2 {
+				r.Step = bounds[2]
+			}
+			if r.Step == 0 {
+				return newError("step of `range` must not be 0")
+			}
+
+			return r
+		},
+	},
+	"channel": &object.Builtin{
+		Fn: func(args ...object.Object) (result object.Object) {
+			if len(args) > 1 {
+				return newError("wrong number of arguments. got=%d, want 0 to 1",
+					len(args))
+			}
+
+			size := int64(0)
+			if len(args) == 1 {
+				integer, ok := args[0].(*object.Integer)
+				if !ok {
+					return newError("argument to `channel` must be INTEGER, got %s",
+						args[0].Type())
+				}
+				if integer.Value < 0 {
+					return newError("size of `channel` must not be negative, got %d",
+						integer.Value)
+				}
+				size = integer.Value
+			}
+
+			// a buffer larger than Go can allocate panics in make, in sloth it is just an error
+			defer func() {
+				if recover() != nil {
+					result = newError("size of `channel` is too large, got %d", size)
+				}
+			}()
+
+			return &object.Channel{Ch: make(chan object.Object, size)}
+		},
+	},
+	"send": &object.Builtin{
+		Fn: func(args ...object.Object) (result object.Object) {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			ch, ok := args[0].(*object.Channel)
+			if !ok {
+				return newError("argument to `send` must be CHANNEL, got %s",
+					args[0].Type())
+			}
+
+			// sending on a closed channel panics in Go, in sloth it is just an error
+			defer func() {
+				if recover() != nil {
+					result = newError("send on closed channel")
+				}
+			}()
+			ch.Ch <- args[1]
+
+			return NULL
+		},
+	},
+	"recv": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			ch, ok := args[0].(*object.Channel)
+			if !ok {
+				return newError("argument to `recv` must be CHANNEL, got %s",
+					args[0].Type())
+			}
+
+			val, ok := <-ch.Ch
+			if !ok {
+				return NULL
+			}
+
+			return val
+		},
+	},
+	"close": &object.Builtin{
+		Fn: func(args ...object.Object) (result object.Object) {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			ch, ok := args[0].(*object.Channel)
+			if !ok {
+				return newError("argument to `close` must be CHANNEL, got %s",
+					args[0].Type())
+			}
+
+			defer func() {
+				if recover() != nil {
+					result = newError("close of closed channel")
+				}
+			}()
+			close(ch.Ch)
+
+			return NULL
+		},
+	},
+	"mutex": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+
+			return object.NewMutex()
+		},
+	},
+	"lock": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			m, ok := args[0].(*object.Mutex)
+			if !ok {
+				return newError("argument to `lock` must be MUTEX, got %s",
+					args[0].Type())
+			}
+
+			m.Lock()
+
+			return NULL
+		},
+	},
+	"unlock": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			m, ok := args[0].(*object.Mutex)
+			if !ok {
+				return newError("argument to `unlock` must be MUTEX, got %s",
+					args[0].Type())
+			}
+
+			if !m.Unlock() {
+				return newError("unlock of unlocked mutex")
+			}
+
+			return NULL
+		},
+	},
+	"waitgroup": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+
+			return &object.WaitGroup{}
+		},
+	},
+	"add": &object.Builtin{
+		Fn: func(args ...object.Object) (result object.Object) {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			wg, ok := args[0].(*object.WaitGroup)
+			if !ok {
+				return newError("argument to `add` must be WAIT_GROUP, got %s",
+					args[0].Type())
+			}
+			delta, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("argument to `add` must be INTEGER, got %s",
+					args[1].Type())
+			}
+
+			// a negative counter panics in Go, in sloth it is just an error
+			defer func() {
+				if recover() != nil {
+					result = newError("negative waitgroup counter")
+				}
+			}()
+			wg.Add(int(delta.Value))
+
+			return NULL
+		},
+	},
+	"done": &object.Builtin{
+		Fn: func(args ...object.Object) (result object.Object) {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			wg, ok := args[0].(*object.WaitGroup)
+			if !ok {
+				return newError("argument to `done` must be WAIT_GROUP, got %s",
+					args[0].Type())
+			}
+
+			defer func() {
+				if recover() != nil {
+					result = newError("negative waitgroup counter")
+				}
+			}()
+			wg.Done()
+
+			return NULL
+		},
+	},
+	"wait": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			wg, ok := args[0].(*object.WaitGroup)
+			if !ok {
+				return newError("argument to `wait` must be WAIT_GROUP, got %s",
+					args[0].Type())
+			}
+
+			wg.Wait()
+
+			return NULL
+		},
+	},
+	"env_get": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			name, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `env_get` must be STRING, got %s",
+					args[0].Type())
+			}
+
+			val, ok := os.LookupEnv(name.Value)
+			if !ok {
+				return NULL
+			}
+
+			return &object.String{Value: val}
+		},
+	},
+	"env_set": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			name, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `env_set` must be STRING, got %s",
+					args[0].Type())
+			}
+			val, ok := args[1].(*object.String)
+			if !ok {
+				return newError("argument to `env_set` must be STRING, got %s",
+					args[1].Type())
+			}
+
+			if err := os.Setenv(name.Value, val.Value); err != nil {
+				return newError("env_set: %s", err)
+			}
+
+			return NULL
+		},
+	},
+	"env_all": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+
+			hash := object.NewHash()
+			for _, kv := range os.Environ() {
+				name, val, _ := strings.Cut(kv, "=")
+				key := &object.String{Value: name}
+				hash.Set(key.HashKey(), object.HashPair{Key: key, Value: &object.String{Value: val}})
+			}
+
+			return hash
+		},
+	},
+	"exec": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want 1 to 2",
+					len(args))
+			}
+			name, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `exec` must be STRING, got %s",
+					args[0].Type())
+			}
+
+			cmdArgs := []string{}
+			if len(args) == 2 {
+				arr, ok := args[1].(*object.Array)
+				if !ok {
+					return newError("argument to `exec` must be ARRAY, got %s",
+						args[1].Type())
+				}
+				for _, el := range arr.Elements {
+					str, ok := el.(*object.String)
+					if !ok {
+						return newError("arguments of `exec` must be STRING, got %s",
+							el.Type())
+					}
+					cmdArgs = append(cmdArgs, str.Value)
+				}
+			}
+
+			var stdout, stderr bytes.Buffer
+			cmd := exec.Command(name.Value, cmdArgs...)
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+
+			// a command that ran and failed is a result, only one that couldn't be run at all is an error
+			code := 0
+			if err := cmd.Run(); err != nil {
+				exitErr, ok := err.(*exec.ExitError)
+				if !ok {
+					return newError("exec: %s", err)
+				}
+				code = exitErr.ExitCode()
+			}
+
+			return newHash(map[string]object.Object{
+				"stdout": &object.String{Value: stdout.String()},
+				"stderr": &object.String{Value: stderr.String()},
+				"code":   object.IntegerOf(int64(code)),
+			})
+		},
+	},
+	"exit": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments. got=%d, want 0 to 1",
+					len(args))
+			}
+
+			if len(args) == 0 {
+				return &object.Exit{}
+			}
+
+			code, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument to `exit` must be INTEGER, got %s",
+					args[0].Type())
+			}
+
+			return &object.Exit{Code: code.Value}
+		},
+	},
+	"assert": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want 1 to 2",
+					len(args))
+			}
+
+			if isTruthy(args[0]) {
+				return NULL
+			}
+			if len(args) == 2 {
+				return newError("assertion failed: %s", args[1].Inspect())
+			}
+
+			return newError("assertion failed")
+		},
+	},
+	"assert_eq": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+
+			if !objectsEqual(args[0], args[1]) {
+				return newError("assertion failed: %s != %s", args[0].Inspect(), args[1].Inspect())
+			}
+
+			return NULL
+		},
+	},
+	"clone": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Array:
+				elements := make([]object.Object, len(arg.Elements))
+				copy(elements, arg.Elements)
+				return &object.Array{Elements: elements}
+			case *object.Hash:
+				return cloneHash(arg)
+			default:
+				return arg
+			}
+		},
+	},
+	"deep_clone": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
-			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			return deepClone(args[0], map[object.Object]object.Object{})
+		},
+	},
+	"freeze": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			freeze(args[0], map[object.Object]bool{})
+			return args[0]
+		},
+	},
+	"is_frozen": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Array:
+				return nativeBoolToBooleanObject(arg.IsFrozen())
+			case *object.Hash:
+				return nativeBoolToBooleanObject(arg.IsFrozen())
+			default:
+				// everything else can't be changed to begin with
+				return TRUE
+			}
+		},
+	},
+	"deep_equal": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+
+			return nativeBoolToBooleanObject(objectsEqual(args[0], args[1]))
+		},
+	},
+	"default_hash": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want 1 to 2",
+					len(args))
+			}
+
+			switch args[0].(type) {
+			case *object.Function, *object.Builtin:
+			default:
+				return newError("first argument to `default_hash` must be FUNCTION, got %s",
+					args[0].Type())
+			}
+
+			hash := object.NewHash()
+			hash.Default = args[0]
+
+			if len(args) == 2 {
+				pairs, ok := args[1].(*object.Hash)
+				if !ok {
+					return newError("second argument to `default_hash` must be HASH, got %s",
+						args[1].Type())
+				}
+				for _, pair := range pairs.Ordered() {
+					key, _ := object.HashKeyOf(pair.Key)
+					hash.Set(key, pair)
+				}
+			}
+
+			return hash
+		},
+	},
+	"implements": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+
+			missing, err := missingMethods("implements", args[0], args[1])
+			if err != nil {
+				return err
+			}
+
+			return nativeBoolToBooleanObject(len(missing) == 0)
+		},
+	},
+	"assert_implements": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+
+			missing, err := missingMethods("assert_implements", args[0], args[1])
+			if err != nil {
+				return err
+			}
+			if len(missing) > 0 {
+				return newError("assertion failed: %s is missing %s", args[0].Type(), strings.Join(missing, ", "))
 			}
 
 			return NULL
 		},
 	},
+	"format": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+			}
+
+			return formatValues("format", args[0], args[1:])
+		},
+	},
+	"puts": &object.Builtin{
+		Bind: func(registry *object.Builtins) object.BuiltinFunction {
+			return func(args ...object.Object) object.Object {
+				output := registry.Output()
+				for _, arg := range args {
+					fmt.Fprintln(output, arg.Inspect())
+				}
+
+				return NULL
+			}
+		},
+	},
+	"printf": &object.Builtin{
+		Bind: func(registry *object.Builtins) object.BuiltinFunction {
+			return func(args ...object.Object) object.Object {
+				if len(args) < 1 {
+					return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+				}
+
+				formatted := formatValues("printf", args[0], args[1:])
+				if isError(formatted) {
+					return formatted
+				}
+				io.WriteString(registry.Output(), formatted.Inspect())
+
+				return NULL
+			}
+		},
+	},
+	"input": &object.Builtin{
+		Bind: func(registry *object.Builtins) object.BuiltinFunction {
+			return func(args ...object.Object) object.Object {
+				if len(args) > 1 {
+					return newError("wrong number of arguments. got=%d, want 0 to 1", len(args))
+				}
+				if len(args) == 1 {
+					prompt, ok := args[0].(*object.String)
+					if !ok {
+						return newError("argument to `input` must be STRING, got %s", args[0].Type())
+					}
+					io.WriteString(registry.Output(), prompt.Value)
+				}
+
+				line, err := registry.Input().ReadString('\n')
+				if err != nil && err != io.EOF {
+					return newError("could not read input: %s", err)
+				}
+				if err == io.EOF && line == "" {
+					return NULL
+				}
+
+				return &object.String{Value: strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")}
+			}
+		},
+	},
+})
+
+/*
+map and filter call back into sloth functions through applyFunction, which itself looks builtins up, so they are added
+to builtins once it has been initialized rather than in its literal.
+*/
+func init() {
+	builtins.Register("map", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			iterable, ok := args[0].(object.Iterable)
+			if !ok {
+				return newError("argument to `map` must be iterable, got %s",
+					args[0].Type())
+			}
+
+			elements := []object.Object{}
+			it := iterable.Iterator()
+			for element, ok := it.Next(); ok; element, ok = it.Next() {
+				mapped := applyFunction(args[1], []object.Object{element})
+				if isError(mapped) {
+					return mapped
+				}
+				elements = append(elements, mapped)
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	})
+
+	builtins.Register("filter", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			iterable, ok := args[0].(object.Iterable)
+			if !ok {
+				return newError("argument to `filter` must be iterable, got %s",
+					args[0].Type())
+			}
+
+			elements := []object.Object{}
+			it := iterable.Iterator()
+			for element, ok := it.Next(); ok; element, ok = it.Next() {
+				keep := applyFunction(args[1], []object.Object{element})
+				if isError(keep) {
+					return keep
+				}
+				if isTruthy(keep) {
+					elements = append(elements, element)
+				}
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	})
+}
+
+/*
+RegisterBuiltin makes fn callable from sloth code as name, alongside len, puts and friends. It lets programs that
+embed sloth expose their own Go functions to scripts. Registering a name that already exists replaces that builtin.
+
+Builtins registered here are shared by every evaluation in the process that doesn't have a registry of its own, see
+NewBuiltins, so register them before evaluating anything; RegisterBuiltin is not safe to call while a program is being
+evaluated.
+*/
+func RegisterBuiltin(name string, fn object.BuiltinFunction) {
+	builtins.Register(name, &object.Builtin{Fn: fn})
+}
+
+/*
+SetOutput makes puts and printf write to w rather than standard output, for programs that embed sloth and want what a
+script prints for themselves. It applies to the builtins environments without a registry of their own use, and to
+registries made with NewBuiltins afterwards; a registry's own SetOutput sets it for just that registry. Like
+RegisterBuiltin, it is not safe to call while a program is being evaluated.
+*/
+func SetOutput(w io.Writer) {
+	builtins.SetOutput(w)
+}
+
+/*
+SetInput makes the input builtin read lines from r rather than standard input, for programs that embed sloth and feed
+a script its input themselves. Like SetOutput, it applies to the builtins environments without a registry of their own
+use, and is not safe to call while a program is being evaluated.
+*/
+func SetInput(r io.Reader) {
+	builtins.SetInput(r)
+}
+
+/*
+UnregisterBuiltin removes the builtin called name, so scripts calling it fail as if it never existed. Programs that run
+untrusted scripts use it to take away builtins like exec that reach outside the interpreter. Like RegisterBuiltin, it
+is not safe to call while a program is being evaluated.
+*/
+func UnregisterBuiltin(name string) {
+	builtins.Unregister(name)
+}
+
+/*
+formatValues fills in the verbs of the format string layout with values, for the builtin called name. %d takes an
+integer, %s a string, %v any value as puts would print it, and %% is a percent sign. Each verb takes the next value,
+and every value has to be taken.
+*/
+func formatValues(name string, layout object.Object, values []object.Object) object.Object {
+	str, ok := layout.(*object.String)
+	if !ok {
+		return newError("argument to `%s` must be STRING, got %s", name, layout.Type())
+	}
+
+	var out strings.Builder
+	used := 0
+	runes := []rune(str.Value)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '%' {
+			out.WriteRune(runes[i])
+			continue
+		}
+
+		i++
+		if i == len(runes) {
+			return newError("format string of `%s` ends with a lone %%", name)
+		}
+		verb := runes[i]
+		if verb == '%' {
+			out.WriteRune('%')
+			continue
+		}
+		if verb != 'd' && verb != 's' && verb != 'v' {
+			return newError("unknown verb %%%c in format string of `%s`", verb, name)
+		}
+		if used == len(values) {
+			return newError("missing value for %%%c in format string of `%s`", verb, name)
+		}
+
+		value := values[used]
+		used++
+		switch {
+		case verb == 'd' && !isInteger(value):
+			return newError("value for %%d in `%s` must be INTEGER, got %s", name, value.Type())
+		case verb == 's' && value.Type() != object.STRING_OBJ:
+			return newError("value for %%s in `%s` must be STRING, got %s", name, value.Type())
+		}
+		out.WriteString(value.Inspect())
+	}
+
+	if used < len(values) {
+		return newError("too many values for format string of `%s`. got=%d, want=%d", name, len(values), used)
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+/*
+objectsEqual reports whether a and b hold the same value, which is what ==, assert_eq and deep_equal compare. Arrays are
+equal element by element and hashes pair by pair, whatever order the pairs were added in; a hash's default function
+doesn't take part. Values that can't be taken apart, like functions and channels, are only equal to themselves.
+*/
+func objectsEqual(a, b object.Object) bool {
+	switch a := a.(type) {
+	case *object.Integer, *object.BigInteger:
+		if !isInteger(b) {
+			return false
+		}
+		return toBigInt(a).Cmp(toBigInt(b)) == 0
+	case *object.String:
+		b, ok := b.(*object.String)
+		return ok && a.Value == b.Value
+	case *object.Array:
+		b, ok := b.(*object.Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !objectsEqual(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		b, ok := b.(*object.Hash)
+		if !ok || len(a.Pairs) != len(b.Pairs) {
+			return false
+		}
+		for key, pair := range a.Pairs {
+			other, ok := b.Pairs[key]
+			if !ok || !objectsEqual(pair.Value, other.Value) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+/*
+deepClone copies obj and every array and hash inside it. copies maps every array and hash copied so far to its copy, so
+a value reached twice is copied once, and a structure that contains itself, which Go code can build, makes a copy that
+contains itself rather than one that never ends. Anything that isn't an array or hash is shared, not copied.
+*/
+func deepClone(obj object.Object, copies map[object.Object]object.Object) object.Object {
+	if done, ok := copies[obj]; ok {
+		return done
+	}
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		array := &object.Array{Elements: make([]object.Object, len(obj.Elements))}
+		copies[obj] = array
+		for i, element := range obj.Elements {
+			array.Elements[i] = deepClone(element, copies)
+		}
+		return array
+	case *object.Hash:
+		hash := object.NewHash()
+		hash.Default = obj.Default
+		copies[obj] = hash
+		for _, pair := range obj.Ordered() {
+			key, _ := object.HashKeyOf(pair.Key)
+			hash.Set(key, object.HashPair{Key: pair.Key, Value: deepClone(pair.Value, copies)})
+		}
+		return hash
+	default:
+		return obj
+	}
+}
+
+// cloneHash returns a shallow copy of hash, which isn't frozen even if hash is.
+func cloneHash(hash *object.Hash) *object.Hash {
+	clone := object.NewHash()
+	clone.Default = hash.Default
+	for _, pair := range hash.Ordered() {
+		key, _ := object.HashKeyOf(pair.Key)
+		clone.Set(key, pair)
+	}
+
+	return clone
+}
+
+// frozenError is what push, set and delete return for an array or hash that has been frozen.
+func frozenError(builtin string, frozen object.Object) *object.Error {
+	return newError("`%s` cannot change a frozen %s", builtin, frozen.Type())
+}
+
+// freeze freezes obj and every array and hash inside it. seen holds the ones visited so far, so a structure that
+// contains itself is only walked once.
+func freeze(obj object.Object, seen map[object.Object]bool) {
+	if seen[obj] {
+		return
+	}
+	seen[obj] = true
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		obj.Freeze()
+		for _, element := range obj.Elements {
+			freeze(element, seen)
+		}
+	case *object.Hash:
+		obj.Freeze()
+		for _, pair := range obj.Ordered() {
+			freeze(pair.Value, seen)
+		}
+	}
+}
+
+/*
+missingMethods returns the names in names, an array of strings, that obj can't be called with as obj.name(): the ones
+that aren't a key of obj or whose value isn't a function. Anything but a hash is missing every method. builtin is the
+name of the builtin asking, for the error about names not being an array of strings.
+*/
+func missingMethods(builtin string, obj, names object.Object) ([]string, *object.Error) {
+	list, ok := names.(*object.Array)
+	if !ok {
+		return nil, newError("second argument to `%s` must be ARRAY, got %s", builtin, names.Type())
+	}
+
+	hash, _ := obj.(*object.Hash)
+
+	missing := []string{}
+	for _, element := range list.Elements {
+		name, ok := element.(*object.String)
+		if !ok {
+			return nil, newError("method names given to `%s` must be STRING, got %s", builtin, element.Type())
+		}
+
+		if hash != nil {
+			if pair, ok := hash.Pairs[name.HashKey()]; ok {
+				switch pair.Value.(type) {
+				case *object.Function, *object.Builtin:
+					continue
+				}
+			}
+		}
+		missing = append(missing, name.Value)
+	}
+
+	return missing, nil
+}
+
+// stringArgument checks that the builtin called name was given a single string and returns it.
+func stringArgument(name string, args []object.Object) (string, *object.Error) {
+	if len(args) != 1 {
+		return "", newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return "", newError("argument to `%s` must be STRING, got %s", name, args[0].Type())
+	}
+
+	return str.Value, nil
+}
+
+// newHash builds a hash with string keys, the shape builtins use to return several named results at once. The keys are
+// added in sorted order, so the hash always prints the same way.
+func newHash(pairs map[string]object.Object) *object.Hash {
+	names := make([]string, 0, len(pairs))
+	for name := range pairs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := object.NewHash()
+	for _, name := range names {
+		key := &object.String{Value: name}
+		hash.Set(key.HashKey(), object.HashPair{Key: key, Value: pairs[name]})
+	}
+
+	return hash
+}
+
+// IsBuiltin reports whether name refers to a builtin function.
+func IsBuiltin(name string) bool {
+	_, ok := builtins.Get(name)
+	return ok
+}
+
+/*
+NewBuiltins returns a registry holding the builtins environments without one of their own use: those sloth comes with,
+as changed by RegisterBuiltin, UnregisterBuiltin, Deny, SetOutput and SetInput. Attached to an environment with
+SetBuiltins, it makes an interpreter of its own: it can be changed further, with DenyIn among others, for just the
+scripts evaluated there, and they import modules of their own.
+*/
+func NewBuiltins() *object.Builtins {
+	return builtins.Clone()
+}
+
+// builtinsOf returns the registry scripts evaluated in env get their builtins from.
+func builtinsOf(env *object.Environment) *object.Builtins {
+	if registry := env.Builtins(); registry != nil {
+		return registry
+	}
+	return builtins
 }
@@ -0,0 +1,103 @@
+package evaluator
+
+import (
+	"github.com/sean-d/sloth/object"
+)
+
+/*
+The coroutine builtins run a function a bit at a time: coroutine(fn) makes a coroutine of it, resume(co, val) runs it
+until it calls yield(val), and status(co) says whether it is suspended, running or dead. yield suspends the
+coroutine it is called in, wherever the function calling it was written, so a coroutine's function can hand the
+yielding off to helpers, a recursive generator calling itself for one. Called outside of a coroutine it is an error.
+
+The first resume calls the function, with val as its argument if there is one. Every later one hands val to the yield
+the coroutine is suspended in, which returns it. resume returns what the coroutine yielded or, once it is done, what
+its function returned; an error in the coroutine is an error in resume and leaves the coroutine dead.
+*/
+func init() {
+	builtins.Register("coroutine", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			switch args[0].(type) {
+			case *object.Function, *object.Builtin:
+				return object.NewCoroutine(args[0])
+			default:
+				return newError("argument to `coroutine` must be FUNCTION, got %s",
+					args[0].Type())
+			}
+		},
+	})
+
+	builtins.Register("resume", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want 1 to 2",
+					len(args))
+			}
+			co, ok := args[0].(*object.Coroutine)
+			if !ok {
+				return newError("argument to `resume` must be COROUTINE, got %s",
+					args[0].Type())
+			}
+
+			var val object.Object = NULL
+			if len(args) == 2 {
+				val = args[1]
+			}
+
+			result, err := co.Resume(val, func() object.Object {
+				return applyFunction(co.Fn, args[1:])
+			})
+			if err != nil {
+				return newError("%s", err)
+			}
+
+			return result
+		},
+	})
+
+	builtins.Register("yield", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments. got=%d, want 0 to 1",
+					len(args))
+			}
+			co := object.RunningCoroutine()
+			if co == nil {
+				return newError("yield outside of a coroutine")
+			}
+
+			var val object.Object = NULL
+			if len(args) == 1 {
+				val = args[0]
+			}
+
+			resumed, err := co.Yield(val)
+			if err != nil {
+				return newError("%s", err)
+			}
+
+			return resumed
+		},
+	})
+
+	builtins.Register("status", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			co, ok := args[0].(*object.Coroutine)
+			if !ok {
+				return newError("argument to `status` must be COROUTINE, got %s",
+					args[0].Type())
+			}
+
+			return &object.String{Value: co.Status()}
+		},
+	})
+}
@@ -0,0 +1,61 @@
+package evaluator
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/sean-d/sloth/object"
+	"hash"
+	"hash/crc32"
+)
+
+/*
+The digest builtins hash the bytes of a string and return the digest in lower case hexadecimal, the form checksums are
+usually published in. md5 and sha1 are there to check existing checksums, not to secure anything new.
+*/
+func init() {
+	digests := map[string]func() hash.Hash{
+		"sha256": sha256.New,
+		"sha1":   sha1.New,
+		"md5":    md5.New,
+		"crc32":  func() hash.Hash { return crc32.NewIEEE() },
+	}
+	for name, newHash := range digests {
+		builtins.Register(name, &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				s, err := stringArgument(name, args)
+				if err != nil {
+					return err
+				}
+
+				h := newHash()
+				h.Write([]byte(s))
+
+				return &object.String{Value: hex.EncodeToString(h.Sum(nil))}
+			},
+		})
+	}
+
+	builtins.Register("hmac_sha256", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			key, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `hmac_sha256` must be STRING, got %s", args[0].Type())
+			}
+			msg, ok := args[1].(*object.String)
+			if !ok {
+				return newError("argument to `hmac_sha256` must be STRING, got %s", args[1].Type())
+			}
+
+			mac := hmac.New(sha256.New, []byte(key.Value))
+			mac.Write([]byte(msg.Value))
+
+			return &object.String{Value: hex.EncodeToString(mac.Sum(nil))}
+		},
+	})
+}
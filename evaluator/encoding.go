@@ -0,0 +1,67 @@
+package evaluator
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/sean-d/sloth/object"
+)
+
+/*
+The encoding builtins turn the bytes of a string into base64 or hexadecimal text and back, for talking to web APIs and
+the like. Decoding doesn't check that the bytes it gets back are valid UTF-8, so a string can hold binary data.
+*/
+func init() {
+	builtins.Register("base64_encode", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			s, err := stringArgument("base64_encode", args)
+			if err != nil {
+				return err
+			}
+
+			return &object.String{Value: base64.StdEncoding.EncodeToString([]byte(s))}
+		},
+	})
+
+	builtins.Register("base64_decode", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			s, err := stringArgument("base64_decode", args)
+			if err != nil {
+				return err
+			}
+
+			decoded, decodeErr := base64.StdEncoding.DecodeString(s)
+			if decodeErr != nil {
+				return newError("base64_decode: %s", decodeErr)
+			}
+
+			return &object.String{Value: string(decoded)}
+		},
+	})
+
+	builtins.Register("hex_encode", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			s, err := stringArgument("hex_encode", args)
+			if err != nil {
+				return err
+			}
+
+			return &object.String{Value: hex.EncodeToString([]byte(s))}
+		},
+	})
+
+	builtins.Register("hex_decode", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			s, err := stringArgument("hex_decode", args)
+			if err != nil {
+				return err
+			}
+
+			decoded, decodeErr := hex.DecodeString(s)
+			if decodeErr != nil {
+				return newError("hex_decode: %s", decodeErr)
+			}
+
+			return &object.String{Value: string(decoded)}
+		},
+	})
+}
@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"github.com/sean-d/sloth/ast"
 	"github.com/sean-d/sloth/object"
+	"github.com/sean-d/sloth/token"
+	"math"
+	"math/big"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
 )
 
 /*
@@ -19,8 +22,31 @@ while evaluating a part of the AST. Each AST node needs a different form of eval
 decide what these forms look like. As an example, let’s say that we pass an *ast.Program node to Eval. What Eval should
 do then is to evaluate each of *ast.Program.Statements by calling itself with a single statement. The return value of
 the outer call to Eval is the return value of the last call.
+
+Hooks attached to env, see object.Hooks, are told about every node before and after it is evaluated.
 */
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	if env.Hooks() == nil {
+		return eval(node, env)
+	}
+
+	enterNode(node, env)
+	return exitNode(node, env, eval(node, env))
+}
+
+// eval is Eval without the hooks.
+func eval(node ast.Node, env *object.Environment) object.Object {
+	// the parser never leaves a node out, but trees built by hand or decoded from JSON can
+	if ast.IsNil(node) {
+		return newError("cannot evaluate a missing node")
+	}
+
+	if budget := env.Budget(); budget != nil {
+		if err := budget.Step(); err != nil {
+			return newError("%s", err)
+		}
+	}
+
 	switch node := node.(type) {
 
 	// Statements
@@ -40,19 +66,93 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return &object.ReturnValue{Value: val}
 
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return errorAt(node.Token, throwError(val))
+
 	case *ast.LetStatement:
+		if node.Name == nil {
+			return malformed(node.Token, "let statement", "name")
+		}
+		if env.IsConst(node.Name.Value) {
+			return errorAt(node.Name.Token, newError("cannot reassign constant %s", node.Name.Value))
+		}
 		val := Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if node.IsConst() {
+			env.SetConst(node.Name.Value, val)
+		} else {
+			env.Set(node.Name.Value, val)
+		}
+
+	case *ast.FunctionStatement:
+		if node.Name == nil {
+			return malformed(node.Token, "function statement", "name")
+		}
+		if env.IsConst(node.Name.Value) {
+			return errorAt(node.Name.Token, newError("cannot reassign constant %s", node.Name.Value))
+		}
+		// the function gets an environment of its own holding its name, so it can call itself through it even if the
+		// name is later rebound in env
+		fnEnv := object.NewEnclosedEnvironment(env)
+		fn := Eval(node.Function, fnEnv)
+		if isError(fn) {
+			return fn
+		}
+		fnEnv.Set(node.Name.Value, fn)
+		env.Set(node.Name.Value, fn)
+
+	case *ast.ClassStatement:
+		if err := checkClass(node); err != nil {
+			return err
+		}
+		if env.IsConst(node.Name.Value) {
+			return errorAt(node.Name.Token, newError("cannot reassign constant %s", node.Name.Value))
+		}
+		constructor := Eval(node.Constructor(), env)
+		if isError(constructor) {
+			return constructor
+		}
+		env.Set(node.Name.Value, constructor)
+
+	case *ast.ExportStatement:
+		if node.Declaration == nil && len(node.Names) == 0 {
+			return malformed(node.Token, "export statement", "declaration or names")
+		}
+		if node.Declaration != nil {
+			if declared := Eval(node.Declaration, env); isError(declared) {
+				return declared
+			}
+		}
+		for _, name := range node.Exported() {
+			if name == nil {
+				return malformed(node.Token, "export statement", "name")
+			}
+			env.Export(name.Value)
+		}
+
+	case *ast.ImportStatement:
+		if node.Path == nil || node.Alias == nil && len(node.Names) == 0 {
+			return malformed(node.Token, "import statement", "path or names")
+		}
+		if err := evalImportStatement(node, env); err != nil {
+			return err
+		}
 
 	// Expressions
 	case *ast.StringLiteral:
-		return &object.String{Value: node.Value}
+		return charge(env, object.InternString(node.Value))
 
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		if node.Big != nil {
+			return charge(env, &object.BigInteger{Value: node.Big})
+		}
+		return charge(env, object.IntegerOf(node.Value))
 
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
@@ -62,7 +162,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return errorAt(node.Token, charge(env, evalPrefixExpression(node.Operator, right)))
 
 	case *ast.InfixExpression:
 		left := Eval(node.Left, env)
@@ -70,26 +170,43 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return left
 		}
 
+		// the right side of ?? is only evaluated when it is needed
+		if node.Operator == "??" {
+			if left != NULL {
+				return left
+			}
+			return Eval(node.Right, env)
+		}
+
 		right := Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 
-		return evalInfixExpression(node.Operator, left, right)
+		return errorAt(node.Token, charge(env, evalInfixExpression(node.Operator, left, right)))
 
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
 
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+
+	case *ast.SpawnExpression:
+		return evalSpawnExpression(node, env)
+
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return errorAt(node.Token, evalIdentifier(node, env))
 
 	case *ast.FunctionLiteral:
+		if err := checkFunction(node); err != nil {
+			return err
+		}
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return charge(env, &object.Function{Parameters: params, Defaults: node.Defaults, Rest: node.Rest, Env: env, Body: body})
 
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
+		function := evalCallee(node.Function, env)
 		if isError(function) {
 			return function
 		}
@@ -99,28 +216,61 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		callHook(env, function, args)
+		// calls of sloth functions are charged for as they evaluate, but builtins allocate behind the budget's back
+		if _, ok := function.(*object.Builtin); ok {
+			return errorAt(node.Token, charge(env, applyFunction(function, args)))
+		}
+		return errorAt(node.Token, applyFunction(function, args))
 
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return charge(env, &object.Array{Elements: elements})
 
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return charge(env, evalHashLiteral(node, env))
+
+	case *ast.SliceExpression:
+		return errorAt(node.Token, charge(env, evalSliceExpression(node, env)))
 
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
+		if node.Optional && left == NULL {
+			return NULL
+		}
 		index := Eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
-		return evalIndexExpression(left, index)
+		return errorAt(node.Token, evalIndexExpression(left, index))
+
+	case *ast.DotExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		if node.Name == nil {
+			return malformed(node.Token, "dot expression", "name")
+		}
+		return errorAt(node.Token, evalDotExpression(left, node.Name.Value))
+
+	case *ast.ImportExpression:
+		if node.Path == nil {
+			return malformed(node.Token, "import", "path")
+		}
+		return errorAt(node.Token, importModule(node.Path.Value, env))
+
+	case *ast.SpreadExpression:
+		return errorAt(node.Token, newError("... can only spread the arguments of a call or the elements of an array"))
+
+	default:
+		return newError("cannot evaluate %T", node)
 	}
 
 	return nil
@@ -138,7 +288,7 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 		switch result := result.(type) {
 		case *object.ReturnValue:
 			return result.Value
-		case *object.Error:
+		case *object.Error, *object.Exit:
 			return result
 		}
 	}
@@ -162,7 +312,7 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.EXIT_OBJ {
 				return result
 			}
 		}
@@ -171,6 +321,101 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
+/*
+evalTryExpression evaluates the try block and, if that produces an error, the catch block instead. The catch block gets
+an environment of its own in which the catch parameter is bound to the error's value, so the parameter doesn't clobber
+a binding of the same name outside of it. A return inside the try block is not an error and passes
+straight through.
+*/
+func evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(te.Body, env)
+
+	err, ok := result.(*object.Error)
+	if !ok {
+		if result == nil {
+			return NULL
+		}
+		return result
+	}
+
+	if te.Param == nil {
+		return malformed(te.Token, "try expression", "catch parameter")
+	}
+
+	catchEnv := object.NewEnclosedEnvironment(env)
+	catchEnv.Set(te.Param.Value, errorValue(err))
+
+	result = Eval(te.Catch, catchEnv)
+	if result == nil {
+		return NULL
+	}
+
+	return result
+}
+
+/*
+evalSpawnExpression starts a call on a goroutine of its own and returns a channel that the call's result, or its
+error, is sent to when it finishes. Spawning a call expression works like Go's go statement: the function and its
+arguments are evaluated right away and only the call itself happens in the spawned task. Anything else is spawned as a
+function called without arguments.
+
+Every call gets a fresh environment enclosed by the one the function closes over, so bindings made by a spawned task
+never land in an environment another task binds into.
+*/
+func evalSpawnExpression(se *ast.SpawnExpression, env *object.Environment) object.Object {
+	var function object.Object
+	var args []object.Object
+
+	if call, ok := se.Function.(*ast.CallExpression); ok {
+		function = evalCallee(call.Function, env)
+		if isError(function) {
+			return function
+		}
+
+		args = evalExpressions(call.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+	} else {
+		function = Eval(se.Function, env)
+		if isError(function) {
+			return function
+		}
+	}
+
+	switch function.(type) {
+	case *object.Function, *object.Builtin:
+	default:
+		return newError("cannot spawn %s", function.Type())
+	}
+
+	result := &object.Channel{Ch: make(chan object.Object, 1)}
+	go func() {
+		result.Ch <- applyFunction(function, args)
+	}()
+
+	return result
+}
+
+// throwError turns a thrown value into an error that propagates like any other. A thrown string is the error's message.
+func throwError(val object.Object) *object.Error {
+	if str, ok := val.(*object.String); ok {
+		return &object.Error{Message: str.Value, Value: val}
+	}
+
+	return &object.Error{Message: val.Inspect(), Value: val}
+}
+
+// errorValue is what a catch block sees of an error: the thrown value as it was thrown, or for errors raised by the
+// evaluator a hash describing it, {"message": ...}.
+func errorValue(err *object.Error) object.Object {
+	if err.Value != nil {
+		return err.Value
+	}
+
+	return newHash(map[string]object.Object{"message": &object.String{Value: err.Message}})
+}
+
 // nativeBoolToBooleanObject returns a bool obj based on trutiness
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
@@ -186,6 +431,8 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
+	case "+":
+		return evalPlusPrefixOperatorExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
@@ -193,13 +440,23 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 
 // evalInfixExpression returns an Object of what is passed in for evaluation if the operand is supported.
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	if left.Type() == object.HASH_OBJ {
+		if result, ok := evalOverloadedInfix(operator, left, right); ok {
+			return result
+		}
+	}
+
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isInteger(left) && isInteger(right):
+		return evalBigIntegerInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(objectsEqual(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!objectsEqual(left, right))
+	case operator == ">>" && isCallable(left) && isCallable(right):
+		return composeFunctions([]object.Object{right, left})
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s",
 			left.Type(), operator, right.Type())
@@ -225,31 +482,67 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-// evalMinusPrefixOperatorExpression checks if the operand is an integer. If it isn’t, we return NULL. But if it is,
+// evalMinusPrefixOperatorExpression checks if the operand is an integer. If it isn’t, we return an error. But if it is,
 // we extract the value of the *object.Integer. Then we allocate a new object to wrap a negated version of this value.
+// Negating the smallest int64 overflows and so produces a BigInteger.
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return object.NewInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
+		return object.IntegerOf(-right.Value)
+	case *object.BigInteger:
+		return object.NewInteger(new(big.Int).Neg(right.Value))
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
+}
+
+// evalPlusPrefixOperatorExpression returns the operand of a unary + unchanged if it is an integer, big or not. Like -,
+// it is an error for anything else.
+func evalPlusPrefixOperatorExpression(right object.Object) object.Object {
+	if !isInteger(right) {
+		return newError("unknown operator: +%s", right.Type())
+	}
 
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+	return right
 }
 
-// evalIntegerInfixExpression adds, subtracts, multiplies, and divides the values wrapped by *object.Integers
+// evalIntegerInfixExpression adds, subtracts, multiplies, and divides the values wrapped by *object.Integers.
+// Arithmetic that overflows an int64 is redone with math/big and produces a BigInteger instead of wrapping around.
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
 
+	if isDivision(operator) && rightVal == 0 {
+		return newError("division by zero")
+	}
+
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		result := leftVal + rightVal
+		if (leftVal^result)&(rightVal^result) < 0 {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return object.IntegerOf(result)
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		result := leftVal - rightVal
+		if (leftVal^rightVal)&(leftVal^result) < 0 {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return object.IntegerOf(result)
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		result := leftVal * rightVal
+		if leftVal != 0 && (result/leftVal != rightVal || (leftVal == -1 && rightVal == math.MinInt64)) {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return object.IntegerOf(result)
 	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return object.IntegerOf(leftVal / rightVal)
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
@@ -264,6 +557,62 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
+// evalBigIntegerInfixExpression is evalIntegerInfixExpression for operands of which at least one is, or whose result
+// would be, outside the int64 range. Results that fit into an int64 again come back as plain Integers.
+func evalBigIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toBigInt(left)
+	rightVal := toBigInt(right)
+
+	if isDivision(operator) && rightVal.Sign() == 0 {
+		return newError("division by zero")
+	}
+
+	switch operator {
+	case "+":
+		return object.NewInteger(new(big.Int).Add(leftVal, rightVal))
+	case "-":
+		return object.NewInteger(new(big.Int).Sub(leftVal, rightVal))
+	case "*":
+		return object.NewInteger(new(big.Int).Mul(leftVal, rightVal))
+	case "/":
+		return object.NewInteger(new(big.Int).Quo(leftVal, rightVal))
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+// isDivision reports whether operator divides its left operand by its right one and so can't take a zero on the right.
+// That is true for modulo as much as for division.
+func isDivision(operator string) bool {
+	return operator == "/" || operator == "%"
+}
+
+// isInteger reports whether obj is an Integer or a BigInteger.
+func isInteger(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.BIG_INTEGER_OBJ
+}
+
+// toBigInt returns the value of an Integer or BigInteger as a *big.Int. The result must not be modified.
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInteger:
+		return obj.Value
+	default:
+		return nil
+	}
+}
+
 // evalIfExpression determines what should be evaluated.
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
@@ -288,7 +637,7 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := builtinsOf(env).Lookup(node.Value); ok {
 		return builtin
 	}
 
@@ -309,15 +658,91 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
+/*
+charge counts obj, freshly allocated by evaluation in env, against env's budget and checks that it isn't larger than
+the budget allows. It returns obj, or an error once a limit is exceeded. Errors and the singleton null and boolean
+objects are never charged for.
+*/
+func charge(env *object.Environment, obj object.Object) object.Object {
+	budget := env.Budget()
+	if budget == nil || obj == nil || obj == NULL || obj == TRUE || obj == FALSE || isError(obj) {
+		return obj
+	}
+
+	if err := budget.Allocate(1); err != nil {
+		return newError("%s", err)
+	}
+	if err := budget.CheckSize(obj); err != nil {
+		return newError("%s", err)
+	}
+
+	return obj
+}
+
+/*
+errorAt records tok's position on obj if it is an error that doesn't know where it happened yet, and returns obj.
+Errors are stamped on their way out of the node that raised them, so by the time an error has propagated to the top
+it carries the position of the innermost node it came from rather than that of the call that started it all.
+*/
+func errorAt(tok token.Token, obj object.Object) object.Object {
+	if err, ok := obj.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = tok.Pos
+	}
+
+	return obj
+}
+
+// malformed returns the error for a node, found at tok, that was built without a part it can't do without.
+func malformed(tok token.Token, node string, part string) *object.Error {
+	return errorAt(tok, newError("malformed %s: missing %s", node, part)).(*object.Error)
+}
+
+/*
+checkFunction returns an error if fl lacks a body or one of its parameters. It is checked when the function is created
+rather than each time it is called, so a malformed function fails where it is written.
+*/
+func checkFunction(fl *ast.FunctionLiteral) *object.Error {
+	if fl.Body == nil {
+		return malformed(fl.Token, "function literal", "body")
+	}
+	for _, param := range fl.Parameters {
+		if param == nil {
+			return malformed(fl.Token, "function literal", "parameter")
+		}
+	}
+
+	return nil
+}
+
+// checkClass returns an error if cs lacks its name or one of its fields or methods, which Constructor needs.
+func checkClass(cs *ast.ClassStatement) *object.Error {
+	if cs.Name == nil {
+		return malformed(cs.Token, "class statement", "name")
+	}
+	for _, field := range cs.Fields {
+		if field == nil {
+			return malformed(cs.Token, "class statement", "field")
+		}
+	}
+	for _, method := range cs.Methods {
+		if method == nil || method.Name == nil || method.Function == nil {
+			return malformed(cs.Token, "class statement", "method")
+		}
+	}
+
+	return nil
+}
+
 // newError is a useful helper to handle where NULL was otherwise used. It returns...erors
 func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
-// isError returns a bool representing if the supplied obj is an object error type
+// isError returns a bool representing if the supplied obj is an object error type, or an exit, which has to stop
+// evaluation and propagate in all the same places an error does.
 func isError(obj object.Object) bool {
 	if obj != nil {
-		return obj.Type() == object.ERROR_OBJ
+		return obj.Type() == object.ERROR_OBJ || obj.Type() == object.EXIT_OBJ
 	}
 	return false
 }
@@ -329,25 +754,67 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	var result []object.Object
 
 	for _, e := range exps {
-		evaluated := Eval(e, env)
+		spread, ok := e.(*ast.SpreadExpression)
+		if !ok || ast.IsNil(spread) {
+			evaluated := Eval(e, env)
+			if isError(evaluated) {
+				return []object.Object{evaluated}
+			}
+			result = append(result, evaluated)
+			continue
+		}
+
+		evaluated := Eval(spread.Value, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
-		result = append(result, evaluated)
+		array, ok := evaluated.(*object.Array)
+		if !ok {
+			return []object.Object{errorAt(spread.Token, newError("cannot spread %s", evaluated.Type()))}
+		}
+		result = append(result, array.Elements...)
 	}
 
 	return result
 }
 
 // applyFunction checks that we really have a *object.Function and converts the fn parameter to a *object.Function reference
-// in order to get access to the function’s .Env and .Body fields (which object.Object doesn’t define).
+// in order to get access to the function’s .Env and .Body fields (which object.Object doesn’t define). Arguments bound
+// to the function by partial or curry go in front of args, and a curried function still short of arguments is returned
+// with args bound rather than called.
 func applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		for {
+			if len(fn.Bound) > 0 {
+				args = append(append([]object.Object{}, fn.Bound...), args...)
+			}
+			if len(args) < fn.Curry {
+				curried := *fn
+				curried.Bound = args
+				return &curried
+			}
+
+			extendedEnv, err := extendFunctionEnv(fn, args)
+			if err != nil {
+				return err
+			}
+			evaluated := evalFunctionBody(fn.Body, extendedEnv, true)
+
+			call, ok := evaluated.(*tailCall)
+			if !ok {
+				return unwrapReturnValue(evaluated)
+			}
+
+			callHook(extendedEnv, call.fn, call.args)
+			// a tail call to another sloth function reuses this loop instead of nesting another applyFunction
+			next, ok := call.fn.(*object.Function)
+			if !ok {
+				return charge(extendedEnv, applyFunction(call.fn, call.args))
+			}
+			fn, args = next, call.args
+		}
 
 	case *object.Builtin:
 		return fn.Fn(args...)
@@ -357,6 +824,129 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	}
 }
 
+/*
+tailCall is what a call in tail position evaluates to inside a function body: the function and the already evaluated
+arguments, but not the result of calling it. It travels back up to applyFunction like a return value would and
+applyFunction then makes the call from its loop, so a function that recurses in tail position runs in constant Go
+stack space. A tailCall never escapes applyFunction.
+*/
+const TAIL_CALL_OBJ = "TAIL_CALL"
+
+type tailCall struct {
+	fn   object.Object
+	args []object.Object
+}
+
+func (tc *tailCall) Type() object.ObjectType { return TAIL_CALL_OBJ }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+/*
+evalFunctionBody evaluates a block that is (part of) a function body. It is evalBlockStatement with two differences:
+the value of a return statement is evaluated in tail position, and, when tail is true, so is the last statement of the
+block. Blocks of if expressions in tail position are evaluated in tail position too, which is how a call in either
+branch of the final if of a function becomes a tail call.
+
+Besides the usual values it can return a *object.ReturnValue, an *object.Error, or a *tailCall, all of which stop the
+evaluation of the enclosing blocks.
+*/
+func evalFunctionBody(block *ast.BlockStatement, env *object.Environment, tail bool) object.Object {
+	if block == nil {
+		return newError("cannot evaluate a missing block")
+	}
+
+	var result object.Object
+
+	for i, statement := range block.Statements {
+		last := i == len(block.Statements)-1
+
+		switch statement := statement.(type) {
+		case *ast.ReturnStatement:
+			enterNode(statement, env)
+			val := evalTailExpression(statement.ReturnValue, env)
+			if isError(val) || isTailCall(val) {
+				return exitNode(statement, env, val)
+			}
+			return exitNode(statement, env, &object.ReturnValue{Value: val})
+
+		case *ast.ExpressionStatement:
+			if last && tail {
+				enterNode(statement, env)
+				return exitNode(statement, env, evalTailExpression(statement.Expression, env))
+			}
+
+			if ie, ok := statement.Expression.(*ast.IfExpression); ok {
+				enterNode(statement, env)
+				enterNode(ie, env)
+				result = exitNode(statement, env, exitNode(ie, env, evalIfBranch(ie, env, false)))
+			} else {
+				result = Eval(statement, env)
+			}
+
+		default:
+			result = Eval(statement, env)
+		}
+
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.EXIT_OBJ || rt == TAIL_CALL_OBJ {
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+// evalTailExpression evaluates an expression whose value is the result of the function it appears in. Calls are not
+// made but handed back as a *tailCall; if expressions pass tail position on to their branches.
+func evalTailExpression(exp ast.Expression, env *object.Environment) object.Object {
+	switch exp := exp.(type) {
+	case *ast.CallExpression:
+		enterNode(exp, env)
+		function := evalCallee(exp.Function, env)
+		if isError(function) {
+			return exitNode(exp, env, function)
+		}
+
+		args := evalExpressions(exp.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return exitNode(exp, env, args[0])
+		}
+
+		return exitNode(exp, env, &tailCall{fn: function, args: args})
+
+	case *ast.IfExpression:
+		enterNode(exp, env)
+		return exitNode(exp, env, evalIfBranch(exp, env, true))
+
+	default:
+		return Eval(exp, env)
+	}
+}
+
+// evalIfBranch is evalIfExpression for if expressions inside function bodies: the chosen branch is evaluated with
+// evalFunctionBody so that returns, and with tail set the last statement, inside it can become tail calls.
+func evalIfBranch(ie *ast.IfExpression, env *object.Environment, tail bool) object.Object {
+	condition := Eval(ie.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return evalFunctionBody(ie.Consequence, env, tail)
+	} else if ie.Alternative != nil {
+		return evalFunctionBody(ie.Alternative, env, tail)
+	} else {
+		return NULL
+	}
+}
+
+// isTailCall reports whether obj is a call deferred to applyFunction.
+func isTailCall(obj object.Object) bool {
+	_, ok := obj.(*tailCall)
+	return ok
+}
+
 /*
 evalStringInfixExpression
 
@@ -382,23 +972,78 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
+		if result, ok := callOperatorMethod(left, "__index", index); ok {
+			return result
+		}
 		return evalHashIndexExpression(left, index)
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
 }
 
-// evalHashIndexExpression ensures that an object used as key is usable
+// evalDotExpression looks name up in left, which has to be a hash. hash.name is hash["name"], so a missing key is null.
+func evalDotExpression(left object.Object, name string) object.Object {
+	if left.Type() != object.HASH_OBJ {
+		return newError("dot operator not supported: %s", left.Type())
+	}
+
+	return evalHashIndexExpression(left, object.InternString(name))
+}
+
+/*
+evalCallee evaluates the function part of a call. For anything but a DotExpression that is just Eval, but hash.name()
+is a method call: a sloth function found under name is returned as a copy that has self bound to the hash, in an
+environment of its own enclosed by the one the function closes over. The function stored in the hash is left alone, so
+the same function stored in two hashes sees the right self in each of them.
+*/
+func evalCallee(exp ast.Expression, env *object.Environment) object.Object {
+	dot, ok := exp.(*ast.DotExpression)
+	if !ok {
+		return Eval(exp, env)
+	}
+
+	left := Eval(dot.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	if dot.Name == nil {
+		return malformed(dot.Token, "dot expression", "name")
+	}
+
+	value := errorAt(dot.Token, evalDotExpression(left, dot.Name.Value))
+	fn, ok := value.(*object.Function)
+	if !ok {
+		return value
+	}
+
+	return charge(env, bindSelf(fn, left))
+}
+
+// bindSelf returns a copy of fn with self bound to the hash it is a method of, see evalCallee.
+func bindSelf(fn *object.Function, self object.Object) *object.Function {
+	method := *fn
+	method.Env = object.NewEnclosedEnvironment(fn.Env)
+	method.Env.Set("self", self)
+
+	return &method
+}
+
+// evalHashIndexExpression ensures that an object used as key is usable. A key the hash doesn't have is null, or whatever
+// the hash's default function returns if it has one.
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
+	key, ok := object.HashKeyOf(index)
 	if !ok {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Pairs[key]
 	if !ok {
+		if hashObject.Default != nil {
+			return applyFunction(hashObject.Default, []object.Object{})
+		}
 		return NULL
 	}
 
@@ -409,14 +1054,18 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 evalArrayIndexExpression
 
 Here we actually retrieve the element with the specified index from the array. Besides the little type assertion and
-conversion dances this function is pretty straightforward: it checks if the given index is out of range and if that’s
-the case it returns NULL, otherwise the desired element.
+conversion dances this function is pretty straightforward: a negative index counts back from the end of the array, so
+-1 is the last element. If the index is still out of range it returns NULL, otherwise the desired element.
 */
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
 	max := int64(len(arrayObject.Elements) - 1)
 
+	if idx < 0 {
+		idx += max + 1
+	}
+
 	if idx < 0 || idx > max {
 		return NULL
 	}
@@ -424,30 +1073,95 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
+/*
+evalSliceExpression evaluates arr[start:end] into a new array holding the elements from start up to, but not including,
+end. A missing start means the beginning and a missing end the end of the array. Negative bounds count back from the
+end the same way negative indexes do. Bounds outside of the array are clamped to it, and a start past the end produces
+an empty array.
+*/
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if node.Optional && left == NULL {
+		return NULL
+	}
+
+	array, ok := left.(*object.Array)
+	if !ok {
+		return newError("slice operator not supported: %s", left.Type())
+	}
+
+	length := int64(len(array.Elements))
+	start, end := int64(0), length
+
+	if node.Low != nil {
+		bound := Eval(node.Low, env)
+		if isError(bound) {
+			return bound
+		}
+		integer, ok := bound.(*object.Integer)
+		if !ok {
+			return newError("slice bound must be INTEGER, got %s", bound.Type())
+		}
+		start = integer.Value
+	}
+
+	if node.High != nil {
+		bound := Eval(node.High, env)
+		if isError(bound) {
+			return bound
+		}
+		integer, ok := bound.(*object.Integer)
+		if !ok {
+			return newError("slice bound must be INTEGER, got %s", bound.Type())
+		}
+		end = integer.Value
+	}
+
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+
+	start = min(max(start, 0), length)
+	end = min(max(end, start), length)
+
+	elements := make([]object.Object, end-start)
+	copy(elements, array.Elements[start:end])
+
+	return &object.Array{Elements: elements}
+}
+
 /*
 evalHashLiteral
 
-When iterating over the node.Pairs the keyNode is the first to be evaluated. Besides checking if the call to Eval
-produced an error we also make a type assertion about the evaluation result: it needs to implement the
-object.Hashable interface, otherwise it’s unusable as a hash key. That’s exactly why we added the Hashable definition.
+The pairs are evaluated in the order they are written in, which is also the order the hash keeps them in. Of each
+pair the keyNode is the first to be evaluated. Besides checking if the call to Eval produced an error we also make a
+check on the evaluation result: object.HashKeyOf needs to find a HashKey for it, which it does for anything
+implementing the object.Hashable interface and for arrays of such things, otherwise it’s unusable as a hash key.
 
 Then we call Eval again, to evaluate valueNode. If that call to Eval also doesn’t produce an error, we can add the
-newly produced key-value pair to our pairs map. We do this by generating a HashKey for the aptly-named hashKey object
-with a call to HashKey(). Then we initialize a new HashPair, pointing to both key and value and add it to pairs.
+newly produced key-value pair to the hash, under the HashKey we got for the key. We initialize a new HashPair, pointing to both key and value and Set it on the hash.
 */
 func evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
+
+	for _, keyNode := range node.OrderedKeys() {
+		valueNode := node.Pairs[keyNode]
 
-	for keyNode, valueNode := range node.Pairs {
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.HashKeyOf(key)
 		if !ok {
 			return newError("unusable as hash key: %s", key.Type())
 		}
@@ -457,23 +1171,78 @@ func evalHashLiteral(
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, object.HashPair{Key: key, Value: value})
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
-// extendFunctionEnv creates a new *object.Environment that’s enclosed by the function’s environment.
-// In this new, enclosed environment it binds the arguments of the function call to the function’s parameter names.
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+/*
+extendFunctionEnv creates a new *object.Environment that’s enclosed by the function’s environment.
+In this new, enclosed environment it binds the arguments of the function call to the function’s parameter names.
+
+Parameters without an argument get their default value, evaluated in the new environment so a default can refer to the
+parameters before it. Arguments beyond the parameters are collected into an array bound to the rest parameter. Calls
+that leave a parameter without a value, or pass extra arguments to a function without a rest parameter, are errors.
+*/
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, object.Object) {
+	required := requiredParameters(fn)
+	if len(args) < required || (fn.Rest == nil && len(args) > len(fn.Parameters)) {
+		return nil, wrongNumberOfArguments(fn, required, len(args))
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
+	if err := env.Budget().Allocate(1); err != nil {
+		return nil, newError("%s", err)
+	}
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+
+		value := Eval(fn.Defaults[paramIdx], env)
+		if isError(value) {
+			return nil, value
+		}
+		env.Set(param.Value, value)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
 	}
 
-	return env
+	return env, nil
+}
+
+// requiredParameters returns how many arguments fn has to be called with: its parameters up to the last one without a
+// default value.
+func requiredParameters(fn *object.Function) int {
+	required := 0
+	for i := range fn.Parameters {
+		if i >= len(fn.Defaults) || fn.Defaults[i] == nil {
+			required = i + 1
+		}
+	}
+
+	return required
+}
+
+// wrongNumberOfArguments builds the error for a call to fn with got arguments, describing how many fn accepts.
+func wrongNumberOfArguments(fn *object.Function, required int, got int) *object.Error {
+	switch {
+	case fn.Rest != nil:
+		return newError("wrong number of arguments. got=%d, want at least %d", got, required)
+	case required == len(fn.Parameters):
+		return newError("wrong number of arguments. got=%d, want=%d", got, required)
+	default:
+		return newError("wrong number of arguments. got=%d, want %d to %d", got, required, len(fn.Parameters))
+	}
 }
 
 // unwrapReturnValue returns the return value if what is expected matches or the object itself otherwise
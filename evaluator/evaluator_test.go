@@ -1,9 +1,16 @@
 package evaluator
 
 import (
+	"errors"
+	"github.com/sean-d/sloth/ast"
 	"github.com/sean-d/sloth/lexer"
 	"github.com/sean-d/sloth/object"
 	"github.com/sean-d/sloth/parser"
+	"github.com/sean-d/sloth/token"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -16,6 +23,10 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"10", 10},
 		{"-5", -5},
 		{"-10", -10},
+		{"+5", 5},
+		{"+-5", -5},
+		{"-+5", -5},
+		{"5 - +5", 0},
 		{"5 + 5 + 5 + 5 - 10", 10},
 		{"2 * 2 * 2 * 2 * 2", 32},
 		{"-50 + 100 + -50", 0},
@@ -35,6 +46,43 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerPromotion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4611686018427387904 * 2", "9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"+99999999999999999999", "99999999999999999999"},
+		{"-+99999999999999999999", "-99999999999999999999"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"9223372036854775808 * 9223372036854775808", "85070591730234615865843651857942052864"},
+		{"99999999999999999999 - 99999999999999999998", "1"},
+		{"9223372036854775808 > 9223372036854775807", "true"},
+		{"9223372036854775807 + 1 == 9223372036854775808", "true"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+
+	// results that fit into an int64 again are plain integers
+	testIntegerObject(t, testEval("(9223372036854775807 + 10) - 20"), 9223372036854775797)
+
+	big, ok := testEval("9223372036854775807 + 1").(*object.BigInteger)
+	if !ok {
+		t.Fatalf("overflowing result is not BigInteger.")
+	}
+	if big.Type() != object.BIG_INTEGER_OBJ {
+		t.Errorf("wrong type. got=%s", big.Type())
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -59,6 +107,22 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{`"a" == "a"`, true},
+		{`"a" + "b" == "ab"`, true},
+		{`"a" != "a"`, false},
+		{`"a" == "b"`, false},
+		{"[1, 2] == [1, 2]", true},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, 2] != [1, 2, 3]", true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} != {"a": 1, "b": 2}`, true},
+		{"[] == {}", false},
+		{`1 == "1"`, false},
+		{"9223372036854775807 + 1 == 9223372036854775808", true},
+		{"let f = fn() { 1 }; f == f", true},
+		{"fn() { 1 } == fn() { 1 }", false},
 	}
 
 	for _, tt := range tests {
@@ -177,6 +241,14 @@ func TestErrorHandling(t *testing.T) {
 			"-true",
 			"unknown operator: -BOOLEAN",
 		},
+		{
+			"+true",
+			"unknown operator: +BOOLEAN",
+		},
+		{
+			`+"5"`,
+			"unknown operator: +STRING",
+		},
 		{
 			"true + false;",
 			"unknown operator: BOOLEAN + BOOLEAN",
@@ -217,6 +289,22 @@ if (10 > 1) {
 			`{"name": "sloth"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			`{[1, {}]: 1}`,
+			"unusable as hash key: ARRAY",
+		},
+		{
+			"5 / 0",
+			"division by zero",
+		},
+		{
+			"let f = fn(x) { 10 / (x - x) }; f(3)",
+			"division by zero",
+		},
+		{
+			"99999999999999999999 / 0",
+			"division by zero",
+		},
 	}
 
 	for _, tt := range tests {
@@ -236,6 +324,37 @@ if (10 > 1) {
 	}
 }
 
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedLine int
+		expectedCol  int
+	}{
+		{"5 + true;", 1, 3},
+		{"let x = 1;\n-true", 2, 1},
+		{"let f = fn(x) {\n  x + true\n};\nf(1)", 2, 5},
+		{"len(1, 2)", 1, 4},
+		{"let a = [1];\n  foobar", 2, 3},
+		{"[1, 2][\"a\"]", 1, 7},
+		{"const c = 1;\nconst c = 2;", 2, 7},
+		{"if (true) {\n  throw \"no\";\n}", 2, 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Pos.Line != tt.expectedLine || errObj.Pos.Column != tt.expectedCol {
+			t.Errorf("wrong position for %q. want=%d:%d, got=%s", tt.input, tt.expectedLine, tt.expectedCol, errObj.Pos)
+		}
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -252,6 +371,26 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const a = 5; a;", "5"},
+		{"const a = 5; let a = 6; a;", "ERROR: cannot reassign constant a"},
+		{"const a = 5; const a = 6; a;", "ERROR: cannot reassign constant a"},
+		{"const f = 5; fn f() { 1 }", "ERROR: cannot reassign constant f"},
+		{"const a = 5; let f = fn() { let a = 6; a }; f() + a;", "11"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 
@@ -288,6 +427,79 @@ func TestFunctionApplication(t *testing.T) {
 		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
 		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
 		{"fn(x) { x; }(5)", 5},
+		{"let double = |x| x * 2; double(5);", 10},
+		{"let add = |x, y| x + y; add(5, add(2, 3));", 10},
+		{"let adder = |x| |y| x + y; adder(3)(7);", 10},
+		{"let f = |a, b = 10| a + b; f(1);", 11},
+		{"(|| 5)()", 5},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, b = 10) { a + b }; f(1)", "11"},
+		{"let f = fn(a, b = 10) { a + b }; f(1, 2)", "3"},
+		{"let f = fn(a, b = a * 2) { a + b }; f(5)", "15"},
+		{"let f = fn(...rest) { rest }; f()", "[]"},
+		{"let f = fn(a, ...rest) { rest }; f(1, 2, 3)", "[2, 3]"},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, rest] }; f(1)", "[1, 2, []]"},
+		{"let f = fn(a, b) { a }; f(1)", "ERROR: wrong number of arguments. got=1, want=2"},
+		{"let f = fn(a) { a }; f(1, 2)", "ERROR: wrong number of arguments. got=2, want=1"},
+		{"let f = fn(a, b = 1) { a }; f()", "ERROR: wrong number of arguments. got=0, want 1 to 2"},
+		{"let f = fn(a, ...rest) { a }; f()", "ERROR: wrong number of arguments. got=0, want at least 1"},
+		{"let f = fn(a = nope) { a }; f()", "ERROR: identifier not found: nope"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestSpreadExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, b, c) { a + b * c }; f(...[1, 2, 3])", "7"},
+		{"let f = fn(a, b, c) { [a, b, c] }; f(1, ...[2], ...[], 3)", "[1, 2, 3]"},
+		{"let f = fn(a, ...rest) { rest }; let xs = [1, 2, 3]; f(...xs)", "[2, 3]"},
+		{"len(...[\"abc\"])", "3"},
+		{"let rest = [2, 3]; [1, ...rest, 9]", "[1, 2, 3, 9]"},
+		{"[...[], ...[[1]]]", "[[1]]"},
+		{"let xs = [1]; let ys = [...xs]; push(ys, 2); xs", "[1]"},
+		{"let f = fn(a) { a }; f(...[])", "ERROR: wrong number of arguments. got=0, want=1"},
+		{"[1, ...2]", "ERROR: cannot spread INTEGER"},
+		{"puts(...{})", "ERROR: cannot spread HASH"},
+		{"[...nope]", "ERROR: identifier not found: nope"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestFunctionStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"fn add(a, b) { a + b } add(2, 3)", 5},
+		{"fn fact(n) { if (n < 2) { return 1; } n * fact(n - 1) } fact(5)", 120},
+		{"fn fact(n) { if (n < 2) { return 1; } n * fact(n - 1) }; let f = fact; let fact = fn(n) { 0 }; f(5)", 120},
+		{"let outer = fn() { fn inner(x) { x * 2 } inner(21) }; outer()", 42},
 	}
 
 	for _, tt := range tests {
@@ -312,6 +524,46 @@ ourFunction(20) + first + second;`
 	testIntegerObject(t, testEval(input), 70)
 }
 
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{
+			"let count = fn(n) { if (n == 0) { 0 } else { count(n - 1) } }; count(1000000);",
+			0,
+		},
+		{
+			"let sum = fn(n, acc) { if (n == 0) { return acc; } sum(n - 1, acc + n) }; sum(100000, 0);",
+			5000050000,
+		},
+		{
+			`
+let isEven = fn(n) { if (n == 0) { return true; } return isOdd(n - 1); };
+let isOdd = fn(n) { if (n == 0) { return false; } return isEven(n - 1); };
+if (isEven(100001)) { 1 } else { 0 }
+`,
+			0,
+		},
+		{
+			"let f = fn(n) { if (n > 0) { return f(n - 1) + 1; } 0 }; f(10);",
+			10,
+		},
+		{
+			"let f = fn(n) { if (n > 2) { 10 } g(n) }; let g = fn(n) { n * 2 }; f(5);",
+			10,
+		},
+		{
+			"let twice = fn(x) { x * 2 }; let f = fn(x) { twice(x) }; f(len([1, 2, 3]));",
+			6,
+		},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -321,6 +573,12 @@ func testEval(input string) object.Object {
 	return Eval(program, env)
 }
 
+// getBuiltin returns the builtin called name from the registry environments without one of their own use.
+func getBuiltin(name string) *object.Builtin {
+	builtin, _ := builtins.Get(name)
+	return builtin
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result, ok := obj.(*object.Integer)
 	if !ok {
@@ -370,6 +628,12 @@ func TestStringLiteral(t *testing.T) {
 	if str.Value != "Hello World!" {
 		t.Errorf("String has wrong value. got=%q", str.Value)
 	}
+
+	// the same literal is the same string every time it is evaluated, so its hash key is only worked out once
+	again := testEval(`let h = {"Hello World!": 1}; "Hello World!"`)
+	if again != evaluated {
+		t.Errorf("string literal is not interned")
+	}
 }
 
 func TestStringConcatenation(t *testing.T) {
@@ -419,112 +683,1443 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
-func TestArrayLiterals(t *testing.T) {
-	input := "[1, 2 * 2, 3 + 3]"
+func TestTryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { 5 } catch (e) { 10 }`, 5},
+		{`try { 5 + true; 1 } catch (e) { 10 }`, 10},
+		{`try { 5 + true } catch (e) { e["message"] }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`try { missing } catch (e) { e["message"] }`, "identifier not found: missing"},
+		{`let f = fn() { 1 / 0 }; try { f() } catch (e) { e["message"] }`, "division by zero"},
+		{`let e = 1; try { 1 / 0 } catch (e) { 2 }; e`, 1},
+		{`let f = fn() { try { return 1; } catch (e) { 2 }; 3 }; f()`, 1},
+		{`try { 1 / 0 } catch (e) { 2 / 0 }`, errors.New("division by zero")},
+		{`try { 1 } catch (e) { 2 / 0 }`, 1},
+		{`try { } catch (e) { 1 }`, nil},
+		{`try { throw 42; } catch (e) { e }`, 42},
+		{`try { throw "oops"; 1 } catch (e) { e }`, "oops"},
+		{`try { throw {"code": 7}; } catch (e) { e["code"] }`, 7},
+		{`let f = fn(x) { if (x > 2) { throw "too big"; } x }; try { f(3) } catch (e) { e }`, "too big"},
+		{`try { throw missing; } catch (e) { e["message"] }`, "identifier not found: missing"},
+		{`throw "oops"; 1`, errors.New("oops")},
+		{`throw [1, 2];`, errors.New("[1, 2]")},
+		{`try { throw 1; } catch (e) { throw e + 1; }`, errors.New("2")},
+	}
 
-	evaluated := testEval(input)
-	result, ok := evaluated.(*object.Array)
-	if !ok {
-		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. expected=%q, got=%q", expected, str.Value)
+			}
+		case error:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected.Error() {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected.Error(), errObj.Message)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
 	}
+}
 
-	if len(result.Elements) != 3 {
-		t.Fatalf("array has wrong num of elements. got=%d",
-			len(result.Elements))
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len("héllo")`, "5"},
+		{`len("日本")`, "2"},
+		{`byte_len("héllo")`, "6"},
+		{`byte_len("")`, "0"},
+		{`bytes("hé")`, "[104, 195, 169]"},
+		{`bytes("")`, "[]"},
+		{`chars("hé日")`, "[h, é, 日]"},
+		{`chars("")`, "[]"},
+		{`ord("a")`, "97"},
+		{`ord("é")`, "233"},
+		{`chr(97)`, "a"},
+		{`chr(26085)`, "日"},
+		{`map(chars("abc"), ord)`, "[97, 98, 99]"},
+		{`chr(ord("a") + 1)`, "b"},
+		{`byte_len(1)`, "ERROR: argument to `byte_len` must be STRING, got INTEGER"},
+		{`bytes([])`, "ERROR: argument to `bytes` must be STRING, got ARRAY"},
+		{`chars(1)`, "ERROR: argument to `chars` must be STRING, got INTEGER"},
+		{`ord("ab")`, "ERROR: argument to `ord` must be a single character, got \"ab\""},
+		{`ord("")`, "ERROR: argument to `ord` must be a single character, got \"\""},
+		{`ord(97)`, "ERROR: argument to `ord` must be STRING, got INTEGER"},
+		{`chr(-1)`, "ERROR: -1 is not a valid character code"},
+		{`chr(55296)`, "ERROR: 55296 is not a valid character code"},
+		{`chr(1114112)`, "ERROR: 1114112 is not a valid character code"},
+		{`chr("a")`, "ERROR: argument to `chr` must be INTEGER, got STRING"},
+		{`format("x=%d y=%s", 1, "a")`, "x=1 y=a"},
+		{`format("%v and %v, 100%%", [1, "b"], {"k": true})`, "[1, b] and {k: true}, 100%"},
+		{`format("%d", 99999999999999999999)`, "99999999999999999999"},
+		{`format("héllo")`, "héllo"},
+		{`format()`, "ERROR: wrong number of arguments. got=0, want at least 1"},
+		{`format(1)`, "ERROR: argument to `format` must be STRING, got INTEGER"},
+		{`format("%d", "1")`, "ERROR: value for %d in `format` must be INTEGER, got STRING"},
+		{`format("%s", 1)`, "ERROR: value for %s in `format` must be STRING, got INTEGER"},
+		{`format("%d %d", 1)`, "ERROR: missing value for %d in format string of `format`"},
+		{`format("%d", 1, 2)`, "ERROR: too many values for format string of `format`. got=2, want=1"},
+		{`format("%x", 1)`, "ERROR: unknown verb %x in format string of `format`"},
+		{`format("50%")`, "ERROR: format string of `format` ends with a lone %"},
 	}
 
-	testIntegerObject(t, result.Elements[0], 1)
-	testIntegerObject(t, result.Elements[1], 4)
-	testIntegerObject(t, result.Elements[2], 6)
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
 }
 
-// TestArrayIndexExpressions tests for off-by-one errors when accessing and retrieving the elements in an array.
-func TestArrayIndexExpressions(t *testing.T) {
+func TestRegexBuiltins(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected interface{}
+		expected string
 	}{
-		{
-			"[1, 2, 3][0]",
-			1,
-		},
-		{
-			"[1, 2, 3][1]",
-			2,
-		},
-		{
-			"[1, 2, 3][2]",
-			3,
-		},
-		{
-			"let i = 0; [1][i];",
-			1,
-		},
-		{
-			"[1, 2, 3][1 + 1];",
-			3,
-		},
-		{
-			"let myArray = [1, 2, 3]; myArray[2];",
-			3,
-		},
-		{
-			"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];",
-			6,
-		},
-		{
-			"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]",
-			2,
-		},
-		{
-			"[1, 2, 3][3]",
-			nil,
-		},
-		{
-			"[1, 2, 3][-1]",
-			nil,
-		},
+		{`regex("a+b")`, `regex("a+b")`},
+		{`re_match(regex("(\w+)@(\w+)"), "mail sloth@zoo now")`, "[sloth@zoo, sloth, zoo]"},
+		{`re_match("(a)|(b)", "b")`, "[b, null, b]"},
+		{`re_match("x", "abc")`, "null"},
+		{`re_find_all("[0-9]+", "1 22 333")`, "[[1], [22], [333]]"},
+		{`re_find_all("(.)=(.)", "a=1,b=2")`, "[[a=1, a, 1], [b=2, b, 2]]"},
+		{`re_find_all("x", "abc")`, "[]"},
+		{`re_named("(?P<key>\w+)=(?P<value>\w*)(?P<rest>;)?", "k=v")`, "{key: k, value: v, rest: null}"},
+		{`re_named("(?P<key>a)", "b")`, "null"},
+		{`re_replace("(\w+)@(\w+)", "a@b c@d", "${2}.${1}")`, "b.a d.c"},
+		{`re_replace(regex("[aeiou]"), "sloth", |m| "<" + m[0] + ">")`, "sl<o>th"},
+		{`re_replace("[0-9]", "a1b22", len)`, "ERROR: function given to `re_replace` must return STRING, got INTEGER"},
+		{`re_replace("a", "a", fn(m) { throw "no"; })`, "ERROR: no"},
+		{`re_replace("a", "a", 1)`, "ERROR: third argument to `re_replace` must be STRING or FUNCTION, got INTEGER"},
+		{`regex("(")`, "ERROR: invalid regular expression given to `regex`: error parsing regexp: missing closing ): `(`"},
+		{`re_match(1, "a")`, "ERROR: first argument to `re_match` must be REGEX or STRING, got INTEGER"},
+		{`re_find_all("a", [])`, "ERROR: second argument to `re_find_all` must be STRING, got ARRAY"},
+		{`re_match("a")`, "ERROR: wrong number of arguments. got=1, want=2"},
 	}
 
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
-		integer, ok := tt.expected.(int)
-		if ok {
-			testIntegerObject(t, evaluated, int64(integer))
-		} else {
-			testNullObject(t, evaluated)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
 		}
 	}
 }
 
-/*
-TestHashLiterals
+func TestEncodingBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`base64_encode("héllo sloth")`, "aMOpbGxvIHNsb3Ro"},
+		{`base64_decode("aMOpbGxvIHNsb3Ro")`, "héllo sloth"},
+		{`base64_decode(base64_encode(""))`, ""},
+		{`hex_encode("hé")`, "68c3a9"},
+		{`hex_decode("68C3A9")`, "hé"},
+		{`byte_len(hex_decode("ff00"))`, "2"},
+		{`base64_decode("a!")`, "ERROR: base64_decode: illegal base64 data at input byte 1"},
+		{`hex_decode("abc")`, "ERROR: hex_decode: encoding/hex: odd length hex string"},
+		{`hex_encode(1)`, "ERROR: argument to `hex_encode` must be STRING, got INTEGER"},
+		{`base64_encode()`, "ERROR: wrong number of arguments. got=0, want=1"},
+	}
 
-This test function shows what we want from Eval when it encounters a *ast.HashLiteral: a fresh *object.Hash with the
-correct number of HashPairs mapped to the matching HashKeys in its Pairs attribute.
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
 
-And it also shows another requirement we have: strings, identifiers, infix operator expressions, booleans and integers -
-they should all be usable as keys. Any expression really. As long as it produces an object that implements the
-Hashable interface it should usable as a hash key.
+func TestDigestBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sha256("abc")`, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{`sha1("abc")`, "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{`md5("abc")`, "900150983cd24fb0d6963f7d28e17f72"},
+		{`crc32("abc")`, "352441c2"},
+		{`hmac_sha256("key", "The quick brown fox jumps over the lazy dog")`,
+			"f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{`md5(1)`, "ERROR: argument to `md5` must be STRING, got INTEGER"},
+		{`hmac_sha256("key", [])`, "ERROR: argument to `hmac_sha256` must be STRING, got ARRAY"},
+		{`hmac_sha256("key")`, "ERROR: wrong number of arguments. got=1, want=2"},
+	}
 
-Then there are the values. They can be produced by any expression, too. We test for this here
-by asserting that 10 - 9 evaluates to 1, 6 / 2 to 3 and so on.
-*/
-func TestHashLiterals(t *testing.T) {
-	input := `let two = "two";
-    {
-        "one": 10 - 9,
-        two: 1 + 1,
-        "thr" + "ee": 6 / 2,
-        4: 4,
-        true: 5,
-        false: 6
-    }`
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
 
-	evaluated := testEval(input)
-	result, ok := evaluated.(*object.Hash)
-	if !ok {
-		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+func TestSpawnAndChannels(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`recv(spawn fn() { 1 + 2 })`, "3"},
+		{`let double = fn(x) { x * 2 }; recv(spawn double(21))`, "42"},
+		{`recv(spawn len("abc"))`, "3"},
+		{`let ch = channel(); spawn fn() { send(ch, "hi") }; recv(ch)`, "hi"},
+		{`let ch = channel(2); send(ch, 1); send(ch, 2); recv(ch) + recv(ch)`, "3"},
+		{`let ch = channel(1); close(ch); recv(ch)`, "null"},
+		{`let ch = channel(1); close(ch); send(ch, 1)`, "ERROR: send on closed channel"},
+		{`let ch = channel(1); close(ch); close(ch)`, "ERROR: close of closed channel"},
+		{`recv(spawn fn() { 1 + true })`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`let ch = channel(); let f = fn(n) { if (n > 0) { send(ch, n); f(n - 1) } else { close(ch) } }; spawn f(3);
+		  recv(ch) + recv(ch) + recv(ch)`, "6"},
+		{`spawn 1`, "ERROR: cannot spawn INTEGER"},
+		{`spawn missing(1)`, "ERROR: identifier not found: missing"},
+		{`channel(-1)`, "ERROR: size of `channel` must not be negative, got -1"},
+		{`channel(9223372036854775807)`, "ERROR: size of `channel` is too large, got 9223372036854775807"},
+		{`recv(1)`, "ERROR: argument to `recv` must be CHANNEL, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSynchronizationBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let m = mutex(); lock(m); unlock(m)`, "null"},
+		{`let m = mutex(); unlock(m)`, "ERROR: unlock of unlocked mutex"},
+		{`let m = mutex(); lock(m); unlock(m); unlock(m)`, "ERROR: unlock of unlocked mutex"},
+		{`let m = mutex(); lock(m); let t = spawn fn() { lock(m); 2 }; unlock(m); recv(t)`, "2"},
+		{`let wg = waitgroup(); let ch = channel(3); add(wg, 3);
+		  let work = fn(n) { send(ch, n); done(wg) };
+		  spawn work(1); spawn work(2); spawn work(3);
+		  wait(wg); recv(ch) + recv(ch) + recv(ch)`, "6"},
+		{`let wg = waitgroup(); wait(wg)`, "null"},
+		{`let wg = waitgroup(); done(wg)`, "ERROR: negative waitgroup counter"},
+		{`let wg = waitgroup(); add(wg, -1)`, "ERROR: negative waitgroup counter"},
+		{`lock(1)`, "ERROR: argument to `lock` must be MUTEX, got INTEGER"},
+		{`add(waitgroup(), "a")`, "ERROR: argument to `add` must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestEnvironmentVariableBuiltins(t *testing.T) {
+	t.Setenv("SLOTH_TEST_VAR", "sloth")
+	t.Setenv("SLOTH_TEST_SET", "")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`env_get("SLOTH_TEST_VAR")`, "sloth"},
+		{`env_get("SLOTH_TEST_MISSING_VAR")`, "null"},
+		{`env_set("SLOTH_TEST_SET", "set"); env_get("SLOTH_TEST_SET")`, "set"},
+		{`env_all()["SLOTH_TEST_VAR"]`, "sloth"},
+		{`env_get(1)`, "ERROR: argument to `env_get` must be STRING, got INTEGER"},
+		{`env_set("SLOTH_TEST_SET", 1)`, "ERROR: argument to `env_set` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestIterableBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, "[2, 4, 6]"},
+		{`map("abc", fn(c) { c + c })`, "[aa, bb, cc]"},
+		{`map({"a": 1}, fn(k) { k })`, "[a]"},
+		{`map(range(3), fn(x) { x })`, "[0, 1, 2]"},
+		{`map(range(2, 5), fn(x) { x })`, "[2, 3, 4]"},
+		{`map(range(10, 0, -3), fn(x) { x })`, "[10, 7, 4, 1]"},
+		{`map(range(3, 3), fn(x) { x })`, "[]"},
+		{`filter(range(10), fn(x) { x > 6 })`, "[7, 8, 9]"},
+		{`filter([1, 2, 3], fn(x) { x > 5 })`, "[]"},
+		{`range(1, 5)`, "range(1, 5)"},
+		{`range(1, 5, 2)`, "range(1, 5, 2)"},
+		{`range(1, 5, 0)`, "ERROR: step of `range` must not be 0"},
+		{`range("a")`, "ERROR: arguments to `range` must be INTEGER, got STRING"},
+		{`map(1, fn(x) { x })`, "ERROR: argument to `map` must be iterable, got INTEGER"},
+		{`filter(1, fn(x) { x })`, "ERROR: argument to `filter` must be iterable, got INTEGER"},
+		{`map([1], fn(x) { x + true })`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	RegisterBuiltin("double", func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		integer, ok := args[0].(*object.Integer)
+		if !ok {
+			return newError("argument to `double` must be INTEGER, got %s", args[0].Type())
+		}
+		return &object.Integer{Value: integer.Value * 2}
+	})
+	defer builtins.Unregister("double")
+
+	testIntegerObject(t, testEval("double(21)"), 42)
+
+	errObj, ok := testEval(`double("a")`).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned.")
+	}
+	if errObj.Message != "argument to `double` must be INTEGER, got STRING" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestCloneBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`clone([1, [2, 3]])`, "[1, [2, 3]]"},
+		{`clone({"b": 1, "a": [2]})`, "{b: 1, a: [2]}"},
+		{`clone(default_hash(fn() { 0 }))["x"]`, "0"},
+		{`clone(5)`, "5"},
+		{`deep_clone([1, {"a": [2, {"b": 3}]}])`, "[1, {a: [2, {b: 3}]}]"},
+		{`let h = {"a": [1]}; deep_clone(h) == h`, "true"},
+		{`deep_clone(default_hash(fn() { 7 }, {"a": {}}))["b"]`, "7"},
+		{`clone()`, "ERROR: wrong number of arguments. got=0, want=1"},
+		{`deep_clone(1, 2)`, "ERROR: wrong number of arguments. got=2, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	inner := &object.Array{Elements: []object.Object{object.IntegerOf(1)}}
+	outer := &object.Array{Elements: []object.Object{inner, inner}}
+
+	shallow := getBuiltin("clone").Fn(outer).(*object.Array)
+	if shallow == outer || shallow.Elements[0] != inner {
+		t.Errorf("clone should copy the array but share its elements")
+	}
+
+	deep := getBuiltin("deep_clone").Fn(outer).(*object.Array)
+	if deep == outer || deep.Elements[0] == inner {
+		t.Errorf("deep_clone should copy the array and its elements")
+	}
+	if deep.Elements[0] != deep.Elements[1] {
+		t.Errorf("deep_clone should copy a value reached twice only once")
+	}
+
+	// a hash that contains itself, which only Go code can build
+	cyclic := object.NewHash()
+	key := &object.String{Value: "self"}
+	cyclic.Set(key.HashKey(), object.HashPair{Key: key, Value: cyclic})
+
+	copied := getBuiltin("deep_clone").Fn(cyclic).(*object.Hash)
+	if copied == cyclic {
+		t.Fatalf("deep_clone returned the hash itself")
+	}
+	if copied.Pairs[key.HashKey()].Value != copied {
+		t.Errorf("the copy of a hash that contains itself should contain the copy")
+	}
+}
+
+func TestFreezeBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`is_frozen([1])`, "false"},
+		{`is_frozen(freeze([1]))`, "true"},
+		{`let config = freeze({"db": {"ports": [1, 2]}}); [is_frozen(config), is_frozen(config["db"]), is_frozen(config.db.ports)]`, "[true, true, true]"},
+		{`freeze({"a": 1})`, "{a: 1}"},
+		{`let a = freeze([1]); [is_frozen(clone(a)), is_frozen(deep_clone(a))]`, "[false, false]"},
+		{`let a = freeze([1]); let b = push(clone(a), 2); [a, b, is_frozen(b)]`, "[[1], [1, 2], false]"},
+		{`push(freeze([1]), 2)`, "ERROR: `push` cannot change a frozen ARRAY"},
+		{`set(freeze([1]), 0, 2)`, "ERROR: `set` cannot change a frozen ARRAY"},
+		{`set(freeze({"a": 1}), "a", 2)`, "ERROR: `set` cannot change a frozen HASH"},
+		{`delete(freeze({"a": 1}), "a")`, "ERROR: `delete` cannot change a frozen HASH"},
+		{`let config = freeze({"db": {"ports": [1]}}); push(config.db.ports, 2)`, "ERROR: `push` cannot change a frozen ARRAY"},
+		{`let config = freeze({"db": {"port": 1}}); set(config.db, "port", 2)`, "ERROR: `set` cannot change a frozen HASH"},
+		{`let h = freeze({"a": 1}); let c = set(clone(h), "a", 2); [h, c, is_frozen(c)]`, "[{a: 1}, {a: 2}, false]"},
+		{`is_frozen(5)`, "true"},
+		{`freeze(5)`, "5"},
+		{`freeze()`, "ERROR: wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	hash := object.NewHash()
+	key := &object.String{Value: "a"}
+	hash.Set(key.HashKey(), object.HashPair{Key: key, Value: hash})
+	getBuiltin("freeze").Fn(hash)
+
+	if err := hash.Set(key.HashKey(), object.HashPair{Key: key, Value: TRUE}); err != object.ErrFrozen {
+		t.Errorf("Set on a frozen hash should return ErrFrozen, got %v", err)
+	}
+	if err := hash.Delete(key.HashKey()); err != object.ErrFrozen {
+		t.Errorf("Delete on a frozen hash should return ErrFrozen, got %v", err)
+	}
+	if hash.Pairs[key.HashKey()].Value != hash {
+		t.Errorf("Set or Delete changed a frozen hash")
+	}
+
+	array := &object.Array{Elements: []object.Object{TRUE}}
+	getBuiltin("freeze").Fn(array)
+	if err := array.Set(0, FALSE); err != object.ErrFrozen {
+		t.Errorf("Set on a frozen array should return ErrFrozen, got %v", err)
+	}
+	if array.Elements[0] != TRUE {
+		t.Errorf("Set changed a frozen array")
+	}
+}
+
+func TestSetAndDeleteBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = [1, 2, 3]; [set(a, 1, "b"), a]`, "[[1, b, 3], [1, 2, 3]]"},
+		{`set([1, 2, 3], -1, 0)`, "[1, 2, 0]"},
+		{`let h = {"a": 1, "b": 2}; [set(h, "a", 3), set(h, "c", 4), h]`, "[{a: 3, b: 2}, {a: 1, b: 2, c: 4}, {a: 1, b: 2}]"},
+		{`set({}, [1, 2], true)[[1, 2]]`, "true"},
+		{`let h = {"a": 1, "b": 2, "c": 3}; [delete(h, "b"), delete(h, "z"), h]`, "[{a: 1, c: 3}, {a: 1, b: 2, c: 3}, {a: 1, b: 2, c: 3}]"},
+		{`set(delete({"a": 1, "b": 2}, "a"), "a", 3)`, "{b: 2, a: 3}"},
+		{`set([1], 1, 2)`, "ERROR: index 1 out of range for `set`, the array has 1 elements"},
+		{`set([1], "0", 2)`, "ERROR: index to `set` an ARRAY must be INTEGER, got STRING"},
+		{`set({}, fn() {}, 1)`, "ERROR: unusable as hash key: FUNCTION"},
+		{`set("abc", 0, "x")`, "ERROR: argument to `set` must be ARRAY or HASH, got STRING"},
+		{`delete([1], 0)`, "ERROR: argument to `delete` must be HASH, got ARRAY"},
+		{`set([1], 0)`, "ERROR: wrong number of arguments. got=2, want=3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestExecBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`exec("sh", ["-c", "echo out; echo err >&2"])["stdout"]`, "out\n"},
+		{`exec("sh", ["-c", "echo out; echo err >&2"])["stderr"]`, "err\n"},
+		{`exec("sh", ["-c", "exit 3"])["code"]`, "3"},
+		{`exec("true")["code"]`, "0"},
+		{`exec("sh", [1])`, "ERROR: arguments of `exec` must be STRING, got INTEGER"},
+		{`exec("sloth-no-such-command")`, "ERROR: exec: exec: \"sloth-no-such-command\": executable file not found in $PATH"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFilesystemBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/file.txt", []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`list_dir("DIR")`, "[file.txt]"},
+		{`mkdir("DIR/a/b"); mkdir("DIR/a/b"); list_dir("DIR/a")`, "[b]"},
+		{`[exists("DIR/a/b"), exists("DIR/nope")]`, "[true, false]"},
+		{`let s = stat("DIR/file.txt"); [s["name"], s["size"], s["is_dir"], s["mode"], s["mod_time"] > 0]`,
+			"[file.txt, 5, false, -rw-------, true]"},
+		{`stat("DIR/a")["is_dir"]`, "true"},
+		{`remove("DIR/a/b"); list_dir("DIR/a")`, "[]"},
+		{`remove("DIR/a/b")`, "ERROR: remove: remove DIR/a/b: no such file or directory"},
+		{`list_dir("DIR/nope")`, "ERROR: list_dir: open DIR/nope: no such file or directory"},
+		{`stat("DIR/nope")`, "ERROR: stat: stat DIR/nope: no such file or directory"},
+		{`list_dir(1)`, "ERROR: argument to `list_dir` must be STRING, got INTEGER"},
+		{`exists()`, "ERROR: wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		input := strings.ReplaceAll(tt.input, "DIR", dir)
+		expected := strings.ReplaceAll(tt.expected, "DIR", dir)
+		evaluated := testEval(input)
+		if evaluated.Inspect() != expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", input, expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestArgparseBuiltin(t *testing.T) {
+	spec := `{"count": {"type": "int", "default": 1, "help": "how many"}, "name": "", "loud": false}`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`argparse(SPEC, [])`, "{count: 1, name: , loud: false, args: []}"},
+		{`argparse(SPEC, ["--count", "3", "a", "--name=sloth", "--loud", "b"])`,
+			"{count: 3, name: sloth, loud: true, args: [a, b]}"},
+		{`argparse(SPEC, ["--loud=false", "-", "--", "--count"])`, "{count: 1, name: , loud: false, args: [-, --count]}"},
+		{`argparse(SPEC, ["--count=99999999999999999999"])["count"]`, "99999999999999999999"},
+		{`argparse({"n": {"type": "int"}}, [])`, "{n: null, args: []}"},
+		{`argparse(SPEC, ["--size", "1"])`, "ERROR: argparse: unknown option --size"},
+		{`argparse(SPEC, ["-c"])`, "ERROR: argparse: unknown option -c"},
+		{`argparse(SPEC, ["--count"])`, "ERROR: argparse: --count needs a value"},
+		{`argparse(SPEC, ["--count", "x"])`, "ERROR: argparse: --count takes an integer, got \"x\""},
+		{`argparse(SPEC, ["--loud=yes"])`, "ERROR: argparse: --loud takes true or false, got \"yes\""},
+		{`argparse({"n": {"type": "int", "required": true}}, [])`, "ERROR: argparse: missing required option --n"},
+		{`argparse({"n": {"help": "no type"}}, [])`, "ERROR: argparse: --n needs a type or a default"},
+		{`argparse({"n": {"type": "float"}}, [])`, "ERROR: argparse: type of --n must be int, string or bool, got float"},
+		{`argparse({"n": {"type": "int", "default": "1"}}, [])`, "ERROR: argparse: default of --n must be INTEGER, got STRING"},
+		{`argparse({"n": [1]}, [])`, "ERROR: argparse: --n must be given a default or a HASH, got ARRAY"},
+		{`argparse({"args": 1}, [])`, "ERROR: argparse: option name args is reserved"},
+		{`argparse({}, [1])`, "ERROR: arguments given to `argparse` must be STRING, got INTEGER"},
+		{`argparse([], [])`, "ERROR: argument to `argparse` must be HASH, got ARRAY"},
+	}
+
+	for _, tt := range tests {
+		input := strings.ReplaceAll(tt.input, "SPEC", spec)
+		evaluated := testEval(input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	var output strings.Builder
+	SetOutput(&output)
+	defer SetOutput(os.Stdout)
+
+	evaluated := testEval(strings.ReplaceAll(`argparse(SPEC, ["a", "--help"]); puts("after");`, "SPEC", spec))
+	if exit, ok := evaluated.(*object.Exit); !ok || exit.Code != 0 {
+		t.Fatalf("--help should exit with 0. got=%s", evaluated.Inspect())
+	}
+	expected := "options:\n" +
+		"  --help         show this help\n" +
+		"  --count int    how many (default 1)\n" +
+		"  --name string\n" +
+		"  --loud\n"
+	if output.String() != expected {
+		t.Errorf("wrong help. expected=\n%s\ngot=\n%s", expected, output.String())
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"counter.sloth":                      `puts("loading counter"); let count = 3; let inc = fn(n) { n + 1 };`,
+		"lib/uses.sloth":                     `let c = import "../counter.sloth"; let twice = c.inc(c.inc(c.count));`,
+		"a.sloth":                            `let b = import "b.sloth";`,
+		"b.sloth":                            `let a = import "a.sloth";`,
+		"broken.sloth":                       `let x = ;`,
+		"shapes.sloth":                       `let secret = 7; export let area = fn(r) { secret * r }; export { pi }; let pi = 3;`,
+		"ghost.sloth":                        `export { ghost };`,
+		"vendor/example.com/u/lib/mod.sloth": `export let greet = fn(n) { "hi " + n };`,
+		"app/main.sloth":                     `from "example.com/u/lib" import greet; let out = greet("app");`,
+		"app/local.sloth":                    `let out = (import "./example.com/u/lib").greet("local");`,
+		"fails.sloth": `let x = 1;
+let y = x + "a";`,
+	}
+	for name, source := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		output   string
+		expected string
+	}{
+		{`let c = import "DIR/counter.sloth"; [c.count, c.inc(1)]`, "loading counter\n", "[3, 2]"},
+		{`let c = import "DIR/counter.sloth"; c.count`, "", "3"},
+		{`(import "DIR/lib/uses.sloth").twice`, "", "5"},
+		{`import "DIR/counter.sloth" == import "DIR/lib/uses.sloth"["c"]`, "", "true"},
+		{`is_frozen(import "DIR/counter.sloth")`, "", "true"},
+		{`import "DIR/a.sloth"`, "", "ERROR: DIR/a.sloth:1:9: DIR/b.sloth:1:9: import cycle: DIR/a.sloth -> DIR/b.sloth -> DIR/a.sloth"},
+		{`import "DIR/missing.sloth"`, "", "ERROR: cannot import DIR/missing.sloth: no such file or directory"},
+		{`import "DIR/broken.sloth"`, "", "ERROR: cannot import DIR/broken.sloth: 1:9: no prefix parse function for ; found"},
+		{`import "DIR/fails.sloth"`, "", "ERROR: DIR/fails.sloth:2:11: type mismatch: INTEGER + STRING"},
+		{`import "DIR/counter.sloth" as c; c.inc(c.count)`, "", "4"},
+		{`from "DIR/counter.sloth" import inc, count; inc(count)`, "", "4"},
+		{`let f = fn() { from "DIR/counter.sloth" import count; count }; [f(), count]`, "",
+			"ERROR: identifier not found: count"},
+		{`from "DIR/counter.sloth" import count, nope`, "", "ERROR: DIR/counter.sloth has no binding named nope"},
+		{`const c = 1; import "DIR/counter.sloth" as c`, "", "ERROR: cannot reassign constant c"},
+		{`let s = import "DIR/shapes.sloth"; [s.pi, s.area(2), s.secret]`, "", "[3, 14, null]"},
+		{`from "DIR/shapes.sloth" import area, secret`, "", "ERROR: DIR/shapes.sloth has no binding named secret"},
+		{`import "DIR/ghost.sloth"`, "", "ERROR: DIR/ghost.sloth: ghost is exported but never bound"},
+		{`export let x = 1; x`, "", "1"},
+		{`(import "DIR/app/main.sloth").out`, "", "hi app"},
+		{`(import "DIR/vendor/example.com/u/lib").greet("dir")`, "", "hi dir"},
+		{`import "DIR/app/local.sloth"`, "",
+			"ERROR: DIR/app/local.sloth:1:12: cannot import DIR/app/example.com/u/lib: no such file or directory"},
+		{`from "DIR/missing.sloth" import x`, "", "ERROR: cannot import DIR/missing.sloth: no such file or directory"},
+	}
+
+	for _, tt := range tests {
+		input := strings.ReplaceAll(tt.input, "DIR", dir)
+		expected := strings.ReplaceAll(tt.expected, "DIR", dir)
+		var output strings.Builder
+		SetOutput(&output)
+		evaluated := testEval(input)
+		SetOutput(os.Stdout)
+
+		if output.String() != tt.output {
+			t.Errorf("wrong output for %q. expected=%q, got=%q", input, tt.output, output.String())
+		}
+		if evaluated.Inspect() != expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", input, expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestExitBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`exit(); 1`, "exit(0)"},
+		{`exit(3); 1`, "exit(3)"},
+		{`let f = fn() { if (true) { exit(2); } 1 }; f(); 5`, "exit(2)"},
+		{`let f = fn(n) { if (n == 0) { exit(4) } f(n - 1) }; f(100)`, "exit(4)"},
+		{`try { exit(1) } catch (e) { 2 }`, "exit(1)"},
+		{`map([1, 2], fn(x) { exit(x) })`, "exit(1)"},
+		{`let f = fn(x = exit(5)) { x }; f(); 1`, "exit(5)"},
+		{`[1, exit(6), 3]`, "exit(6)"},
+		{`exit("a")`, "ERROR: argument to `exit` must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestAssertBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`assert(1 < 2)`, "null"},
+		{`assert(1 > 2)`, "ERROR: assertion failed"},
+		{`assert({}["a"], "was null")`, "ERROR: assertion failed: was null"},
+		{`assert(false, "no"); 1`, "ERROR: assertion failed: no"},
+		{`assert_eq(1 + 1, 2)`, "null"},
+		{`assert_eq(9223372036854775807 + 1, 9223372036854775808)`, "null"},
+		{`assert_eq("a" + "b", "ab")`, "null"},
+		{`assert_eq([1, [2, "x"]], [1, [2, "x"]])`, "null"},
+		{`assert_eq({"a": [1]}, {"a": [1]})`, "null"},
+		{`assert_eq({}["a"], [][0])`, "null"},
+		{`assert_eq(true, true)`, "null"},
+		{`deep_equal([1, {"a": [2]}], [1, {"a": [2]}])`, "true"},
+		{`deep_equal([1], [2])`, "false"},
+		{`deep_equal(1)`, "ERROR: wrong number of arguments. got=1, want=2"},
+		{`assert_eq(1, 2)`, "ERROR: assertion failed: 1 != 2"},
+		{`assert_eq([1, 2], [1])`, "ERROR: assertion failed: [1, 2] != [1]"},
+		{`assert_eq({"a": 1}, {"a": 2})`, "ERROR: assertion failed: {a: 1} != {a: 2}"},
+		{`assert_eq(1, "1")`, "ERROR: assertion failed: 1 != 1"},
+		{`try { assert_eq(1, 2) } catch (e) { e["message"] }`, "assertion failed: 1 != 2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestImplementsBuiltins(t *testing.T) {
+	stack := `let stack = {"items": [], "push": fn(x) { x }, "pop": fn() { 0 }, "size": len}; `
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{stack + `implements(stack, ["push", "pop"])`, "true"},
+		{stack + `implements(stack, ["push", "size"])`, "true"},
+		{stack + `implements(stack, [])`, "true"},
+		{stack + `implements(stack, ["push", "peek"])`, "false"},
+		{stack + `implements(stack, ["items"])`, "false"},
+		{`implements([1, 2], ["push"])`, "false"},
+		{`implements(1, [])`, "true"},
+		{`class Point { x; fn norm() { self.x } }; implements(Point(1), ["norm"])`, "true"},
+		{`implements({}, "push")`, "ERROR: second argument to `implements` must be ARRAY, got STRING"},
+		{`implements({}, [1])`, "ERROR: method names given to `implements` must be STRING, got INTEGER"},
+		{stack + `assert_implements(stack, ["push", "pop"])`, "null"},
+		{stack + `assert_implements(stack, ["push", "peek", "items"])`, "ERROR: assertion failed: HASH is missing peek, items"},
+		{`assert_implements(5, ["push"])`, "ERROR: assertion failed: INTEGER is missing push"},
+		{`assert_implements({}, {})`, "ERROR: second argument to `assert_implements` must be ARRAY, got HASH"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestBudget(t *testing.T) {
+	tests := []struct {
+		input          string
+		maxAllocations int64
+		maxSize        int
+		maxSteps       int64
+		expected       string
+	}{
+		{`let a = [1, 2, 3]; len(a)`, 0, 0, 0, "3"},
+		{`let a = [1, 2, 3]; len(a)`, 10, 3, 0, "3"},
+		{`[1, 2, 3, 4]`, 0, 3, 0, "ERROR: size limit of 3 exceeded: ARRAY has 4"},
+		{`push([1, 2, 3], 4)`, 0, 3, 0, "ERROR: size limit of 3 exceeded: ARRAY has 4"},
+		{`{"a": 1, "b": 2}`, 0, 1, 0, "ERROR: size limit of 1 exceeded: HASH has 2"},
+		{`"ab" + "cd"`, 0, 3, 0, "ERROR: size limit of 3 exceeded: STRING has 4"},
+		{`let f = fn(s) { f(s + "x") }; f("")`, 0, 100, 0, "ERROR: size limit of 100 exceeded: STRING has 101"},
+		{`let f = fn(n) { f(n + 1) }; f(0)`, 1000, 0, 0, "ERROR: allocation limit of 1000 objects exceeded"},
+		{`map(range(100), fn(x) { [x] })`, 50, 0, 0, "ERROR: allocation limit of 50 objects exceeded"},
+		{`let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(10)`, 100, 0, 0, "0"},
+		{`recv(spawn fn() { let g = fn(n) { g(n + 1) }; g(0) })`, 100, 0, 0, "ERROR: allocation limit of 100 objects exceeded"},
+		{`let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(10)`, 0, 0, 1000, "0"},
+		{`let f = fn(n) { f(n + 1) }; f(0)`, 0, 0, 1000, "ERROR: step limit of 1000 exceeded"},
+		{`map(range(1000), fn(x) { x })`, 0, 0, 1000, "ERROR: step limit of 1000 exceeded"},
+		{`map(range(1000000000000), clone)`, 0, 1000, 0, "ERROR: size limit of 1000 exceeded: RANGE has 1000000000000"},
+		{`range(10, -9223372036854775807 - 1, -1)`, 0, 1000, 0,
+			"ERROR: size limit of 1000 exceeded: RANGE has 9223372036854775807"},
+		{`let f = fn(x) { f(x * x) }; f(99)`, 0, 100, 0, "ERROR: size limit of 100 exceeded: BIG_INTEGER has 107"},
+	}
+
+	for _, tt := range tests {
+		budget := &object.Budget{MaxAllocations: tt.maxAllocations, MaxSize: tt.maxSize, MaxSteps: tt.maxSteps}
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		evaluated := Eval(program, object.NewBudgetedEnvironment(budget))
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+/*
+FuzzEval evaluates arbitrary input that parses, under a budget that ends runaway programs with an error. It must never
+panic. Builtins that block, print or reach outside the process are taken away while it runs, as a fuzzed program calls
+them with nobody on the other end.
+*/
+func FuzzEval(f *testing.F) {
+	for _, name := range []string{"exec", "env_set", "mkdir", "remove", "send", "recv", "lock", "wait"} {
+		builtin := getBuiltin(name)
+		UnregisterBuiltin(name)
+		defer RegisterBuiltin(name, builtin.Fn)
+	}
+	SetOutput(io.Discard)
+	defer SetOutput(os.Stdout)
+	SetInput(strings.NewReader(""))
+	defer SetInput(os.Stdin)
+
+	for _, seed := range []string{
+		"let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(10)",
+		`fn f(a, b = a * 2, ...rest) { [a, b, rest] } f(1, 2, 3)[2][0:1]`,
+		`let h = {"a": {"b": 1}}; h?["a"]?["b"] ?? len(keys(h))`,
+		`try { throw {"code": 1}; } catch (e) { e["code"] + 1 }`,
+		`class Point { x; y = 0; fn sum() { self.x + self.y } } Point(1).sum()`,
+		`map(range(0, 10, 2), |x| x * x)`,
+		`let c = channel(1); close(c); close(c)`,
+		`freeze([1, [2]]) == deep_clone([1, [2]])`,
+		`"abc"[-1:] + chr(ord("a") + 99999999999999999999)`,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			return
+		}
+
+		budget := &object.Budget{MaxAllocations: 10_000, MaxSize: 10_000, MaxSteps: 100_000}
+		if evaluated := Eval(program, object.NewBudgetedEnvironment(budget)); evaluated != nil {
+			_ = evaluated.Inspect()
+		}
+	})
+}
+
+// unknownNode is a node Eval has never heard of, as an extension of the ast package might define.
+type unknownNode struct{}
+
+func (unknownNode) TokenLiteral() string { return "" }
+func (unknownNode) String() string       { return "" }
+func (unknownNode) Pos() token.Position  { return token.Position{} }
+func (unknownNode) End() token.Position  { return token.Position{} }
+
+// TestMalformedNodes evaluates trees the parser would never build, which have to fail with an error, not a panic.
+func TestMalformedNodes(t *testing.T) {
+	one := &ast.IntegerLiteral{Value: 1}
+	name := &ast.Identifier{Value: "x"}
+	block := &ast.BlockStatement{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: one}}}
+	throw := &ast.BlockStatement{Statements: []ast.Statement{&ast.ThrowStatement{Value: one}}}
+
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{nil, "cannot evaluate a missing node"},
+		{(*ast.BlockStatement)(nil), "cannot evaluate a missing node"},
+		{unknownNode{}, "cannot evaluate evaluator.unknownNode"},
+		{&ast.Program{Statements: []ast.Statement{nil}}, "cannot evaluate a missing node"},
+		{&ast.LetStatement{Value: one}, "malformed let statement: missing name"},
+		{&ast.LetStatement{Name: name}, "cannot evaluate a missing node"},
+		{&ast.FunctionStatement{Name: name}, "cannot evaluate a missing node"},
+		{&ast.FunctionStatement{Function: &ast.FunctionLiteral{Body: block}}, "malformed function statement: missing name"},
+		{&ast.ClassStatement{Name: name, Fields: []*ast.Identifier{nil}}, "malformed class statement: missing field"},
+		{&ast.ClassStatement{Name: name, Methods: []*ast.FunctionStatement{{Name: name}}},
+			"malformed class statement: missing method"},
+		{&ast.InfixExpression{Operator: "+", Left: one}, "cannot evaluate a missing node"},
+		{&ast.PrefixExpression{Operator: "-"}, "cannot evaluate a missing node"},
+		{&ast.IfExpression{Condition: &ast.Boolean{Value: true}}, "cannot evaluate a missing node"},
+		{&ast.FunctionLiteral{}, "malformed function literal: missing body"},
+		{&ast.FunctionLiteral{Parameters: []*ast.Identifier{nil}, Body: block}, "malformed function literal: missing parameter"},
+		{&ast.CallExpression{Arguments: []ast.Expression{one}}, "cannot evaluate a missing node"},
+		{&ast.TryExpression{Body: throw, Catch: block}, "malformed try expression: missing catch parameter"},
+		{&ast.TryExpression{Body: throw, Param: name}, "cannot evaluate a missing node"},
+		{&ast.DotExpression{Left: &ast.HashLiteral{Pairs: map[ast.Expression]ast.Expression{}}},
+			"malformed dot expression: missing name"},
+		{&ast.ArrayLiteral{Elements: []ast.Expression{one, nil}}, "cannot evaluate a missing node"},
+		{&ast.IndexExpression{Left: &ast.ArrayLiteral{}}, "cannot evaluate a missing node"},
+	}
+
+	for _, tt := range tests {
+		evaluated := Eval(tt.node, object.NewEnvironment())
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("Eval(%#v) should have failed, got=%v", tt.node, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error for %#v. expected=%q, got=%q", tt.node, tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestCoroutines(t *testing.T) {
+	counter := `let counter = coroutine(fn(n) { let step = yield(n); yield(n + step); "done" });`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{counter + `status(counter)`, "suspended"},
+		{counter + `resume(counter, 1)`, "1"},
+		{counter + `resume(counter, 1); resume(counter, 10)`, "11"},
+		{counter + `resume(counter, 1); resume(counter, 10); resume(counter)`, "done"},
+		{counter + `resume(counter, 1); resume(counter, 10); resume(counter); status(counter)`, "dead"},
+		{counter + `resume(counter, 1); resume(counter, 10); resume(counter); resume(counter)`,
+			"ERROR: cannot resume a dead coroutine"},
+		{`let c = coroutine(fn() { status(c) }); resume(c)`, "running"},
+		{`let c = coroutine(fn() { resume(c) }); resume(c)`, "ERROR: cannot resume a running coroutine"},
+		{`let c = coroutine(fn() { let inner = fn(x) { yield(x * 2) }; inner(4) }); resume(c)`, "8"},
+		{`let c = coroutine(fn() { yield() }); resume(c)`, "null"},
+		{`fn count(n) { if (n < 3) { yield(n); count(n + 1) } else { "done" } } let co = coroutine(fn() { count(0) });
+resume(co); resume(co); [resume(co), resume(co)]`, "[2, done]"},
+		{`let helper = fn(x) { yield(x + 1) }; let c = coroutine(fn(x) { helper(x) }); resume(c, 1)`, "2"},
+		{`let c = coroutine(fn() { map([1, 2], fn(x) { yield(x) }) }); resume(c); resume(c, 10); resume(c, 20)`, "[10, 20]"},
+		{`let inner = coroutine(fn() { yield("inner") }); let outer = coroutine(fn() { yield(resume(inner) + "!") });
+resume(outer)`, "inner!"},
+		{`let c = coroutine(fn() { 1 / 0 }); resume(c)`, "ERROR: division by zero"},
+		{`let c = coroutine(fn() { 1 / 0 }); try { resume(c) } catch (e) { status(c) }`, "dead"},
+		{`let c = coroutine(len); resume(c, "abc")`, "3"},
+		{`let c = coroutine(fn() { 1 }); c`, "coroutine(suspended)"},
+		{`yield(1)`, "ERROR: yield outside of a coroutine"},
+		{`let c = coroutine(fn() { await(async(fn() { yield(1) })) }); resume(c)`, "ERROR: yield outside of a coroutine"},
+		{`coroutine(1)`, "ERROR: argument to `coroutine` must be FUNCTION, got INTEGER"},
+		{`resume(1)`, "ERROR: argument to `resume` must be COROUTINE, got INTEGER"},
+		{`status([])`, "ERROR: argument to `status` must be COROUTINE, got ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFutures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = async(fn(x) { x * 2 }, 21); await(f)`, "42"},
+		{`let f = async(fn() { "once" }); await(f) + await(f)`, "onceonce"},
+		{`await(async(len, "abc"))`, "3"},
+		{`all([async(fn() { 1 }), async(fn(x) { x }, 2), async(fn() { 3 })])`, "[1, 2, 3]"},
+		{`all([])`, "[]"},
+		{`let f = async(fn() { 1 }); await(f); f`, "future(resolved)"},
+		{`await(async(fn() { 1 / 0 }))`, "ERROR: division by zero"},
+		{`all([async(fn() { 1 }), async(fn() { throw "failed" })])`, "ERROR: failed"},
+		{`let ch = channel(); let f = async(fn() { recv(ch) }); send(ch, "sent"); await(f)`, "sent"},
+		{`async(1)`, "ERROR: argument to `async` must be FUNCTION, got INTEGER"},
+		{`await(1)`, "ERROR: argument to `await` must be FUTURE, got INTEGER"},
+		{`all(1)`, "ERROR: argument to `all` must be ARRAY, got INTEGER"},
+		{`all([async(fn() { 1 }), 2])`, "ERROR: element 1 of the argument to `all` must be FUTURE, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestUnregisterBuiltin(t *testing.T) {
+	exec := getBuiltin("exec")
+	UnregisterBuiltin("exec")
+	defer RegisterBuiltin("exec", exec.Fn)
+
+	if IsBuiltin("exec") {
+		t.Fatalf("exec is still a builtin after UnregisterBuiltin")
+	}
+
+	errObj, ok := testEval(`exec("true")`).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned.")
+	}
+	if errObj.Message != "identifier not found: exec" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestDeny(t *testing.T) {
+	saved := builtins.Clone()
+	defer func() { builtins = saved }()
+
+	for _, capability := range []string{"fs", "env"} {
+		if err := Deny(capability); err != nil {
+			t.Fatalf("Deny(%q) returned error: %s", capability, err)
+		}
+	}
+	if err := Deny("telepathy"); err == nil || err.Error() != `unknown capability "telepathy", want one of env, exec, fs, net` {
+		t.Errorf("wrong error for an unknown capability. got=%v", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`exists(".")`, "ERROR: `exists` is not allowed here: the fs capability has been denied"},
+		{`env_get("HOME")`, "ERROR: `env_get` is not allowed here: the env capability has been denied"},
+		{`import "lib.sloth"`, "ERROR: cannot import lib.sloth: the fs capability has been denied"},
+		{`len("still here")`, "10"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestBuiltinsRegistry(t *testing.T) {
+	registry := NewBuiltins()
+	registry.Unregister("len")
+	registry.Register("shout", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		return &object.String{Value: strings.ToUpper(args[0].Inspect()) + "!"}
+	}})
+	registry.Register("text.reverse", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		runes := []rune(args[0].Inspect())
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return &object.String{Value: string(runes)}
+	}})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`shout("hi")`, "HI!"},
+		{`text.reverse("abc")`, "cba"},
+		{`let f = fn(s) { text.reverse(s) }; f("xy")`, "yx"},
+		{`is_frozen(text)`, "true"},
+		{`len("abc")`, "ERROR: identifier not found: len"},
+		{`reverse("abc")`, "ERROR: identifier not found: reverse"},
+		{`first([1, 2])`, "1"},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.SetBuiltins(registry)
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	// the registry is a copy: environments without one of their own still get the builtins sloth comes with
+	testIntegerObject(t, testEval(`len("abc")`), 3)
+	if errObj, ok := testEval(`shout("hi")`).(*object.Error); !ok || errObj.Message != "identifier not found: shout" {
+		t.Errorf("a builtin registered with a registry leaked out of it. got=%v", errObj)
+	}
+}
+
+func TestIsolatedInterpreters(t *testing.T) {
+	dir := t.TempDir()
+	module := filepath.Join(dir, "loud.sloth")
+	if err := os.WriteFile(module, []byte(`puts("loading"); let x = 1;`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := builtins.Clone()
+	defer func() { builtins = saved }()
+	early := NewBuiltins()
+	if err := Deny("exec"); err != nil {
+		t.Fatal(err)
+	}
+
+	type interpreter struct {
+		registry *object.Builtins
+		output   strings.Builder
+	}
+	sandboxed, trusted := &interpreter{registry: NewBuiltins()}, &interpreter{registry: NewBuiltins()}
+	for _, in := range []*interpreter{sandboxed, trusted} {
+		in.registry.SetOutput(&in.output)
+		in.registry.SetInput(strings.NewReader("a line\n"))
+	}
+	for _, capability := range []string{"fs", "env"} {
+		if err := DenyIn(sandboxed.registry, capability); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	eval := func(registry *object.Builtins, input string) string {
+		env := object.NewEnvironment()
+		env.SetBuiltins(registry)
+		return Eval(parser.New(lexer.New(input)).ParseProgram(), env).Inspect()
+	}
+
+	tests := []struct {
+		in       *interpreter
+		input    string
+		expected string
+	}{
+		{sandboxed, `exists(".")`, "ERROR: `exists` is not allowed here: the fs capability has been denied"},
+		{trusted, `exists(".")`, "true"},
+		{sandboxed, `env_get("NO_SUCH_VARIABLE")`, "ERROR: `env_get` is not allowed here: the env capability has been denied"},
+		{trusted, `env_get("NO_SUCH_VARIABLE")`, "null"},
+		{sandboxed, `exec("true")`, "ERROR: `exec` is not allowed here: the exec capability has been denied"},
+		{sandboxed, `import "MODULE"`, "ERROR: cannot import MODULE: the fs capability has been denied"},
+		{trusted, `(import "MODULE").x`, "1"},
+		{trusted, `(import "MODULE").x`, "1"},
+		{sandboxed, `puts("sandboxed"); input()`, "a line"},
+		{trusted, `printf("%s", "trusted"); input()`, "a line"},
+	}
+
+	for _, tt := range tests {
+		input := strings.ReplaceAll(tt.input, "MODULE", module)
+		expected := strings.ReplaceAll(tt.expected, "MODULE", module)
+		if got := eval(tt.in.registry, input); got != expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", input, expected, got)
+		}
+	}
+
+	// the trusted interpreter loaded the module once, and printed into its own output
+	if got := sandboxed.output.String(); got != "sandboxed\n" {
+		t.Errorf("wrong output of the sandboxed interpreter. got=%q", got)
+	}
+	if got := trusted.output.String(); got != "loading\ntrusted" {
+		t.Errorf("wrong output of the trusted interpreter. got=%q", got)
+	}
+
+	// a registry made before Deny keeps the capability, and one made after it starts out without it
+	if got := eval(early, `exec("true")`); strings.Contains(got, "denied") {
+		t.Errorf("Deny reached a registry made before it. got=%q", got)
+	}
+	if !NewBuiltins().IsDenied("exec") || early.IsDenied("exec") {
+		t.Errorf("wrong capabilities denied to registries made before and after Deny")
+	}
+}
+
+func TestHooks(t *testing.T) {
+	input := `
+let count = fn(n, acc) { if (n == 0) { return acc; } count(n - 1, acc + 1) };
+count(3, 0) + len("ab");
+`
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	entered, exited, tailExits := 0, 0, 0
+	var calls []string
+	env := object.NewEnvironment()
+	env.SetHooks(&object.Hooks{
+		OnNodeEnter: func(node ast.Node, env *object.Environment) {
+			entered++
+		},
+		OnNodeExit: func(node ast.Node, env *object.Environment, result object.Object) {
+			exited++
+			if _, ok := node.(*ast.CallExpression); ok && result == nil {
+				tailExits++
+			}
+		},
+		OnCall: func(fn object.Object, args []object.Object) {
+			var inspected []string
+			for _, arg := range args {
+				inspected = append(inspected, arg.Inspect())
+			}
+			calls = append(calls, string(fn.Type())+"("+strings.Join(inspected, ", ")+")")
+		},
+	})
+
+	testIntegerObject(t, Eval(program, env), 5)
+
+	if entered == 0 || entered != exited {
+		t.Errorf("nodes entered and exited don't match. entered=%d, exited=%d", entered, exited)
+	}
+	if tailExits != 3 {
+		t.Errorf("wrong number of tail calls exited with a nil result. expected=3, got=%d", tailExits)
+	}
+	expected := []string{"FUNCTION(3, 0)", "FUNCTION(2, 1)", "FUNCTION(1, 2)", "FUNCTION(0, 3)", "BUILTIN(ab)"}
+	if strings.Join(calls, " ") != strings.Join(expected, " ") {
+		t.Errorf("wrong calls. expected=%q, got=%q", expected, calls)
+	}
+}
+
+func TestStepper(t *testing.T) {
+	tests := []struct {
+		input    string
+		steps    []string
+		expected string
+	}{
+		{"let a = 1; let b = a + 1; a + b", []string{"<nil>", "<nil>", "3"}, "3"},
+		{"1; return 2; 3", []string{"1", "2"}, "2"},
+		{"1; missing; 3", []string{"1", "ERROR: identifier not found: missing"}, "ERROR: identifier not found: missing"},
+		{"", nil, "<nil>"},
+	}
+
+	inspect := func(obj object.Object) string {
+		if obj == nil {
+			return "<nil>"
+		}
+		return obj.Inspect()
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		stepper := NewStepper(program, object.NewEnvironment())
+
+		var steps []string
+		for {
+			step, ok := stepper.Step()
+			if !ok {
+				break
+			}
+			if step.Statement != program.Statements[step.Index] {
+				t.Errorf("step %d of %q has the wrong statement. got=%q", step.Index, tt.input, step.Statement)
+			}
+			steps = append(steps, inspect(step.Result))
+		}
+
+		if strings.Join(steps, " | ") != strings.Join(tt.steps, " | ") {
+			t.Errorf("wrong steps for %q. expected=%q, got=%q", tt.input, tt.steps, steps)
+		}
+		if !stepper.Done() {
+			t.Errorf("stepper for %q is not done after its last step", tt.input)
+		}
+		if got := inspect(stepper.Result()); got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+		if got := inspect(testEval(tt.input)); got != tt.expected {
+			t.Errorf("Eval disagrees with the stepper for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	stepper := NewStepper(parser.New(lexer.New("let x = 2; x * 3; x * 4")).ParseProgram(), object.NewEnvironment())
+	step, _ := stepper.Step()
+	if x, ok := step.Env.Get("x"); !ok || x.Inspect() != "2" {
+		t.Errorf("x is not bound after the first step. got=%v", x)
+	}
+	testIntegerObject(t, stepper.Run(), 8)
+}
+
+func TestOutputBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		output   string
+		expected string
+	}{
+		{`puts(1, "a", [2]); printf("%d-%s", 3, "b"); printf("!
+")`, "1\na\n[2]\n3-b!\n", "null"},
+		{`printf("%v%%", 50)`, "50%", "null"},
+		{`printf()`, "", "ERROR: wrong number of arguments. got=0, want at least 1"},
+		{`printf("%d", "1")`, "", "ERROR: value for %d in `printf` must be INTEGER, got STRING"},
+		{`printf("%s")`, "", "ERROR: missing value for %s in format string of `printf`"},
+	}
+
+	for _, tt := range tests {
+		var output strings.Builder
+		SetOutput(&output)
+		evaluated := testEval(tt.input)
+		SetOutput(os.Stdout)
+
+		if output.String() != tt.output {
+			t.Errorf("wrong output for %q. expected=%q, got=%q", tt.input, tt.output, output.String())
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestInputBuiltin(t *testing.T) {
+	var output strings.Builder
+	SetOutput(&output)
+	defer SetOutput(os.Stdout)
+	SetInput(strings.NewReader("one\ntwo\r\n\nthree"))
+	defer SetInput(os.Stdin)
+
+	evaluated := testEval(`[input("name? "), input(), input(), input(), input()]`)
+	if evaluated.Inspect() != "[one, two, , three, null]" {
+		t.Errorf("wrong lines read. got=%s", evaluated.Inspect())
+	}
+	if output.String() != "name? " {
+		t.Errorf("wrong prompt written. got=%q", output.String())
+	}
+
+	for input, expected := range map[string]string{
+		`input(1)`:        "argument to `input` must be STRING, got INTEGER",
+		`input("a", "b")`: "wrong number of arguments. got=2, want 0 to 1",
+	} {
+		evaluated := testEval(input)
+		if errObj, ok := evaluated.(*object.Error); !ok || errObj.Message != expected {
+			t.Errorf("wrong result for %q. expected error %q, got=%s", input, expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d",
+			len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+// TestArrayIndexExpressions tests for off-by-one errors when accessing and retrieving the elements in an array.
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			"[1, 2, 3][0]",
+			1,
+		},
+		{
+			"[1, 2, 3][1]",
+			2,
+		},
+		{
+			"[1, 2, 3][2]",
+			3,
+		},
+		{
+			"let i = 0; [1][i];",
+			1,
+		},
+		{
+			"[1, 2, 3][1 + 1];",
+			3,
+		},
+		{
+			"let myArray = [1, 2, 3]; myArray[2];",
+			3,
+		},
+		{
+			"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];",
+			6,
+		},
+		{
+			"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]",
+			2,
+		},
+		{
+			"[1, 2, 3][3]",
+			nil,
+		},
+		{
+			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
+			nil,
+		},
+		{
+			"[][-1]",
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestArraySliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3, 4, 5][1:4]", []int64{2, 3, 4}},
+		{"[1, 2, 3, 4, 5][:2]", []int64{1, 2}},
+		{"[1, 2, 3, 4, 5][3:]", []int64{4, 5}},
+		{"[1, 2, 3][:]", []int64{1, 2, 3}},
+		{"[1, 2, 3][1:10]", []int64{2, 3}},
+		{"[1, 2, 3][2:1]", []int64{}},
+		{"[1, 2, 3][5:]", []int64{}},
+		{"[1, 2, 3, 4, 5][-2:]", []int64{4, 5}},
+		{"[1, 2, 3, 4, 5][:-1]", []int64{1, 2, 3, 4}},
+		{"[1, 2, 3][-10:1]", []int64{1}},
+		{"let a = [1, 2, 3]; let b = a[0:2]; a", []int64{1, 2, 3}},
+		{"let n = 1; [1, 2, 3][n:n + 1]", []int64{2}},
+		{`let h = {}; h["a"]?[1:2]`, nil},
+		{`[1, 2, 3]["a":]`, "slice bound must be INTEGER, got STRING"},
+		{`"abc"[0:1]`, "slice operator not supported: STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements for %q. want=%d, got=%d", tt.input, len(expected), len(array.Elements))
+				continue
+			}
+			for i, e := range expected {
+				testIntegerObject(t, array.Elements[i], e)
+			}
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+/*
+TestHashLiterals
+
+This test function shows what we want from Eval when it encounters a *ast.HashLiteral: a fresh *object.Hash with the
+correct number of HashPairs mapped to the matching HashKeys in its Pairs attribute.
+
+And it also shows another requirement we have: strings, identifiers, infix operator expressions, booleans and integers -
+they should all be usable as keys. Any expression really. As long as it produces an object that implements the
+Hashable interface it should usable as a hash key.
+
+Then there are the values. They can be produced by any expression, too. We test for this here
+by asserting that 10 - 9 evaluates to 1, 6 / 2 to 3 and so on.
+*/
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+    {
+        "one": 10 - 9,
+        two: 1 + 1,
+        "thr" + "ee": 6 / 2,
+        4: 4,
+        true: 5,
+        false: 6
+    }`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
 	}
 
 	expected := map[object.HashKey]int64{
@@ -550,11 +2145,302 @@ func TestHashLiterals(t *testing.T) {
 	}
 }
 
+func TestNullSafeOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"a": 1}["a"] ?? 5`, 1},
+		{`{"a": 1}["b"] ?? 5`, 5},
+		{`let h = {"a": {"b": 2}}; h?["a"]?["b"]`, 2},
+		{`let h = {"a": {"b": 2}}; h?["x"]?["b"]`, nil},
+		{`let h = {"a": {"b": 2}}; h?["x"]?["b"] ?? 7`, 7},
+		{`[1, 2][5] ?? 3`, 3},
+		{`false ?? 3`, false},
+		{`1 ?? undefined_thing`, 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestPipeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let double = fn(x) { x * 2 }; 3 |> double`, "6"},
+		{`let add = fn(a, b) { a + b }; 1 |> add(2) |> add(3)`, "6"},
+		{`[1, 2, 3] |> map(|n| n * n) |> filter(|n| n > 1)`, "[4, 9]"},
+		{`"sloth" |> len == 5`, "true"},
+		{`let f = fn(x) { x / 0 }; 1 |> f`, "ERROR: division by zero"},
+		{`1 |> 2`, "ERROR: not a function: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestCompose(t *testing.T) {
+	functions := `let inc = fn(x) { x + 1 }; let double = fn(x) { x * 2 };`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{functions + `compose(inc, double)(5)`, "11"},
+		{functions + `compose(double, inc, |a, b| a * b)(2, 3)`, "14"},
+		{functions + `compose(inc)(1)`, "2"},
+		{functions + `(inc >> double)(5)`, "12"},
+		{functions + `5 |> inc >> double >> inc`, "13"},
+		{functions + `let twice = fn(f) { f >> f }; twice(double)(3)`, "12"},
+		{functions + `compose(len, first)(["abc"])`, "3"},
+		{functions + `map([1, 2], inc >> double)`, "[4, 6]"},
+		{`compose(fn(x) { x + 1 }, fn() { 1 / 0 })()`, "ERROR: division by zero"},
+		{`compose()`, "ERROR: wrong number of arguments. got=0, want at least 1"},
+		{`compose(len, 1)`, "ERROR: arguments to `compose` must be FUNCTION, got INTEGER"},
+		{`len >> 1`, "ERROR: type mismatch: BUILTIN >> INTEGER"},
+		{`1 >> 2`, "ERROR: unknown operator: INTEGER >> INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestPartialAndCurry(t *testing.T) {
+	add3 := `let add3 = fn(a, b, c) { a + b + c };`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{add3 + `partial(add3, 1)(2, 3)`, "6"},
+		{add3 + `partial(partial(add3, 1), 2)(3)`, "6"},
+		{add3 + `partial(add3, 1, 2, 3)()`, "6"},
+		{add3 + `let inc = partial(add3, 1, 0); map([1, 2], inc)`, "[2, 3]"},
+		{add3 + `partial(add3, 1, 2, 3, 4)()`, "ERROR: wrong number of arguments. got=4, want=3"},
+		{add3 + `curry(add3)(1)(2)(3)`, "6"},
+		{add3 + `curry(add3)(1, 2)(3)`, "6"},
+		{add3 + `curry(add3)(1)(2, 3)`, "6"},
+		{add3 + `let c = curry(add3)(1); c(10)(100) + c(20)(200)`, "332"},
+		{add3 + `curry(partial(add3, 1))(2)(3)`, "6"},
+		{`let f = fn(a, b = 2) { a * b }; curry(f)(5)`, "10"},
+		{`let f = fn(a, b = 2) { a * b }; curry(f, 2)(5)(3)`, "15"},
+		{`let count = fn(n, acc) { if (n == 0) { return acc; } partial(count, n - 1)(acc + 1) }; count(10000, 0)`,
+			"10000"},
+		{`partial(len, "abc")()`, "3"},
+		{`curry(push, 2)([1])(2)`, "[1, 2]"},
+		{`partial(1)`, "ERROR: argument to `partial` must be FUNCTION, got INTEGER"},
+		{`curry(len)`, "ERROR: `curry` can't tell how many arguments a builtin takes, pass it as the second argument"},
+		{`curry(fn(x) { x }, "2")`, "ERROR: second argument to `curry` must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	// a builtin that says how many arguments it needs, like a compiled function, is curried by it
+	required := 2
+	pair := &object.Builtin{Required: &required, Fn: func(args ...object.Object) object.Object {
+		return &object.Array{Elements: args}
+	}}
+	registry := NewBuiltins()
+	registry.Register("pair", pair)
+	env := object.NewEnvironment()
+	env.SetBuiltins(registry)
+	for input, expected := range map[string]string{
+		`curry(pair)(1)(2)`:             "[1, 2]",
+		`curry(partial(pair, 1))(2, 3)`: "[1, 2, 3]",
+		`curry(pair, 3)(1)(2)(3)`:       "[1, 2, 3]",
+	} {
+		evaluated := Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+		if evaluated.Inspect() != expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", input, expected, evaluated.Inspect())
+		}
+	}
+}
+
 /*
 TestHashIndexExpressions is making sure its use of index operator expressions produces the correct value - only this time with hashes.
 The different test cases here use string, integer or boolean hash keys when retrieving values out of a hash.
 So, in essence, what the test really asserts is that the HashKey methods implemented by various data types are called correctly.
 */
+func TestDotExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let p = {"name": "sloth", "age": 3}; p.age`, "3"},
+		{`let p = {"name": "sloth"}; p.age`, "null"},
+		{`{"a": {"b": [1, 2]}}.a.b[1]`, "2"},
+		{`let p = {"name": "sloth", "greet": fn() { "hi " + self.name }}; p.greet()`, "hi sloth"},
+		{`let p = {"name": "sloth", "greet": |greeting| greeting + " " + self.name}; p.greet("hey")`, "hey sloth"},
+		{`let greet = fn() { self.name }; let a = {"name": "a", "f": greet}; let b = {"name": "b", "f": greet}; [a.f(), b.f()]`, "[a, b]"},
+		{`let counter = {"n": 3, "down": fn(i) { if (i == 0) { 0 } else { self.down(i - 1) } }}; counter.down(100000)`, "0"},
+		{`let m = {"len": len}; m.len("four")`, "4"},
+		{`let p = {"f": fn() { self }}; let f = p.f; f()`, "ERROR: identifier not found: self"},
+		{`let x = 5; x.name`, "ERROR: dot operator not supported: INTEGER"},
+		{`let p = {"name": "sloth"}; p.name()`, "ERROR: not a function: STRING"},
+		{`let p = {}; p.missing()`, "ERROR: not a function: NULL"},
+		{`let p = {"f": fn() { self.n }, "n": 1}; recv(spawn p.f())`, "1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestClassStatements(t *testing.T) {
+	point := `class Point {
+  x;
+  y = 0;
+  fn norm() { self.x * self.x + self.y * self.y }
+  fn scale(by) { Point(self.x * by, self.y * by) }
+}
+`
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{point + "Point(3, 4).norm()", "25"},
+		{point + "Point(3).y", "0"},
+		{point + "Point(1, 2).scale(3).norm()", "45"},
+		{point + "let p = Point(1, 2); [p.x, p[\"y\"]]", "[1, 2]"},
+		{point + "Point()", "ERROR: wrong number of arguments. got=0, want 1 to 2"},
+		{point + "Point(1, 2, 3)", "ERROR: wrong number of arguments. got=3, want 1 to 2"},
+		{"class Empty {}; Empty()", "{}"},
+		{"class Pair { a; b = a + 1; }; Pair(1).b", "2"},
+		{"const Point = 1; class Point {}", "ERROR: cannot reassign constant Point"},
+		{"class Bad { x = nope; }; Bad()", "ERROR: identifier not found: nope"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestOperatorOverloading(t *testing.T) {
+	vec := `class Vec {
+  x;
+  y;
+  fn __add(other) { Vec(self.x + other.x, self.y + other.y) }
+  fn __sub(other) { Vec(self.x - other.x, self.y - other.y) }
+  fn __mul(k) { Vec(self.x * k, self.y * k) }
+  fn __div(k) { Vec(self.x / k, self.y / k) }
+  fn __eq(other) { [self.x, self.y] == [other.x, other.y] }
+  fn __lt(other) { self.x * self.x + self.y * self.y < other.x * other.x + other.y * other.y }
+  fn __gt(other) { other < self }
+  fn __index(i) { if (i == 0) { self.x } else { self.y } }
+}
+`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{vec + `let v = Vec(1, 2) + Vec(3, 4); [v.x, v.y]`, "[4, 6]"},
+		{vec + `let v = Vec(5, 5) - Vec(1, 2); [v.x, v.y]`, "[4, 3]"},
+		{vec + `(Vec(1, 2) * 3).y`, "6"},
+		{vec + `(Vec(4, 8) / 4).y`, "2"},
+		{vec + `Vec(1, 2) == Vec(1, 2)`, "true"},
+		{vec + `Vec(1, 2) != Vec(1, 2)`, "false"},
+		{vec + `Vec(1, 2) != Vec(2, 1)`, "true"},
+		{vec + `Vec(1, 1) < Vec(2, 2)`, "true"},
+		{vec + `Vec(1, 1) > Vec(2, 2)`, "false"},
+		{vec + `let v = Vec(7, 9); [v[0], v[1], v.x]`, "[7, 9, 7]"},
+		{vec + `let v = Vec(7, 9); v?[1]`, "9"},
+		{vec + `Vec(1, 1) / 0`, "ERROR: division by zero"},
+		{`let money = {"cents": 150, "__add": fn(o) { money_of(self.cents + o.cents) }};
+let money_of = fn(c) { {"cents": c, "__add": money["__add"]} };
+(money + money + money).cents`, "450"},
+		{`{"__eq": fn(o) { 1 }} == 2`, "true"},
+		{`{"__eq": fn(o) { false }} == 2`, "false"},
+		{`{"__add": 1} + {"__add": 1}`, "ERROR: unknown operator: HASH + HASH"},
+		{`{"a": 1} == {"a": 1}`, "true"},
+		{vec + `1 + Vec(1, 1)`, "ERROR: type mismatch: INTEGER + HASH"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, "c": 3}`, "{b: 1, a: 2, c: 3}"},
+		{`{3: "x", 1: "y", true: "z", 2: "w"}`, "{3: x, 1: y, true: z, 2: w}"},
+		{`{"a": 1, "b": 2, "a": 3}`, "{a: 3, b: 2}"},
+		{`map({"zebra": 1, "apple": 2, "mango": 3}, |key| key)`, "[zebra, apple, mango]"},
+		{`class P { z; a; fn m() { 1 } }; P(1, 2)`, "{z: 1, a: 2, m: fn() {\n1\n}}"},
+		{`let ch = channel(4); let f = fn(x) { send(ch, x); x }; {f("b"): f(1), f("a"): f(2)}; [recv(ch), recv(ch), recv(ch), recv(ch)]`, "[b, 1, a, 2]"},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 5; i++ {
+			evaluated := testEval(tt.input)
+			if evaluated.Inspect() != tt.expected {
+				t.Fatalf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestDefaultHash(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let h = default_hash(fn() { [] }); h["missing"]`, "[]"},
+		{`let h = default_hash(fn() { 0 }, {"a": 1}); [h["a"], h["b"], h.c]`, "[1, 0, 0]"},
+		{`let h = default_hash(fn() { 0 }, {"b": 1, "a": 2}); h["x"]; h`, "{b: 1, a: 2}"},
+		{`let h = default_hash(|| 1); h?["x"]`, "1"},
+		{`let h = default_hash(len); h["x"]`, "ERROR: wrong number of arguments. got=0, want=1"},
+		{`let h = default_hash(fn() { throw "no default" }); try { h["x"] } catch (e) { e }`, "no default"},
+		{`let n = 5; let h = default_hash(fn() { n * 2 }); h[1]`, "10"},
+		{`assert_eq(default_hash(fn() { 0 }, {"a": 1}), {"a": 1})`, "null"},
+		{`default_hash(1)`, "ERROR: first argument to `default_hash` must be FUNCTION, got INTEGER"},
+		{`default_hash(fn() { 0 }, [])`, "ERROR: second argument to `default_hash` must be HASH, got ARRAY"},
+		{`default_hash()`, "ERROR: wrong number of arguments. got=0, want 1 to 2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestHashIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -588,6 +2474,26 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{[1, 2]: 5}[[1, 2]]`,
+			5,
+		},
+		{
+			`{[1, 2]: 5}[[2, 1]]`,
+			nil,
+		},
+		{
+			`let x = 3; {[x, ["a", true]]: 5}[[3, ["a", true]]]`,
+			5,
+		},
+		{
+			`{[]: 5}[[]]`,
+			5,
+		},
+		{
+			`{[1]: 5}[[[1]]]`,
+			nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,109 @@
+package evaluator
+
+import (
+	"errors"
+	"github.com/sean-d/sloth/object"
+	"io/fs"
+	"os"
+)
+
+/*
+The filesystem builtins work on paths relative to the directory the program was started in. An operation the system
+refuses, like listing a directory that isn't there, is an error naming the builtin and the path. Like exec, they reach
+outside the interpreter, so hosts running untrusted scripts will want to unregister them.
+*/
+func init() {
+	builtins.Register("list_dir", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			path, err := stringArgument("list_dir", args)
+			if err != nil {
+				return err
+			}
+
+			entries, readErr := os.ReadDir(path)
+			if readErr != nil {
+				return newError("list_dir: %s", readErr)
+			}
+
+			names := make([]object.Object, 0, len(entries))
+			for _, entry := range entries {
+				names = append(names, &object.String{Value: entry.Name()})
+			}
+
+			return &object.Array{Elements: names}
+		},
+	})
+
+	builtins.Register("mkdir", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			path, err := stringArgument("mkdir", args)
+			if err != nil {
+				return err
+			}
+
+			// like mkdir -p, any missing parents are made too and a directory that is already there is fine
+			if mkdirErr := os.MkdirAll(path, 0755); mkdirErr != nil {
+				return newError("mkdir: %s", mkdirErr)
+			}
+
+			return NULL
+		},
+	})
+
+	builtins.Register("remove", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			path, err := stringArgument("remove", args)
+			if err != nil {
+				return err
+			}
+
+			// only files and empty directories, so a wrong path can't take a whole tree with it
+			if removeErr := os.Remove(path); removeErr != nil {
+				return newError("remove: %s", removeErr)
+			}
+
+			return NULL
+		},
+	})
+
+	builtins.Register("stat", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			path, err := stringArgument("stat", args)
+			if err != nil {
+				return err
+			}
+
+			info, statErr := os.Stat(path)
+			if statErr != nil {
+				return newError("stat: %s", statErr)
+			}
+
+			return newHash(map[string]object.Object{
+				"name":     &object.String{Value: info.Name()},
+				"size":     object.IntegerOf(info.Size()),
+				"mod_time": object.IntegerOf(info.ModTime().Unix()),
+				"is_dir":   nativeBoolToBooleanObject(info.IsDir()),
+				"mode":     &object.String{Value: info.Mode().String()},
+			})
+		},
+	})
+
+	builtins.Register("exists", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			path, err := stringArgument("exists", args)
+			if err != nil {
+				return err
+			}
+
+			_, statErr := os.Stat(path)
+			switch {
+			case statErr == nil:
+				return TRUE
+			case errors.Is(statErr, fs.ErrNotExist):
+				return FALSE
+			default:
+				return newError("exists: %s", statErr)
+			}
+		},
+	})
+}
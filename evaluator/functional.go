@@ -0,0 +1,150 @@
+package evaluator
+
+import (
+	"github.com/sean-d/sloth/object"
+)
+
+/*
+The functional builtins make new functions out of the ones they are given, which can be called, passed around and
+combined again like any other function. compose returns a builtin written on the spot that closes over the functions
+it was made from and calls them through applyFunction. partial and curry return sloth functions as copies with the
+arguments bound to them, which applyFunction passes along when they are called, and builtins wrapped in new ones.
+*/
+func init() {
+	builtins.Register("compose", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want at least 1",
+					len(args))
+			}
+			for _, arg := range args {
+				if !isCallable(arg) {
+					return newError("arguments to `compose` must be FUNCTION, got %s",
+						arg.Type())
+				}
+			}
+
+			return composeFunctions(args)
+		},
+	})
+
+	builtins.Register("partial", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want at least 1",
+					len(args))
+			}
+
+			bound := args[1:]
+			switch fn := args[0].(type) {
+			case *object.Function:
+				partial := *fn
+				partial.Bound = append(append([]object.Object{}, fn.Bound...), bound...)
+				return &partial
+			case *object.Builtin:
+				partial := &object.Builtin{
+					Fn: func(args ...object.Object) object.Object {
+						return fn.Fn(append(append([]object.Object{}, bound...), args...)...)
+					},
+				}
+				if fn.Required != nil {
+					required := max(*fn.Required-len(bound), 0)
+					partial.Required = &required
+				}
+				return partial
+			default:
+				return newError("argument to `partial` must be FUNCTION, got %s",
+					args[0].Type())
+			}
+		},
+	})
+
+	builtins.Register("curry", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want 1 to 2",
+					len(args))
+			}
+
+			arity := -1
+			if len(args) == 2 {
+				n, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("second argument to `curry` must be INTEGER, got %s",
+						args[1].Type())
+				}
+				if n.Value < 0 {
+					return newError("number of arguments to `curry` must not be negative, got %d",
+						n.Value)
+				}
+				arity = int(n.Value)
+			}
+
+			switch fn := args[0].(type) {
+			case *object.Function:
+				if arity < 0 {
+					arity = requiredParameters(fn)
+				}
+				curried := *fn
+				curried.Curry = arity
+				return &curried
+			case *object.Builtin:
+				if arity < 0 && fn.Required != nil {
+					arity = *fn.Required
+				}
+				if arity < 0 {
+					return newError("`curry` can't tell how many arguments a builtin takes, pass it as the second argument")
+				}
+				return curryBuiltin(fn, arity, nil)
+			default:
+				return newError("argument to `curry` must be FUNCTION, got %s",
+					args[0].Type())
+			}
+		},
+	})
+}
+
+// curryBuiltin returns a builtin that collects arguments for fn, on top of those bound already, until it has arity of
+// them and then calls fn with them all.
+func curryBuiltin(fn *object.Builtin, arity int, bound []object.Object) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			all := append(append([]object.Object{}, bound...), args...)
+			if len(all) < arity {
+				return curryBuiltin(fn, arity, all)
+			}
+			return fn.Fn(all...)
+		},
+	}
+}
+
+/*
+composeFunctions returns a function that calls the last of fns with the arguments it is called with, and then each of
+the others, from right to left, with the result of the one after it, the way compose(f, g)(x) is f(g(x)). An error in
+any of them stops the chain and is what the composed function returns.
+*/
+func composeFunctions(fns []object.Object) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			result := applyFunction(fns[len(fns)-1], args)
+			for i := len(fns) - 2; i >= 0; i-- {
+				if isError(result) {
+					return result
+				}
+				result = applyFunction(fns[i], []object.Object{result})
+			}
+
+			return result
+		},
+	}
+}
+
+// isCallable reports whether obj can be called like a function.
+func isCallable(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Function, *object.Builtin:
+		return true
+	}
+
+	return false
+}
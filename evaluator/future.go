@@ -0,0 +1,87 @@
+package evaluator
+
+import (
+	"github.com/sean-d/sloth/object"
+)
+
+/*
+The future builtins run calls concurrently and join them again: async(fn, args...) starts fn on a task of its own and
+returns a future for its result right away, await(future) waits for that result, and all(futures) waits for a whole
+array of them. A future can be awaited any number of times, by any number of tasks, and always gives the same result.
+An error in the call is an error in await, and in all if it happens in any of the calls it waits for.
+*/
+func init() {
+	builtins.Register("async", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want at least 1",
+					len(args))
+			}
+
+			switch args[0].(type) {
+			case *object.Function, *object.Builtin:
+			default:
+				return newError("argument to `async` must be FUNCTION, got %s",
+					args[0].Type())
+			}
+
+			future := object.NewFuture()
+			go func() {
+				future.Resolve(applyFunction(args[0], args[1:]))
+			}()
+
+			return future
+		},
+	})
+
+	builtins.Register("await", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			future, ok := args[0].(*object.Future)
+			if !ok {
+				return newError("argument to `await` must be FUTURE, got %s",
+					args[0].Type())
+			}
+
+			return future.Wait()
+		},
+	})
+
+	// all waits for every future before looking at any result, so no call is still running once it returns
+	builtins.Register("all", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			array, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `all` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			for i, element := range array.Elements {
+				if _, ok := element.(*object.Future); !ok {
+					return newError("element %d of the argument to `all` must be FUTURE, got %s",
+						i, element.Type())
+				}
+			}
+
+			results := make([]object.Object, len(array.Elements))
+			for i, element := range array.Elements {
+				results[i] = element.(*object.Future).Wait()
+			}
+
+			for _, result := range results {
+				if isError(result) {
+					return result
+				}
+			}
+
+			return &object.Array{Elements: results}
+		},
+	})
+}
@@ -0,0 +1,34 @@
+package evaluator
+
+import (
+	"github.com/sean-d/sloth/ast"
+	"github.com/sean-d/sloth/object"
+)
+
+// enterNode tells the hooks of env, if there are any, that node is about to be evaluated.
+func enterNode(node ast.Node, env *object.Environment) {
+	if hooks := env.Hooks(); hooks != nil && hooks.OnNodeEnter != nil {
+		hooks.OnNodeEnter(node, env)
+	}
+}
+
+// exitNode tells the hooks of env, if there are any, that node evaluated to result, and returns result. A tail call
+// hasn't been made yet and is not something the hooks could make sense of, so they are given nil for it instead.
+func exitNode(node ast.Node, env *object.Environment, result object.Object) object.Object {
+	if hooks := env.Hooks(); hooks != nil && hooks.OnNodeExit != nil {
+		if isTailCall(result) {
+			hooks.OnNodeExit(node, env, nil)
+		} else {
+			hooks.OnNodeExit(node, env, result)
+		}
+	}
+
+	return result
+}
+
+// callHook tells the hooks of env, if there are any, that fn is about to be called with args.
+func callHook(env *object.Environment, fn object.Object, args []object.Object) {
+	if hooks := env.Hooks(); hooks != nil && hooks.OnCall != nil {
+		hooks.OnCall(fn, args)
+	}
+}
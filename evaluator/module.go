@@ -0,0 +1,215 @@
+package evaluator
+
+import (
+	"errors"
+	"github.com/sean-d/sloth/ast"
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/object"
+	"github.com/sean-d/sloth/parser"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VendorDir is the directory `sloth get` fetches modules into, and where imports look for modules they don't find
+// next to the file importing them.
+const VendorDir = "vendor"
+
+// ModuleFile is the file that importing a directory imports, the entry point of the module the directory holds.
+const ModuleFile = "mod.sloth"
+
+/*
+importModule evaluates to the module at path: a frozen hash of the top-level bindings the file exports, or of all of
+them if it doesn't export any. See resolveImport for how path is found. Modules are cached by the registry of builtins
+env has, so each interpreter imports a file once and interpreters with registries of their own don't share modules.
+
+A module that is part of the chain of imports that led to path would, if imported again, import itself forever, so
+that is an error naming the whole chain.
+*/
+func importModule(path string, env *object.Environment) object.Object {
+	registry := builtinsOf(env)
+	if registry.IsDenied("fs") {
+		return newError("cannot import %s: the fs capability has been denied", path)
+	}
+
+	importer := env.Module()
+	abs, err := resolveImport(path, importer)
+	if err != nil {
+		return newError("cannot import %s: %s", path, err)
+	}
+
+	for m := importer; m != nil; m = m.Importer {
+		if m.Path == abs {
+			return newError("import cycle: %s", importChain(importer, abs))
+		}
+	}
+
+	return registry.Modules().Load(abs, func() object.Object {
+		return loadModule(&object.Module{Path: abs, Importer: importer}, env)
+	})
+}
+
+/*
+evalImportStatement imports the module node names and binds it to its alias, or each of the names it imports to the
+module's binding of that name, which the module has to have. It returns the error or exit the import ended with, if
+it didn't simply succeed.
+*/
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	names := node.Names
+	if node.Alias != nil {
+		names = []*ast.Identifier{node.Alias}
+	}
+	for _, name := range names {
+		if env.IsConst(name.Value) {
+			return errorAt(name.Token, newError("cannot reassign constant %s", name.Value))
+		}
+	}
+
+	module := importModule(node.Path.Value, env)
+	exports, ok := module.(*object.Hash)
+	if !ok {
+		return errorAt(node.Token, module)
+	}
+	if node.Alias != nil {
+		env.Set(node.Alias.Value, exports)
+		return nil
+	}
+
+	values := make([]object.Object, len(node.Names))
+	for i, name := range node.Names {
+		key := &object.String{Value: name.Value}
+		pair, ok := exports.Pairs[key.HashKey()]
+		if !ok {
+			return errorAt(name.Token, newError("%s has no binding named %s", node.Path.Value, name.Value))
+		}
+		values[i] = pair.Value
+	}
+	for i, name := range node.Names {
+		env.Set(name.Value, values[i])
+	}
+
+	return nil
+}
+
+/*
+resolveImport returns the absolute path of the file an import of path made from importer loads. A relative path is
+found from the directory of the file doing the importing, or the working directory for code that isn't in a file.
+If nothing is there and the path doesn't start with a dot, like a fetched module's github.com/user/lib, it is looked
+for in the vendor directory next to the importing file and then in those of the directories above it, the nearest
+first. A path naming a directory imports the directory's mod.sloth.
+
+When the path isn't found anywhere, the path it would have had next to the importer is returned, for the error
+reading it to name.
+*/
+func resolveImport(path string, importer *object.Module) (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if importer != nil {
+		dir = filepath.Dir(importer.Path)
+	}
+
+	candidates := []string{path}
+	if !filepath.IsAbs(path) {
+		candidates = []string{filepath.Join(dir, path)}
+		if !strings.HasPrefix(path, ".") {
+			for d := dir; ; d = filepath.Dir(d) {
+				candidates = append(candidates, filepath.Join(d, VendorDir, path))
+				if filepath.Dir(d) == d {
+					break
+				}
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			candidate = filepath.Join(candidate, ModuleFile)
+		}
+		return filepath.Abs(candidate)
+	}
+
+	return filepath.Abs(candidates[0])
+}
+
+// loadModule reads, parses and evaluates module for the code in importer that imports it.
+func loadModule(module *object.Module, importer *object.Environment) object.Object {
+	name := displayPath(module.Path)
+
+	source, err := os.ReadFile(module.Path)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return newError("cannot import %s: %s", name, err)
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if errs := p.ParseErrors(); len(errs) > 0 {
+		return newError("cannot import %s: %s: %s", name, errs[0].Pos(), errs[0].Message)
+	}
+
+	env := object.NewModuleEnvironment(module, importer)
+	switch evaluated := Eval(program, env).(type) {
+	case *object.Error:
+		// the error's position is in the module, not in the file the import is in
+		if evaluated.Pos.IsValid() {
+			return newError("%s:%s: %s", name, evaluated.Pos, evaluated.Message)
+		}
+		return newError("%s: %s", name, evaluated.Message)
+	case *object.Exit:
+		return evaluated
+	}
+
+	// a file that doesn't export anything exports everything
+	names := env.Exports()
+	if names == nil {
+		names = env.Names()
+	}
+
+	exports := object.NewHash()
+	for _, binding := range names {
+		value, ok := env.Get(binding)
+		if !ok {
+			return newError("%s: %s is exported but never bound", name, binding)
+		}
+		key := &object.String{Value: binding}
+		exports.Set(key.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+	exports.Freeze()
+
+	return exports
+}
+
+// importChain returns the chain of imports that led from the program's main file through importer to path, as
+// a -> b -> c.
+func importChain(importer *object.Module, path string) string {
+	chain := []string{displayPath(path)}
+	for m := importer; m != nil; m = m.Importer {
+		chain = append([]string{displayPath(m.Path)}, chain...)
+	}
+
+	return strings.Join(chain, " -> ")
+}
+
+// displayPath returns path relative to the working directory when it is inside it, which is how errors name modules.
+func displayPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+
+	return rel
+}
@@ -0,0 +1,52 @@
+package evaluator
+
+import (
+	"github.com/sean-d/sloth/object"
+)
+
+/*
+The functions in this file are the evaluator's operations on values, exported for code that runs sloth programs
+without walking their syntax tree, such as programs compiled to Go by the transpile package. Going through them keeps
+such programs behaving exactly as they would under Eval, down to the error messages.
+*/
+
+// Prefix applies the prefix operator, ! or -, to right.
+func Prefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// Infix applies the infix operator to left and right. ?? is not handled here, as its right side is only evaluated when
+// left is null.
+func Infix(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// Index looks index up in left, an array or a hash.
+func Index(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// Apply calls fn, a sloth function or a builtin, with args.
+func Apply(fn object.Object, args []object.Object) object.Object {
+	return applyFunction(fn, args)
+}
+
+// IsTruthy reports whether obj counts as true in a condition: everything but null and false does.
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// Throw turns the value of a throw statement into the error it raises.
+func Throw(val object.Object) *object.Error {
+	return throwError(val)
+}
+
+// Caught returns what the parameter of a catch block is bound to when it catches err.
+func Caught(err *object.Error) object.Object {
+	return errorValue(err)
+}
+
+// LookupBuiltin returns the builtin called name, if there is one.
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	return builtins.Get(name)
+}
@@ -0,0 +1,72 @@
+package evaluator
+
+import (
+	"github.com/sean-d/sloth/object"
+)
+
+/*
+operatorMethods maps the infix operators a hash, and so an instance of a class, can overload to the methods it
+overloads them with. A hash that has a function under one of these names is the left operand of the operator calls it
+with self bound to the hash and the right operand as its argument, instead of evaluating the operator itself. != is
+the opposite of __eq, and both == and != turn what __eq returns into a boolean.
+
+Indexing a hash with [] calls its __index method the same way, with the index. Dot access isn't indexing, so
+self.field still works inside __index, where self["field"] would call __index again.
+*/
+var operatorMethods = map[string]string{
+	"+":  "__add",
+	"-":  "__sub",
+	"*":  "__mul",
+	"/":  "__div",
+	"==": "__eq",
+	"!=": "__eq",
+	"<":  "__lt",
+	">":  "__gt",
+}
+
+// evalOverloadedInfix evaluates left operator right with the method left overloads operator with, if it has one, and
+// reports whether it did.
+func evalOverloadedInfix(operator string, left, right object.Object) (object.Object, bool) {
+	name, ok := operatorMethods[operator]
+	if !ok {
+		return nil, false
+	}
+
+	result, ok := callOperatorMethod(left, name, right)
+	if !ok || isError(result) {
+		return result, ok
+	}
+
+	switch operator {
+	case "==":
+		return nativeBoolToBooleanObject(isTruthy(result)), true
+	case "!=":
+		return nativeBoolToBooleanObject(!isTruthy(result)), true
+	}
+
+	return result, true
+}
+
+// callOperatorMethod calls the method called name of receiver with args, if receiver is a hash with a function or a
+// builtin under name, and reports whether it did.
+func callOperatorMethod(receiver object.Object, name string, args ...object.Object) (object.Object, bool) {
+	hash, ok := receiver.(*object.Hash)
+	if !ok {
+		return nil, false
+	}
+
+	key := &object.String{Value: name}
+	pair, ok := hash.Pairs[key.HashKey()]
+	if !ok {
+		return nil, false
+	}
+	switch fn := pair.Value.(type) {
+	case *object.Function:
+		return applyFunction(bindSelf(fn, hash), args), true
+	case *object.Builtin:
+		// a compiled program's functions are builtins, which have no body to bind self in
+		return applyFunction(fn, args), true
+	default:
+		return nil, false
+	}
+}
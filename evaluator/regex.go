@@ -0,0 +1,170 @@
+package evaluator
+
+import (
+	"github.com/sean-d/sloth/object"
+	"regexp"
+	"strings"
+)
+
+/*
+The regular expression builtins take a pattern either as a regex, compiled once with the regex builtin, or as a string
+compiled on every call. A match is an array of the text the whole pattern matched followed by what each of its groups
+captured, null for a group that took no part in the match. re_replace calls back into sloth functions through
+applyFunction, so like map and filter these are added to builtins once it has been initialized.
+*/
+func init() {
+	builtins.Register("regex", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			re, err := toRegex("regex", args[0])
+			if err != nil {
+				return err
+			}
+
+			return &object.Regex{Value: re}
+		},
+	})
+
+	builtins.Register("re_match", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			re, s, err := regexArguments("re_match", args, 2)
+			if err != nil {
+				return err
+			}
+
+			loc := re.FindStringSubmatchIndex(s)
+			if loc == nil {
+				return NULL
+			}
+
+			return matchArray(s, loc)
+		},
+	})
+
+	builtins.Register("re_find_all", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			re, s, err := regexArguments("re_find_all", args, 2)
+			if err != nil {
+				return err
+			}
+
+			matches := []object.Object{}
+			for _, loc := range re.FindAllStringSubmatchIndex(s, -1) {
+				matches = append(matches, matchArray(s, loc))
+			}
+
+			return &object.Array{Elements: matches}
+		},
+	})
+
+	builtins.Register("re_named", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			re, s, err := regexArguments("re_named", args, 2)
+			if err != nil {
+				return err
+			}
+
+			loc := re.FindStringSubmatchIndex(s)
+			if loc == nil {
+				return NULL
+			}
+
+			groups := matchArray(s, loc).Elements
+			hash := object.NewHash()
+			for i, name := range re.SubexpNames() {
+				if name == "" {
+					continue
+				}
+				key := &object.String{Value: name}
+				hash.Set(key.HashKey(), object.HashPair{Key: key, Value: groups[i]})
+			}
+
+			return hash
+		},
+	})
+
+	builtins.Register("re_replace", &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			re, s, err := regexArguments("re_replace", args, 3)
+			if err != nil {
+				return err
+			}
+
+			switch replacement := args[2].(type) {
+			case *object.String:
+				return &object.String{Value: re.ReplaceAllString(s, replacement.Value)}
+			case *object.Function, *object.Builtin:
+				var out strings.Builder
+				last := 0
+				for _, loc := range re.FindAllStringSubmatchIndex(s, -1) {
+					replaced := applyFunction(replacement, []object.Object{matchArray(s, loc)})
+					if isError(replaced) {
+						return replaced
+					}
+					str, ok := replaced.(*object.String)
+					if !ok {
+						return newError("function given to `re_replace` must return STRING, got %s", replaced.Type())
+					}
+					out.WriteString(s[last:loc[0]])
+					out.WriteString(str.Value)
+					last = loc[1]
+				}
+				out.WriteString(s[last:])
+
+				return &object.String{Value: out.String()}
+			default:
+				return newError("third argument to `re_replace` must be STRING or FUNCTION, got %s", args[2].Type())
+			}
+		},
+	})
+}
+
+// toRegex returns the regular expression pattern holds, a regex or a string to compile, for the builtin called name.
+func toRegex(name string, pattern object.Object) (*regexp.Regexp, *object.Error) {
+	switch pattern := pattern.(type) {
+	case *object.Regex:
+		return pattern.Value, nil
+	case *object.String:
+		re, err := regexp.Compile(pattern.Value)
+		if err != nil {
+			return nil, newError("invalid regular expression given to `%s`: %s", name, err)
+		}
+		return re, nil
+	default:
+		return nil, newError("first argument to `%s` must be REGEX or STRING, got %s", name, pattern.Type())
+	}
+}
+
+// regexArguments checks the arguments of the builtin called name, want of them starting with a pattern and the string
+// to search, and returns those two.
+func regexArguments(name string, args []object.Object, want int) (*regexp.Regexp, string, *object.Error) {
+	if len(args) != want {
+		return nil, "", newError("wrong number of arguments. got=%d, want=%d", len(args), want)
+	}
+	re, err := toRegex(name, args[0])
+	if err != nil {
+		return nil, "", err
+	}
+	s, ok := args[1].(*object.String)
+	if !ok {
+		return nil, "", newError("second argument to `%s` must be STRING, got %s", name, args[1].Type())
+	}
+
+	return re, s.Value, nil
+}
+
+// matchArray turns the index pairs Go's regexp reports for a match in s into the array the builtins return for it.
+func matchArray(s string, loc []int) *object.Array {
+	groups := make([]object.Object, 0, len(loc)/2)
+	for i := 0; i+1 < len(loc); i += 2 {
+		if loc[i] < 0 {
+			groups = append(groups, NULL)
+			continue
+		}
+		groups = append(groups, &object.String{Value: s[loc[i]:loc[i+1]]})
+	}
+
+	return &object.Array{Elements: groups}
+}
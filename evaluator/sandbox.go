@@ -0,0 +1,65 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/sean-d/sloth/object"
+	"sort"
+	"strings"
+)
+
+/*
+capabilities maps each thing a script can be kept from doing to the builtins that do it. net has no builtins yet; it
+is there so sandboxes can deny it up front and keep denying whatever reaches the network once something does.
+*/
+var capabilities = map[string][]string{
+	"fs":   {"list_dir", "mkdir", "remove", "stat", "exists"},
+	"exec": {"exec"},
+	"env":  {"env_get", "env_set", "env_all"},
+	"net":  nil,
+}
+
+/*
+Deny takes capability, one of fs, exec, env and net, away from every program evaluated afterwards with the builtins
+environments without a registry of their own use, and from those given registries made with NewBuiltins from then on.
+Registries made before keep it; DenyIn takes it away from one of them.
+
+Programs that run untrusted scripts call it before evaluating them; there is no way to grant a capability back. Like
+UnregisterBuiltin, it is not safe to call while a program is being evaluated.
+*/
+func Deny(capability string) error {
+	return DenyIn(builtins, capability)
+}
+
+/*
+DenyIn takes capability away from the programs evaluated with registry only. Its builtins are replaced with ones that
+fail saying what was denied, rather than removed, so a sandboxed script learns why instead of being told the builtin
+doesn't exist. Denying fs also keeps scripts from importing modules, which are files too.
+*/
+func DenyIn(registry *object.Builtins, capability string) error {
+	names, ok := capabilities[capability]
+	if !ok {
+		return fmt.Errorf("unknown capability %q, want one of %s", capability, strings.Join(Capabilities(), ", "))
+	}
+
+	registry.MarkDenied(capability)
+	for _, name := range names {
+		registry.Register(name, &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				return newError("`%s` is not allowed here: the %s capability has been denied", name, capability)
+			},
+		})
+	}
+
+	return nil
+}
+
+// Capabilities returns the names of the capabilities Deny takes, sorted.
+func Capabilities() []string {
+	names := make([]string, 0, len(capabilities))
+	for name := range capabilities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,82 @@
+package evaluator
+
+import (
+	"github.com/sean-d/sloth/ast"
+	"github.com/sean-d/sloth/object"
+)
+
+/*
+Stepper evaluates a program one top-level statement at a time instead of all at once the way Eval does, so a host can
+look at the environment and what each statement evaluated to in between, for stepping through a script in a debugger
+or showing how it builds up its state. Calling Step until it returns false evaluates the program exactly as Eval
+would, and Result then returns what Eval would have.
+
+Anything finer than a statement is left to object.Hooks: an OnNodeEnter hook that blocks until the host is ready to
+go on pauses evaluation at every node.
+*/
+type Stepper struct {
+	program *ast.Program
+	env     *object.Environment
+	next    int // the index of the statement the next Step evaluates
+	result  object.Object
+	done    bool
+}
+
+// Step is what a Stepper left behind after evaluating one statement of its program.
+type Step struct {
+	Index     int                 // the index of Statement in the program's statements
+	Statement ast.Statement       // the statement that was evaluated
+	Result    object.Object       // what Statement evaluated to, with a return value unwrapped
+	Env       *object.Environment // the environment the program is evaluated in, with the bindings made so far
+}
+
+// NewStepper returns a Stepper that evaluates program in env, starting with its first statement.
+func NewStepper(program *ast.Program, env *object.Environment) *Stepper {
+	return &Stepper{program: program, env: env}
+}
+
+/*
+Step evaluates the next statement of the program and returns what it evaluated to. It returns false, and evaluates
+nothing, once the program is done: every statement has been evaluated, or one of them returned, failed with an error,
+or called exit, which ends the program just like it would under Eval.
+*/
+func (s *Stepper) Step() (Step, bool) {
+	if s.Done() {
+		return Step{}, false
+	}
+
+	index, statement := s.next, s.program.Statements[s.next]
+	s.next++
+
+	result := Eval(statement, s.env)
+	switch r := result.(type) {
+	case *object.ReturnValue:
+		result = r.Value
+		s.done = true
+	case *object.Error, *object.Exit:
+		s.done = true
+	}
+	s.result = result
+
+	return Step{Index: index, Statement: statement, Result: result, Env: s.env}, true
+}
+
+// Done reports whether the program has been evaluated as far as it goes, so that Step has nothing left to do.
+func (s *Stepper) Done() bool {
+	return s.done || s.next >= len(s.program.Statements)
+}
+
+// Result returns what the last statement evaluated so far evaluated to, which once the stepper is done is what Eval
+// would have returned for the whole program. It is nil before the first Step.
+func (s *Stepper) Result() object.Object {
+	return s.result
+}
+
+// Run evaluates the rest of the program without stopping and returns its result, as Result would afterwards.
+func (s *Stepper) Run() object.Object {
+	for {
+		if _, ok := s.Step(); !ok {
+			return s.result
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package format
+
+import (
+	"bytes"
+	"errors"
+	"github.com/sean-d/sloth/ast"
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/parser"
+	"strings"
+)
+
+// INDENT is what every level of block nesting is indented by.
+const INDENT = "  "
+
+/*
+Source parses src and prints it back out in canonical form. If src does not parse, the parser errors are joined into
+the returned error and the formatted output is nil; a file that doesn't parse is never rewritten.
+*/
+func Source(src []byte) ([]byte, error) {
+	l := lexer.New(string(src))
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	return []byte(Program(program)), nil
+}
+
+/*
+Program prints program in canonical form:
+- one statement per line, each ending in a semicolon unless it ends in a block (a bare if or try expression)
+- blocks opened on the same line as their if/else/fn and indented by INDENT
+- a single space around infix operators and after commas and colons
+- parentheses only where precedence requires them
+
+Top level statements that span more than one line are set apart from their neighbours by a blank line. Doc comments
+are kept above the declarations they document; /// comments anywhere else are dropped.
+*/
+func Program(program *ast.Program) string {
+	pr := &printer{}
+
+	previousMultiline := false
+	for i, s := range program.Statements {
+		start := pr.out.Len()
+		pr.statement(s)
+		multiline := strings.Contains(pr.out.String()[start:], "\n")
+
+		if i > 0 && (multiline || previousMultiline) {
+			formatted := pr.out.String()[start:]
+			pr.out.Truncate(start)
+			pr.out.WriteString("\n")
+			pr.out.WriteString(formatted)
+		}
+
+		pr.out.WriteString("\n")
+		previousMultiline = multiline
+	}
+
+	return pr.out.String()
+}
+
+// Node prints a single statement or expression in canonical form, without a trailing newline.
+func Node(node ast.Node) string {
+	if program, ok := node.(*ast.Program); ok {
+		return Program(program)
+	}
+
+	pr := &printer{}
+
+	switch node := node.(type) {
+	case ast.Statement:
+		pr.statement(node)
+	case ast.Expression:
+		pr.expression(node)
+	}
+
+	return pr.out.String()
+}
+
+// printer accumulates formatted output and keeps track of how deeply nested the block currently being printed is.
+type printer struct {
+	out   bytes.Buffer
+	depth int
+}
+
+func (pr *printer) write(s string) {
+	pr.out.WriteString(s)
+}
+
+func (pr *printer) newline() {
+	pr.out.WriteString("\n")
+	pr.out.WriteString(strings.Repeat(INDENT, pr.depth))
+}
+
+func (pr *printer) statement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		pr.doc(s.Doc)
+		if s.IsConst() {
+			pr.write("const ")
+		} else {
+			pr.write("let ")
+		}
+		pr.write(s.Name.Value)
+		pr.write(" = ")
+		pr.expression(s.Value)
+		pr.write(";")
+	case *ast.ReturnStatement:
+		pr.write("return")
+		if s.ReturnValue != nil {
+			pr.write(" ")
+			pr.expression(s.ReturnValue)
+		}
+		pr.write(";")
+	case *ast.ThrowStatement:
+		pr.write("throw ")
+		pr.expression(s.Value)
+		pr.write(";")
+	case *ast.ExpressionStatement:
+		pr.expression(s.Expression)
+		switch s.Expression.(type) {
+		case *ast.IfExpression, *ast.TryExpression:
+		default:
+			pr.write(";")
+		}
+	case *ast.FunctionStatement:
+		pr.doc(s.Doc)
+		pr.write("fn ")
+		pr.write(s.Name.Value)
+		pr.function(s.Function)
+	case *ast.ClassStatement:
+		pr.doc(s.Doc)
+		pr.class(s)
+	case *ast.ImportStatement:
+		pr.write(s.String())
+	case *ast.ExportStatement:
+		pr.export(s)
+	case *ast.BlockStatement:
+		pr.block(s)
+	default:
+		pr.write(s.String())
+	}
+}
+
+// export prints an export statement. The doc comment of an exported declaration goes above the export rather than
+// between it and the declaration.
+func (pr *printer) export(es *ast.ExportStatement) {
+	var doc string
+	decl := es.Declaration
+	switch d := decl.(type) {
+	case nil:
+		pr.write(es.String())
+		return
+	case *ast.LetStatement:
+		undocumented := *d
+		doc, undocumented.Doc = d.Doc, ""
+		decl = &undocumented
+	case *ast.FunctionStatement:
+		undocumented := *d
+		doc, undocumented.Doc = d.Doc, ""
+		decl = &undocumented
+	case *ast.ClassStatement:
+		undocumented := *d
+		doc, undocumented.Doc = d.Doc, ""
+		decl = &undocumented
+	}
+
+	pr.doc(doc)
+	pr.write("export ")
+	pr.statement(decl)
+}
+
+// class prints a class declaration: its fields one per line, then its methods, each set apart by a blank line.
+func (pr *printer) class(cs *ast.ClassStatement) {
+	pr.write("class " + cs.Name.Value + " {")
+	if len(cs.Fields) == 0 && len(cs.Methods) == 0 {
+		pr.write("}")
+		return
+	}
+
+	pr.depth++
+	for i, field := range cs.Fields {
+		pr.newline()
+		pr.write(field.Value)
+		if i < len(cs.Defaults) && cs.Defaults[i] != nil {
+			pr.write(" = ")
+			pr.expression(cs.Defaults[i])
+		}
+		pr.write(";")
+	}
+	for i, method := range cs.Methods {
+		if i > 0 || len(cs.Fields) > 0 {
+			pr.write("\n")
+		}
+		pr.newline()
+		pr.statement(method)
+	}
+	pr.depth--
+	pr.newline()
+	pr.write("}")
+}
+
+// doc prints the doc comment of a declaration, one /// line per line of it, above the declaration.
+func (pr *printer) doc(doc string) {
+	if doc == "" {
+		return
+	}
+
+	for _, line := range strings.Split(doc, "\n") {
+		if line == "" {
+			pr.write("///")
+		} else {
+			pr.write("/// " + line)
+		}
+		pr.newline()
+	}
+}
+
+// block prints a brace delimited block with each of its statements on its own, indented line. An empty block is
+// printed as {}.
+func (pr *printer) block(b *ast.BlockStatement) {
+	if b == nil || len(b.Statements) == 0 {
+		pr.write("{}")
+		return
+	}
+
+	pr.write("{")
+	pr.depth++
+	for _, s := range b.Statements {
+		pr.newline()
+		pr.statement(s)
+	}
+	pr.depth--
+	pr.newline()
+	pr.write("}")
+}
+
+func (pr *printer) expression(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		pr.write(e.Value)
+	case *ast.IntegerLiteral:
+		pr.write(e.Token.Literal)
+	case *ast.StringLiteral:
+		pr.write("\"" + e.Value + "\"")
+	case *ast.Boolean:
+		pr.write(e.Token.Literal)
+	case *ast.PrefixExpression:
+		_, infix := e.Right.(*ast.InfixExpression)
+		pr.write(e.Operator)
+		pr.operand(e.Right, infix)
+	case *ast.InfixExpression:
+		precedence := parser.Precedence(e.Token.Type)
+		pr.operand(e.Left, bindsLooser(e.Left, precedence, false) || endsInShortFunction(e.Left))
+		pr.write(" " + e.Operator + " ")
+		pr.operand(e.Right, bindsLooser(e.Right, precedence, true))
+	case *ast.IfExpression:
+		pr.write("if (")
+		pr.expression(e.Condition)
+		pr.write(") ")
+		pr.block(e.Consequence)
+		if e.Alternative != nil {
+			pr.write(" else ")
+			pr.block(e.Alternative)
+		}
+	case *ast.TryExpression:
+		pr.write("try ")
+		pr.block(e.Body)
+		pr.write(" catch (" + e.Param.Value + ") ")
+		pr.block(e.Catch)
+	case *ast.SpawnExpression:
+		pr.write("spawn ")
+		pr.operand(e.Function, needsParensAsOperand(e.Function))
+	case *ast.FunctionLiteral:
+		if body := e.ShortBody(); body != nil {
+			pr.write("|")
+			pr.parameters(e)
+			pr.write("| ")
+			pr.expression(body)
+			return
+		}
+		pr.write("fn")
+		pr.function(e)
+	case *ast.CallExpression:
+		if e.Piped() {
+			pr.pipe(e)
+			return
+		}
+		pr.operand(e.Function, needsParensAsOperand(e.Function))
+		pr.write("(")
+		pr.list(e.Arguments)
+		pr.write(")")
+	case *ast.ArrayLiteral:
+		pr.write("[")
+		pr.list(e.Elements)
+		pr.write("]")
+	case *ast.SpreadExpression:
+		pr.write("...")
+		pr.expression(e.Value)
+	case *ast.ImportExpression:
+		pr.write("import \"" + e.Path.Value + "\"")
+	case *ast.IndexExpression:
+		pr.operand(e.Left, needsParensAsOperand(e.Left))
+		if e.Optional {
+			pr.write("?")
+		}
+		pr.write("[")
+		pr.expression(e.Index)
+		pr.write("]")
+	case *ast.DotExpression:
+		pr.operand(e.Left, needsParensAsOperand(e.Left))
+		pr.write("." + e.Name.Value)
+	case *ast.SliceExpression:
+		pr.operand(e.Left, needsParensAsOperand(e.Left))
+		if e.Optional {
+			pr.write("?")
+		}
+		pr.write("[")
+		if e.Low != nil {
+			pr.expression(e.Low)
+		}
+		pr.write(":")
+		if e.High != nil {
+			pr.expression(e.High)
+		}
+		pr.write("]")
+	case *ast.HashLiteral:
+		pr.write("{")
+		for i, key := range e.OrderedKeys() {
+			if i > 0 {
+				pr.write(", ")
+			}
+			pr.expression(key)
+			pr.write(": ")
+			pr.expression(e.Pairs[key])
+		}
+		pr.write("}")
+	case nil:
+	default:
+		pr.write(e.String())
+	}
+}
+
+// function prints the parameter list and body of a function literal, everything after fn or the function's name.
+func (pr *printer) function(fl *ast.FunctionLiteral) {
+	pr.write("(")
+	pr.parameters(fl)
+	pr.write(") ")
+	pr.block(fl.Body)
+}
+
+// parameters prints a function's parameter list without the parentheses or pipes around it.
+func (pr *printer) parameters(fl *ast.FunctionLiteral) {
+	for i, p := range fl.Parameters {
+		if i > 0 {
+			pr.write(", ")
+		}
+		pr.write(p.Value)
+		if i < len(fl.Defaults) && fl.Defaults[i] != nil {
+			pr.write(" = ")
+			pr.expression(fl.Defaults[i])
+		}
+	}
+	if fl.Rest != nil {
+		if len(fl.Parameters) > 0 {
+			pr.write(", ")
+		}
+		pr.write("..." + fl.Rest.Value)
+	}
+}
+
+func (pr *printer) list(exps []ast.Expression) {
+	for i, e := range exps {
+		if i > 0 {
+			pr.write(", ")
+		}
+		pr.expression(e)
+	}
+}
+
+// pipe prints a call written as a pipe the way it was written, value |> f or value |> f(args).
+func (pr *printer) pipe(e *ast.CallExpression) {
+	pr.operand(e.Arguments[0], bindsLooser(e.Arguments[0], parser.PIPELINE, false) || endsInShortFunction(e.Arguments[0]))
+	pr.write(" |> ")
+	if !e.Rparen.IsValid() {
+		pr.operand(e.Function, bindsLooser(e.Function, parser.PIPELINE, true))
+		return
+	}
+	pr.operand(e.Function, needsParensAsOperand(e.Function))
+	pr.write("(")
+	pr.list(e.Arguments[1:])
+	pr.write(")")
+}
+
+func (pr *printer) operand(e ast.Expression, parens bool) {
+	if parens {
+		pr.write("(")
+	}
+	pr.expression(e)
+	if parens {
+		pr.write(")")
+	}
+}
+
+// needsParensAsOperand reports whether e has to be wrapped in parentheses when it is the thing being called or indexed.
+// Calls and index expressions bind tighter than every prefix and infix operator.
+func needsParensAsOperand(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.InfixExpression, *ast.PrefixExpression:
+		return true
+	case *ast.CallExpression:
+		return e.Piped()
+	}
+
+	return endsInShortFunction(e)
+}
+
+// endsInShortFunction reports whether e is, or ends with, a function in the short form. Its body would take in anything
+// printed after it, so it has to be wrapped in parentheses to stay a left operand or to be called.
+func endsInShortFunction(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.FunctionLiteral:
+		return e.ShortBody() != nil
+	case *ast.InfixExpression:
+		return endsInShortFunction(e.Right)
+	case *ast.PrefixExpression:
+		return endsInShortFunction(e.Right)
+	case *ast.CallExpression:
+		return e.Piped() && !e.Rparen.IsValid() && endsInShortFunction(e.Function)
+	}
+
+	return false
+}
+
+// bindsLooser reports whether e has to be wrapped in parentheses to stay an operand of an infix operator with the given
+// precedence. All infix operators are left associative, so an equal precedence only needs parentheses on the right.
+func bindsLooser(e ast.Expression, precedence int, right bool) bool {
+	var own int
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		own = parser.Precedence(e.Token.Type)
+	case *ast.CallExpression:
+		if !e.Piped() {
+			return false
+		}
+		own = parser.PIPELINE
+	default:
+		return false
+	}
+
+	if right {
+		return own <= precedence
+	}
+
+	return own < precedence
+}
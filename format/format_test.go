@@ -0,0 +1,152 @@
+package format
+
+import (
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let x=5",
+			"let x = 5;\n",
+		},
+		{
+			"return   x",
+			"return x;\n",
+		},
+		{
+			"1+2*3; (1+2)*3; 1-(2-3); (1-2)-3",
+			"1 + 2 * 3;\n(1 + 2) * 3;\n1 - (2 - 3);\n1 - 2 - 3;\n",
+		},
+		{
+			"-(a+b); !-a; (-a)[0]; -a[0]",
+			"-(a + b);\n!-a;\n(-a)[0];\n-a[0];\n",
+		},
+		{
+			"+a; -+a; a - -b; a + +b",
+			"+a;\n-+a;\na - -b;\na + +b;\n",
+		},
+		{
+			`import "a.sloth"as a ;from "b.sloth" import  x,y`,
+			"import \"a.sloth\" as a;\nfrom \"b.sloth\" import x, y;\n",
+		},
+		{
+			"/// Doubles.\nexport   fn double(x){x*2}\nexport{double,y}",
+			"/// Doubles.\nexport fn double(x) {\n  x * 2;\n}\n\nexport { double, y };\n",
+		},
+		{
+			`let m=import   "lib.sloth"`,
+			"let m = import \"lib.sloth\";\n",
+		},
+		{
+			"f( ...xs ,1); [ 0,... a+b ]",
+			"f(...xs, 1);\n[0, ...a + b];\n",
+		},
+		{
+			`{"b":1,"a":[1,2]}`,
+			"{\"b\": 1, \"a\": [1, 2]};\n",
+		},
+		{
+			"if (x) { 1 } else { 2 }",
+			"if (x) {\n  1;\n} else {\n  2;\n}\n",
+		},
+		{
+			"let f = fn(){}; f()",
+			"let f = fn() {};\nf();\n",
+		},
+		{
+			"let add = fn(a, b) { return a + b; }; add(1, 2)",
+			"let add = fn(a, b) {\n  return a + b;\n};\n\nadd(1, 2);\n",
+		},
+		{
+			"let f = fn(a,b=1+2,...rest){}",
+			"let f = fn(a, b = 1 + 2, ...rest) {};\n",
+		},
+		{
+			"fn add(a,b){a+b}; add(1,2)",
+			"fn add(a, b) {\n  a + b;\n}\n\nadd(1, 2);\n",
+		},
+		{
+			"a[1:2]; a[ : n+1 ]; a[1:]; a?[:]",
+			"a[1:2];\na[:n + 1];\na[1:];\na?[:];\n",
+		},
+		{
+			"let r = try{f()}catch(e){e[\"message\"]}; try { g() } catch (e) {}",
+			"let r = try {\n  f();\n} catch (e) {\n  e[\"message\"];\n};\n\ntry {\n  g();\n} catch (e) {}\n",
+		},
+		{
+			"spawn  worker(1); spawn fn(){ x }",
+			"spawn worker(1);\n\nspawn fn() {\n  x;\n};\n",
+		},
+		{
+			"///  Adds.\n///\nfn add(a,b){a+b}\n/// The answer.\nconst answer=42; /// dropped\nputs(answer)",
+			"///  Adds.\n///\nfn add(a, b) {\n  a + b;\n}\n\n/// The answer.\nconst answer = 42;\n\nputs(answer);\n",
+		},
+		{
+			"throw  \"oops\"",
+			"throw \"oops\";\n",
+		},
+		{
+			"map(xs,|x|x*2); let add=|a,b=1|a+b",
+			"map(xs, |x| x * 2);\nlet add = |a, b = 1| a + b;\n",
+		},
+		{
+			"(|x| x)(1); (|x| x) + 1; 1 + |x| x; (a + |x| x)[0]",
+			"(|x| x)(1);\n(|x| x) + 1;\n1 + |x| x;\n(a + |x| x)[0];\n",
+		},
+		{
+			"p . name; p.greet( 1 ).x; (-p).x; a.b[0]",
+			"p.name;\np.greet(1).x;\n(-p).x;\na.b[0];\n",
+		},
+		{
+			"/// A point.\nclass Point{x;y=0;fn norm(){self.x*self.x} fn zero(){Point(0)}}; class Empty{}",
+			"/// A point.\nclass Point {\n  x;\n  y = 0;\n\n  fn norm() {\n    self.x * self.x;\n  }\n\n  fn zero() {\n    Point(0);\n  }\n}\n\nclass Empty {}\n",
+		},
+		{
+			"f>>g; x |> (f >> g); (x |> f) >> g",
+			"f >> g;\nx |> f >> g;\n(x |> f) >> g;\n",
+		},
+		{
+			"x|>f; x |> f(1,2) |> g; (a==b) |> f; x |> (y |> f); (x |> f)(1); (x |> f) + 1; x |> |y| y + 1",
+			"x |> f;\nx |> f(1, 2) |> g;\n(a == b) |> f;\nx |> (y |> f);\n(x |> f)(1);\n(x |> f) + 1;\nx |> |y| y + 1;\n",
+		},
+		{
+			"map(arr, fn(x) { if (x > 1) { x } })",
+			"map(arr, fn(x) {\n  if (x > 1) {\n    x;\n  }\n});\n",
+		},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Fatalf("Source(%q) returned error: %s", tt.input, err)
+		}
+
+		if string(formatted) != tt.expected {
+			t.Errorf("Source(%q) wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, formatted)
+		}
+
+		again, err := Source(formatted)
+		if err != nil {
+			t.Fatalf("Source(%q) returned error: %s", formatted, err)
+		}
+
+		if string(again) != string(formatted) {
+			t.Errorf("Source is not idempotent for %q. got=%q", formatted, again)
+		}
+	}
+}
+
+func TestSourceParseError(t *testing.T) {
+	formatted, err := Source([]byte("let = 5"))
+	if err == nil {
+		t.Fatalf("expected an error, got formatted=%q", formatted)
+	}
+
+	if formatted != nil {
+		t.Errorf("expected no output for unparseable source, got=%q", formatted)
+	}
+}
@@ -1,26 +1,98 @@
 package lexer
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
 	"github.com/sean-d/sloth/token"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+/*
+Lexer reads its input through a bufio.Reader, one character at a time, so it never needs more of the input in memory
+than the token it is working on plus a few characters of lookahead. That is what lets NewReader tokenize large files
+or network streams as they arrive; New is the same thing over a string.
+*/
 type Lexer struct {
-	input        string
-	position     int  // current position in input (points to current char)
-	readPosition int  // current reading position in input (after current char)
-	ch           byte // current char under examination
+	input     *bufio.Reader
+	err       error    // the first error reading input failed with, other than io.EOF
+	errors    []*Error // one for every ILLEGAL token returned so far
+	position  int      // current position in input (points to current char)
+	ch        byte     // current char under examination
+	line      int      // line of the current char, starting at 1
+	lineStart int      // position of the first char on the current line
 }
 
 // New returns a pointer to a Lexer that is instantiated with the possible inputs
-// The new Lexer has an input with the rest being 0.
 // readChar() is called to have ch represent the first char in the Lexer.
+// A leading shebang line (#!/usr/bin/env sloth) is skipped so scripts can be made executable directly.
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewReader(strings.NewReader(input))
+}
+
+// NewReader returns a Lexer that reads its input from r as tokens are asked for, rather than all at once. If reading
+// from r fails, the lexer treats it as the end of the input and Err reports why.
+func NewReader(r io.Reader) *Lexer {
+	l := &Lexer{input: bufio.NewReader(r), position: -1, line: 1}
 	l.readChar()
+	l.skipShebang()
 
 	return l
 }
 
+// Err returns the error reading the input failed with, or nil if the input was read without trouble.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+// Error explains what is wrong with an ILLEGAL token. Pos is the position of the token it belongs to.
+type Error struct {
+	Pos     token.Position
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+// Errors returns an Error for every ILLEGAL token returned so far, in the order the tokens were returned.
+func (l *Lexer) Errors() []*Error {
+	return l.errors
+}
+
+// illegal records why the ILLEGAL token at pos is illegal.
+func (l *Lexer) illegal(pos token.Position, format string, a ...any) {
+	l.errors = append(l.errors, &Error{Pos: pos, Message: fmt.Sprintf(format, a...)})
+}
+
+/*
+unexpected returns an ILLEGAL token for the character at pos, which sloth has no use for. Characters outside ASCII
+take more than one byte; all of them are read so that the token holds the whole character and the error can name it.
+*/
+func (l *Lexer) unexpected(pos token.Position) token.Token {
+	char := []byte{l.ch}
+	for l.ch >= utf8.RuneSelf && len(char) < utf8.UTFMax && !utf8.FullRune(char) {
+		next := l.peekChar()
+		if next == 0 || utf8.RuneStart(next) {
+			break
+		}
+		l.readChar()
+		char = append(char, l.ch)
+	}
+
+	r, _ := utf8.DecodeRune(char)
+	if r < utf8.RuneSelf && unicode.IsPrint(r) {
+		l.illegal(pos, "unexpected character %q", r)
+	} else {
+		l.illegal(pos, "unexpected character %U", r)
+	}
+
+	return token.Token{Type: token.ILLEGAL, Literal: string(char)}
+}
+
 // NextToken works as follows:
 // We look at the current character under examination (l.ch) and return a token depending on which character it is.
 // Before returning the token we advance our pointers into the input so when we call NextToken() again the l.ch field is already updated.
@@ -29,24 +101,38 @@ func New(input string) *Lexer {
 // We identify if a character is a letter and if so, it needs to keep reading until a non-letter occurs. This signifies the end
 // of a keyword or identifier and then we sort our if what was just read is a keyword or identifier so the correct token type is used.
 //
-// We early exit in default: when calling readIdentifier/readNumber, we call readChar repeatedly and advances the readPosition/position fields
+// We early exit in default: when calling readIdentifier/readNumber, we call readChar repeatedly and advances the position field
 // beyond the last character of the current identifier. Because of this, we don't need to call readChar() after the switch again.
-// If we wind up at the token.ILLEGAL we have something we have no idea what to do with.
+// If we wind up at the token.ILLEGAL we have something we have no idea what to do with. Every ILLEGAL token comes with
+// an Error saying what was wrong with it, see Errors.
 //
 // There are special cases with double-characters. As more 2-char literals become special, We will eventually use a helper function to determine if the char following a specific
 // char is what we desire and if so, we build a string from them both and return a token.Token with that string and associated
 // token type.
 //
 // A small function called newToken helps us with initializing these tokens.
+//
+// Every token is stamped with the position of its first character, which is noted before the switch; the branches
+// only fill in the type and literal.
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
 
+	pos := token.Position{Offset: l.position, Line: l.line, Column: l.position - l.lineStart + 1}
+
+	if op, ok := l.readOperator(); ok {
+		return token.Token{Type: token.TokenType(op), Literal: op, Pos: pos}
+	}
+
 	switch l.ch {
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
+		if l.ch == 0 {
+			tok = token.Token{Type: token.ILLEGAL, Literal: `"` + tok.Literal}
+			l.illegal(pos, "unterminated string literal starting at line %d", pos.Line)
+		}
 	case '=':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -69,20 +155,56 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.BANG, l.ch)
 		}
+	case '?':
+		switch l.peekChar() {
+		case '?':
+			l.readChar()
+			tok = token.Token{Type: token.NULLISH, Literal: "??"}
+		case '[':
+			l.readChar()
+			tok = token.Token{Type: token.OPTIONAL_LBRACKET, Literal: "?["}
+		default:
+			tok = l.unexpected(pos)
+		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		if l.peekChar() == '/' && l.peekCharAt(2) == '/' {
+			tok.Type = token.DOC_COMMENT
+			tok.Literal = l.readDocComment()
+		} else {
+			tok = newToken(token.SLASH, l.ch)
+		}
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
 	case '<':
 		tok = newToken(token.LT, l.ch)
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.COMPOSE, Literal: ">>"}
+		} else {
+			tok = newToken(token.GT, l.ch)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '|':
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.PIPELINE, Literal: "|>"}
+		} else {
+			tok = newToken(token.PIPE, l.ch)
+		}
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(2) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case '{':
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
@@ -102,21 +224,55 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos = pos
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Type = token.INT
 			tok.Literal = l.readNumber()
+			tok.Pos = pos
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch)
+			tok = l.unexpected(pos)
 		}
 	}
 
 	l.readChar()
+	tok.Pos = pos
 
 	return tok
 }
 
+// readOperator reads the longest operator registered with token.RegisterOperator that the input continues with, if
+// there is one. Nothing is read otherwise.
+func (l *Lexer) readOperator() (string, bool) {
+	for _, op := range token.Operators() {
+		if !l.continuesWith(op) {
+			continue
+		}
+
+		for range op {
+			l.readChar()
+		}
+		return op, true
+	}
+
+	return "", false
+}
+
+// continuesWith reports whether the input from the current character on starts with s.
+func (l *Lexer) continuesWith(s string) bool {
+	if l.ch != s[0] {
+		return false
+	}
+
+	for i := 1; i < len(s); i++ {
+		if l.peekCharAt(i) != s[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // skipWhitespace will determine if the current character is a space, a newline, a tab, or a return
 // and call readChar to get the next character.
 //
@@ -127,64 +283,116 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-// readChar provides the next character and advances the position in the input string.
-// 1. checks if the end of input has been reached
-// 1a. if so, l.ch gets set to 0 and signals nothing has been read or EOF
-// 1b. if EOF is not true, l.ch gets set to the next char by accessing l.input[l.readPosition]
+// skipShebang advances past the first line of the input when it starts with #!. It only ever applies to the very
+// start of the input; a #! anywhere else is still an ILLEGAL token.
+func (l *Lexer) skipShebang() {
+	if l.position != 0 || l.ch != '#' || l.peekChar() != '!' {
+		return
+	}
+
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+}
+
+// readChar provides the next character and advances the position in the input.
+// 1. reads the next byte from the input
+// 1a. at the end of input, or if reading failed, l.ch gets set to 0 and signals nothing has been read or EOF
+// 1b. otherwise l.ch gets set to the byte read
+//
+// 2. l.position is incremented by one, so it always points to the position of l.ch in the input.
 //
-// 2. l.position is updated to the just used l.readPosition and l.readPosition is incremented by one.
-// This way, l.readPosition will always point to the next position that will be read from
-// and l.position always points to the position last read.
+// Stepping past a newline moves on to the next line, which is how the lexer knows the line and column of each token.
 //
 // We are only supporting ASCII to keep thing simple
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
-		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+	if l.ch == '\n' {
+		l.line++
+		l.lineStart = l.position + 1
+	}
+
+	ch, err := l.input.ReadByte()
+	if err != nil {
+		if !errors.Is(err, io.EOF) && l.err == nil {
+			l.err = err
+		}
+		ch = 0
 	}
-	l.position = l.readPosition
-	l.readPosition += 1
+
+	l.ch = ch
+	l.position++
 }
 
-// peekChar is really similar to readChar, except that it doesn’t increment l.position and l.readPosition.
+// peekChar is really similar to readChar, except that it doesn’t move l.position.
 // We only want to “peek” ahead in the input and not move around in it, so we know what a call to readChar() would return.
 func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
+	return l.peekCharAt(1)
+}
+
+// peekCharAt looks offset characters ahead of the current one without moving. peekCharAt(1) is peekChar().
+func (l *Lexer) peekCharAt(offset int) byte {
+	if l.err != nil {
+		return 0
+	}
+
+	ahead, err := l.input.Peek(offset)
+	if err != nil || len(ahead) < offset {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+	return ahead[offset-1]
 }
 
-// readIdentifier reads in an identifier and advances the lexer position until it encounters a non-letter character
+// readIdentifier reads in an identifier and advances the lexer position until it encounters a character that is
+// neither a letter nor a digit. Identifiers start with a letter, which the caller has checked, so 2x is still a
+// number followed by a name.
 func (l *Lexer) readIdentifier() string {
-	position := l.position
-	for isLetter(l.ch) {
+	var out strings.Builder
+	for isLetter(l.ch) || isDigit(l.ch) {
+		out.WriteByte(l.ch)
 		l.readChar()
 	}
-	return l.input[position:l.position]
+
+	return out.String()
 }
 
 // readNumber only takes in ints. we are not worrying about any other numbers. who cares :)
 func (l *Lexer) readNumber() string {
-	position := l.position
+	var out strings.Builder
 	for isDigit(l.ch) {
+		out.WriteByte(l.ch)
 		l.readChar()
 	}
-	return l.input[position:l.position]
+	return out.String()
 }
 
 // readString calls readChar until it encounters either a closing double quote or the end of the input.
 func (l *Lexer) readString() string {
-	position := l.position + 1
+	var out strings.Builder
 	for {
 		l.readChar()
 		if l.ch == '"' || l.ch == 0 {
 			break
 		}
+		out.WriteByte(l.ch)
+	}
+	return out.String()
+}
+
+// readDocComment reads a /// comment up to the end of its line and returns its text. The slashes and a single space
+// following them are not part of the text.
+func (l *Lexer) readDocComment() string {
+	l.readChar()
+	l.readChar()
+	if l.peekChar() == ' ' {
+		l.readChar()
+	}
+
+	var out strings.Builder
+	for l.peekChar() != '\n' && l.peekChar() != 0 {
+		l.readChar()
+		out.WriteByte(l.ch)
 	}
-	return l.input[position:l.position]
+	return out.String()
 }
 
 // isLetter returns true if the passed in character is a->z or A->Z or is a underscore.
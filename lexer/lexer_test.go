@@ -1,8 +1,12 @@
 package lexer
 
 import (
+	"errors"
 	"github.com/sean-d/sloth/token"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestNextToken(t *testing.T) {
@@ -175,4 +179,313 @@ if (5 < 10) {
 		}
 
 	})
+	t.Run("Shebang Test", func(t *testing.T) {
+		input := "#!/usr/bin/env sloth\nlet x = 5;"
+
+		tests := []struct {
+			expectedType    token.TokenType
+			expectedLiteral string
+		}{
+			{token.LET, "let"},
+			{token.IDENT, "x"},
+			{token.ASSIGN, "="},
+			{token.INT, "5"},
+			{token.SEMICOLON, ";"},
+			{token.EOF, ""},
+		}
+
+		l := New(input)
+
+		for i, tt := range tests {
+			tok := l.NextToken()
+
+			if tok.Type != tt.expectedType {
+				t.Fatalf("test[%d] - token type wrong. got %q wanted %q", i, tok.Type, tt.expectedType)
+			}
+
+			if tok.Literal != tt.expectedLiteral {
+				t.Fatalf("test[%d] - literal wrong. got %q wanted %q", i, tok.Literal, tt.expectedLiteral)
+			}
+		}
+	})
+	t.Run("Null Safe Operators Test", func(t *testing.T) {
+		input := `a ?? b; h?["k"] |> f >> g`
+
+		tests := []struct {
+			expectedType    token.TokenType
+			expectedLiteral string
+		}{
+			{token.IDENT, "a"},
+			{token.NULLISH, "??"},
+			{token.IDENT, "b"},
+			{token.SEMICOLON, ";"},
+			{token.IDENT, "h"},
+			{token.OPTIONAL_LBRACKET, "?["},
+			{token.STRING, "k"},
+			{token.RBRACKET, "]"},
+			{token.PIPELINE, "|>"},
+			{token.IDENT, "f"},
+			{token.COMPOSE, ">>"},
+			{token.IDENT, "g"},
+			{token.EOF, ""},
+		}
+
+		l := New(input)
+
+		for i, tt := range tests {
+			tok := l.NextToken()
+
+			if tok.Type != tt.expectedType {
+				t.Fatalf("test[%d] - token type wrong. got %q wanted %q", i, tok.Type, tt.expectedType)
+			}
+
+			if tok.Literal != tt.expectedLiteral {
+				t.Fatalf("test[%d] - literal wrong. got %q wanted %q", i, tok.Literal, tt.expectedLiteral)
+			}
+		}
+	})
+	t.Run("Ellipsis Test", func(t *testing.T) {
+		input := `fn(...rest) ..`
+
+		tests := []struct {
+			expectedType    token.TokenType
+			expectedLiteral string
+		}{
+			{token.FUNCTION, "fn"},
+			{token.LPAREN, "("},
+			{token.ELLIPSIS, "..."},
+			{token.IDENT, "rest"},
+			{token.RPAREN, ")"},
+			{token.DOT, "."},
+			{token.DOT, "."},
+			{token.EOF, ""},
+		}
+
+		l := New(input)
+
+		for i, tt := range tests {
+			tok := l.NextToken()
+
+			if tok.Type != tt.expectedType {
+				t.Fatalf("test[%d] - token type wrong. got %q wanted %q", i, tok.Type, tt.expectedType)
+			}
+
+			if tok.Literal != tt.expectedLiteral {
+				t.Fatalf("test[%d] - literal wrong. got %q wanted %q", i, tok.Literal, tt.expectedLiteral)
+			}
+		}
+	})
+
+	t.Run("Doc Comment Test", func(t *testing.T) {
+		input := "/// Adds things.\n///\n///  indented\nlet a = 1 / 2;///last"
+
+		tests := []struct {
+			expectedType    token.TokenType
+			expectedLiteral string
+		}{
+			{token.DOC_COMMENT, "Adds things."},
+			{token.DOC_COMMENT, ""},
+			{token.DOC_COMMENT, " indented"},
+			{token.LET, "let"},
+			{token.IDENT, "a"},
+			{token.ASSIGN, "="},
+			{token.INT, "1"},
+			{token.SLASH, "/"},
+			{token.INT, "2"},
+			{token.SEMICOLON, ";"},
+			{token.DOC_COMMENT, "last"},
+			{token.EOF, ""},
+		}
+
+		l := New(input)
+
+		for i, tt := range tests {
+			tok := l.NextToken()
+
+			if tok.Type != tt.expectedType {
+				t.Fatalf("test[%d] - token type wrong. got %q wanted %q", i, tok.Type, tt.expectedType)
+			}
+
+			if tok.Literal != tt.expectedLiteral {
+				t.Fatalf("test[%d] - literal wrong. got %q wanted %q", i, tok.Literal, tt.expectedLiteral)
+			}
+		}
+	})
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "#!/usr/bin/env sloth\nlet x = 5;\n  puts(\"hi\")\n\n}"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedPos     token.Position
+	}{
+		{"let", token.Position{Offset: 21, Line: 2, Column: 1}},
+		{"x", token.Position{Offset: 25, Line: 2, Column: 5}},
+		{"=", token.Position{Offset: 27, Line: 2, Column: 7}},
+		{"5", token.Position{Offset: 29, Line: 2, Column: 9}},
+		{";", token.Position{Offset: 30, Line: 2, Column: 10}},
+		{"puts", token.Position{Offset: 34, Line: 3, Column: 3}},
+		{"(", token.Position{Offset: 38, Line: 3, Column: 7}},
+		{"hi", token.Position{Offset: 39, Line: 3, Column: 8}},
+		{")", token.Position{Offset: 43, Line: 3, Column: 12}},
+		{"}", token.Position{Offset: 46, Line: 5, Column: 1}},
+		{"", token.Position{Offset: 47, Line: 5, Column: 2}},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - literal wrong. got %q wanted %q", i, tok.Literal, tt.expectedLiteral)
+		}
+
+		if tok.Pos != tt.expectedPos {
+			t.Fatalf("test[%d] - position of %q wrong. got %+v wanted %+v", i, tok.Literal, tok.Pos, tt.expectedPos)
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	input := "#!/usr/bin/env sloth\n/// doc\nlet five = 5;\nlet s = \"a\nb\";\nfn(x, ...rest) { x ?? rest?[0] } // 3 == 4"
+
+	expected := New(input)
+	l := NewReader(iotest.OneByteReader(strings.NewReader(input)))
+
+	for i := 0; ; i++ {
+		want := expected.NextToken()
+		got := l.NextToken()
+
+		if got != want {
+			t.Fatalf("test[%d] - token wrong. got %+v wanted %+v", i, got, want)
+		}
+		if got.Type == token.EOF {
+			break
+		}
+	}
+
+	if l.Err() != nil {
+		t.Errorf("unexpected error: %s", l.Err())
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	failure := errors.New("connection reset")
+	l := NewReader(io.MultiReader(strings.NewReader("let x"), iotest.ErrReader(failure)))
+
+	for _, expected := range []token.TokenType{token.LET, token.IDENT, token.EOF} {
+		if tok := l.NextToken(); tok.Type != expected {
+			t.Fatalf("token type wrong. got %q wanted %q", tok.Type, expected)
+		}
+	}
+
+	if !errors.Is(l.Err(), failure) {
+		t.Errorf("Err() wrong. got %v wanted %v", l.Err(), failure)
+	}
+}
+
+func TestIllegalTokenErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+		expectedMessage string
+		expectedPos     token.Position
+	}{
+		{"let x = #;", "#", "unexpected character '#'", token.Position{Offset: 8, Line: 1, Column: 9}},
+		{"a ? b", "?", "unexpected character '?'", token.Position{Offset: 2, Line: 1, Column: 3}},
+		{"a~b", "~", "unexpected character '~'", token.Position{Offset: 1, Line: 1, Column: 2}},
+		{"let café = 1;", "é", "unexpected character U+00E9", token.Position{Offset: 7, Line: 1, Column: 8}},
+		{"x\n\x01", "\x01", "unexpected character U+0001", token.Position{Offset: 2, Line: 2, Column: 1}},
+		{"puts(1);\n\"abc\ndef", "\"abc\ndef", "unterminated string literal starting at line 2", token.Position{Offset: 9, Line: 2, Column: 1}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+
+		var tok token.Token
+		for tok = l.NextToken(); tok.Type != token.ILLEGAL && tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		if tok.Type != token.ILLEGAL {
+			t.Errorf("no ILLEGAL token for %q", tt.input)
+			continue
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("literal wrong for %q. got %q wanted %q", tt.input, tok.Literal, tt.expectedLiteral)
+		}
+
+		errs := l.Errors()
+		if len(errs) != 1 {
+			t.Errorf("wrong number of errors for %q. got %d wanted 1", tt.input, len(errs))
+			continue
+		}
+		if errs[0].Message != tt.expectedMessage {
+			t.Errorf("message wrong for %q. got %q wanted %q", tt.input, errs[0].Message, tt.expectedMessage)
+		}
+		if errs[0].Pos != tt.expectedPos || tok.Pos != tt.expectedPos {
+			t.Errorf("position wrong for %q. got error at %+v, token at %+v, wanted %+v", tt.input, errs[0].Pos, tok.Pos, tt.expectedPos)
+		}
+	}
+}
+
+func TestRegisteredKeywords(t *testing.T) {
+	unless := token.RegisterKeyword("unless")
+
+	l := New("unless (x) { unlessx }")
+	for _, expected := range []token.TokenType{unless, token.LPAREN, token.IDENT, token.RPAREN, token.LBRACE, token.IDENT, token.RBRACE, token.EOF} {
+		if tok := l.NextToken(); tok.Type != expected {
+			t.Fatalf("token type wrong for %q. got %q wanted %q", tok.Literal, tok.Type, expected)
+		}
+	}
+}
+
+func TestRegisteredOperators(t *testing.T) {
+	token.RegisterOperator("~>")
+	token.RegisterOperator("=>")
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"},
+		{"~>", "~>"},
+		{token.IDENT, "f"},
+		{"=>", "=>"},
+		{token.EQ, "=="},
+		{token.ASSIGN, "="},
+		{token.GT, ">"},
+		{token.PIPE, "|"},
+		{token.EOF, ""},
+	}
+
+	l := New("x ~> f => == = > |")
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - token wrong. got %q %q wanted %q %q", i, tok.Type, tok.Literal, tt.expectedType, tt.expectedLiteral)
+		}
+	}
+}
+
+func TestIdentifiersWithDigits(t *testing.T) {
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "_1"},
+		{token.IDENT, "x2y"},
+		{token.INT, "2"},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New("_1 x2y 2x")
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - token wrong. got %q %q wanted %q %q", i, tok.Type, tok.Literal, tt.expectedType, tt.expectedLiteral)
+		}
+	}
 }
@@ -0,0 +1,275 @@
+package lint
+
+import (
+	"fmt"
+	"github.com/sean-d/sloth/ast"
+	"github.com/sean-d/sloth/evaluator"
+	"strings"
+)
+
+// Kinds of issue the linter reports.
+const (
+	UNUSED          = "unused"
+	UNREACHABLE     = "unreachable"
+	SHADOW          = "shadow"
+	UNKNOWN_BUILTIN = "unknown-builtin"
+	CONST_REASSIGN  = "const-reassign"
+)
+
+// Issue is a single problem found in a program. Node is the statement or expression the issue is about.
+type Issue struct {
+	Kind    string
+	Message string
+	Node    ast.Node
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s", i.Kind, i.Message)
+}
+
+/*
+binding is a name introduced by a let statement, a function declaration, or a function parameter. decl is the
+statement that introduced it, or nil for parameters: only declared bindings are ever reported as unused, parameters
+are part of a function's signature and callers decide what to pass. function marks bindings whose value is a function.
+*/
+type binding struct {
+	name     string
+	decl     ast.Statement
+	function bool
+	constant bool
+	used     bool
+}
+
+/*
+scope mirrors an object.Environment. Only the program, function calls and catch blocks create environments in the
+evaluator; blocks of an if expression bind into whatever environment they are evaluated in, and so they don't get a
+scope of their own here either.
+*/
+type scope struct {
+	bindings map[string]*binding
+	outer    *scope
+	order    []*binding
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{bindings: make(map[string]*binding), outer: outer}
+}
+
+func (s *scope) lookup(name string) (*binding, bool) {
+	if b, ok := s.bindings[name]; ok {
+		return b, true
+	}
+	if s.outer != nil {
+		return s.outer.lookup(name)
+	}
+	return nil, false
+}
+
+/*
+linter walks a program once. Function bodies are not walked where they appear but queued in pending and walked once the
+scope they are defined in has been walked completely. That matches when they run: a function body only looks names up
+when it is called, by which point every binding of its enclosing scope, including the function itself, exists.
+*/
+type linter struct {
+	issues  []Issue
+	scopes  []*scope
+	pending []func()
+}
+
+// Program lints program and returns every issue found.
+func Program(program *ast.Program) []Issue {
+	l := &linter{}
+
+	global := l.newScope(nil)
+	l.statements(program.Statements, global)
+
+	for len(l.pending) > 0 {
+		next := l.pending[0]
+		l.pending = l.pending[1:]
+		next()
+	}
+
+	for _, s := range l.scopes {
+		for _, b := range s.order {
+			if b.used || b.decl == nil || strings.HasPrefix(b.name, "_") {
+				continue
+			}
+			// top level functions that are never called are a library's API, not a mistake
+			if b.function && s == global {
+				continue
+			}
+			l.report(UNUSED, b.decl, "%s is declared but never used", b.name)
+		}
+	}
+
+	return l.issues
+}
+
+func (l *linter) newScope(outer *scope) *scope {
+	s := newScope(outer)
+	l.scopes = append(l.scopes, s)
+	return s
+}
+
+func (l *linter) report(kind string, node ast.Node, format string, a ...interface{}) {
+	l.issues = append(l.issues, Issue{Kind: kind, Message: fmt.Sprintf(format, a...), Node: node})
+}
+
+// declare binds name in s, reporting it if it shadows a binding of an enclosing scope or rebinds a constant.
+func (l *linter) declare(s *scope, name string, node ast.Node, decl ast.Statement, function bool) {
+	if existing, ok := s.bindings[name]; ok && existing.constant {
+		l.report(CONST_REASSIGN, node, "cannot reassign constant %s", name)
+	}
+
+	if s.outer != nil {
+		if _, ok := s.outer.lookup(name); ok {
+			l.report(SHADOW, node, "%s shadows a binding from an enclosing scope", name)
+		}
+	}
+
+	b := &binding{name: name, decl: decl, function: function}
+	if let, ok := decl.(*ast.LetStatement); ok {
+		b.constant = let.IsConst()
+	}
+	s.bindings[name] = b
+	s.order = append(s.order, b)
+}
+
+// statements walks a list of statements, reporting anything that follows a return or a throw.
+func (l *linter) statements(statements []ast.Statement, s *scope) {
+	for i, statement := range statements {
+		l.statement(statement, s)
+
+		if i == len(statements)-1 {
+			continue
+		}
+		switch statement.(type) {
+		case *ast.ReturnStatement:
+			l.report(UNREACHABLE, statements[i+1], "unreachable code after return")
+			return
+		case *ast.ThrowStatement:
+			l.report(UNREACHABLE, statements[i+1], "unreachable code after throw")
+			return
+		}
+	}
+}
+
+func (l *linter) statement(statement ast.Statement, s *scope) {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		l.expression(statement.Value, s)
+		_, function := statement.Value.(*ast.FunctionLiteral)
+		l.declare(s, statement.Name.Value, statement, statement, function)
+	case *ast.FunctionStatement:
+		l.declare(s, statement.Name.Value, statement, statement, true)
+		l.expression(statement.Function, s)
+	case *ast.ClassStatement:
+		l.declare(s, statement.Name.Value, statement, statement, true)
+		l.expression(statement.Constructor(), s)
+	case *ast.ImportStatement:
+		if statement.Alias != nil {
+			l.declare(s, statement.Alias.Value, statement.Alias, statement, false)
+		}
+		for _, name := range statement.Names {
+			l.declare(s, name.Value, name, statement, false)
+		}
+	case *ast.ExportStatement:
+		if statement.Declaration != nil {
+			l.statement(statement.Declaration, s)
+		}
+		// exported names are used by whatever imports the file
+		for _, name := range statement.Exported() {
+			if b, ok := s.lookup(name.Value); ok {
+				b.used = true
+			}
+		}
+	case *ast.ReturnStatement:
+		l.expression(statement.ReturnValue, s)
+	case *ast.ThrowStatement:
+		l.expression(statement.Value, s)
+	case *ast.ExpressionStatement:
+		l.expression(statement.Expression, s)
+	case *ast.BlockStatement:
+		l.statements(statement.Statements, s)
+	}
+}
+
+func (l *linter) expression(exp ast.Expression, s *scope) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if b, ok := s.lookup(exp.Value); ok {
+			b.used = true
+		}
+	case *ast.PrefixExpression:
+		l.expression(exp.Right, s)
+	case *ast.InfixExpression:
+		l.expression(exp.Left, s)
+		l.expression(exp.Right, s)
+	case *ast.IfExpression:
+		l.expression(exp.Condition, s)
+		if exp.Consequence != nil {
+			l.statements(exp.Consequence.Statements, s)
+		}
+		if exp.Alternative != nil {
+			l.statements(exp.Alternative.Statements, s)
+		}
+	case *ast.TryExpression:
+		if exp.Body != nil {
+			l.statements(exp.Body.Statements, s)
+		}
+		// the catch block is evaluated in an environment of its own holding the catch parameter
+		catchScope := l.newScope(s)
+		l.declare(catchScope, exp.Param.Value, exp.Param, nil, false)
+		if exp.Catch != nil {
+			l.statements(exp.Catch.Statements, catchScope)
+		}
+	case *ast.SpawnExpression:
+		l.expression(exp.Function, s)
+	case *ast.FunctionLiteral:
+		l.pending = append(l.pending, func() {
+			fnScope := l.newScope(s)
+			for i, param := range exp.Parameters {
+				if i < len(exp.Defaults) && exp.Defaults[i] != nil {
+					l.expression(exp.Defaults[i], fnScope)
+				}
+				l.declare(fnScope, param.Value, param, nil, false)
+			}
+			if exp.Rest != nil {
+				l.declare(fnScope, exp.Rest.Value, exp.Rest, nil, false)
+			}
+			if exp.Body != nil {
+				l.statements(exp.Body.Statements, fnScope)
+			}
+		})
+	case *ast.CallExpression:
+		if ident, ok := exp.Function.(*ast.Identifier); ok {
+			if _, bound := s.lookup(ident.Value); !bound && !evaluator.IsBuiltin(ident.Value) {
+				l.report(UNKNOWN_BUILTIN, exp, "call to unknown function %s", ident.Value)
+			}
+		}
+		l.expression(exp.Function, s)
+		for _, arg := range exp.Arguments {
+			l.expression(arg, s)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			l.expression(el, s)
+		}
+	case *ast.SpreadExpression:
+		l.expression(exp.Value, s)
+	case *ast.IndexExpression:
+		l.expression(exp.Left, s)
+		l.expression(exp.Index, s)
+	case *ast.DotExpression:
+		l.expression(exp.Left, s)
+	case *ast.SliceExpression:
+		l.expression(exp.Left, s)
+		l.expression(exp.Low, s)
+		l.expression(exp.High, s)
+	case *ast.HashLiteral:
+		for _, key := range exp.OrderedKeys() {
+			l.expression(key, s)
+			l.expression(exp.Pairs[key], s)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package lint
+
+import (
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/parser"
+	"testing"
+)
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let x = 5; puts(x);",
+			[]string{},
+		},
+		{
+			"let x = 5;",
+			[]string{"unused: x is declared but never used"},
+		},
+		{
+			"let _x = 5; let lib = fn() { 1 };",
+			[]string{},
+		},
+		{
+			"let f = fn() { return 1; 2; }; f();",
+			[]string{"unreachable: unreachable code after return"},
+		},
+		{
+			"let x = 1; let f = fn(x) { x }; f(x);",
+			[]string{"shadow: x shadows a binding from an enclosing scope"},
+		},
+		{
+			"let x = 1; let f = fn() { let x = 2; x }; f(x);",
+			[]string{"shadow: x shadows a binding from an enclosing scope"},
+		},
+		{
+			"let x = try { 1 } catch (e) { y(e) }; puts(x);",
+			[]string{"unknown-builtin: call to unknown function y"},
+		},
+		{
+			"try { 1 } catch (e) { let m = e; }",
+			[]string{"unused: m is declared but never used"},
+		},
+		{
+			"let f = fn() { throw 1; 2; }; f();",
+			[]string{"unreachable: unreachable code after throw"},
+		},
+		{
+			"const x = 1; puts(x); let x = 2; puts(x);",
+			[]string{"const-reassign: cannot reassign constant x"},
+		},
+		{
+			`import "a.sloth" as a; from "b.sloth" import f, g; f(a);`,
+			[]string{"unused: g is declared but never used"},
+		},
+		{
+			"export let x = 1; let y = 2; let z = 3; export { y };",
+			[]string{"unused: z is declared but never used"},
+		},
+		{
+			"frobnicate(1); len([1]);",
+			[]string{"unknown-builtin: call to unknown function frobnicate"},
+		},
+		{
+			"let fact = fn(n) { if (n < 2) { return 1; } n * fact(n - 1) }; fact(5);",
+			[]string{},
+		},
+		{
+			"let a = fn() { b() }; let b = fn() { 1 }; a();",
+			[]string{},
+		},
+		{
+			"fn fact(n) { if (n < 2) { return 1; } n * fact(n - 1) } let f = fn() { fn helper() { 1 } 2 }; f();",
+			[]string{"unused: helper is declared but never used"},
+		},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+
+		issues := Program(program)
+		if len(issues) != len(tt.expected) {
+			t.Errorf("wrong number of issues for %q. got=%v, want=%v", tt.input, issues, tt.expected)
+			continue
+		}
+
+		for i, issue := range issues {
+			if issue.String() != tt.expected[i] {
+				t.Errorf("wrong issue for %q. got=%q, want=%q", tt.input, issue.String(), tt.expected[i])
+			}
+		}
+	}
+}
@@ -1,21 +1,180 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"github.com/sean-d/sloth/evaluator"
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/object"
+	"github.com/sean-d/sloth/optimize"
+	"github.com/sean-d/sloth/parser"
 	"github.com/sean-d/sloth/repl"
+	"github.com/sean-d/sloth/token"
+	"io"
 	"os"
 	"os/user"
+	"path/filepath"
+	"strings"
 )
 
+// commands maps subcommand names to their implementations. Each one is handed the arguments following its name and
+// returns the exit code for the process. Anything that isn't a subcommand is treated as a script to run.
+var commands = map[string]func(args []string) int{
+	"fmt":   fmtCommand,
+	"vet":   vetCommand,
+	"test":  testCommand,
+	"doc":   docCommand,
+	"ast":   astCommand,
+	"build": buildCommand,
+	"get":   getCommand,
+	"check": checkCommand,
+}
+
+// maxSteps is how many steps, see object.Budget, a script or each input to the REPL may take, or 0 for no limit.
+var maxSteps int64
+
 func main() {
-	usr, err := user.Current()
+	noExec := flag.Bool("no-exec", false, "disable the exec builtin, so scripts can't run external commands")
+	deny := flag.String("deny", "", "take the comma separated `capabilities` away from scripts: "+
+		strings.Join(evaluator.Capabilities(), ", "))
+	noColor := flag.Bool("no-color", false, "turn off syntax highlighting in the REPL")
+	noBanner := flag.Bool("no-banner", false, "start the REPL without the welcome banner")
+	quiet := flag.Bool("quiet", false, "leave out the REPL's prompts and load messages, for driving it from other programs")
+	flag.Int64Var(&maxSteps, "max-steps", 0, "stop a script, or an input to the REPL, after `n` evaluation steps")
+	var load []string
+	flag.Func("load", "evaluate `file` into the REPL before the first prompt; may be repeated", func(path string) error {
+		load = append(load, path)
+		return nil
+	})
+	flag.Parse()
+
+	if *noExec {
+		evaluator.UnregisterBuiltin("exec")
+	}
+	if *deny != "" {
+		for _, capability := range strings.Split(*deny, ",") {
+			if err := evaluator.Deny(strings.TrimSpace(capability)); err != nil {
+				fmt.Fprintf(os.Stderr, "sloth: --deny: %s\n", err)
+				os.Exit(2)
+			}
+		}
+	}
+
+	if args := flag.Args(); len(args) > 0 {
+		if command, ok := commands[args[0]]; ok {
+			os.Exit(command(args[1:]))
+		}
+
+		os.Exit(runFile(args[0], args[1:], os.Stderr))
+	}
+
+	if !isTerminal(os.Stdin) {
+		os.Exit(runStdin(os.Stdin, os.Stderr))
+	}
+
+	config := repl.Config{Load: load, Quiet: *quiet, MaxSteps: maxSteps}
+	if !*noBanner && !*quiet {
+		usr, err := user.Current()
+
+		if err != nil {
+			panic(err)
+		}
+
+		config.Banner = fmt.Sprintf("%s\n\n\nwelcom %s to sloth.0\n\n", repl.WELCOME_SLOTH, usr.Username)
+	}
+
+	// highlighting is off when asked for, see https://no-color.org, or when the output isn't going to a terminal
+	config.NoColor = *noColor || os.Getenv("NO_COLOR") != "" || !isTerminal(os.Stdout)
+
+	repl.Start(os.Stdin, os.Stdout, repl.WithConfig(config))
+}
+
+// runFile reads, parses, and evaluates the script at path, with args as its command line arguments, and returns the
+// exit code the process should end with: 0 when the script ran to completion, 1 when it could not be read, failed to
+// parse, or produced a runtime error. Anything that went wrong is written to errOut.
+func runFile(path string, args []string, errOut io.Writer) int {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(errOut, "sloth: %s\n", err)
+		return 1
+	}
+
+	return runSource(path, string(source), args, errOut)
+}
 
+// runStdin evaluates everything piped into in as a single program, the same way runFile does for a script.
+func runStdin(in io.Reader, errOut io.Writer) int {
+	source, err := io.ReadAll(in)
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(errOut, "sloth: %s\n", err)
+		return 1
+	}
+
+	return runSource("<stdin>", string(source), nil, errOut)
+}
+
+// runSource parses and evaluates source, reporting errors against name, and returns the process exit code. The
+// program finds args, its command line arguments, in the array bound to args.
+func runSource(name string, source string, args []string, errOut io.Writer) int {
+	l := lexer.New(source)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParseErrors(errOut, name, source, p.ParseErrors())
+		return 1
+	}
+
+	elements := []object.Object{}
+	for _, arg := range args {
+		elements = append(elements, &object.String{Value: arg})
+	}
+	env := object.NewEnvironment()
+	if maxSteps != 0 {
+		env = object.NewBudgetedEnvironment(&object.Budget{MaxSteps: maxSteps})
+	}
+	// imports in a script are found from its directory; piped in code has none and finds them from the working one.
+	// The script's environment shares the budget of env.
+	if abs, err := filepath.Abs(name); err == nil && name != "<stdin>" {
+		env = object.NewModuleEnvironment(&object.Module{Path: abs}, env)
+	}
+	env.Set("args", &object.Array{Elements: elements})
+	switch evaluated := evaluator.Eval(optimize.Program(program), env).(type) {
+	case *object.Error:
+		printRuntimeError(errOut, name, source, evaluated)
+		return 1
+	case *object.Exit:
+		return int(evaluated.Code)
 	}
 
-	fmt.Printf("%s\n\n\n", repl.WELCOME_SLOTH)
-	fmt.Printf("welcom %s to sloth.0\n\n", usr.Username)
+	return 0
+}
+
+// printParseErrors writes each of errs to w against name, followed by the line of source it was found on.
+func printParseErrors(w io.Writer, name string, source string, errs []*parser.Error) {
+	for _, err := range errs {
+		fmt.Fprintf(w, "%s:%s: parser error: %s\n", name, err.Pos(), err.Message)
+		io.WriteString(w, token.Snippet(source, err.Pos()))
+	}
+}
+
+// printRuntimeError writes err to w against name, followed by the line of source it happened on if that is known.
+func printRuntimeError(w io.Writer, name string, source string, err *object.Error) {
+	if !err.Pos.IsValid() {
+		fmt.Fprintf(w, "%s: %s\n", name, err.Inspect())
+		return
+	}
+
+	fmt.Fprintf(w, "%s:%s: %s\n", name, err.Pos, err.Inspect())
+	io.WriteString(w, token.Snippet(source, err.Pos))
+}
+
+// isTerminal reports whether f is attached to a terminal rather than a pipe or a redirected file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
 
-	repl.Start(os.Stdin, os.Stdout)
+	return info.Mode()&os.ModeCharDevice != 0
 }
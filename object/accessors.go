@@ -0,0 +1,93 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+ErrUnbound is returned, wrapped, by GetString, GetInt, GetBool, GetSlice and GetMap for names that aren't bound. Those
+look name up like Get does and convert what it is bound to into a Go value, so that hosts reading results out of an
+environment don't need a type switch for every one of them. If name is bound to the wrong type of object, the error
+says what it is bound to instead.
+*/
+var ErrUnbound = errors.New("not bound")
+
+// GetString returns the String name is bound to.
+func (e *Environment) GetString(name string) (string, error) {
+	obj, err := e.lookup(name, STRING_OBJ)
+	if err != nil {
+		return "", err
+	}
+
+	return obj.(*String).Value, nil
+}
+
+// GetInt returns the Integer name is bound to. A BigInteger will do too, as long as it fits into an int64.
+func (e *Environment) GetInt(name string) (int64, error) {
+	obj, ok := e.Get(name)
+	if !ok {
+		return 0, fmt.Errorf("%s is %w", name, ErrUnbound)
+	}
+
+	switch obj := obj.(type) {
+	case *Integer:
+		return obj.Value, nil
+	case *BigInteger:
+		if !obj.Value.IsInt64() {
+			return 0, fmt.Errorf("%s is too big for an int64: %s", name, obj.Inspect())
+		}
+		return obj.Value.Int64(), nil
+	default:
+		return 0, fmt.Errorf("%s is %s, not %s", name, obj.Type(), INTEGER_OBJ)
+	}
+}
+
+// GetBool returns the Boolean name is bound to.
+func (e *Environment) GetBool(name string) (bool, error) {
+	obj, err := e.lookup(name, BOOLEAN_OBJ)
+	if err != nil {
+		return false, err
+	}
+
+	return obj.(*Boolean).Value, nil
+}
+
+// GetSlice returns the elements of the Array name is bound to, each converted with ToGoValue.
+func (e *Environment) GetSlice(name string) ([]interface{}, error) {
+	obj, err := e.lookup(name, ARRAY_OBJ)
+	if err != nil {
+		return nil, err
+	}
+
+	return ToGoValue(obj).([]interface{}), nil
+}
+
+// GetMap returns the pairs of the Hash name is bound to, with the values converted with ToGoValue. Every key of the
+// hash has to be a String.
+func (e *Environment) GetMap(name string) (map[string]interface{}, error) {
+	obj, err := e.lookup(name, HASH_OBJ)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := ToGoValue(obj).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s has keys that aren't strings", name)
+	}
+
+	return m, nil
+}
+
+// lookup returns what name is bound to, or an error if it isn't bound or isn't bound to an object of type want.
+func (e *Environment) lookup(name string, want ObjectType) (Object, error) {
+	obj, ok := e.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%s is %w", name, ErrUnbound)
+	}
+	if obj.Type() != want {
+		return nil, fmt.Errorf("%s is %s, not %s", name, obj.Type(), want)
+	}
+
+	return obj, nil
+}
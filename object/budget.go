@@ -0,0 +1,101 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+/*
+Budget limits how much memory and time a program can make the evaluator use, for hosts running scripts they don't trust.
+It counts the objects evaluation allocates and caps how many elements an array, hash or range, or how many bytes a
+string or big integer, may hold. MaxSteps caps how many nodes of the syntax tree evaluation may visit; loops inside
+builtins, such as map calling a builtin over a range, aren't steps, but MaxSize bounds the ranges they walk. A zero
+limit means no limit. A Budget is attached to an environment with NewBudgetedEnvironment and shared by every environment
+enclosed by it, including those of spawned tasks, so it counts for a whole program.
+*/
+type Budget struct {
+	MaxAllocations int64
+	MaxSize        int
+	MaxSteps       int64
+
+	allocations atomic.Int64
+	steps       atomic.Int64
+}
+
+// Allocate records n more allocations and returns an error once the budget's allocation limit is exceeded.
+func (b *Budget) Allocate(n int64) error {
+	if b == nil || b.MaxAllocations == 0 {
+		return nil
+	}
+
+	if b.allocations.Add(n) > b.MaxAllocations {
+		return fmt.Errorf("allocation limit of %d objects exceeded", b.MaxAllocations)
+	}
+
+	return nil
+}
+
+// Step records that evaluation visited one more node and returns an error once the budget's step limit is exceeded.
+func (b *Budget) Step() error {
+	if b == nil || b.MaxSteps == 0 {
+		return nil
+	}
+
+	if b.steps.Add(1) > b.MaxSteps {
+		return fmt.Errorf("step limit of %d exceeded", b.MaxSteps)
+	}
+
+	return nil
+}
+
+// CheckSize returns an error if obj is an array, hash, string, range or big integer larger than the budget allows.
+func (b *Budget) CheckSize(obj Object) error {
+	if b == nil || b.MaxSize == 0 {
+		return nil
+	}
+
+	size := 0
+	switch obj := obj.(type) {
+	case *Array:
+		size = len(obj.Elements)
+	case *Hash:
+		size = len(obj.Pairs)
+	case *String:
+		size = len(obj.Value)
+	case *BigInteger:
+		size = (obj.Value.BitLen() + 7) / 8
+	case *Range:
+		// a range holds none of its elements, but whatever walks it walks every one of them
+		size = int(min(obj.length(), math.MaxInt))
+	}
+
+	if size > b.MaxSize {
+		return fmt.Errorf("size limit of %d exceeded: %s has %d", b.MaxSize, obj.Type(), size)
+	}
+
+	return nil
+}
+
+/*
+Reset forgets the allocations and steps recorded so far, so the budget's limits apply afresh. The REPL resets its
+budget before every input, which makes the limits a quota for each input rather than for the whole session.
+*/
+func (b *Budget) Reset() {
+	if b == nil {
+		return
+	}
+
+	b.allocations.Store(0)
+	b.steps.Store(0)
+}
+
+// Allocations returns how many allocations have been recorded so far.
+func (b *Budget) Allocations() int64 {
+	return b.allocations.Load()
+}
+
+// Steps returns how many steps have been recorded so far.
+func (b *Budget) Steps() int64 {
+	return b.steps.Load()
+}
@@ -0,0 +1,202 @@
+package object
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+Builtins is a registry of builtin functions, the ones scripts can call without binding them first. Every environment
+evaluates with the registry attached to it with SetBuiltins, or, like environments enclosed by it, that of the
+environment it was made from; environments without one use the builtins sloth comes with. Giving each interpreter a
+registry of its own lets programs embedding several of them add and take away builtins for one without affecting the
+others.
+
+A builtin whose name has a dot in it, like "http.get", belongs to the namespace before the dot. Scripts don't see it
+under its own name but reach it through the namespace, which evaluates to a frozen hash of the namespace's builtins
+keyed by the rest of their names, so it is called as http.get(url). A builtin with the same name as a namespace hides
+it.
+
+Besides builtins, a registry holds what else sets one interpreter apart from another: the capabilities denied to its
+scripts, where they print to and read from, and the modules they have imported, so that two interpreters with
+registries of their own share none of it.
+
+A registry is safe for concurrent use, but changing one while a program is being evaluated with it changes what the
+program sees halfway through.
+*/
+type Builtins struct {
+	mu      sync.RWMutex
+	fns     map[string]*Builtin
+	denied  map[string]bool
+	output  io.Writer
+	input   *bufio.Reader
+	modules *ModuleCache
+}
+
+// stdin is what registries without input of their own read from. It is shared so that none of what one of them has
+// buffered is lost to the others.
+var stdin = bufio.NewReader(os.Stdin)
+
+// NewBuiltins returns a registry holding the builtins in fns, keyed by name, that prints to standard output, reads from
+// standard input and has nothing denied or imported yet. fns itself is not kept.
+func NewBuiltins(fns map[string]*Builtin) *Builtins {
+	b := &Builtins{
+		fns:     make(map[string]*Builtin, len(fns)),
+		denied:  map[string]bool{},
+		output:  os.Stdout,
+		input:   stdin,
+		modules: NewModuleCache(),
+	}
+	for name, fn := range fns {
+		b.fns[name] = fn
+	}
+	return b
+}
+
+// bind returns builtin as it is called from scripts using this registry.
+func (b *Builtins) bind(builtin *Builtin) *Builtin {
+	if builtin.Bind == nil {
+		return builtin
+	}
+	return &Builtin{Fn: builtin.Bind(b)}
+}
+
+// Register makes builtin callable as name, replacing the builtin registered under name before, if there was one.
+func (b *Builtins) Register(name string, builtin *Builtin) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fns[name] = builtin
+}
+
+// Unregister removes the builtin called name, so scripts calling it fail as if it never existed.
+func (b *Builtins) Unregister(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.fns, name)
+}
+
+// Get returns the builtin registered under name, if there is one. Namespaces are not builtins; see Lookup.
+func (b *Builtins) Get(name string) (*Builtin, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	builtin, ok := b.fns[name]
+	if !ok {
+		return nil, false
+	}
+	return b.bind(builtin), true
+}
+
+// Lookup returns what a script gets for name: the builtin registered under it or, if it is a namespace, a frozen hash
+// of the builtins in the namespace.
+func (b *Builtins) Lookup(name string) (Object, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if builtin, ok := b.fns[name]; ok {
+		return b.bind(builtin), true
+	}
+
+	var members []string
+	for qualified := range b.fns {
+		if namespace, _, ok := strings.Cut(qualified, "."); ok && namespace == name {
+			members = append(members, qualified)
+		}
+	}
+	if members == nil {
+		return nil, false
+	}
+	sort.Strings(members)
+
+	hash := NewHash()
+	for _, qualified := range members {
+		key := &String{Value: strings.TrimPrefix(qualified, name+".")}
+		hash.Set(key.HashKey(), HashPair{Key: key, Value: b.bind(b.fns[qualified])})
+	}
+	hash.Freeze()
+
+	return hash, true
+}
+
+// Names returns the names of the builtins in the registry, namespaced ones included, sorted.
+func (b *Builtins) Names() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	names := make([]string, 0, len(b.fns))
+	for name := range b.fns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+/*
+Clone returns a registry holding the same builtins as this one, with the same capabilities denied and printing to and
+reading from the same places, which can be changed without changing this one. The clone starts without any modules
+imported, as it is meant for another interpreter.
+*/
+func (b *Builtins) Clone() *Builtins {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	clone := NewBuiltins(b.fns)
+	for capability := range b.denied {
+		clone.denied[capability] = true
+	}
+	clone.output, clone.input = b.output, b.input
+
+	return clone
+}
+
+// MarkDenied records that capability has been taken away from the scripts using the registry. Taking away the
+// builtins that make up the capability is up to the caller.
+func (b *Builtins) MarkDenied(capability string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.denied[capability] = true
+}
+
+// IsDenied reports whether capability has been taken away from the scripts using the registry.
+func (b *Builtins) IsDenied(capability string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.denied[capability]
+}
+
+// Output returns where the scripts using the registry print to.
+func (b *Builtins) Output() io.Writer {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.output
+}
+
+// SetOutput makes the scripts using the registry print to w.
+func (b *Builtins) SetOutput(w io.Writer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.output = w
+}
+
+// Input returns where the scripts using the registry read lines from.
+func (b *Builtins) Input() *bufio.Reader {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.input
+}
+
+// SetInput makes the scripts using the registry read lines from r.
+func (b *Builtins) SetInput(r io.Reader) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.input = bufio.NewReader(r)
+}
+
+// Modules returns the cache of the modules imported by the scripts using the registry.
+func (b *Builtins) Modules() *ModuleCache {
+	return b.modules
+}
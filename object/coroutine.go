@@ -0,0 +1,144 @@
+package object
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// The states a coroutine can be in, as the status builtin reports them.
+const (
+	CoroutineSuspended = "suspended"
+	CoroutineRunning   = "running"
+	CoroutineDead      = "dead"
+)
+
+/*
+Coroutine is a function that runs a bit at a time. Resume runs it until it yields a value, which Resume returns, and
+it then sits suspended until it is resumed again, at which point the yield returns what it was resumed with. Once the
+function returns, Resume returns its result and the coroutine is dead.
+
+The function runs on a goroutine of its own, started by the first Resume, and control is handed back and forth over
+unbuffered channels, so the coroutine and whoever resumed it never run at the same time. A coroutine that is never
+resumed to the end leaves its goroutine blocked for as long as the process lives. Whatever runs on that goroutine, the
+function and everything it calls, finds the coroutine with RunningCoroutine.
+*/
+type Coroutine struct {
+	Fn Object
+
+	mu      sync.Mutex
+	status  string
+	started bool
+	in      chan Object           // what Resume hands a suspended coroutine
+	out     chan coroutineMessage // what the coroutine hands back to Resume
+}
+
+// coroutineMessage is a value yielded by a coroutine or, if done is set, the result of its function.
+type coroutineMessage struct {
+	value Object
+	done  bool
+}
+
+// NewCoroutine returns a suspended coroutine for fn that hasn't started yet.
+func NewCoroutine(fn Object) *Coroutine {
+	return &Coroutine{
+		Fn:     fn,
+		status: CoroutineSuspended,
+		in:     make(chan Object),
+		out:    make(chan coroutineMessage),
+	}
+}
+
+func (c *Coroutine) Type() ObjectType { return COROUTINE_OBJ }
+func (c *Coroutine) Inspect() string  { return "coroutine(" + c.Status() + ")" }
+
+// Status returns the state the coroutine is in: CoroutineSuspended, CoroutineRunning or CoroutineDead.
+func (c *Coroutine) Status() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+/*
+Resume runs the coroutine until it yields or returns and returns the value it yielded or returned. The first time,
+start is called on the coroutine's goroutine to run its function, and val is not used; after that, val is what the
+yield the coroutine is suspended in returns. A coroutine that is running or dead can't be resumed.
+*/
+func (c *Coroutine) Resume(val Object, start func() Object) (Object, error) {
+	c.mu.Lock()
+	switch c.status {
+	case CoroutineRunning:
+		c.mu.Unlock()
+		return nil, errors.New("cannot resume a running coroutine")
+	case CoroutineDead:
+		c.mu.Unlock()
+		return nil, errors.New("cannot resume a dead coroutine")
+	}
+	c.status = CoroutineRunning
+	started := c.started
+	c.started = true
+	c.mu.Unlock()
+
+	if started {
+		c.in <- val
+	} else {
+		go func() {
+			id := goroutineID()
+			running.Store(id, c)
+			defer running.Delete(id)
+
+			c.out <- coroutineMessage{value: start(), done: true}
+		}()
+	}
+	msg := <-c.out
+
+	c.mu.Lock()
+	if msg.done {
+		c.status = CoroutineDead
+	} else {
+		c.status = CoroutineSuspended
+	}
+	c.mu.Unlock()
+
+	return msg.value, nil
+}
+
+// Yield hands val to the Resume that is running the coroutine, suspends the coroutine, and returns what it is resumed
+// with next. Only a running coroutine can yield.
+func (c *Coroutine) Yield(val Object) (Object, error) {
+	if c.Status() != CoroutineRunning {
+		return nil, errors.New("cannot yield from a coroutine that isn't running")
+	}
+
+	c.out <- coroutineMessage{value: val}
+	return <-c.in, nil
+}
+
+// running maps the id of the goroutine every coroutine that has started and not yet returned runs on to the coroutine.
+var running sync.Map
+
+/*
+RunningCoroutine returns the coroutine whose function the caller is running in, however many calls deep, or nil if
+it isn't running in one. That is what lets a function called by a coroutine yield from it, though it was written
+outside of the coroutine's function; a goroutine started from a coroutine, by spawn for one, isn't running in it.
+*/
+func RunningCoroutine() *Coroutine {
+	if c, ok := running.Load(goroutineID()); ok {
+		return c.(*Coroutine)
+	}
+
+	return nil
+}
+
+// goroutineID returns the id of the goroutine it is called on, which Go only gives out as the first line of a stack
+// trace: "goroutine 7 [running]:".
+func goroutineID() uint64 {
+	var buf [64]byte
+	trace := buf[:runtime.Stack(buf[:], false)]
+	trace = bytes.TrimPrefix(trace, []byte("goroutine "))
+	id, _ := strconv.ParseUint(string(trace[:bytes.IndexByte(trace, ' ')]), 10, 64)
+
+	return id
+}
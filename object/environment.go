@@ -1,27 +1,131 @@
 package object
 
+import (
+	"sort"
+	"sync"
+)
+
 // NewEnclosedEnvironment makes creating such an enclosed environment easy. The Get method has also been changed.
 // It checks the enclosing environment for the given name.
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.budget = outer.budget
+	env.module = outer.module
+	env.hooks = outer.hooks
+	env.builtins = outer.builtins
+	return env
+}
+
+/*
+Module is a file of sloth code evaluated in an environment of its own. Path is its absolute path and Importer the module
+whose import loaded it, nil for a program's main file, so following Importer walks the chain of imports that led to
+the module back to the start.
+*/
+type Module struct {
+	Path     string
+	Importer *Module
+}
+
+// NewModuleEnvironment returns a new, empty Environment for evaluating module. It is limited by the same budget,
+// observed by the same hooks and has the same builtins as importer, the environment of the code importing the module,
+// if there is one.
+func NewModuleEnvironment(module *Module, importer *Environment) *Environment {
+	env := NewEnvironment()
+	env.module = module
+	if importer != nil {
+		env.budget = importer.budget
+		env.hooks = importer.hooks
+		env.builtins = importer.builtins
+	}
+	return env
+}
+
+// NewBudgetedEnvironment returns a new Environment whose evaluation, and that of every environment enclosed by it, is
+// limited by budget.
+func NewBudgetedEnvironment(budget *Budget) *Environment {
+	env := NewEnvironment()
+	env.budget = budget
 	return env
 }
 
 // NewEnvironment returns a new Environment
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, outer: nil, constants: make(map[string]bool)}
 }
 
+/*
+Environment maps names to the objects bound to them. Names bound with SetConst are constants: the evaluator refuses
+to bind them again in the same environment, though an enclosed environment may still shadow them.
+
+Spawned tasks share the environments their functions close over with the code that spawned them, so an Environment is
+safe for concurrent use.
+*/
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	mu        sync.RWMutex
+	store     map[string]Object
+	outer     *Environment
+	constants map[string]bool
+	budget    *Budget
+	module    *Module
+	exports   []string // the names exported from the environment, in the order they were first exported
+	hooks     *Hooks
+	builtins  *Builtins
+}
+
+// Budget returns the budget evaluation in this environment is limited by, or nil if it isn't limited.
+func (e *Environment) Budget() *Budget {
+	return e.budget
+}
+
+// Hooks returns the hooks observing evaluation in this environment, or nil if nothing is.
+func (e *Environment) Hooks() *Hooks {
+	return e.hooks
+}
+
+// SetHooks has hooks observe evaluation in this environment and every environment enclosed by it afterwards, which
+// includes those of the functions defined in it. It is meant to be called before evaluation starts.
+func (e *Environment) SetHooks(hooks *Hooks) {
+	e.hooks = hooks
+}
+
+// Builtins returns the builtins scripts evaluated in this environment can call, or nil if they get the ones sloth
+// comes with.
+func (e *Environment) Builtins() *Builtins {
+	return e.builtins
+}
+
+// SetBuiltins has scripts evaluated in this environment, and every environment enclosed by it afterwards, call the
+// builtins in builtins instead of the ones sloth comes with. It is meant to be called before evaluation starts.
+func (e *Environment) SetBuiltins(builtins *Builtins) {
+	e.builtins = builtins
+}
+
+// Module returns the module evaluation in this environment belongs to, or nil if it isn't part of a file.
+func (e *Environment) Module() *Module {
+	return e.module
+}
+
+// Names returns the names bound in this environment, not counting enclosing ones, sorted.
+func (e *Environment) Names() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
 }
 
 // Get is an Environment getter
 func (e *Environment) Get(name string) (Object, bool) {
+	e.mu.RLock()
 	obj, ok := e.store[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
@@ -30,6 +134,50 @@ func (e *Environment) Get(name string) (Object, bool) {
 
 // Set is an Environment setter
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.store[name] = val
+	return val
+}
+
+// SetConst binds name to val like Set does and marks the binding as a constant.
+func (e *Environment) SetConst(name string, val Object) Object {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.store[name] = val
+	e.constants[name] = true
 	return val
 }
+
+// IsConst reports whether name is bound as a constant in this environment. Enclosing environments are not consulted,
+// since binding a name in an enclosed environment shadows rather than reassigns it.
+func (e *Environment) IsConst(name string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.constants[name]
+}
+
+// Export marks name as one of the bindings the module evaluated in this environment exports.
+func (e *Environment) Export(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, exported := range e.exports {
+		if exported == name {
+			return
+		}
+	}
+	e.exports = append(e.exports, name)
+}
+
+// Exports returns the names exported from this environment, in the order they were first exported, or nil if nothing
+// has been.
+func (e *Environment) Exports() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.exports == nil {
+		return nil
+	}
+	return append([]string(nil), e.exports...)
+}
@@ -0,0 +1,46 @@
+package object
+
+/*
+Future is the result of a call that runs on a goroutine of its own, made by the async builtin, and is there before the
+call has finished. Resolve is called once with what the call evaluated to, and Wait waits for that and returns it, as
+often as anyone asks; unlike receiving from a channel, waiting doesn't use the result up.
+*/
+type Future struct {
+	done   chan struct{}
+	result Object
+}
+
+// NewFuture returns a future that hasn't been resolved yet.
+func NewFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+func (f *Future) Type() ObjectType { return FUTURE_OBJ }
+func (f *Future) Inspect() string {
+	if f.Resolved() {
+		return "future(resolved)"
+	}
+	return "future(pending)"
+}
+
+// Resolve sets the result of the future and wakes everyone waiting for it. It must only be called once.
+func (f *Future) Resolve(result Object) {
+	f.result = result
+	close(f.done)
+}
+
+// Resolved reports whether the future has its result yet.
+func (f *Future) Resolved() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait waits until the future is resolved and returns its result.
+func (f *Future) Wait() Object {
+	<-f.done
+	return f.result
+}
@@ -0,0 +1,20 @@
+package object
+
+import "github.com/sean-d/sloth/ast"
+
+/*
+Hooks are called by the evaluator as it goes, so that tools like debuggers, profilers and coverage trackers can watch
+a program run without changes to the evaluator. Any of them can be left nil. They are attached to an environment with
+SetHooks and, like a Budget, observe every environment enclosed by it, including those of spawned tasks, so hooks
+that keep state have to be safe for concurrent use if the program spawns.
+
+OnNodeEnter is called before a statement or expression is evaluated, and OnNodeExit after, with what it evaluated to.
+A call in tail position is only made once the function it is in has returned, so its node is exited with a nil result
+and the call itself shows up in OnCall later. OnCall is called with the function and its arguments just before every
+call, of sloth functions and builtins alike.
+*/
+type Hooks struct {
+	OnNodeEnter func(node ast.Node, env *Environment)
+	OnNodeExit  func(node ast.Node, env *Environment, result Object)
+	OnCall      func(fn Object, args []Object)
+}
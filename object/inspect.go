@@ -0,0 +1,130 @@
+package object
+
+import (
+	"strings"
+)
+
+// MaxInspectDepth is how deeply nested arrays and hashes are printed before the rest is elided as [...] or {...}.
+const MaxInspectDepth = 32
+
+// PrettyWidth is the line width Pretty tries to stay within before breaking an array or hash across lines.
+const PrettyWidth = 80
+
+// PrettyIndent is what every level of nesting is indented by when Pretty breaks an array or hash across lines.
+const PrettyIndent = "  "
+
+/*
+inspector prints arrays and hashes, nested to any depth. It keeps the collections it is currently inside of so a value
+that contains itself prints as <cycle> rather than recursing forever. A value that is merely shared, appearing twice
+without containing itself, is printed in full both times.
+*/
+type inspector struct {
+	width int
+	path  map[Object]bool
+}
+
+// newInspector makes an inspector that breaks collections wider than width across lines; 0 keeps everything on one.
+func newInspector(width int) *inspector {
+	return &inspector{width: width, path: map[Object]bool{}}
+}
+
+/*
+Pretty prints obj the way Inspect does, but breaks any array or hash that doesn't fit in PrettyWidth columns across
+lines, one element or pair per line, indented by PrettyIndent per level of nesting:
+
+	{
+	  name: "sloth",
+	  tags: ["slow", "steady"],
+	  ...
+	}
+
+Cycles print as <cycle> and anything nested deeper than MaxInspectDepth as [...] or {...}, as they do in Inspect.
+*/
+func Pretty(obj Object) string {
+	return newInspector(PrettyWidth).pretty(obj, 0)
+}
+
+// children returns the brackets of an array or hash and its parts to print between them, each printed with print: its
+// elements, or its pairs in "key: value" form.
+func (in *inspector) children(obj Object, depth int, print func(Object, int) string) (open, close string, parts []string) {
+	switch obj := obj.(type) {
+	case *Array:
+		for _, e := range obj.Elements {
+			parts = append(parts, print(e, depth+1))
+		}
+		return "[", "]", parts
+	case *Hash:
+		for _, pair := range obj.Ordered() {
+			parts = append(parts, pair.Key.Inspect()+": "+print(pair.Value, depth+1))
+		}
+		return "{", "}", parts
+	}
+
+	return "", "", nil
+}
+
+// enter guards printing a collection: it returns what to print instead when obj is part of a cycle or nested too
+// deeply, and otherwise marks obj as being printed until the returned leave is called.
+func (in *inspector) enter(obj Object, depth int) (instead string, leave func()) {
+	if in.path[obj] {
+		return "<cycle>", nil
+	}
+	if depth >= MaxInspectDepth {
+		if _, ok := obj.(*Hash); ok {
+			return "{...}", nil
+		}
+		return "[...]", nil
+	}
+
+	in.path[obj] = true
+	return "", func() { delete(in.path, obj) }
+}
+
+// flat prints obj on one line.
+func (in *inspector) flat(obj Object, depth int) string {
+	switch obj.(type) {
+	case *Array, *Hash:
+	default:
+		return obj.Inspect()
+	}
+	instead, leave := in.enter(obj, depth)
+	if leave == nil {
+		return instead
+	}
+	defer leave()
+
+	open, close, parts := in.children(obj, depth, in.flat)
+	return open + strings.Join(parts, ", ") + close
+}
+
+// pretty prints obj on one line if it fits in what is left of the line at this depth, or across lines if it doesn't.
+func (in *inspector) pretty(obj Object, depth int) string {
+	flat := in.flat(obj, depth)
+	if len(flat)+depth*len(PrettyIndent) <= in.width {
+		return flat
+	}
+	instead, leave := in.enter(obj, depth)
+	if leave == nil {
+		return instead
+	}
+	defer leave()
+
+	open, close, parts := in.children(obj, depth, in.pretty)
+	if len(parts) == 0 {
+		return flat
+	}
+
+	indent := strings.Repeat(PrettyIndent, depth)
+	var out strings.Builder
+	out.WriteString(open + "\n")
+	for i, part := range parts {
+		out.WriteString(indent + PrettyIndent + part)
+		if i < len(parts)-1 {
+			out.WriteString(",")
+		}
+		out.WriteString("\n")
+	}
+	out.WriteString(indent + close)
+
+	return out.String()
+}
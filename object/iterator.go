@@ -0,0 +1,121 @@
+package object
+
+import "fmt"
+
+/*
+Iterator hands out the elements of a collection one at a time. Next returns the next element and true, or nil and
+false once there are none left. An Iterator is used up by walking it; ask the collection for a fresh one to start over.
+*/
+type Iterator interface {
+	Next() (Object, bool)
+}
+
+/*
+Iterable is implemented by every object that can be walked element by element. Builtins like map and filter only ask
+for an Iterable, so a new collection type works with all of them as soon as it can hand out an Iterator.
+*/
+type Iterable interface {
+	Iterator() Iterator
+}
+
+// sliceIterator walks a fixed list of objects, which is all Array, String and Hash iteration comes down to.
+type sliceIterator struct {
+	elements []Object
+	next     int
+}
+
+func (si *sliceIterator) Next() (Object, bool) {
+	if si.next >= len(si.elements) {
+		return nil, false
+	}
+
+	element := si.elements[si.next]
+	si.next++
+
+	return element, true
+}
+
+// Iterator walks the elements of the array in order. Changes made to the array afterwards are not seen.
+func (ao *Array) Iterator() Iterator {
+	elements := make([]Object, len(ao.Elements))
+	copy(elements, ao.Elements)
+
+	return &sliceIterator{elements: elements}
+}
+
+// Iterator walks the characters of the string, each one as a string of its own.
+func (s *String) Iterator() Iterator {
+	elements := []Object{}
+	for _, r := range s.Value {
+		elements = append(elements, &String{Value: string(r)})
+	}
+
+	return &sliceIterator{elements: elements}
+}
+
+// Iterator walks the keys of the hash in the order they were added.
+func (h *Hash) Iterator() Iterator {
+	elements := make([]Object, 0, len(h.Pairs))
+	for _, pair := range h.Ordered() {
+		elements = append(elements, pair.Key)
+	}
+
+	return &sliceIterator{elements: elements}
+}
+
+/*
+Range is the integers from Start up to, but not including, End, Step apart. A negative Step counts down from Start
+to End instead. A Range never holds its elements; they are produced one at a time by its Iterator.
+*/
+type Range struct {
+	Start int64
+	End   int64
+	Step  int64
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("range(%d, %d)", r.Start, r.End)
+	}
+
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.End, r.Step)
+}
+
+// length returns how many integers r produces. The differences are taken as uint64, as they may not fit in an int64.
+func (r *Range) length() uint64 {
+	switch {
+	case r.Step > 0 && r.Start < r.End:
+		return (uint64(r.End)-uint64(r.Start)-1)/uint64(r.Step) + 1
+	case r.Step < 0 && r.Start > r.End:
+		return (uint64(r.Start)-uint64(r.End)-1)/uint64(-r.Step) + 1
+	}
+
+	return 0
+}
+
+func (r *Range) Iterator() Iterator {
+	return &rangeIterator{r: r, next: r.Start}
+}
+
+type rangeIterator struct {
+	r    *Range
+	next int64
+	done bool
+}
+
+func (ri *rangeIterator) Next() (Object, bool) {
+	if ri.done || (ri.r.Step > 0 && ri.next >= ri.r.End) || (ri.r.Step < 0 && ri.next <= ri.r.End) {
+		return nil, false
+	}
+
+	current := ri.next
+	// stop instead of wrapping around when the step would carry next past the ends of int64
+	if next := current + ri.r.Step; (ri.r.Step > 0) != (next > current) {
+		ri.done = true
+	} else {
+		ri.next = next
+	}
+
+	return IntegerOf(current), true
+}
@@ -0,0 +1,207 @@
+package object
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+// TAG is the struct tag FromGoValue reads to rename a field, e.g. `sloth:"name"`. A tag of "-" skips the field.
+const TAG = "sloth"
+
+/*
+FromGoValue converts an ordinary Go value into the object the evaluator would use for it, so embedders don't have to
+wrap every value by hand:
+- nil and nil pointers become NULL
+- bools become TRUE or FALSE, strings become Strings
+- signed and unsigned integers become Integers, or BigIntegers when they don't fit into an int64, as do *big.Ints
+- slices and arrays become Arrays of their converted elements
+- maps become Hashes; their keys must convert to something with a HashKey
+- structs become Hashes keyed by field name, or by the name given in a `sloth:"..."` tag; unexported fields are skipped
+- pointers and interfaces are followed to the value they hold
+- values that already are an Object are returned unchanged
+
+Anything else, like floats, channels, and funcs, has no sloth counterpart and is converted into an *Error.
+*/
+func FromGoValue(value interface{}) Object {
+	if value == nil {
+		return NULL
+	}
+
+	if obj, ok := value.(Object); ok {
+		return obj
+	}
+
+	if b, ok := value.(*big.Int); ok {
+		if b == nil {
+			return NULL
+		}
+		return NewInteger(new(big.Int).Set(b))
+	}
+
+	return fromReflectValue(reflect.ValueOf(value))
+}
+
+func fromReflectValue(v reflect.Value) Object {
+	if v.IsValid() && v.CanInterface() {
+		if obj, ok := v.Interface().(Object); ok {
+			return obj
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return NULL
+
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return NULL
+		}
+		return fromReflectValue(v.Elem())
+
+	case reflect.Bool:
+		if v.Bool() {
+			return TRUE
+		}
+		return FALSE
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return IntegerOf(v.Int())
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return NewInteger(new(big.Int).SetUint64(v.Uint()))
+
+	case reflect.String:
+		return &String{Value: v.String()}
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return NULL
+		}
+
+		elements := make([]Object, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			el := fromReflectValue(v.Index(i))
+			if el.Type() == ERROR_OBJ {
+				return el
+			}
+			elements = append(elements, el)
+		}
+		return &Array{Elements: elements}
+
+	case reflect.Map:
+		if v.IsNil() {
+			return NULL
+		}
+
+		hash := &Hash{Pairs: make(map[HashKey]HashPair)}
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fromReflectValue(iter.Key())
+			if key.Type() == ERROR_OBJ {
+				return key
+			}
+
+			hashKey, ok := HashKeyOf(key)
+			if !ok {
+				return &Error{Message: fmt.Sprintf("cannot convert Go value of type %s: unusable as hash key: %s",
+					v.Type(), key.Type())}
+			}
+
+			value := fromReflectValue(iter.Value())
+			if value.Type() == ERROR_OBJ {
+				return value
+			}
+
+			// Go maps have no order to keep, so the pairs are left for Hash.Ordered to sort by key
+			hash.Pairs[hashKey] = HashPair{Key: key, Value: value}
+		}
+		return hash
+
+	case reflect.Struct:
+		hash := NewHash()
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name := field.Name
+			if tag, ok := field.Tag.Lookup(TAG); ok {
+				if tag == "-" {
+					continue
+				}
+				if tag != "" {
+					name = tag
+				}
+			}
+
+			value := fromReflectValue(v.Field(i))
+			if value.Type() == ERROR_OBJ {
+				return value
+			}
+
+			key := &String{Value: name}
+			hash.Set(key.HashKey(), HashPair{Key: key, Value: value})
+		}
+		return hash
+
+	default:
+		return &Error{Message: fmt.Sprintf("cannot convert Go value of type %s", v.Type())}
+	}
+}
+
+/*
+ToGoValue is the inverse of FromGoValue:
+- NULL becomes nil
+- Booleans, Integers, and Strings become bool, int64, and string; BigIntegers become *big.Int
+- Arrays become []interface{}
+- Hashes become map[string]interface{} when every key is a String and map[interface{}]interface{} otherwise
+
+Objects without a Go counterpart, like functions and errors, are returned as they are.
+*/
+func ToGoValue(obj Object) interface{} {
+	switch obj := obj.(type) {
+	case nil, *Null:
+		return nil
+	case *Boolean:
+		return obj.Value
+	case *Integer:
+		return obj.Value
+	case *BigInteger:
+		return new(big.Int).Set(obj.Value)
+	case *String:
+		return obj.Value
+	case *Array:
+		elements := make([]interface{}, 0, len(obj.Elements))
+		for _, el := range obj.Elements {
+			elements = append(elements, ToGoValue(el))
+		}
+		return elements
+	case *Hash:
+		stringKeys := true
+		for _, pair := range obj.Pairs {
+			if _, ok := pair.Key.(*String); !ok {
+				stringKeys = false
+				break
+			}
+		}
+
+		if stringKeys {
+			m := make(map[string]interface{}, len(obj.Pairs))
+			for _, pair := range obj.Pairs {
+				m[pair.Key.(*String).Value] = ToGoValue(pair.Value)
+			}
+			return m
+		}
+
+		m := make(map[interface{}]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			m[ToGoValue(pair.Key)] = ToGoValue(pair.Value)
+		}
+		return m
+	default:
+		return obj
+	}
+}
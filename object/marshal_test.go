@@ -0,0 +1,109 @@
+package object
+
+import (
+	"reflect"
+	"testing"
+)
+
+type marshalPerson struct {
+	Name    string `sloth:"name"`
+	Age     int
+	Tags    []string `sloth:"tags"`
+	Secret  string   `sloth:"-"`
+	private int
+}
+
+func TestFromGoValue(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{true, "true"},
+		{int8(-3), "-3"},
+		{uint16(7), "7"},
+		{"sloth", "sloth"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]bool{true, false}, "[true, false]"},
+		{map[string]int{"a": 1}, "{a: 1}"},
+		{&marshalPerson{Name: "sid", Age: 3, Secret: "shh", private: 1}, ""},
+		{(*marshalPerson)(nil), "null"},
+		{[]interface{}{1, "a", nil}, "[1, a, null]"},
+		{uint64(1 << 63), "9223372036854775808"},
+	}
+
+	for _, tt := range tests {
+		obj := FromGoValue(tt.input)
+		if obj.Type() == ERROR_OBJ {
+			t.Errorf("FromGoValue(%#v) returned error: %s", tt.input, obj.Inspect())
+			continue
+		}
+		if tt.expected != "" && obj.Inspect() != tt.expected {
+			t.Errorf("FromGoValue(%#v) wrong. got=%q, want=%q", tt.input, obj.Inspect(), tt.expected)
+		}
+	}
+
+	if FromGoValue(true) != TRUE || FromGoValue(false) != FALSE {
+		t.Errorf("booleans are not converted to the TRUE and FALSE singletons")
+	}
+}
+
+func TestFromGoValueStruct(t *testing.T) {
+	obj := FromGoValue(marshalPerson{Name: "sid", Age: 3, Tags: []string{"slow"}, Secret: "shh"})
+
+	hash, ok := obj.(*Hash)
+	if !ok {
+		t.Fatalf("object is not Hash. got=%T (%+v)", obj, obj)
+	}
+
+	expected := map[string]string{"name": "sid", "Age": "3", "tags": "[slow]"}
+	if len(hash.Pairs) != len(expected) {
+		t.Errorf("hash has wrong number of pairs. got=%d, want=%d", len(hash.Pairs), len(expected))
+	}
+
+	for key, value := range expected {
+		pair, ok := hash.Pairs[(&String{Value: key}).HashKey()]
+		if !ok {
+			t.Errorf("no pair for key %q", key)
+			continue
+		}
+		if pair.Value.Inspect() != value {
+			t.Errorf("wrong value for key %q. got=%q, want=%q", key, pair.Value.Inspect(), value)
+		}
+	}
+}
+
+func TestFromGoValueUnsupported(t *testing.T) {
+	for _, input := range []interface{}{1.5, make(chan int), func() {}, map[float64]int{1: 1}} {
+		if obj := FromGoValue(input); obj.Type() != ERROR_OBJ {
+			t.Errorf("FromGoValue(%T) should return an error. got=%T (%+v)", input, obj, obj)
+		}
+	}
+}
+
+func TestToGoValue(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected interface{}
+	}{
+		{nil, nil},
+		{false, false},
+		{42, int64(42)},
+		{"sloth", "sloth"},
+		{[]interface{}{1, "a", true}, []interface{}{int64(1), "a", true}},
+		{map[string]interface{}{"a": []int{1}}, map[string]interface{}{"a": []interface{}{int64(1)}}},
+		{map[int]string{1: "a"}, map[interface{}]interface{}{int64(1): "a"}},
+	}
+
+	for _, tt := range tests {
+		actual := ToGoValue(FromGoValue(tt.input))
+		if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("round trip of %#v wrong. got=%#v, want=%#v", tt.input, actual, tt.expected)
+		}
+	}
+
+	fn := &Builtin{}
+	if ToGoValue(fn) != fn {
+		t.Errorf("objects without a Go counterpart should be returned unchanged")
+	}
+}
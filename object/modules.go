@@ -0,0 +1,52 @@
+package object
+
+import "sync"
+
+/*
+ModuleCache holds every module imported so far by its absolute path, so a file is evaluated once however many times,
+and from however many places, it is imported, and every import of it sees the same bindings. A module that failed to
+load is dropped again, so a later import tries once more.
+*/
+type ModuleCache struct {
+	mu      sync.Mutex
+	entries map[string]*moduleEntry
+}
+
+// moduleEntry is a module in the cache: done is closed once it has been loaded and exports holds what its import
+// evaluates to, the module or the error loading it failed with.
+type moduleEntry struct {
+	done    chan struct{}
+	exports Object
+}
+
+// NewModuleCache returns an empty ModuleCache.
+func NewModuleCache() *ModuleCache {
+	return &ModuleCache{entries: map[string]*moduleEntry{}}
+}
+
+// Load returns the module at path, calling load to load it if it isn't in the cache. If another goroutine, a spawned
+// task, is loading it already, Load waits for it to finish instead.
+func (c *ModuleCache) Load(path string, load func() Object) Object {
+	c.mu.Lock()
+	entry, loaded := c.entries[path]
+	if !loaded {
+		entry = &moduleEntry{done: make(chan struct{})}
+		c.entries[path] = entry
+	}
+	c.mu.Unlock()
+
+	if loaded {
+		<-entry.done
+		return entry.exports
+	}
+
+	entry.exports = load()
+	if t := entry.exports.Type(); t == ERROR_OBJ || t == EXIT_OBJ {
+		c.mu.Lock()
+		delete(c.entries, path)
+		c.mu.Unlock()
+	}
+	close(entry.done)
+
+	return entry.exports
+}
@@ -2,10 +2,17 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/sean-d/sloth/ast"
+	"github.com/sean-d/sloth/token"
 	"hash/fnv"
-	"strings"
+	"math/big"
+	"regexp"
+	"sort"
+	"sync"
+	"sync/atomic"
 )
 
 /*
@@ -20,12 +27,31 @@ const (
 	ERROR_OBJ        = "ERROR"
 	BUILTIN_OBJ      = "BUILTIN"
 	INTEGER_OBJ      = "INTEGER"
+	BIG_INTEGER_OBJ  = "BIG_INTEGER"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	STRING_OBJ       = "STRING"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
 	FUNCTION_OBJ     = "FUNCTION"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	RANGE_OBJ        = "RANGE"
+	CHANNEL_OBJ      = "CHANNEL"
+	MUTEX_OBJ        = "MUTEX"
+	WAIT_GROUP_OBJ   = "WAIT_GROUP"
+	EXIT_OBJ         = "EXIT"
+	REGEX_OBJ        = "REGEX"
+	COROUTINE_OBJ    = "COROUTINE"
+	FUTURE_OBJ       = "FUTURE"
+)
+
+/*
+NULL, TRUE and FALSE are the only null and boolean objects there are. The evaluator compares against them by identity,
+so anything producing a null or a boolean has to hand out one of these rather than allocating its own.
+*/
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
 )
 
 type Object interface {
@@ -48,6 +74,55 @@ type Integer struct {
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 
+// The range of integers IntegerOf hands out shared objects for. Loop counters, indexes and lengths mostly fall in it.
+const (
+	MIN_CACHED_INTEGER = -128
+	MAX_CACHED_INTEGER = 255
+)
+
+var smallIntegers = func() []*Integer {
+	cache := make([]*Integer, MAX_CACHED_INTEGER-MIN_CACHED_INTEGER+1)
+	for i := range cache {
+		cache[i] = &Integer{Value: int64(i + MIN_CACHED_INTEGER)}
+	}
+	return cache
+}()
+
+/*
+IntegerOf returns an Integer holding v. Integers between MIN_CACHED_INTEGER and MAX_CACHED_INTEGER are allocated once
+and shared, so arithmetic on small numbers doesn't allocate at all. That is only safe because an Integer is never
+modified once it has been created; anything producing an integer should go through IntegerOf.
+*/
+func IntegerOf(v int64) *Integer {
+	if v >= MIN_CACHED_INTEGER && v <= MAX_CACHED_INTEGER {
+		return smallIntegers[v-MIN_CACHED_INTEGER]
+	}
+
+	return &Integer{Value: v}
+}
+
+/*
+BigInteger
+
+Integer arithmetic that would overflow an int64 produces a BigInteger instead of wrapping around. BigIntegers are only
+ever used for values outside the int64 range: results that fit again are handed back as plain Integers, which keeps
+the common case fast and means an Integer and a BigInteger are never equal.
+*/
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (bi *BigInteger) Type() ObjectType { return BIG_INTEGER_OBJ }
+func (bi *BigInteger) Inspect() string  { return bi.Value.String() }
+
+// NewInteger returns v as an Integer when it fits into an int64 and as a BigInteger otherwise.
+func NewInteger(v *big.Int) Object {
+	if v.IsInt64() {
+		return IntegerOf(v.Int64())
+	}
+	return &BigInteger{Value: v}
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -55,13 +130,35 @@ type Boolean struct {
 func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
 func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
 
+// String is immutable once created, which lets it compute its hash key only once; hash is 0 until then.
 type String struct {
 	Value string
+
+	hash atomic.Uint64
 }
 
 func (s *String) Type() ObjectType { return STRING_OBJ }
 func (s *String) Inspect() string  { return s.Value }
 
+// interned holds the String InternString returns for every string it has been given.
+var interned sync.Map
+
+/*
+InternString returns a String holding s, the same one every time it is given the same s, so its hash key is worked out
+once however often it is used as a key. The evaluator interns the strings written in the source, string literals and
+the names after a dot, which are the keys a program uses over and over. Strings a program builds at run time are
+allocated as usual, as interning them would keep every one of them around for good. Like IntegerOf, this is only safe
+because a String is never modified once it has been created.
+*/
+func InternString(s string) *String {
+	if str, ok := interned.Load(s); ok {
+		return str.(*String)
+	}
+
+	str, _ := interned.LoadOrStore(s, &String{Value: s})
+	return str.(*String)
+}
+
 /*
 I know i know....nulls...
 */
@@ -77,31 +174,53 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+/*
+Exit is what the exit builtin evaluates to. Like an Error it stops evaluation wherever it appears and travels all the
+way back up to whoever called Eval, but it can't be caught. Ending the process with Code is left to that caller.
+*/
+type Exit struct {
+	Code int64
+}
+
+func (e *Exit) Type() ObjectType { return EXIT_OBJ }
+func (e *Exit) Inspect() string  { return fmt.Sprintf("exit(%d)", e.Code) }
+
+// Error is a runtime error. Value is what a throw statement threw, and nil for errors raised by the evaluator itself.
+// Pos is where in the source the error happened, when that is known.
 type Error struct {
 	Message string
+	Value   Object
+	Pos     token.Position
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 
+/*
+Function carries the parameters of the literal it was created from, including the default value expressions and
+rest parameter, which are only evaluated when the function is called.
+
+Functions made with partial and curry also carry arguments bound to them already, which are passed in front of the
+ones they are called with. A curried function called with fewer than Curry arguments, counting the bound ones, isn't
+called but returns a copy of itself with them bound too.
+*/
 type Function struct {
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Bound      []Object
+	Curry      int
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString("fn")
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ast.ParameterList(f.Parameters, f.Defaults, f.Rest))
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
@@ -109,8 +228,18 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+/*
+Builtin is a function written in Go. Calling it runs Fn. A builtin that depends on the interpreter it is called from,
+like puts, which prints to wherever that interpreter's output goes, sets Bind instead: getting it from a registry hands
+Bind the registry for the Fn to run.
+
+Required, if set, is how many arguments the builtin needs. Builtins standing in for a function whose parameters are
+known, like those of a compiled program, set it so curry can tell how many arguments to wait for.
+*/
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn       BuiltinFunction
+	Bind     func(registry *Builtins) BuiltinFunction
+	Required *int
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
@@ -126,22 +255,35 @@ they are just a list of objects.
 */
 type Array struct {
 	Elements []Object
+	frozen   atomic.Bool
 }
 
-func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
-func (ao *Array) Inspect() string {
-	var out bytes.Buffer
+/*
+Freeze marks the array as one that must not change anymore: Set refuses to change it, and so do the builtins that make
+a changed array out of one, push and set. Go code writing to Elements directly has to check IsFrozen itself. Freezing
+can't be undone.
+*/
+func (ao *Array) Freeze() { ao.frozen.Store(true) }
 
-	elements := []string{}
-	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
+// IsFrozen reports whether the array has been frozen.
+func (ao *Array) IsFrozen() bool { return ao.frozen.Load() }
+
+// Set replaces the element at index, which has to be one of the array's, with element. It returns ErrFrozen, and
+// leaves the array as it is, if the array is frozen.
+func (ao *Array) Set(index int, element Object) error {
+	if ao.IsFrozen() {
+		return ErrFrozen
 	}
+	ao.Elements[index] = element
 
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ", "))
-	out.WriteString("]")
+	return nil
+}
 
-	return out.String()
+func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
+
+// Inspect prints the array on one line. See Pretty for a form that breaks long arrays across lines.
+func (ao *Array) Inspect() string {
+	return newInspector(0).flat(ao, 0)
 }
 
 /*
@@ -173,11 +315,76 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+func (bi *BigInteger) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write(bi.Value.Bytes())
+	if bi.Value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+
+	return HashKey{Type: bi.Type(), Value: h.Sum64()}
+}
+
+/*
+HashKey hashes the string the first time it is asked for and remembers the result, since the same string object is
+often used as a key over and over, see InternString. A string that happens to hash to 0 is simply hashed again every time.
+*/
 func (s *String) HashKey() HashKey {
+	if hash := s.hash.Load(); hash != 0 {
+		return HashKey{Type: s.Type(), Value: hash}
+	}
+
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
+	hash := h.Sum64()
+	s.hash.Store(hash)
+
+	return HashKey{Type: s.Type(), Value: hash}
+}
+
+/*
+HashKeyOf returns the HashKey of obj and whether it has one. Anything Hashable has one, and so does an array all of
+whose elements have one: its HashKey is made from theirs, in order, so two arrays holding equal keys are the same key
+and arrays can be used as composite keys, like {[x, y]: "tile"}. An array holding a hash, a function or itself has
+none.
 
-	return HashKey{Type: s.Type(), Value: h.Sum64()}
+Scripts can't change an array once it is made, which is what makes this safe. A Go program that changes the elements
+of an array used as a key leaves the pair filed under the key the array had when it was added.
+*/
+func HashKeyOf(obj Object) (HashKey, bool) {
+	return hashKeyOf(obj, nil)
+}
+
+// hashKeyOf is HashKeyOf for an obj inside the arrays in path, which it can't be one of.
+func hashKeyOf(obj Object, path []*Array) (HashKey, bool) {
+	switch obj := obj.(type) {
+	case Hashable:
+		return obj.HashKey(), true
+	case *Array:
+		for _, outer := range path {
+			if outer == obj {
+				return HashKey{}, false
+			}
+		}
+		path = append(path, obj)
+
+		h := fnv.New64a()
+		var buf []byte
+		for _, el := range obj.Elements {
+			key, ok := hashKeyOf(el, path)
+			if !ok {
+				return HashKey{}, false
+			}
+			buf = append(buf[:0], key.Type...)
+			buf = append(buf, 0)
+			buf = binary.BigEndian.AppendUint64(buf, key.Value)
+			h.Write(buf)
+		}
+
+		return HashKey{Type: obj.Type(), Value: h.Sum64()}, true
+	default:
+		return HashKey{}, false
+	}
 }
 
 type HashPair struct {
@@ -185,8 +392,101 @@ type HashPair struct {
 	Value Object
 }
 
+/*
+Hash keeps its pairs in Pairs for looking them up, and the order their keys were added in beside it, so that printing
+and iterating a hash always goes through the pairs in the same order: the order they were written in for hash literals.
+Pairs should be added with Set, which keeps track of that order. Pairs put into the map directly still show up, after
+the others and sorted by key, but their order no longer says anything about when they were added.
+
+Default, when it isn't nil, is a function called without arguments for the value of every key the hash doesn't have.
+
+A frozen hash must not change anymore, the same way a frozen array must not: Set and Delete refuse to change it.
+*/
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	Pairs   map[HashKey]HashPair
+	Default Object
+	order   []HashKey
+	frozen  atomic.Bool
+}
+
+// ErrFrozen is returned when something tries to change a frozen array or hash.
+var ErrFrozen = errors.New("cannot change a frozen value")
+
+// Freeze marks the hash as one that must not change anymore. Freezing can't be undone.
+func (h *Hash) Freeze() { h.frozen.Store(true) }
+
+// IsFrozen reports whether the hash has been frozen.
+func (h *Hash) IsFrozen() bool { return h.frozen.Load() }
+
+// NewHash returns an empty hash.
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+// Set adds pair to the hash under key. A key that is already in the hash keeps its place and only has its pair replaced.
+// Set returns ErrFrozen, and leaves the hash as it is, if the hash is frozen.
+func (h *Hash) Set(key HashKey, pair HashPair) error {
+	if h.IsFrozen() {
+		return ErrFrozen
+	}
+	if h.Pairs == nil {
+		h.Pairs = make(map[HashKey]HashPair)
+	}
+	if _, ok := h.Pairs[key]; !ok {
+		h.order = append(h.order, key)
+	}
+	h.Pairs[key] = pair
+
+	return nil
+}
+
+// Delete removes key and its pair from the hash, if it is there. It returns ErrFrozen, and leaves the hash as it is, if
+// the hash is frozen.
+func (h *Hash) Delete(key HashKey) error {
+	if h.IsFrozen() {
+		return ErrFrozen
+	}
+	if _, ok := h.Pairs[key]; !ok {
+		return nil
+	}
+
+	delete(h.Pairs, key)
+	for i, k := range h.order {
+		if k == key {
+			h.order = append(h.order[:i], h.order[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+// Ordered returns the pairs of the hash in the order their keys were added.
+func (h *Hash) Ordered() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	seen := make(map[HashKey]bool, len(h.order))
+	for _, key := range h.order {
+		if pair, ok := h.Pairs[key]; ok && !seen[key] {
+			pairs = append(pairs, pair)
+			seen[key] = true
+		}
+	}
+
+	if len(pairs) == len(h.Pairs) {
+		return pairs
+	}
+
+	unordered := []HashPair{}
+	for key, pair := range h.Pairs {
+		if !seen[key] {
+			unordered = append(unordered, pair)
+		}
+	}
+	sort.Slice(unordered, func(i, j int) bool {
+		return unordered[i].Key.Inspect() < unordered[j].Key.Inspect()
+	})
+
+	return append(pairs, unordered...)
 }
 
 type Hashable interface {
@@ -195,19 +495,61 @@ type Hashable interface {
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
 
-// Inspect outputs the key and value objects for the give *object.Hash.
+// Inspect prints the hash on one line, its pairs in insertion order. See Pretty for a form that breaks long hashes
+// across lines.
 func (h *Hash) Inspect() string {
-	var out bytes.Buffer
+	return newInspector(0).flat(h, 0)
+}
+
+// Channel passes objects between spawned tasks. It wraps a Go channel, buffered or not depending on how it was made.
+type Channel struct {
+	Ch chan Object
+}
 
-	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(), pair.Value.Inspect()))
+func (c *Channel) Type() ObjectType { return CHANNEL_OBJ }
+func (c *Channel) Inspect() string  { return fmt.Sprintf("channel(%d)", cap(c.Ch)) }
+
+/*
+Mutex is a lock for sharing state between spawned tasks. It is a channel with room for a single token rather than a
+sync.Mutex, because unlocking a sync.Mutex that isn't locked kills the whole process, and in sloth that is just an
+error. Lock and Unlock are there for the builtins; Unlock reports whether the mutex was locked.
+*/
+type Mutex struct {
+	token chan struct{}
+}
+
+func NewMutex() *Mutex {
+	return &Mutex{token: make(chan struct{}, 1)}
+}
+
+func (m *Mutex) Type() ObjectType { return MUTEX_OBJ }
+func (m *Mutex) Inspect() string  { return "mutex" }
+
+func (m *Mutex) Lock() {
+	m.token <- struct{}{}
+}
+
+func (m *Mutex) Unlock() bool {
+	select {
+	case <-m.token:
+		return true
+	default:
+		return false
 	}
+}
 
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
+// WaitGroup waits for a number of spawned tasks to finish, like a sync.WaitGroup.
+type WaitGroup struct {
+	sync.WaitGroup
+}
 
-	return out.String()
+func (wg *WaitGroup) Type() ObjectType { return WAIT_GROUP_OBJ }
+func (wg *WaitGroup) Inspect() string  { return "waitgroup" }
+
+// Regex is a compiled regular expression, in the syntax of Go's regexp package.
+type Regex struct {
+	Value *regexp.Regexp
 }
+
+func (r *Regex) Type() ObjectType { return REGEX_OBJ }
+func (r *Regex) Inspect() string  { return `regex("` + r.Value.String() + `")` }
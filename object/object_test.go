@@ -1,6 +1,10 @@
 package object
 
-import "testing"
+import (
+	"errors"
+	"strings"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -19,4 +23,329 @@ func TestStringHashKey(t *testing.T) {
 	if hello1.HashKey() == diff1.HashKey() {
 		t.Errorf("strings with different content have same hash keys")
 	}
+
+	if hello1.HashKey() != hello1.HashKey() || hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("cached hash key differs from the computed one")
+	}
+}
+
+func TestArrayHashKey(t *testing.T) {
+	pair := func(elements ...Object) *Array { return &Array{Elements: elements} }
+	one, two := &Integer{Value: 1}, &Integer{Value: 2}
+
+	key1, ok1 := HashKeyOf(pair(one, &String{Value: "a"}))
+	key2, ok2 := HashKeyOf(pair(&Integer{Value: 1}, &String{Value: "a"}))
+	if !ok1 || !ok2 || key1 != key2 {
+		t.Errorf("arrays with same elements have different hash keys")
+	}
+
+	different := []*Array{pair(two, one), pair(one), pair(pair(one, two)), pair(one, &String{Value: "1"}), pair()}
+	base, _ := HashKeyOf(pair(one, two))
+	for _, arr := range different {
+		key, ok := HashKeyOf(arr)
+		if !ok {
+			t.Errorf("%s has no hash key", arr.Inspect())
+		}
+		if key == base {
+			t.Errorf("%s has the same hash key as [1, 2]", arr.Inspect())
+		}
+	}
+
+	if _, ok := HashKeyOf(pair(one, NewHash())); ok {
+		t.Errorf("array holding a hash has a hash key")
+	}
+
+	cyclic := pair(one)
+	cyclic.Elements = append(cyclic.Elements, cyclic)
+	if _, ok := HashKeyOf(cyclic); ok {
+		t.Errorf("array holding itself has a hash key")
+	}
+}
+
+func TestIterators(t *testing.T) {
+	tests := []struct {
+		iterable Iterable
+		expected []string
+	}{
+		{&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}, []string{"1", "a"}},
+		{&Array{}, []string{}},
+		{&String{Value: "héj"}, []string{"h", "é", "j"}},
+		{&Range{Start: 0, End: 3, Step: 1}, []string{"0", "1", "2"}},
+		{&Range{Start: 3, End: 0, Step: -2}, []string{"3", "1"}},
+		{&Range{Start: 0, End: 3, Step: -1}, []string{}},
+		{&Range{Start: 9223372036854775806, End: 9223372036854775807, Step: 5}, []string{"9223372036854775806"}},
+	}
+
+	for _, tt := range tests {
+		got := []string{}
+		it := tt.iterable.Iterator()
+		for element, ok := it.Next(); ok; element, ok = it.Next() {
+			got = append(got, element.Inspect())
+		}
+
+		if len(got) != len(tt.expected) {
+			t.Errorf("wrong number of elements. expected=%v, got=%v", tt.expected, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.expected[i] {
+				t.Errorf("wrong element %d. expected=%q, got=%q", i, tt.expected[i], got[i])
+			}
+		}
+
+		if _, ok := it.Next(); ok {
+			t.Errorf("iterator produced an element after it was done")
+		}
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	hash := NewHash()
+	for _, name := range []string{"zebra", "apple", "mango"} {
+		key := &String{Value: name}
+		hash.Set(key.HashKey(), HashPair{Key: key, Value: IntegerOf(int64(len(name)))})
+	}
+
+	// setting a key that is already there replaces its value but keeps its place
+	apple := &String{Value: "apple"}
+	hash.Set(apple.HashKey(), HashPair{Key: apple, Value: IntegerOf(0)})
+
+	// pairs put into the map directly come last, sorted by key
+	for _, name := range []string{"pear", "fig"} {
+		key := &String{Value: name}
+		hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: IntegerOf(1)}
+	}
+
+	expected := "{zebra: 5, apple: 0, mango: 5, fig: 1, pear: 1}"
+	for i := 0; i < 10; i++ {
+		if got := hash.Inspect(); got != expected {
+			t.Fatalf("hash.Inspect() wrong. expected=%q, got=%q", expected, got)
+		}
+	}
+
+	keys := []string{}
+	it := hash.Iterator()
+	for key, ok := it.Next(); ok; key, ok = it.Next() {
+		keys = append(keys, key.Inspect())
+	}
+	if strings.Join(keys, " ") != "zebra apple mango fig pear" {
+		t.Errorf("wrong iteration order. got=%v", keys)
+	}
+
+	if got := (&Hash{}).Inspect(); got != "{}" {
+		t.Errorf("empty hash Inspect() wrong. got=%q", got)
+	}
+}
+
+func TestIntegerOf(t *testing.T) {
+	for _, v := range []int64{MIN_CACHED_INTEGER, -1, 0, 1, MAX_CACHED_INTEGER} {
+		if IntegerOf(v) != IntegerOf(v) {
+			t.Errorf("IntegerOf(%d) is not cached", v)
+		}
+		if IntegerOf(v).Value != v {
+			t.Errorf("IntegerOf(%d) has wrong value. got=%d", v, IntegerOf(v).Value)
+		}
+	}
+
+	for _, v := range []int64{MIN_CACHED_INTEGER - 1, MAX_CACHED_INTEGER + 1, 1 << 40} {
+		if IntegerOf(v) == IntegerOf(v) {
+			t.Errorf("IntegerOf(%d) is cached, but is outside of the cached range", v)
+		}
+		if IntegerOf(v).Value != v {
+			t.Errorf("IntegerOf(%d) has wrong value. got=%d", v, IntegerOf(v).Value)
+		}
+	}
+}
+
+func TestInternString(t *testing.T) {
+	if InternString("name") != InternString("name") {
+		t.Errorf("InternString returned different strings for the same value")
+	}
+	if InternString("name") == InternString("other") {
+		t.Errorf("InternString returned the same string for different values")
+	}
+	if InternString("name").Value != "name" {
+		t.Errorf("InternString has wrong value. got=%q", InternString("name").Value)
+	}
+}
+
+func TestInspectCycles(t *testing.T) {
+	arr := &Array{Elements: []Object{IntegerOf(1)}}
+	arr.Elements = append(arr.Elements, arr)
+
+	hash := NewHash()
+	self := &String{Value: "self"}
+	hash.Set(self.HashKey(), HashPair{Key: self, Value: hash})
+	items := &String{Value: "items"}
+	hash.Set(items.HashKey(), HashPair{Key: items, Value: arr})
+
+	// a value that is shared without containing itself is printed in full both times
+	shared := &Array{Elements: []Object{IntegerOf(2)}}
+	twice := &Array{Elements: []Object{shared, shared}}
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{arr, "[1, <cycle>]"},
+		{hash, "{self: <cycle>, items: [1, <cycle>]}"},
+		{twice, "[[2], [2]]"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.obj.Inspect(); got != tt.expected {
+			t.Errorf("Inspect() wrong. expected=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+func TestInspectMaxDepth(t *testing.T) {
+	var nested Object = &Array{}
+	for i := 0; i < MaxInspectDepth+5; i++ {
+		nested = &Array{Elements: []Object{nested}}
+	}
+
+	expected := strings.Repeat("[", MaxInspectDepth) + "[...]" + strings.Repeat("]", MaxInspectDepth)
+	if got := nested.Inspect(); got != expected {
+		t.Errorf("Inspect() wrong. expected=%q, got=%q", expected, got)
+	}
+}
+
+func TestPretty(t *testing.T) {
+	long := &Array{}
+	for i := 0; i < 30; i++ {
+		long.Elements = append(long.Elements, IntegerOf(int64(i)))
+	}
+
+	hash := NewHash()
+	for _, pair := range []struct {
+		key   string
+		value Object
+	}{{"name", &String{Value: "sloth"}}, {"short", &Array{Elements: []Object{IntegerOf(1)}}}, {"long", long}} {
+		key := &String{Value: pair.key}
+		hash.Set(key.HashKey(), HashPair{Key: key, Value: pair.value})
+	}
+	self := &String{Value: "self"}
+	hash.Set(self.HashKey(), HashPair{Key: self, Value: hash})
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{IntegerOf(5), "5"},
+		{&Array{Elements: []Object{IntegerOf(1), IntegerOf(2)}}, "[1, 2]"},
+		{hash, `{
+  name: sloth,
+  short: [1],
+  long: [
+    0,
+    1,
+    2,
+    3,
+    4,
+    5,
+    6,
+    7,
+    8,
+    9,
+    10,
+    11,
+    12,
+    13,
+    14,
+    15,
+    16,
+    17,
+    18,
+    19,
+    20,
+    21,
+    22,
+    23,
+    24,
+    25,
+    26,
+    27,
+    28,
+    29
+  ],
+  self: <cycle>
+}`},
+	}
+
+	for _, tt := range tests {
+		if got := Pretty(tt.obj); got != tt.expected {
+			t.Errorf("Pretty() wrong. expected=\n%s\ngot=\n%s", tt.expected, got)
+		}
+	}
+}
+
+func TestBudgetReset(t *testing.T) {
+	budget := &Budget{MaxSteps: 2, MaxAllocations: 1}
+	budget.Step()
+	budget.Step()
+	budget.Allocate(1)
+	if err := budget.Step(); err == nil || err.Error() != "step limit of 2 exceeded" {
+		t.Fatalf("expected the step limit to be exceeded, got %v", err)
+	}
+
+	budget.Reset()
+	if budget.Steps() != 0 || budget.Allocations() != 0 {
+		t.Errorf("Reset left steps=%d, allocations=%d", budget.Steps(), budget.Allocations())
+	}
+	if err := budget.Step(); err != nil {
+		t.Errorf("Step after Reset returned error: %s", err)
+	}
+
+	var unlimited *Budget
+	unlimited.Reset()
+}
+
+func TestEnvironmentGetters(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("name", &String{Value: "sloth"})
+	env := NewEnclosedEnvironment(outer)
+	env.Set("n", IntegerOf(42))
+	env.Set("big", FromGoValue(uint64(1<<63)))
+	env.Set("ok", TRUE)
+	env.Set("list", FromGoValue([]interface{}{"a", 1}))
+	env.Set("config", FromGoValue(map[string]int{"x": 1}))
+	env.Set("numbered", FromGoValue(map[int]int{1: 1}))
+
+	if s, err := env.GetString("name"); err != nil || s != "sloth" {
+		t.Errorf("GetString(name) = %q, %v", s, err)
+	}
+	if n, err := env.GetInt("n"); err != nil || n != 42 {
+		t.Errorf("GetInt(n) = %d, %v", n, err)
+	}
+	if b, err := env.GetBool("ok"); err != nil || !b {
+		t.Errorf("GetBool(ok) = %t, %v", b, err)
+	}
+	if list, err := env.GetSlice("list"); err != nil || len(list) != 2 || list[0] != "a" || list[1] != int64(1) {
+		t.Errorf("GetSlice(list) = %v, %v", list, err)
+	}
+	if m, err := env.GetMap("config"); err != nil || len(m) != 1 || m["x"] != int64(1) {
+		t.Errorf("GetMap(config) = %v, %v", m, err)
+	}
+
+	errs := []struct {
+		get      func() error
+		expected string
+	}{
+		{func() error { _, err := env.GetString("n"); return err }, "n is INTEGER, not STRING"},
+		{func() error { _, err := env.GetInt("big"); return err }, "big is too big for an int64: 9223372036854775808"},
+		{func() error { _, err := env.GetBool("missing"); return err }, "missing is not bound"},
+		{func() error { _, err := env.GetSlice("config"); return err }, "config is HASH, not ARRAY"},
+		{func() error { _, err := env.GetMap("numbered"); return err }, "numbered has keys that aren't strings"},
+	}
+	for _, tt := range errs {
+		err := tt.get()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%v", tt.expected, err)
+		}
+	}
+
+	if _, err := env.GetInt("missing"); !errors.Is(err, ErrUnbound) {
+		t.Errorf("error for an unbound name doesn't wrap ErrUnbound: %v", err)
+	}
 }
@@ -0,0 +1,186 @@
+package optimize
+
+import (
+	"github.com/sean-d/sloth/ast"
+)
+
+/*
+Program removes code from program that can never run and returns it. The program is changed in place. Two things are
+removed:
+- statements following a return or a throw in the same block, which the evaluator never gets to
+- the branch of an if expression whose condition is the literal true or false that is never taken
+
+An if with a literal condition used as a statement is replaced by the statements of the branch that is taken. Blocks
+don't open a scope of their own, so that doesn't change what any name refers to. One used as a value is replaced by the
+branch's expression if the branch is a single expression statement, and left alone otherwise.
+
+Optimizing never changes what a program does or evaluates to, only how much work evaluating it takes.
+*/
+func Program(program *ast.Program) *ast.Program {
+	program.Statements = statements(program.Statements)
+	return program
+}
+
+func statements(list []ast.Statement) []ast.Statement {
+	out := make([]ast.Statement, 0, len(list))
+
+	for i, s := range list {
+		last := i == len(list)-1
+
+		if es, ok := s.(*ast.ExpressionStatement); ok {
+			if ie, ok := es.Expression.(*ast.IfExpression); ok {
+				if taken, ok := takenBranch(ie); ok && (!last || !isEmpty(taken)) {
+					if taken != nil {
+						out = append(out, statements(taken.Statements)...)
+					}
+					if endsControlFlow(out) {
+						return out
+					}
+					continue
+				}
+			}
+		}
+
+		out = append(out, statement(s))
+
+		switch s.(type) {
+		case *ast.ReturnStatement, *ast.ThrowStatement:
+			return out
+		}
+	}
+
+	return out
+}
+
+// endsControlFlow reports whether the last statement of list is a return or a throw.
+func endsControlFlow(list []ast.Statement) bool {
+	if len(list) == 0 {
+		return false
+	}
+
+	switch list[len(list)-1].(type) {
+	case *ast.ReturnStatement, *ast.ThrowStatement:
+		return true
+	}
+
+	return false
+}
+
+// takenBranch returns the branch of ie that is always taken and true, or false if the condition isn't a literal. The
+// branch is nil when the condition is false and there is no else.
+func takenBranch(ie *ast.IfExpression) (*ast.BlockStatement, bool) {
+	condition, ok := ie.Condition.(*ast.Boolean)
+	if !ok {
+		return nil, false
+	}
+
+	if condition.Value {
+		return ie.Consequence, true
+	}
+
+	return ie.Alternative, true
+}
+
+func isEmpty(b *ast.BlockStatement) bool {
+	return b == nil || len(b.Statements) == 0
+}
+
+func statement(s ast.Statement) ast.Statement {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		s.Value = expression(s.Value)
+	case *ast.FunctionStatement:
+		expression(s.Function)
+	case *ast.ClassStatement:
+		for i, d := range s.Defaults {
+			s.Defaults[i] = expression(d)
+		}
+		for _, method := range s.Methods {
+			expression(method.Function)
+		}
+	case *ast.ExportStatement:
+		if s.Declaration != nil {
+			s.Declaration = statement(s.Declaration)
+		}
+	case *ast.ReturnStatement:
+		s.ReturnValue = expression(s.ReturnValue)
+	case *ast.ThrowStatement:
+		s.Value = expression(s.Value)
+	case *ast.ExpressionStatement:
+		s.Expression = expression(s.Expression)
+	case *ast.BlockStatement:
+		block(s)
+	}
+
+	return s
+}
+
+func block(b *ast.BlockStatement) {
+	if b != nil {
+		b.Statements = statements(b.Statements)
+	}
+}
+
+func expression(e ast.Expression) ast.Expression {
+	switch e := e.(type) {
+	case *ast.PrefixExpression:
+		e.Right = expression(e.Right)
+	case *ast.InfixExpression:
+		e.Left = expression(e.Left)
+		e.Right = expression(e.Right)
+	case *ast.IfExpression:
+		e.Condition = expression(e.Condition)
+		block(e.Consequence)
+		block(e.Alternative)
+
+		if taken, ok := takenBranch(e); ok && taken != nil && len(taken.Statements) == 1 {
+			if es, ok := taken.Statements[0].(*ast.ExpressionStatement); ok {
+				return es.Expression
+			}
+		}
+	case *ast.TryExpression:
+		block(e.Body)
+		block(e.Catch)
+	case *ast.SpawnExpression:
+		e.Function = expression(e.Function)
+	case *ast.FunctionLiteral:
+		for i, d := range e.Defaults {
+			e.Defaults[i] = expression(d)
+		}
+		block(e.Body)
+	case *ast.CallExpression:
+		e.Function = expression(e.Function)
+		list(e.Arguments)
+	case *ast.ArrayLiteral:
+		list(e.Elements)
+	case *ast.SpreadExpression:
+		e.Value = expression(e.Value)
+	case *ast.IndexExpression:
+		e.Left = expression(e.Left)
+		e.Index = expression(e.Index)
+	case *ast.DotExpression:
+		e.Left = expression(e.Left)
+	case *ast.SliceExpression:
+		e.Left = expression(e.Left)
+		e.Low = expression(e.Low)
+		e.High = expression(e.High)
+	case *ast.HashLiteral:
+		keys := make([]ast.Expression, 0, len(e.Pairs))
+		pairs := make(map[ast.Expression]ast.Expression, len(e.Pairs))
+		for _, key := range e.OrderedKeys() {
+			value := expression(e.Pairs[key])
+			key = expression(key)
+			keys = append(keys, key)
+			pairs[key] = value
+		}
+		e.Keys, e.Pairs = keys, pairs
+	}
+
+	return e
+}
+
+func list(exps []ast.Expression) {
+	for i, e := range exps {
+		exps[i] = expression(e)
+	}
+}
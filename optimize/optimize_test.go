@@ -0,0 +1,101 @@
+package optimize
+
+import (
+	"github.com/sean-d/sloth/evaluator"
+	"github.com/sean-d/sloth/format"
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/object"
+	"github.com/sean-d/sloth/parser"
+	"testing"
+)
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let f = fn() { return 1; puts(2); 3 }; f()",
+			"let f = fn() {\n  return 1;\n};\n\nf();\n",
+		},
+		{
+			"let f = fn() { throw 1; 2 }; f()",
+			"let f = fn() {\n  throw 1;\n};\n\nf();\n",
+		},
+		{
+			"if (true) { puts(1); puts(2) } else { puts(3) }; 4",
+			"puts(1);\nputs(2);\n4;\n",
+		},
+		{
+			"if (false) { puts(1) } else { puts(3) }; 4",
+			"puts(3);\n4;\n",
+		},
+		{
+			"if (false) { puts(1) }; 4",
+			"4;\n",
+		},
+		{
+			"4; if (false) { puts(1) }",
+			"4;\n\nif (false) {\n  puts(1);\n}\n",
+		},
+		{
+			"let f = fn() { if (true) { return 1; } 2 }; f()",
+			"let f = fn() {\n  return 1;\n};\n\nf();\n",
+		},
+		{
+			"let x = if (true) { 1 } else { 2 }; let y = if (false) { 1 }; x",
+			"let x = 1;\n\nlet y = if (false) {\n  1;\n};\n\nx;\n",
+		},
+		{
+			"if (x) { return 1; 2 } else { 3 }",
+			"if (x) {\n  return 1;\n} else {\n  3;\n}\n",
+		},
+		{
+			`{"a": if (true) { 1 } else { 2 }}`,
+			"{\"a\": 1};\n",
+		},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+
+		got := format.Program(Program(program))
+		if got != tt.expected {
+			t.Errorf("Program(%q) wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// TestProgramKeepsResults makes sure optimizing never changes what a program evaluates to.
+func TestProgramKeepsResults(t *testing.T) {
+	inputs := []string{
+		"let f = fn(n) { if (true) { n * 2 } }; f(4)",
+		"let f = fn(n) { if (false) { n } }; f(4)",
+		"let f = fn(n) { if (true) { return n; } n + 1 }; f(4)",
+		"1; if (false) { 2 }",
+		"1; if (true) { }",
+		"let f = fn(n) { if (n == 0) { return 0; 5 } if (true) { f(n - 1) } else { 9 } }; f(100)",
+		"try { if (true) { throw 1; } 2 } catch (e) { e + 10 }",
+	}
+
+	for _, input := range inputs {
+		expected := evaluator.Eval(parser.New(lexer.New(input)).ParseProgram(), object.NewEnvironment())
+		optimized := Program(parser.New(lexer.New(input)).ParseProgram())
+		got := evaluator.Eval(optimized, object.NewEnvironment())
+
+		if inspect(got) != inspect(expected) {
+			t.Errorf("optimizing %q changed its result. expected=%s, got=%s", input, inspect(expected), inspect(got))
+		}
+	}
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	return obj.Inspect()
+}
@@ -1,24 +1,31 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"github.com/sean-d/sloth/ast"
 	"github.com/sean-d/sloth/lexer"
 	"github.com/sean-d/sloth/token"
+	"io"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
 // Setting the PEMDAS order of operations for later consideration.
 const (
 	_ int = iota
 	LOWEST
+	NULLISH     // ??
 	EQUALS      // ==
 	LESSGREATER // < or >
+	PIPELINE    // |>
+	COMPOSE     // >>
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -X or !X
 	CALL        // someFunction(X)
-	INDEX       // array[index]
+	INDEX       // array[index] or hash.key
 )
 
 // precedences is our precedence table: it associates token types with their precedence.
@@ -26,16 +33,32 @@ const (
 // This table can now tell us that + (token.PLUS) and - (token.MINUS) have the same precedence,
 // which is lower than the precedence of * (token.ASTERISK) and / (token.SLASH), for example.
 var precedences = map[token.TokenType]int{
+	token.NULLISH:  NULLISH,
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
 	token.LT:       LESSGREATER,
 	token.GT:       LESSGREATER,
+	token.PIPELINE: PIPELINE,
+	token.COMPOSE:  COMPOSE,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
+
+	token.OPTIONAL_LBRACKET: INDEX,
+}
+
+// Precedence returns the binding power the parser gives to t in infix position, or LOWEST when t is not an infix
+// operator. Tools that print expressions back out use it to decide where parentheses are needed.
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+
+	return LOWEST
 }
 
 /*
@@ -49,23 +72,23 @@ Each token type can have up to two parsing functions associated with it, dependi
 
 /*
 Both of the following function types return an ast.Expression, since that’s what we’re here to parse.
-Only the infixParseFn takes an argument: another ast.Expression. This argument is “left side” of the infix operator that’s being parsed.
+Only the InfixParseFn takes an argument: another ast.Expression. This argument is “left side” of the infix operator that’s being parsed.
 A prefix operator doesn’t have a “left side”, per definition.
 
-prefixParseFns gets called when we encounter the associated token type in prefix position and infixParseFn gets called
+prefixParseFns gets called when we encounter the associated token type in prefix position and InfixParseFn gets called
 when we encounter the token type in infix position.
 */
 type (
-	prefixParseFn func() ast.Expression
-	infixParseFn  func(ast.Expression) ast.Expression
+	PrefixParseFn func() ast.Expression
+	InfixParseFn  func(ast.Expression) ast.Expression
 )
 
 /*
 Parser has the following fields:
 -lexer is a pointer to an instance of the lexer, on which we repeatedly call NextToken() to get the next token in the input.
--errors holds a slice of strings containing any errors the parsing encounters
--curToken and peekToken act exactly like the two “pointers” our lexer has: position and readPosition.
--prefixParseFns and infixParseFns maps ensure the correct prefixParseFn or infixParseFn for the current token type
+-errors holds every error the parsing encounters, in the order they were found
+-prevToken, curToken and peekToken act exactly like the two “pointers” our lexer has: position and readPosition.
+-prefixParseFns and infixParseFns maps ensure the correct PrefixParseFn or InfixParseFn for the current token type
 
 Instead of pointing to a character in the input, they point to the current and the next token.
 
@@ -77,51 +100,85 @@ we are at the end of the line or if we are at just the start of an arithmetic ex
 */
 type Parser struct {
 	lexer  *lexer.Lexer
-	errors []string
+	errors []*Error
 
+	prevToken token.Token
 	curToken  token.Token
 	peekToken token.Token
 
-	prefixParseFns map[token.TokenType]prefixParseFn
-	infixParseFns  map[token.TokenType]infixParseFn
+	// docs holds the /// comments read since the last statement started, for the next declaration to claim
+	docs []string
+
+	// blocks is how many blocks deep the statement being parsed is, 0 at the top level of the program
+	blocks int
+
+	// illegal is set once an illegal token has been skipped in the statement being parsed. The statement is broken
+	// anyway, and whatever else goes wrong in it is most likely down to the missing token, so it isn't reported.
+	illegal bool
+
+	// tracer receives the trace of parse functions entered and left, see Trace; nil when not tracing
+	tracer     io.Writer
+	traceLevel int
+
+	prefixParseFns map[token.TokenType]PrefixParseFn
+	infixParseFns  map[token.TokenType]InfixParseFn
+
+	// precedences holds the precedences set with SetPrecedence, which take priority over the built in ones
+	precedences map[token.TokenType]int
 }
 
-// New returns a pointer to a Parser
-func New(l *lexer.Lexer) *Parser {
+// New returns a pointer to a Parser, configured by any options given
+func New(l *lexer.Lexer, opts ...Option) *Parser {
 	p := &Parser{
 		lexer:  l,
-		errors: []string{},
+		errors: []*Error{},
 	}
 
 	// initialize the prefixParseFns map on Parser and register parsing functions:
 	// EX: if we encounter a token of type token.IDENT the parsing function to call is parseIdentifier, a method we defined on *Parser.
-	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
-	p.registerPrefix(token.IDENT, p.parseIdentifier)
-	p.registerPrefix(token.INT, p.parseIntegerLiteral)
-	p.registerPrefix(token.BANG, p.parsePrefixExpression)
-	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
-	p.registerPrefix(token.TRUE, p.parseBoolean)
-	p.registerPrefix(token.FALSE, p.parseBoolean)
-	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
-	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
-	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
-	p.registerPrefix(token.IF, p.parseIfExpression)
-	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
-	p.registerPrefix(token.STRING, p.parseStringLiteral)
-
-	p.infixParseFns = make(map[token.TokenType]infixParseFn)
-
-	p.registerInfix(token.PLUS, p.parseInfixExpression)
-	p.registerInfix(token.MINUS, p.parseInfixExpression)
-	p.registerInfix(token.SLASH, p.parseInfixExpression)
-	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
-	p.registerInfix(token.EQ, p.parseInfixExpression)
-	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
-	p.registerInfix(token.LT, p.parseInfixExpression)
-	p.registerInfix(token.GT, p.parseInfixExpression)
-
-	p.registerInfix(token.LPAREN, p.parseCallExpression)
-	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.prefixParseFns = make(map[token.TokenType]PrefixParseFn)
+	p.RegisterPrefix(token.IDENT, p.parseIdentifier)
+	p.RegisterPrefix(token.INT, p.parseIntegerLiteral)
+	p.RegisterPrefix(token.BANG, p.parsePrefixExpression)
+	p.RegisterPrefix(token.MINUS, p.parsePrefixExpression)
+	p.RegisterPrefix(token.PLUS, p.parsePrefixExpression)
+	p.RegisterPrefix(token.TRUE, p.parseBoolean)
+	p.RegisterPrefix(token.FALSE, p.parseBoolean)
+	p.RegisterPrefix(token.LPAREN, p.parseGroupedExpression)
+	p.RegisterPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.RegisterPrefix(token.LBRACE, p.parseHashLiteral)
+	p.RegisterPrefix(token.IF, p.parseIfExpression)
+	p.RegisterPrefix(token.TRY, p.parseTryExpression)
+	p.RegisterPrefix(token.SPAWN, p.parseSpawnExpression)
+	p.RegisterPrefix(token.IMPORT, p.parseImportExpression)
+	p.RegisterPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.RegisterPrefix(token.PIPE, p.parseShortFunctionLiteral)
+	p.RegisterPrefix(token.STRING, p.parseStringLiteral)
+
+	p.infixParseFns = make(map[token.TokenType]InfixParseFn)
+
+	p.RegisterInfix(token.PLUS, p.parseInfixExpression)
+	p.RegisterInfix(token.MINUS, p.parseInfixExpression)
+	p.RegisterInfix(token.SLASH, p.parseInfixExpression)
+	p.RegisterInfix(token.ASTERISK, p.parseInfixExpression)
+	p.RegisterInfix(token.EQ, p.parseInfixExpression)
+	p.RegisterInfix(token.NOT_EQ, p.parseInfixExpression)
+	p.RegisterInfix(token.LT, p.parseInfixExpression)
+	p.RegisterInfix(token.GT, p.parseInfixExpression)
+	p.RegisterInfix(token.NULLISH, p.parseInfixExpression)
+	p.RegisterInfix(token.PIPELINE, p.parsePipeExpression)
+	p.RegisterInfix(token.COMPOSE, p.parseInfixExpression)
+
+	p.RegisterInfix(token.LPAREN, p.parseCallExpression)
+	p.RegisterInfix(token.LBRACKET, p.parseIndexExpression)
+	p.RegisterInfix(token.OPTIONAL_LBRACKET, p.parseIndexExpression)
+	p.RegisterInfix(token.DOT, p.parseDotExpression)
+
+	// options come after the built in parse functions, so they can replace them, and before any tokens are read, so a
+	// trace sees everything
+	for _, opt := range opts {
+		opt(p)
+	}
 
 	// Read two tokens to set both curToken and peekToken
 	p.nextToken()
@@ -132,8 +189,43 @@ func New(l *lexer.Lexer) *Parser {
 
 // nextToken is a small helper that advances both curToken and peekToken
 func (p *Parser) nextToken() {
+	p.prevToken = p.curToken
 	p.curToken = p.peekToken
 	p.peekToken = p.lexer.NextToken()
+
+	for {
+		switch p.peekToken.Type {
+		case token.DOC_COMMENT:
+			// doc comments never reach the parse functions; they are set aside for the declaration that follows them
+			p.docs = append(p.docs, p.peekToken.Literal)
+		case token.ILLEGAL:
+			// neither do illegal tokens: the lexer's explanation is reported in their place, and parsing carries on as
+			// if they weren't there rather than piling errors about them on top of it
+			p.illegalTokenError()
+		default:
+			return
+		}
+		p.peekToken = p.lexer.NextToken()
+	}
+}
+
+// illegalTokenError adds the lexer's explanation of the ILLEGAL peekToken to p.errors. The lexer has just returned the
+// token, so its explanation is the last one it has.
+func (p *Parser) illegalTokenError() {
+	msg := fmt.Sprintf("illegal token %q", p.peekToken.Literal)
+	if errs := p.lexer.Errors(); len(errs) > 0 && errs[len(errs)-1].Pos == p.peekToken.Pos {
+		msg = errs[len(errs)-1].Message
+	}
+
+	p.errors = append(p.errors, &Error{Message: msg, Token: p.peekToken, Previous: p.curToken})
+	p.illegal = true
+}
+
+// takeDocs returns the doc comments read since the last call, joined into a single string, and forgets them.
+func (p *Parser) takeDocs() string {
+	doc := strings.Join(p.docs, "\n")
+	p.docs = nil
+	return doc
 }
 
 // curTokenIs returns the bool repr of asserting if the current token is of an assumed type
@@ -164,22 +256,66 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
-// Errors returns a slice of strings containing all parser errors
+/*
+Error is a single syntax error. Token is the token the parser could not make sense of and Previous is the one before
+it, which together are usually enough to find the mistake: "expected next token to be ), got EOF instead" is a lot
+easier to track down knowing it came right after the x on line 12.
+*/
+type Error struct {
+	Message  string
+	Token    token.Token
+	Previous token.Token
+}
+
+// Pos returns where in the input the error was found.
+func (e *Error) Pos() token.Position {
+	return e.Token.Pos
+}
+
+// Error returns the message prefixed with the line and column it was found at.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos(), e.Message)
+}
+
+// Errors returns a slice of strings containing all parser errors. The messages carry no positions; ParseErrors has
+// those.
 func (p *Parser) Errors() []string {
+	msgs := make([]string, len(p.errors))
+	for i, err := range p.errors {
+		msgs[i] = err.Message
+	}
+	return msgs
+}
+
+// ParseErrors returns every error the parser encountered, with the position and tokens each one was found at.
+func (p *Parser) ParseErrors() []*Error {
 	return p.errors
 }
 
+// curError adds an error about curToken to p.errors.
+func (p *Parser) curError(format string, args ...any) {
+	if p.illegal {
+		return
+	}
+	p.errors = append(p.errors, &Error{Message: fmt.Sprintf(format, args...), Token: p.curToken, Previous: p.prevToken})
+}
+
+// peekTokenError adds an error about peekToken to p.errors.
+func (p *Parser) peekTokenError(format string, args ...any) {
+	if p.illegal {
+		return
+	}
+	p.errors = append(p.errors, &Error{Message: fmt.Sprintf(format, args...), Token: p.peekToken, Previous: p.curToken})
+}
+
 // peekError adds an error to p.errors when the type of peekToken does not match the expectation.
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
-		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.peekTokenError("expected next token to be %s, got %s instead", t, p.peekToken.Type)
 }
 
 // noPrefixParseFnError just adds a formatted error message to our parser’s errors field.
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.curError("no prefix parse function for %s found", t)
 }
 
 /*
@@ -194,36 +330,118 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for !p.curTokenIs(token.EOF) {
+		errors := len(p.errors)
+		p.illegal = false
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if len(p.errors) > errors {
+			p.synchronize(false)
+		} else if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
 	}
 
+	// the lexer ends the input early if reading it fails, which must not pass for a complete program
+	if err := p.lexer.Err(); err != nil {
+		p.illegal = false
+		p.curError("could not read input: %s", err)
+	}
+
 	return program
 }
 
+/*
+synchronize skips the rest of a statement that failed to parse, so that one mistake is reported once rather than
+setting off a string of errors about the tokens that follow it. It stops on the semicolon ending the statement, or
+before the next let, const, return or throw, which can only start a new statement. Inside a block it also stops before
+the } closing the block. Braces opened while skipping are skipped along with everything inside them.
+*/
+func (p *Parser) synchronize(inBlock bool) {
+	depth := 0
+
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth > 0 {
+				depth--
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				return
+			}
+		}
+
+		if depth == 0 {
+			switch p.peekToken.Type {
+			case token.LET, token.CONST, token.RETURN, token.THROW, token.EOF:
+				return
+			case token.RBRACE:
+				if inBlock {
+					return
+				}
+			}
+		}
+
+		p.nextToken()
+	}
+}
+
 // parseStatement checks the Type of the current token.
+// Doc comments read before a let, const, fn or class declaration are attached to it. Any other statement drops them.
 func (p *Parser) parseStatement() ast.Statement {
+	defer p.untrace(p.trace("parseStatement"))
+
+	doc := p.takeDocs()
+
 	switch p.curToken.Type {
-	case token.LET:
-		return p.parseLetStatement()
+	case token.LET, token.CONST:
+		stmt := p.parseLetStatement()
+		if stmt == nil {
+			return nil
+		}
+		stmt.Doc = doc
+		return stmt
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.FUNCTION:
+		if p.peekTokenIs(token.IDENT) {
+			stmt := p.parseFunctionStatement()
+			if fs, ok := stmt.(*ast.FunctionStatement); ok {
+				fs.Doc = doc
+			}
+			return stmt
+		}
+		return p.parseExpressionStatement()
+	case token.CLASS:
+		stmt := p.parseClassStatement()
+		if stmt == nil {
+			return nil
+		}
+		stmt.Doc = doc
+		return stmt
+	case token.IMPORT, token.FROM:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement(doc)
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
 /*
-parseLetStatement constructs an *ast.LetStatement node with the token it’s currently sitting on (a token.LET token) and
+parseLetStatement constructs an *ast.LetStatement node with the token it’s currently sitting on (a token.LET or token.CONST token) and
 then advances the tokens while making assertions about the next token with calls to expectPeek.
 
 First it expects a token.IDENT token, which it then uses to construct an *ast.Identifier node. Then it expects an
 equal sign, and finally it jumps over the expression following the equal sign until it encounters a semicolon.
 */
 func (p *Parser) parseLetStatement() *ast.LetStatement {
+	defer p.untrace(p.trace("parseLetStatement"))
+
 	stmt := &ast.LetStatement{Token: p.curToken}
 
 	if !p.expectPeek(token.IDENT) {
@@ -251,6 +469,8 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 // It then brings the parser in place for the expression that comes next by calling nextToken() and finally,
 // there’s the cop-out. It skips over every expression until it encounters a semicolon. That’s it.
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
+	defer p.untrace(p.trace("parseReturnStatement"))
+
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
 	p.nextToken()
@@ -264,6 +484,23 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+// parseThrowStatement constructs an ast.ThrowStatement the same way parseReturnStatement does a return.
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	defer p.untrace(p.trace("parseThrowStatement"))
+
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 /*
 parseExpressionStatement builds an AST node and then attempts to fill its field by calling other parsing functions.
 In this case there are a few differences though: we call parseExpression() with the constant LOWEST, and then we check
@@ -272,6 +509,8 @@ If it’s not there, that’s okay too, we don’t add an error to the parser if
 Expression statements have optional semicolons (which makes it easier to type something like 5 + 5 into the REPL later on).
 */
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+	defer p.untrace(p.trace("parseExpressionStatement"))
+
 	statement := &ast.ExpressionStatement{
 		Token:      p.curToken,
 		Expression: nil,
@@ -289,6 +528,8 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 // parseExpression checks if a parsing function is associated with p.CurToken.Type in the prefix position.
 // if true, the parsing function is called. if false, nil is returned.
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	defer p.untrace(p.trace("parseExpression"))
+
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken.Type)
@@ -297,6 +538,11 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	leftExp := prefix()
 
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+		// the operand failed to parse and said why, an operator has nothing to apply to
+		if leftExp == nil {
+			return nil
+		}
+
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			return leftExp
@@ -313,21 +559,22 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 // peekPrecedence method returns the precedence associated with the token type of p.peekToken.
 // If it doesn’t find a precedence for p.peekToken it defaults to LOWEST, the lowest possible precedence any operator can have.
 func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
-		return p
-	}
-
-	return LOWEST
+	return p.precedence(p.peekToken.Type)
 }
 
 // curPrecedence method returns the precedence associated with the token type of p.curToken.
 // If it doesn’t find a precedence for p.curToken it defaults to LOWEST, the lowest possible precedence any operator can have.
 func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
-		return p
+	return p.precedence(p.curToken.Type)
+}
+
+// precedence returns the precedence of t, looking at the precedences set with SetPrecedence before the built in ones.
+func (p *Parser) precedence(t token.TokenType) int {
+	if precedence, ok := p.precedences[t]; ok {
+		return precedence
 	}
 
-	return LOWEST
+	return Precedence(t)
 }
 
 /*
@@ -338,6 +585,8 @@ you’re associated with and return with curToken being the last token that’s
 Never advance the tokens too far.
 */
 func (p *Parser) parseIdentifier() ast.Expression {
+	defer p.untrace(p.trace("parseIdentifier"))
+
 	return &ast.Identifier{
 		Token: p.curToken,
 		Value: p.curToken.Literal,
@@ -346,14 +595,22 @@ func (p *Parser) parseIdentifier() ast.Expression {
 
 // parseIntegerLiteral makes a call to strconv.ParseInt, which converts the string in p.curToken.Literal into an int64.
 // The int64 then gets saved to the Value field, and we return the newly constructed *ast.IntegerLiteral node.
+// A literal that is out of range for an int64 is parsed into a big.Int and saved to the Big field instead.
 // If that doesn’t work, we add a new error to the parser’s errors field.
 func (p *Parser) parseIntegerLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseIntegerLiteral"))
+
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if big, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			lit.Big = big
+			return lit
+		}
+	}
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.curError("could not parse %q as integer", p.curToken.Literal)
 		return nil
 	}
 
@@ -367,7 +624,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 But then it does something different: it actually advances our tokens by calling p.nextToken().
 
-When parsePrefixExpression is called, p.curToken is either of type token.BANG or token.MINUS, because otherwise it
+When parsePrefixExpression is called, p.curToken is of type token.BANG, token.MINUS or token.PLUS, because otherwise it
 wouldn’t have been called. But in order to correctly parse a prefix expression like -5 more than one token has to be “consumed”.
 So after using p.curToken to build a *ast.PrefixExpression node, the method advances the tokens and calls parseExpression again.
 This time with the precedence of prefix operators as argument.
@@ -379,6 +636,8 @@ an *ast.IntegerLiteral node and returns it. parseExpression returns this newly c
 uses it to fill the Right field of *ast.PrefixExpression.
 */
 func (p *Parser) parsePrefixExpression() ast.Expression {
+	defer p.untrace(p.trace("parsePrefixExpression"))
+
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
 		Operator: p.curToken.Literal,
@@ -397,6 +656,8 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 // calling nextToken and filling the Right field of the node with another call to parseExpression -
 // this time passing in the precedence of the operator token.
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseInfixExpression"))
+
 	expression := &ast.InfixExpression{
 		Token:    p.curToken,
 		Operator: p.curToken.Literal,
@@ -410,13 +671,44 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+/*
+parsePipeExpression parses value |> f, which is another way of writing f(value), into that call. When the right side is
+a call already, value goes in front of its arguments, so value |> f(a, b) is f(value, a, b). Pipes are left associative
+and bind looser than arithmetic but tighter than comparisons, so 1 + 2 |> double == 6 is double(1 + 2) == 6.
+
+The call keeps the |> as its token, which is how the formatter knows to print it back out as a pipe.
+*/
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parsePipeExpression"))
+
+	tok := p.curToken
+	precedence := p.curPrecedence()
+	p.nextToken()
+
+	right := p.parseExpression(precedence)
+	if right == nil {
+		return nil
+	}
+
+	if call, ok := right.(*ast.CallExpression); ok && !call.Piped() {
+		arguments := append([]ast.Expression{left}, call.Arguments...)
+		return &ast.CallExpression{Token: tok, Function: call.Function, Arguments: arguments, Rparen: call.Rparen}
+	}
+
+	return &ast.CallExpression{Token: tok, Function: right, Arguments: []ast.Expression{left}}
+}
+
 // parseBoolean ...get this...parses booleans
 func (p *Parser) parseBoolean() ast.Expression {
+	defer p.untrace(p.trace("parseBoolean"))
+
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
 // parseGroupedExpression is used to parse a group of expressions that returns once a RPAREN is found
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	defer p.untrace(p.trace("parseGroupedExpression"))
+
 	p.nextToken()
 
 	exp := p.parseExpression(LOWEST)
@@ -448,6 +740,8 @@ The first time with a call to nextToken, since we already know that the p.peekTo
 Then with a call to expectPeek since now the next token has to be the opening brace of a block statement, otherwise the program is invalid.
 */
 func (p *Parser) parseIfExpression() ast.Expression {
+	defer p.untrace(p.trace("parseIfExpression"))
+
 	expression := &ast.IfExpression{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
@@ -480,83 +774,483 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+// parseTryExpression parses try { ... } catch (e) { ... }. The catch clause and its parameter are both required.
+func (p *Parser) parseTryExpression() ast.Expression {
+	defer p.untrace(p.trace("parseTryExpression"))
+
+	expression := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Body = p.parseBlockStatement()
+
+	if !p.expectPeek(token.CATCH) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	expression.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Catch = p.parseBlockStatement()
+
+	return expression
+}
+
+// parseSpawnExpression parses spawn followed by the function to spawn. The function binds like the operand of a prefix
+// operator, so spawn f() spawns the result of calling f rather than a call of f.
+func (p *Parser) parseSpawnExpression() ast.Expression {
+	defer p.untrace(p.trace("parseSpawnExpression"))
+
+	expression := &ast.SpawnExpression{Token: p.curToken}
+
+	p.nextToken()
+
+	expression.Function = p.parseExpression(PREFIX)
+
+	return expression
+}
+
+// parseImportExpression parses import followed by the path of the file to import, which has to be a string literal so
+// that what a program imports is known without running it.
+func (p *Parser) parseImportExpression() ast.Expression {
+	defer p.untrace(p.trace("parseImportExpression"))
+
+	expression := &ast.ImportExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	expression.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	return expression
+}
+
+/*
+parseImportStatement parses import "path" as name and from "path" import a, b. An import without an as is just an
+import expression, which can go on to be part of a larger one like import "path".name, so that is parsed as an
+expression statement.
+*/
+func (p *Parser) parseImportStatement() ast.Statement {
+	defer p.untrace(p.trace("parseImportStatement"))
+
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	if p.curTokenIs(token.IMPORT) {
+		exp := p.parseExpression(LOWEST)
+		imp, ok := exp.(*ast.ImportExpression)
+		if !ok || !p.peekTokenIs(token.AS) {
+			if p.peekTokenIs(token.SEMICOLON) {
+				p.nextToken()
+			}
+			return &ast.ExpressionStatement{Token: stmt.Token, Expression: exp}
+		}
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Path = imp.Path
+		stmt.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	} else {
+		if !p.expectPeek(token.STRING) {
+			return nil
+		}
+		stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.expectPeek(token.IMPORT) {
+			return nil
+		}
+		for {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			stmt.Names = append(stmt.Names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+			if !p.peekTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+/*
+parseExportStatement parses export followed by a let, const, fn or class declaration, which gets the doc comment
+written above the export, or by a list of names in braces. Exports make up the module a file defines, so they are
+only allowed at its top level.
+*/
+func (p *Parser) parseExportStatement(doc string) ast.Statement {
+	defer p.untrace(p.trace("parseExportStatement"))
+
+	stmt := &ast.ExportStatement{Token: p.curToken}
+
+	if p.blocks > 0 {
+		p.curError("export is only allowed at the top level of a file")
+		return nil
+	}
+
+	if p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		for {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			stmt.Names = append(stmt.Names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+			if !p.peekTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+		if !p.expectPeek(token.RBRACE) {
+			return nil
+		}
+		stmt.Rbrace = p.curToken.Pos
+
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+		return stmt
+	}
+
+	switch {
+	case p.peekTokenIs(token.LET), p.peekTokenIs(token.CONST), p.peekTokenIs(token.CLASS):
+	case p.peekTokenIs(token.FUNCTION):
+		p.nextToken()
+		if !p.peekTokenIs(token.IDENT) {
+			p.peekTokenError("expected the name of the function to export, got %s instead", p.peekToken.Type)
+			return nil
+		}
+		stmt.Declaration = p.parseFunctionStatement()
+		if fs, ok := stmt.Declaration.(*ast.FunctionStatement); ok {
+			fs.Doc = doc
+			return stmt
+		}
+		return nil
+	default:
+		p.peekTokenError("expected let, const, fn, class or { after export, got %s instead", p.peekToken.Type)
+		return nil
+	}
+
+	p.nextToken()
+	switch decl := p.parseStatement().(type) {
+	case *ast.LetStatement:
+		decl.Doc = doc
+		stmt.Declaration = decl
+	case *ast.ClassStatement:
+		decl.Doc = doc
+		stmt.Declaration = decl
+	default:
+		return nil
+	}
+
+	return stmt
+}
+
 // parseBlockStatement calls parseStatement until it encounters either a }, which signifies the end of the
 // block statement, or a token.EOF, which tells us that there’s no more tokens left to parse. In that case, we can’t
 // successfully parse the block statement and there’s no need to keep on calling parseStatement in an endless loop.
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	defer p.untrace(p.trace("parseBlockStatement"))
+
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 
+	p.blocks++
+	defer func() { p.blocks-- }()
+
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		errors := len(p.errors)
+		p.illegal = false
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if len(p.errors) > errors {
+			p.synchronize(true)
+		} else if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	block.Rbrace = p.curToken.Pos
 
 	return block
 }
 
 // parseFunctionLiteral parses the parameters and block statement in a given function
 func (p *Parser) parseFunctionLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseFunctionLiteral"))
+
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
-	if !p.expectPeek(token.LPAREN) {
+	if !p.parseFunctionSignature(lit) {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	return lit
+}
 
-	if !p.expectPeek(token.LBRACE) {
+/*
+parseShortFunctionLiteral parses |a, b| a + b, the short form of fn(a, b) { return a + b; }. The parameters between the
+pipes take the same forms as in fn's parentheses, and the body is a single expression that the function returns. It
+reaches as far as an expression can, so |x| x + 1 returns x + 1; a comma or closing bracket ends it, which is what lets
+it be passed straight to a call: map(xs, |x| x * 2).
+
+The body is stored the way the long form would have it, as a block holding a return statement, so nothing past the
+parser needs to know which form was written. The literal keeps the opening pipe as its token for the ones that do.
+*/
+func (p *Parser) parseShortFunctionLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseShortFunctionLiteral"))
+
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+
+	if !p.parseFunctionParameters(lit, token.PIPE) {
 		return nil
 	}
 
-	lit.Body = p.parseBlockStatement()
+	p.nextToken()
+	body := p.parseExpression(LOWEST)
+	if body == nil {
+		return nil
+	}
+
+	ret := &ast.ReturnStatement{Token: token.Token{Type: token.RETURN, Literal: "return", Pos: body.Pos()}, ReturnValue: body}
+	lit.Body = &ast.BlockStatement{
+		Token:      token.Token{Type: token.LBRACE, Literal: "{", Pos: body.Pos()},
+		Statements: []ast.Statement{ret},
+	}
 
 	return lit
 }
 
-// parseFunctionParameters method we use here to parse the literal’s parameters
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+/*
+parseClassStatement parses class Name { ... }. The body holds fields, each a name with an optional default and ended by
+a semicolon, and methods, which are written like function declarations. The two can come in any order, but every name
+can only be used once. Doc comments above a method are kept on its FunctionStatement.
+*/
+func (p *Parser) parseClassStatement() *ast.ClassStatement {
+	defer p.untrace(p.trace("parseClassStatement"))
+
+	stmt := &ast.ClassStatement{Token: p.curToken}
 
-	if p.peekTokenIs(token.RPAREN) {
-		p.nextToken()
-		return identifiers
+	if !p.expectPeek(token.IDENT) {
+		return nil
 	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
-	p.nextToken()
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	// a method's name is long behind by the time its body has been parsed, so the error points at the name itself
+	seen := map[string]bool{}
+	member := func(name *ast.Identifier) bool {
+		if seen[name.Value] {
+			message := fmt.Sprintf("duplicate member %s in class %s", name.Value, stmt.Name.Value)
+			p.errors = append(p.errors, &Error{Message: message, Token: name.Token})
+			return false
+		}
+		seen[name.Value] = true
+		return true
+	}
+
+	p.nextToken()
+	for !p.curTokenIs(token.RBRACE) {
+		doc := p.takeDocs()
+
+		switch p.curToken.Type {
+		case token.IDENT:
+			field := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !member(field) {
+				return nil
+			}
+			stmt.Fields = append(stmt.Fields, field)
+
+			if p.peekTokenIs(token.ASSIGN) {
+				p.nextToken()
+				p.nextToken()
+				for len(stmt.Defaults) < len(stmt.Fields)-1 {
+					stmt.Defaults = append(stmt.Defaults, nil)
+				}
+				stmt.Defaults = append(stmt.Defaults, p.parseExpression(LOWEST))
+			} else if len(stmt.Defaults) > 0 {
+				p.curError("field %s without a default follows a field with one", field.Value)
+				return nil
+			}
+
+			if !p.expectPeek(token.SEMICOLON) {
+				return nil
+			}
+
+		case token.FUNCTION:
+			method, ok := p.parseFunctionStatement().(*ast.FunctionStatement)
+			if !ok || !member(method.Name) {
+				return nil
+			}
+			method.Doc = doc
+			stmt.Methods = append(stmt.Methods, method)
+
+		default:
+			p.curError("expected field or method in class %s, got %s instead", stmt.Name.Value, p.curToken.Type)
+			return nil
+		}
 
-	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
+	}
+	stmt.Rbrace = p.curToken.Pos
+
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	return stmt
+}
+
+// parseFunctionStatement parses fn name(params) { ... }. It is sitting on the fn token and the name has already been
+// seen as the peek token by parseStatement.
+func (p *Parser) parseFunctionStatement() ast.Statement {
+	defer p.untrace(p.trace("parseFunctionStatement"))
+
+	stmt := &ast.FunctionStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 
-	return identifiers
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	stmt.Function = &ast.FunctionLiteral{Token: stmt.Token}
+
+	if !p.parseFunctionSignature(stmt.Function) {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseFunctionSignature parses the parenthesized parameter list and the body that follow fn or a function's name
+// into lit. It returns false if either is malformed.
+func (p *Parser) parseFunctionSignature(lit *ast.FunctionLiteral) bool {
+	defer p.untrace(p.trace("parseFunctionSignature"))
+
+	if !p.expectPeek(token.LPAREN) {
+		return false
+	}
+
+	if !p.parseFunctionParameters(lit, token.RPAREN) {
+		return false
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return false
+	}
+
+	lit.Body = p.parseBlockStatement()
+
+	return true
+}
+
+/*
+parseFunctionParameters parses the literal's parameter list into lit. Each parameter is one of
+- a plain name: a
+- a name with a default value: b = 10
+- a rest parameter collecting every extra argument into an array: ...rest
+
+Parameters with defaults can't be followed by ones without, and the rest parameter has to come last. The list ends at
+closing, a right parenthesis or, for the short form, the second pipe. It returns false if the parameter list is
+malformed, having added an error to the parser.
+*/
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral, closing token.TokenType) bool {
+	defer p.untrace(p.trace("parseFunctionParameters"))
+
+	lit.Parameters = []*ast.Identifier{}
+
+	if p.peekTokenIs(closing) {
+		p.nextToken()
+		return true
+	}
+
+	for {
+		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+			if p.peekTokenIs(token.COMMA) {
+				p.peekTokenError("rest parameter ...%s must be the last parameter", lit.Rest.Value)
+				return false
+			}
+			break
+		}
+
+		if !p.curTokenIs(token.IDENT) {
+			p.curError("expected parameter name, got %s instead", p.curToken.Type)
+			return false
+		}
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		lit.Parameters = append(lit.Parameters, ident)
+
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			for len(lit.Defaults) < len(lit.Parameters)-1 {
+				lit.Defaults = append(lit.Defaults, nil)
+			}
+			lit.Defaults = append(lit.Defaults, p.parseExpression(LOWEST))
+		} else if len(lit.Defaults) > 0 {
+			p.curError("parameter %s without a default follows a parameter with one", ident.Value)
+			return false
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	return p.expectPeek(closing)
 }
 
 // parseCallExpression receives the already parsed function as argument and uses it to construct
 // an *ast.CallExpression node. To parse the argument list we call parseCallArguments.
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseCallExpression"))
+
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Rparen = p.curToken.Pos
 	return exp
 }
 
 // parseCallArguments returns a slice of ast.Expression and not *ast.Identifier.
 func (p *Parser) parseCallArguments() []ast.Expression {
+	defer p.untrace(p.trace("parseCallArguments"))
+
 	args := []ast.Expression{}
 
 	if p.peekTokenIs(token.RPAREN) {
@@ -581,19 +1275,26 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseStringLiteral"))
+
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseArrayLiteral"))
+
 	array := &ast.ArrayLiteral{Token: p.curToken}
 
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbracket = p.curToken.Pos
 
 	return array
 }
 
-// parseExpressionList parses a list of comma separated arguments
+// parseExpressionList parses a list of comma separated arguments, any of which may be spread with ...
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	defer p.untrace(p.trace("parseExpressionList"))
+
 	list := []ast.Expression{}
 
 	if p.peekTokenIs(end) {
@@ -602,12 +1303,12 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	}
 
 	p.nextToken()
-	list = append(list, p.parseExpression(LOWEST))
+	list = append(list, p.parseListElement())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		list = append(list, p.parseExpression(LOWEST))
+		list = append(list, p.parseListElement())
 	}
 
 	if !p.expectPeek(end) {
@@ -617,15 +1318,70 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	return list
 }
 
+// parseListElement parses one element of an expression list, which unlike any other expression may be spread with ...
+func (p *Parser) parseListElement() ast.Expression {
+	if !p.curTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+
+	spread := &ast.SpreadExpression{Token: p.curToken}
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+	if spread.Value == nil {
+		return nil
+	}
+
+	return spread
+}
+
+// parseIndexExpression parses left[index] as well as the slice expressions left[start:end], left[:end], left[start:]
+// and left[:]. A colon inside the brackets is what makes it a slice.
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	defer p.untrace(p.trace("parseIndexExpression"))
+
+	tok := p.curToken
+	optional := p.curTokenIs(token.OPTIONAL_LBRACKET)
 
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+
+	var index ast.Expression
+	if !p.curTokenIs(token.COLON) {
+		index = p.parseExpression(LOWEST)
+		if !p.peekTokenIs(token.COLON) {
+			if !p.expectPeek(token.RBRACKET) {
+				return nil
+			}
+			return &ast.IndexExpression{Token: tok, Left: left, Index: index, Optional: optional, Rbracket: p.curToken.Pos}
+		}
+		p.nextToken()
+	}
+
+	slice := &ast.SliceExpression{Token: tok, Left: left, Low: index, Optional: optional}
+
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		slice.High = p.parseExpression(LOWEST)
+	}
 
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	slice.Rbracket = p.curToken.Pos
+
+	return slice
+}
+
+// parseDotExpression parses left.name. Only a name can follow the dot; hash[key] is still the way to get at keys that
+// aren't names.
+func (p *Parser) parseDotExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseDotExpression"))
+
+	exp := &ast.DotExpression{Token: p.curToken, Left: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
 	return exp
 }
@@ -633,6 +1389,8 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 // parseHashLiteral loops over key-value expression pairs by checking for a closing token.RBRACE and calling
 // parseExpression two times. That and the filling of hash.Pairs are the most important parts of this method.
 func (p *Parser) parseHashLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseHashLiteral"))
+
 	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
 
@@ -648,6 +1406,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -657,6 +1416,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.Rbrace = p.curToken.Pos
 
 	return hash
 }
@@ -664,14 +1424,64 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 /*
 These are helper functions for Parser that add entries to the associated maps.
 
-prefixParseFns gets called when we encounter the associated token type in prefix position and
-infixParseFn gets called when we encounter the token type in infix position.
+A PrefixParseFn gets called when we encounter the associated token type in prefix position and an InfixParseFn gets
+called when we encounter the token type in infix position.
+
+They are exported so that programs embedding sloth can teach the parser operators of their own, usually for tokens
+registered with token.RegisterOperator or token.RegisterKeyword. A parse function is called with the operator as the
+current token and has to leave the parser on the last token of what it parsed, the same as the parser's own do;
+CurToken, PeekToken, NextToken, ExpectPeek and ParseExpression are there for it to do that with. Registering a
+function for a token type that already has one replaces it.
 */
 
-func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
+// RegisterPrefix registers fn for tokenType in prefix position.
+func (p *Parser) RegisterPrefix(tokenType token.TokenType, fn PrefixParseFn) {
 	p.prefixParseFns[tokenType] = fn
 }
 
-func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
+// RegisterInfix registers fn for tokenType in infix position. An infix operator also needs a precedence higher than
+// LOWEST to ever be parsed as one, see SetPrecedence.
+func (p *Parser) RegisterInfix(tokenType token.TokenType, fn InfixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
+
+// SetPrecedence gives tokenType the precedence this parser parses it with in infix position, one of the constants
+// from LOWEST to INDEX or anything in between. It takes priority over the built in precedence of tokenType.
+func (p *Parser) SetPrecedence(tokenType token.TokenType, precedence int) {
+	if p.precedences == nil {
+		p.precedences = make(map[token.TokenType]int)
+	}
+	p.precedences[tokenType] = precedence
+}
+
+// CurToken returns the token the parser is on.
+func (p *Parser) CurToken() token.Token {
+	return p.curToken
+}
+
+// PeekToken returns the token after the one the parser is on.
+func (p *Parser) PeekToken() token.Token {
+	return p.peekToken
+}
+
+// NextToken moves the parser on to the next token.
+func (p *Parser) NextToken() {
+	p.nextToken()
+}
+
+// ExpectPeek moves on to the next token if it is of type t and reports whether it did. If it isn't, an error saying
+// so is added to the parser's errors.
+func (p *Parser) ExpectPeek(t token.TokenType) bool {
+	return p.expectPeek(t)
+}
+
+// ParseExpression parses the expression starting at the current token, up to the first infix operator that doesn't
+// bind more tightly than precedence.
+func (p *Parser) ParseExpression(precedence int) ast.Expression {
+	return p.parseExpression(precedence)
+}
+
+// Errorf adds an error about the current token to the parser's errors.
+func (p *Parser) Errorf(format string, args ...any) {
+	p.curError(format, args...)
+}
@@ -1,10 +1,15 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"github.com/sean-d/sloth/ast"
 	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/token"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestLetStatements(t *testing.T) {
@@ -41,6 +46,79 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestConstStatements(t *testing.T) {
+	input := "const x = 5;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+
+	if !stmt.IsConst() {
+		t.Errorf("stmt.IsConst() is false for %q", input)
+	}
+
+	if stmt.String() != input {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+
+	testLiteralExpression(t, stmt.Value, 5)
+}
+
+func TestDocComments(t *testing.T) {
+	input := `
+/// Adds a and b.
+///
+/// Returns the sum.
+fn add(a, b) { a + b }
+/// The answer.
+const answer = add(40, 2);
+/// Not attached to anything.
+puts(answer);
+let undocumented = 1;
+`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 4 {
+		t.Fatalf("program.Statements does not contain 4 statements. got=%d", len(program.Statements))
+	}
+
+	fs, ok := program.Statements[0].(*ast.FunctionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.FunctionStatement. got=%T", program.Statements[0])
+	}
+	if fs.Doc != "Adds a and b.\n\nReturns the sum." {
+		t.Errorf("fs.Doc wrong. got=%q", fs.Doc)
+	}
+
+	tests := []struct {
+		index int
+		doc   string
+	}{
+		{1, "The answer."},
+		{3, ""},
+	}
+
+	for _, tt := range tests {
+		ls, ok := program.Statements[tt.index].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("program.Statements[%d] is not *ast.LetStatement. got=%T", tt.index, program.Statements[tt.index])
+		}
+		if ls.Doc != tt.doc {
+			t.Errorf("program.Statements[%d].Doc wrong. expected=%q, got=%q", tt.index, tt.doc, ls.Doc)
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -77,6 +155,37 @@ func TestReturnStatements(t *testing.T) {
 	}
 }
 
+func TestThrowStatements(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedValue interface{}
+	}{
+		{"throw 5;", 5},
+		{"throw true", true},
+		{"throw foobar;", "foobar"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+
+		throwStmt, ok := program.Statements[0].(*ast.ThrowStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.ThrowStatement. got=%T", program.Statements[0])
+		}
+		if !testLiteralExpression(t, throwStmt.Value, tt.expectedValue) {
+			return
+		}
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := "foobar;"
 
@@ -139,6 +248,27 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "9223372036854775808;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Big == nil {
+		t.Fatalf("literal.Big is nil")
+	}
+	if literal.Big.String() != "9223372036854775808" {
+		t.Errorf("literal.Big not %s. got=%s", "9223372036854775808", literal.Big)
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -149,6 +279,8 @@ func TestParsingPrefixExpressions(t *testing.T) {
 		{"-15;", "-", 15},
 		{"!foobar;", "!", "foobar"},
 		{"-foobar;", "-", "foobar"},
+		{"+15;", "+", 15},
+		{"+foobar;", "+", "foobar"},
 		{"!true;", "!", true},
 		{"!false;", "!", false},
 	}
@@ -345,10 +477,30 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"a * [1, 2, 3, 4][b * c] * d",
 			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
 		},
+		{
+			"-a.b * c.d[0]",
+			"((-(a.b)) * ((c.d)[0]))",
+		},
+		{
+			"a.b.c(d.e)",
+			"((a.b).c)((d.e))",
+		},
 		{
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a ?? b == c",
+			"(a ?? (b == c))",
+		},
+		{
+			"a ?? b ?? c",
+			"((a ?? b) ?? c)",
+		},
+		{
+			"a?[1]?[2] ?? 3",
+			"(((a?[1])?[2]) ?? 3)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -509,6 +661,88 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestTryExpression(t *testing.T) {
+	input := `try { x } catch (e) { y }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.TryExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.TryExpression. got=%T",
+			stmt.Expression)
+	}
+
+	if len(exp.Body.Statements) != 1 || len(exp.Catch.Statements) != 1 {
+		t.Fatalf("try and catch blocks should have 1 statement each. got=%d, %d",
+			len(exp.Body.Statements), len(exp.Catch.Statements))
+	}
+
+	body := exp.Body.Statements[0].(*ast.ExpressionStatement)
+	if !testIdentifier(t, body.Expression, "x") {
+		return
+	}
+
+	if !testIdentifier(t, exp.Param, "e") {
+		return
+	}
+
+	catch := exp.Catch.Statements[0].(*ast.ExpressionStatement)
+	if !testIdentifier(t, catch.Expression, "y") {
+		return
+	}
+
+	for _, input := range []string{"try { x }", "try { x } catch { y }", "try { x } catch (1) { y }"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestSpawnExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"spawn f", "spawn f"},
+		{"spawn f(1, 2)", "spawn f(1, 2)"},
+		{"spawn fn() { x }", "spawn fn() x"},
+		{"spawn f + 1", "(spawn f + 1)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -586,6 +820,241 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameterParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 10) {}", "fn(a, b = 10) "},
+		{"fn(a = 1 + 2, b = a) {}", "fn(a = (1 + 2), b = a) "},
+		{"fn(...rest) {}", "fn(...rest) "},
+		{"fn(a, b = 10, ...rest) {}", "fn(a, b = 10, ...rest) "},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if function.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, function.String())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a = 1, b) {}", "parameter b without a default follows a parameter with one"},
+		{"fn(...rest, a) {}", "rest parameter ...rest must be the last parameter"},
+		{"fn(1) {}", "expected parameter name, got INT instead"},
+	}
+
+	for _, tt := range errorTests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want first=%q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestShortFunctionLiteralParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"|x, y| x + y", "|x, y| (x + y)"},
+		{"|| 42", "|| 42"},
+		{"|a, b = 10, ...rest| rest", "|a, b = 10, ...rest| rest"},
+		{"|x| |y| x + y", "|x| |y| (x + y)"},
+		{"map(xs, |x| x * 2)", "map(xs, |x| (x * 2))"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program for %q. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	// the body is stored the way fn(x) { return x * 2; } would have it
+	p := New(lexer.New("|x| x * 2"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("function.Body.Statements has not 1 statement. got=%d", len(function.Body.Statements))
+	}
+	ret, ok := function.Body.Statements[0].(*ast.ReturnStatement)
+	if !ok {
+		t.Fatalf("function body stmt is not ast.ReturnStatement. got=%T", function.Body.Statements[0])
+	}
+	testInfixExpression(t, ret.ReturnValue, "x", "*", 2)
+
+	if end := function.End(); end.Offset != len("|x| x * 2") {
+		t.Errorf("function.End() wrong. got=%d", end.Offset)
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"|x x", "expected next token to be |, got IDENT instead"},
+		{"|1| x", "expected parameter name, got INT instead"},
+		{"|x|", "no prefix parse function for EOF found"},
+	}
+
+	for _, tt := range errorTests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want first=%q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestDotExpressionParsing(t *testing.T) {
+	p := New(lexer.New("person.name"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	dot, ok := stmt.Expression.(*ast.DotExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.DotExpression. got=%T", stmt.Expression)
+	}
+	testIdentifier(t, dot.Left, "person")
+	testIdentifier(t, dot.Name, "name")
+
+	for _, input := range []string{"person.1", "person.", "person.if"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || !strings.HasPrefix(p.Errors()[0], "expected next token to be IDENT") {
+			t.Errorf("wrong errors for %q. got=%v", input, p.Errors())
+		}
+	}
+}
+
+func TestClassStatementParsing(t *testing.T) {
+	input := `
+/// A point.
+class Point {
+  x;
+  y = x * 2;
+  /// The squared norm.
+  fn norm() { self.x * self.x + self.y * self.y }
+  fn scale(by) { Point(self.x * by, self.y * by) };
+}`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ClassStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ClassStatement. got=%T", program.Statements[0])
+	}
+
+	if stmt.Name.Value != "Point" || stmt.Doc != "A point." {
+		t.Errorf("wrong name or doc. got %q, %q", stmt.Name.Value, stmt.Doc)
+	}
+	if params := ast.ParameterList(stmt.Fields, stmt.Defaults, nil); params != "x, y = (x * 2)" {
+		t.Errorf("wrong fields. got %q", params)
+	}
+	if len(stmt.Methods) != 2 || stmt.Methods[0].Name.Value != "norm" || stmt.Methods[1].Name.Value != "scale" {
+		t.Fatalf("wrong methods. got %v", stmt.Methods)
+	}
+	if stmt.Methods[0].Doc != "The squared norm." {
+		t.Errorf("wrong method doc. got %q", stmt.Methods[0].Doc)
+	}
+
+	constructor := stmt.Constructor()
+	if params := ast.ParameterList(constructor.Parameters, constructor.Defaults, constructor.Rest); params != "x, y = (x * 2)" {
+		t.Errorf("wrong constructor parameters. got %q", params)
+	}
+	hash := constructor.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.HashLiteral)
+	keys := []string{}
+	for _, key := range hash.OrderedKeys() {
+		keys = append(keys, key.String())
+	}
+	if strings.Join(keys, " ") != "x y norm scale" {
+		t.Errorf("wrong constructor keys. got %v", keys)
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"class { x; }", "expected next token to be IDENT, got { instead"},
+		{"class P { x }", "expected next token to be ;, got } instead"},
+		{"class P { x = 1; y; }", "field y without a default follows a field with one"},
+		{"class P { x; fn x() {} }", "duplicate member x in class P"},
+		{"class P { 1; }", "expected field or method in class P, got INT instead"},
+		{"class P { x;", "expected field or method in class P, got EOF instead"},
+	}
+
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want first=%q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestFunctionStatementParsing(t *testing.T) {
+	input := `fn add(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.FunctionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.FunctionStatement. got=%T", program.Statements[0])
+	}
+
+	if stmt.Name.Value != "add" {
+		t.Errorf("stmt.Name.Value not 'add'. got=%s", stmt.Name.Value)
+	}
+
+	if len(stmt.Function.Parameters) != 2 {
+		t.Fatalf("function literal parameters wrong. want 2, got=%d", len(stmt.Function.Parameters))
+	}
+
+	testLiteralExpression(t, stmt.Function.Parameters[0], "x")
+	testLiteralExpression(t, stmt.Function.Parameters[1], "y")
+
+	if stmt.String() != "fn add(x, y) (x + y)" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -853,6 +1322,76 @@ func TestParsingArrayLiterals(t *testing.T) {
 	testInfixExpression(t, array.Elements[2], 3, "+", 3)
 }
 
+func TestParsingSpreadExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"f(...args)", "f(...args)"},
+		{"f(1, ...a + b, 2)", "f(1, ...(a + b), 2)"},
+		{"[1, ...rest, 9]", "[1, ...rest, 9]"},
+		{"[...[1], ...f(...x)]", "[...[1], ...f(...x)]"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program for %q. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	for _, input := range []string{"...x", "let y = ...x;", "f(...)", "[1, ...]"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q should not parse", input)
+		}
+	}
+}
+
+func TestParsingImports(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib.sloth"`, `import "lib.sloth"`},
+		{`let m = import "a/b.sloth"; m.f()`, `let m = import "a/b.sloth";(m.f)()`},
+		{`(import "lib.sloth").x`, `(import "lib.sloth".x)`},
+		{`import "lib.sloth" as lib; lib.x`, `import "lib.sloth" as lib;(lib.x)`},
+		{`from "lib.sloth" import a, b; a + b`, `from "lib.sloth" import a, b;(a + b)`},
+		{`fn f() { from "lib.sloth" import a }`, `fn f() from "lib.sloth" import a;`},
+		{`export let x = 1; export const y = 2`, `export let x = 1;export const y = 2;`},
+		{`export fn f() { 1 } export class P { x; }`, `export fn f() 1export class P { x; }`},
+		{`export { a, b }; export {c}`, `export { a, b };export { c };`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program for %q. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	for _, input := range []string{"import", "import lib", `import ("lib.sloth")`, `import "lib.sloth" as`, `import "lib.sloth" as 1`,
+		`from "lib.sloth" import`, `from "lib.sloth" import a,`, `from lib import a`, `from "lib.sloth" a`,
+		`let x = import "lib.sloth" as lib`, `export`, `export 1`, `export fn() { 1 }`, `export {}`, `export { a, }`,
+		`export { a`, `fn f() { export let x = 1; }`, `if (true) { export { a } }`, `export export let x = 1`} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q should not parse", input)
+		}
+	}
+}
+
 func TestParsingIndexExpressions(t *testing.T) {
 	input := "myArray[1 + 1]"
 
@@ -876,6 +1415,51 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input string
+		start interface{}
+		end   interface{}
+	}{
+		{"myArray[1:4]", 1, 4},
+		{"myArray[:2]", nil, 2},
+		{"myArray[3:]", 3, nil},
+		{"myArray[:]", nil, nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		sliceExp, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+
+		if !testIdentifier(t, sliceExp.Left, "myArray") {
+			return
+		}
+
+		for _, bound := range []struct {
+			exp      ast.Expression
+			expected interface{}
+		}{{sliceExp.Low, tt.start}, {sliceExp.High, tt.end}} {
+			if bound.expected == nil {
+				if bound.exp != nil {
+					t.Errorf("%q: expected omitted bound. got=%s", tt.input, bound.exp.String())
+				}
+				continue
+			}
+			if !testLiteralExpression(t, bound.exp, bound.expected) {
+				return
+			}
+		}
+	}
+}
+
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `{"one": 1, "two": 2, "three": 3}`
 
@@ -983,6 +1567,332 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 	}
 }
 
+func TestParserErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let = 5; let x 5; let y = 10; let f = fn(a, { a }; puts(y); let z = ;",
+			[]string{
+				"expected next token to be IDENT, got = instead",
+				"expected next token to be =, got INT instead",
+				"expected parameter name, got { instead",
+				"no prefix parse function for ; found",
+			},
+		},
+		{
+			"let x 5\nlet y = 10\nreturn )",
+			[]string{
+				"expected next token to be =, got INT instead",
+				"no prefix parse function for ) found",
+			},
+		},
+		{
+			"fn() { let = 1; let b = 2; let c 3 }; let d = ;",
+			[]string{
+				"expected next token to be IDENT, got = instead",
+				"expected next token to be =, got INT instead",
+				"no prefix parse function for ; found",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("wrong number of errors for %q. want=%d, got=%d (%q)", tt.input, len(tt.expected), len(errors), errors)
+			continue
+		}
+		for i, msg := range tt.expected {
+			if errors[i] != msg {
+				t.Errorf("wrong error %d for %q. want=%q, got=%q", i, tt.input, msg, errors[i])
+			}
+		}
+	}
+}
+
+func TestParserErrorPositions(t *testing.T) {
+	input := "let x = 1;\nlet y = add(x,\n  2;\nlet = 3;"
+
+	tests := []struct {
+		expectedMessage  string
+		expectedPos      token.Position
+		expectedToken    string
+		expectedPrevious string
+	}{
+		{"expected next token to be ), got ; instead", token.Position{Offset: 29, Line: 3, Column: 4}, ";", "2"},
+		{"expected next token to be IDENT, got = instead", token.Position{Offset: 35, Line: 4, Column: 5}, "=", "let"},
+	}
+
+	p := New(lexer.New(input))
+	p.ParseProgram()
+
+	errors := p.ParseErrors()
+	if len(errors) != len(tests) {
+		t.Fatalf("wrong number of errors. want=%d, got=%d (%q)", len(tests), len(errors), p.Errors())
+	}
+
+	for i, tt := range tests {
+		err := errors[i]
+		if err.Message != tt.expectedMessage {
+			t.Errorf("errors[%d] message wrong. want=%q, got=%q", i, tt.expectedMessage, err.Message)
+		}
+		if err.Pos() != tt.expectedPos {
+			t.Errorf("errors[%d] position wrong. want=%+v, got=%+v", i, tt.expectedPos, err.Pos())
+		}
+		if err.Token.Literal != tt.expectedToken {
+			t.Errorf("errors[%d] token wrong. want=%q, got=%q", i, tt.expectedToken, err.Token.Literal)
+		}
+		if err.Previous.Literal != tt.expectedPrevious {
+			t.Errorf("errors[%d] previous token wrong. want=%q, got=%q", i, tt.expectedPrevious, err.Previous.Literal)
+		}
+		if p.Errors()[i] != tt.expectedMessage {
+			t.Errorf("Errors()[%d] wrong. want=%q, got=%q", i, tt.expectedMessage, p.Errors()[i])
+		}
+	}
+
+	if got := errors[0].Error(); got != "3:4: expected next token to be ), got ; instead" {
+		t.Errorf("Error() wrong. got=%q", got)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	expected := `BEGIN parseStatement (-)
+	BEGIN parseExpressionStatement (-)
+		BEGIN parseExpression (-)
+			BEGIN parsePrefixExpression (-)
+				BEGIN parseExpression (a)
+					BEGIN parseIdentifier (a)
+					END parseIdentifier
+				END parseExpression
+			END parsePrefixExpression
+			BEGIN parseInfixExpression (+)
+				BEGIN parseExpression (2)
+					BEGIN parseIntegerLiteral (2)
+					END parseIntegerLiteral
+				END parseExpression
+			END parseInfixExpression
+		END parseExpression
+	END parseExpressionStatement
+END parseStatement
+`
+
+	var out strings.Builder
+	p := New(lexer.New("-a + 2;"), Trace(&out))
+	p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if out.String() != expected {
+		t.Errorf("wrong trace. want=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"  foobar;", "foobar"},
+		{"1 + 2 * 3;", "1 + 2 * 3"},
+		{"-(a + b);", "-(a + b"},
+		{`"hello" + "world"`, `"hello" + "world"`},
+		{"add(1, [2, 3])[0]", "add(1, [2, 3])[0]"},
+		{"a?[1:]", "a?[1:]"},
+		{`{"a": 1,
+  "b": 2}`, `{"a": 1,
+  "b": 2}`},
+		{"if (x) { 1 } else { 2 }", "if (x) { 1 } else { 2 }"},
+		{"fn(x) {\n  x\n}", "fn(x) {\n  x\n}"},
+		{"let x = 5;", "let x = 5"},
+		{"return x;", "return x"},
+		{"try { a } catch (e) { b }", "try { a } catch (e) { b }"},
+		{"spawn f(1)", "spawn f(1)"},
+		{"fn add(a) { a }", "fn add(a) { a }"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0]
+		got := tt.input[stmt.Pos().Offset:stmt.End().Offset]
+		if got != tt.expected {
+			t.Errorf("wrong span for %q. want=%q, got=%q (%s to %s)", tt.input, tt.expected, got, stmt.Pos(), stmt.End())
+		}
+	}
+
+	program := New(lexer.New("let a = 1;\nputs(\"x\ny\")")).ParseProgram()
+	if pos := program.Pos(); pos.Line != 1 || pos.Column != 1 {
+		t.Errorf("program.Pos() wrong. got=%s", pos)
+	}
+	if end := program.End(); end.Line != 3 || end.Column != 4 {
+		t.Errorf("program.End() wrong. got=%s", end)
+	}
+}
+
+func TestIllegalTokenErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 5 é;\nlet y = #;\nputs(x);", []string{"unexpected character U+00E9", "unexpected character '#'"}},
+		{"fn() { let a = ?; a }", []string{"unexpected character '?'"}},
+		{`puts("hi);`, []string{"unterminated string literal starting at line 1"}},
+		{"# let x = 1 +;", []string{"unexpected character '#'", "no prefix parse function for ; found"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errs := p.Errors()
+		if len(errs) != len(tt.expected) {
+			t.Errorf("wrong errors for %q. want=%q, got=%q", tt.input, tt.expected, errs)
+			continue
+		}
+		for i, msg := range tt.expected {
+			if errs[i] != msg {
+				t.Errorf("wrong error %d for %q. want=%q, got=%q", i, tt.input, msg, errs[i])
+			}
+		}
+	}
+}
+
+func TestPipeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x |> f", "f(x)"},
+		{"x |> f()", "f(x)"},
+		{"x |> f(1, 2)", "f(x, 1, 2)"},
+		{"x |> f |> g(1) |> h", "h(g(f(x), 1))"},
+		{"1 + 2 |> double", "double((1 + 2))"},
+		{"x |> f == y |> g", "(f(x) == g(y))"},
+		{"a ?? x |> f", "(a ?? f(x))"},
+		{"x |> m.f(1)", "(m.f)(x, 1)"},
+		{"x |> (y |> f)", "f(y)(x)"},
+		{"[1, 2] |> map(|n| n * 2)", "map([1, 2], |n| (n * 2))"},
+		{"x |> f >> g >> h", "((f >> g) >> h)(x)"},
+		{"f >> g == h", "((f >> g) == h)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.Statements[0].String() != tt.expected {
+			t.Errorf("wrong parse for %q. want=%q, got=%q", tt.input, tt.expected, program.Statements[0].String())
+		}
+	}
+
+	p := New(lexer.New("x |> f(1)"))
+	call := p.ParseProgram().Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if !call.Piped() {
+		t.Errorf("call written as a pipe is not Piped")
+	}
+	if call.Pos().Column != 1 || call.End().Column != 10 {
+		t.Errorf("wrong span for a pipe. got=%s-%s", call.Pos(), call.End())
+	}
+}
+
+func TestRegisteredOperators(t *testing.T) {
+	pipe := token.RegisterOperator("~>")
+	not := token.RegisterKeyword("not")
+
+	// x ~> f is f(x), and not x is !x
+	extend := func(p *Parser) {
+		p.SetPrecedence(pipe, NULLISH)
+		p.RegisterInfix(pipe, func(left ast.Expression) ast.Expression {
+			tok := p.CurToken()
+			p.NextToken()
+			function := p.ParseExpression(NULLISH)
+			if function == nil {
+				return nil
+			}
+			return &ast.CallExpression{Token: tok, Function: function, Arguments: []ast.Expression{left}}
+		})
+		p.RegisterPrefix(not, func() ast.Expression {
+			exp := &ast.PrefixExpression{Token: p.CurToken(), Operator: "!"}
+			p.NextToken()
+			exp.Right = p.ParseExpression(PREFIX)
+			return exp
+		})
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x ~> f", "f(x)"},
+		{"1 + 2 ~> double ~> puts", "puts(double((1 + 2)))"},
+		{"[1, 2] ~> len", "len([1, 2])"},
+		{"not true", "(!true)"},
+		{"not a ~> f", "f((!a))"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input), extend)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.Statements[0].String() != tt.expected {
+			t.Errorf("wrong parse for %q. want=%q, got=%q", tt.input, tt.expected, program.Statements[0].String())
+		}
+	}
+
+	// a parser that wasn't told about the operators still lexes them, but has no way of parsing them
+	p := New(lexer.New("x ~> f"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected an error parsing ~> without registering it")
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	input := io.MultiReader(strings.NewReader("let x = 1;"), iotest.ErrReader(errors.New("connection reset")))
+
+	p := New(lexer.NewReader(input))
+	program := p.ParseProgram()
+
+	if len(program.Statements) != 1 {
+		t.Errorf("wrong number of statements. got=%d", len(program.Statements))
+	}
+	if errs := p.Errors(); len(errs) != 1 || errs[0] != "could not read input: connection reset" {
+		t.Errorf("wrong errors. got=%q", errs)
+	}
+}
+
+// FuzzParse parses arbitrary input, which may fail with errors but must never panic, and prints what parsed.
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		"let x = 5; const y = x * (2 + -3);",
+		`fn add(a, b = 2, ...rest) { return a + b; } add(1)`,
+		`let h = {"a": [1, 2][0:1], true: |x| x}; h?["a"] ?? h.b`,
+		`if (x < y) { x } else { try { throw "boom"; } catch (e) { e } }`,
+		`class Point { x; y = 0; fn len() { self.x + self.y } }`,
+		`spawn fn() { puts("hi") }; /// doc comment`,
+		"let s = \"unterminated",
+		"((((((",
+		"fn(,) {",
+		"|||||||0(",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		_ = program.String()
+	})
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 	if len(errors) == 0 {
@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Option changes how a Parser behaves. Options are passed to New.
+type Option func(*Parser)
+
+/*
+Trace makes the parser log every parse function it enters and leaves to w, indented by how deeply the calls are
+nested, along with the token it was looking at on the way in:
+
+	BEGIN parseStatement (1)
+		BEGIN parseExpressionStatement (1)
+			BEGIN parseExpression (1)
+				BEGIN parseIntegerLiteral (1)
+				END parseIntegerLiteral
+				BEGIN parseInfixExpression (+)
+	...
+
+This is meant for working on the grammar: it shows exactly which functions a piece of input goes through and in
+which order, which is where precedence mistakes tend to hide.
+*/
+func Trace(w io.Writer) Option {
+	return func(p *Parser) {
+		p.tracer = w
+	}
+}
+
+// trace logs that the parse function name was entered and returns name for untrace, so both fit in one defer:
+//
+//	defer p.untrace(p.trace("parseExpression"))
+func (p *Parser) trace(name string) string {
+	if p.tracer == nil {
+		return name
+	}
+
+	p.tracePrint(fmt.Sprintf("BEGIN %s (%s)", name, p.curToken.Literal))
+	p.traceLevel++
+
+	return name
+}
+
+// untrace logs that the parse function name was left.
+func (p *Parser) untrace(name string) {
+	if p.tracer == nil {
+		return
+	}
+
+	p.traceLevel--
+	p.tracePrint("END " + name)
+}
+
+func (p *Parser) tracePrint(msg string) {
+	fmt.Fprintf(p.tracer, "%s%s\n", strings.Repeat("\t", p.traceLevel), msg)
+}
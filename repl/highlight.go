@@ -0,0 +1,92 @@
+package repl
+
+import (
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/token"
+	"strings"
+)
+
+// The ANSI escape sequences the REPL colors its output with.
+const (
+	COLOR_RESET   = "\x1b[0m"
+	COLOR_KEYWORD = "\x1b[35m" // magenta
+	COLOR_STRING  = "\x1b[32m" // green
+	COLOR_NUMBER  = "\x1b[33m" // yellow
+	COLOR_COMMENT = "\x1b[90m" // grey
+	COLOR_ERROR   = "\x1b[31m" // red
+)
+
+// Option changes how a REPL session behaves. Options are passed to Start.
+type Option func(*session)
+
+// NoColor turns off syntax highlighting, for terminals that don't understand ANSI colors or users who'd rather not.
+func NoColor() Option {
+	return func(s *session) {
+		s.color = false
+	}
+}
+
+// paint wraps text in the ANSI color code when color is set, and returns it as it is otherwise.
+func paint(color bool, code, text string) string {
+	if !color || code == "" || text == "" {
+		return text
+	}
+
+	return code + text + COLOR_RESET
+}
+
+/*
+highlight colors the sloth source src for the terminal. It runs src through the lexer and colors each token by its
+type, so whatever the lexer takes to be a keyword, including ones added with token.RegisterKeyword, is highlighted as
+one. Everything between tokens, whitespace and comments, is copied across as it is.
+*/
+func highlight(src string) string {
+	var out strings.Builder
+
+	l := lexer.New(src)
+	written := 0
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		start, end := tok.Pos.Offset, tok.End().Offset
+		if tok.Type == token.DOC_COMMENT {
+			// the literal leaves out the slashes, so the comment runs to the end of the line instead
+			if end = strings.IndexByte(src[start:], '\n'); end < 0 {
+				end = len(src)
+			} else {
+				end += start
+			}
+		}
+		if !tok.Pos.IsValid() || start < written || end > len(src) {
+			continue
+		}
+
+		out.WriteString(src[written:start])
+		out.WriteString(paint(true, tokenColor(tok), src[start:end]))
+		written = end
+	}
+	out.WriteString(src[written:])
+
+	return out.String()
+}
+
+// tokenColor returns the color tok is highlighted in, or "" for tokens that are left alone, such as identifiers and
+// operators.
+func tokenColor(tok token.Token) string {
+	switch tok.Type {
+	case token.STRING:
+		return COLOR_STRING
+	case token.INT:
+		return COLOR_NUMBER
+	case token.DOC_COMMENT:
+		return COLOR_COMMENT
+	case token.ILLEGAL:
+		return COLOR_ERROR
+	case token.IDENT:
+		return ""
+	}
+
+	if token.LookupIdent(tok.Literal) == tok.Type {
+		return COLOR_KEYWORD
+	}
+
+	return ""
+}
@@ -0,0 +1,71 @@
+package repl
+
+import (
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/token"
+	"strings"
+)
+
+// INDENT is what every level of open braces, brackets and parentheses indents a continuation line by.
+const INDENT = "  "
+
+// CLEAR_PREVIOUS_LINE moves the cursor up to the line just typed and clears it, so it can be printed again indented.
+const CLEAR_PREVIOUS_LINE = "\x1b[1A\r\x1b[2K"
+
+/*
+openDepth returns how many braces, brackets and parentheses src opens without closing again, and whether it ends
+inside a string that is still open, so the line after it carries on the string. Anything inside a string doesn't count,
+as it is worked out from the lexer's tokens, and neither does anything in a comment.
+*/
+func openDepth(src string) (depth int, inString bool) {
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LBRACE, token.LBRACKET, token.OPTIONAL_LBRACKET, token.LPAREN:
+			depth++
+		case token.RBRACE, token.RBRACKET, token.RPAREN:
+			depth--
+		case token.ILLEGAL:
+			// the lexer reads a string that isn't closed to the end of src
+			inString = strings.HasPrefix(tok.Literal, `"`)
+		}
+	}
+
+	return depth, inString
+}
+
+// leadingClosers returns how many closing braces, brackets and parentheses line starts with. Each of them closes a
+// level opened on an earlier line, so the line is indented that many levels less.
+func leadingClosers(line string) int {
+	closers := 0
+
+	l := lexer.New(line)
+	for tok := l.NextToken(); ; tok = l.NextToken() {
+		switch tok.Type {
+		case token.RBRACE, token.RBRACKET, token.RPAREN:
+			closers++
+		default:
+			return closers
+		}
+	}
+}
+
+/*
+indentLine indents line for the depth left open by the lines before it: INDENT once for every level still open after
+the closers line starts with. Whatever indentation line came with is replaced, so pasted or replayed input is indented
+the same way as input typed at the prompt. A line that starts inside a string is part of the string, and is kept
+exactly as it is.
+*/
+func indentLine(line string, depth int, inString bool) string {
+	if inString {
+		return line
+	}
+	line = strings.TrimLeft(line, " \t")
+
+	level := depth - leadingClosers(line)
+	if level < 0 {
+		level = 0
+	}
+
+	return strings.Repeat(INDENT, level) + line
+}
@@ -0,0 +1,68 @@
+package repl
+
+import "testing"
+
+func TestOpenDepth(t *testing.T) {
+	tests := []struct {
+		src      string
+		depth    int
+		inString bool
+	}{
+		{"", 0, false},
+		{"let f = fn(x) {", 1, false},
+		{"map(xs, fn(x) {", 2, false},
+		{"let a = [1, {\"b\": (2", 3, false},
+		{"xs?[", 1, false},
+		{"}", -1, false},
+		{`puts("({[")`, 0, false},
+		{`let s = "abc`, 0, true},
+		{`puts("(" + "`, 1, true},
+		{"let s = \"a\n  {\n", 0, true},
+		{"let s = \"a\n  (\";", 0, false},
+		{"[\"a\nb\", {", 2, false},
+		{"/// opens ( and {\nlet x = 1;", 0, false},
+		{"fn() {\n  /// closes }\n", 1, false},
+		{"/// a \" in a comment\nlet x = [", 1, false},
+	}
+
+	for _, tt := range tests {
+		depth, inString := openDepth(tt.src)
+		if depth != tt.depth || inString != tt.inString {
+			t.Errorf("openDepth(%q) = %d, %t, want %d, %t", tt.src, depth, inString, tt.depth, tt.inString)
+		}
+	}
+}
+
+func TestIndentLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		depth    int
+		inString bool
+		want     string
+	}{
+		{"x", 0, false, "x"},
+		{"x", 2, false, "    x"},
+		{"   \tx", 1, false, "  x"},
+		{"}", 1, false, "}"},
+		{"  })", 1, false, "})"},
+		{") + 1", 2, false, "  ) + 1"},
+		{`"}"`, 1, false, `  "}"`},
+		{"/// }", 1, false, "  /// }"},
+		{"   kept as typed", 1, true, "   kept as typed"},
+		{"  }\";", 2, true, "  }\";"},
+		{"", 1, true, ""},
+	}
+
+	for _, tt := range tests {
+		if got := indentLine(tt.line, tt.depth, tt.inString); got != tt.want {
+			t.Errorf("indentLine(%q, %d, %t) = %q, want %q", tt.line, tt.depth, tt.inString, got, tt.want)
+		}
+	}
+}
+
+func TestMultiLineString(t *testing.T) {
+	out := run("let s = \"one", "   two {", "", "}\";", "s == \"one\n   two {\n\n}\";")
+	if out != "true\n" {
+		t.Errorf("output = %q, want the string kept as it was typed", out)
+	}
+}
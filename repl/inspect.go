@@ -0,0 +1,185 @@
+package repl
+
+import (
+	"fmt"
+	"github.com/sean-d/sloth/ast"
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/parser"
+	"github.com/sean-d/sloth/token"
+	"io"
+	"strings"
+)
+
+// printTokens writes every token the lexer produces for input, one per line, up to and including EOF.
+func printTokens(out io.Writer, input string) {
+	l := lexer.New(input)
+
+	for {
+		tok := l.NextToken()
+		fmt.Fprintf(out, "%-10s %q\n", tok.Type, tok.Literal)
+		if tok.Type == token.EOF {
+			return
+		}
+	}
+}
+
+// printAST parses input and writes the resulting program as an indented tree, one node per line. Parser errors are
+// printed in red if color is set.
+func printAST(out io.Writer, input string, color bool) {
+	l := lexer.New(input)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(out, input, p.ParseErrors(), color)
+		return
+	}
+
+	writeTree(out, program, 0)
+}
+
+/*
+writeTree walks node depth first and writes one line per node, indented by its depth in the tree. Each line holds the
+node's Go type name and, for leaves, the value it carries. Children are labelled with the field they hang off of so
+that e.g. the Left and Right of an infix expression can be told apart.
+*/
+func writeTree(out io.Writer, node ast.Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	child := func(label string, n ast.Node) {
+		fmt.Fprintf(out, "%s  %s:\n", indent, label)
+		writeTree(out, n, depth+2)
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		fmt.Fprintf(out, "%sProgram\n", indent)
+		for _, s := range node.Statements {
+			writeTree(out, s, depth+1)
+		}
+	case *ast.LetStatement:
+		if node.IsConst() {
+			fmt.Fprintf(out, "%sLetStatement const %s\n", indent, node.Name.Value)
+		} else {
+			fmt.Fprintf(out, "%sLetStatement %s\n", indent, node.Name.Value)
+		}
+		child("Value", node.Value)
+	case *ast.FunctionStatement:
+		fmt.Fprintf(out, "%sFunctionStatement %s\n", indent, node.Name.Value)
+		child("Function", node.Function)
+	case *ast.ClassStatement:
+		fmt.Fprintf(out, "%sClassStatement %s (%s)\n", indent, node.Name.Value, ast.ParameterList(node.Fields, node.Defaults, nil))
+		for _, method := range node.Methods {
+			child("Method", method)
+		}
+	case *ast.ImportStatement:
+		if node.Alias != nil {
+			fmt.Fprintf(out, "%sImportStatement %q as %s\n", indent, node.Path.Value, node.Alias.Value)
+		} else {
+			fmt.Fprintf(out, "%sImportStatement %q (%s)\n", indent, node.Path.Value, ast.ParameterList(node.Names, nil, nil))
+		}
+	case *ast.ExportStatement:
+		if node.Declaration != nil {
+			fmt.Fprintf(out, "%sExportStatement\n", indent)
+			child("Declaration", node.Declaration)
+		} else {
+			fmt.Fprintf(out, "%sExportStatement (%s)\n", indent, ast.ParameterList(node.Names, nil, nil))
+		}
+	case *ast.ThrowStatement:
+		fmt.Fprintf(out, "%sThrowStatement\n", indent)
+		child("Value", node.Value)
+	case *ast.ReturnStatement:
+		fmt.Fprintf(out, "%sReturnStatement\n", indent)
+		child("ReturnValue", node.ReturnValue)
+	case *ast.ExpressionStatement:
+		fmt.Fprintf(out, "%sExpressionStatement\n", indent)
+		writeTree(out, node.Expression, depth+1)
+	case *ast.BlockStatement:
+		fmt.Fprintf(out, "%sBlockStatement\n", indent)
+		for _, s := range node.Statements {
+			writeTree(out, s, depth+1)
+		}
+	case *ast.Identifier:
+		fmt.Fprintf(out, "%sIdentifier %s\n", indent, node.Value)
+	case *ast.IntegerLiteral:
+		fmt.Fprintf(out, "%sIntegerLiteral %d\n", indent, node.Value)
+	case *ast.StringLiteral:
+		fmt.Fprintf(out, "%sStringLiteral %q\n", indent, node.Value)
+	case *ast.Boolean:
+		fmt.Fprintf(out, "%sBoolean %t\n", indent, node.Value)
+	case *ast.PrefixExpression:
+		fmt.Fprintf(out, "%sPrefixExpression %s\n", indent, node.Operator)
+		child("Right", node.Right)
+	case *ast.InfixExpression:
+		fmt.Fprintf(out, "%sInfixExpression %s\n", indent, node.Operator)
+		child("Left", node.Left)
+		child("Right", node.Right)
+	case *ast.IfExpression:
+		fmt.Fprintf(out, "%sIfExpression\n", indent)
+		child("Condition", node.Condition)
+		child("Consequence", node.Consequence)
+		if node.Alternative != nil {
+			child("Alternative", node.Alternative)
+		}
+	case *ast.TryExpression:
+		fmt.Fprintf(out, "%sTryExpression (%s)\n", indent, node.Param.Value)
+		child("Body", node.Body)
+		child("Catch", node.Catch)
+	case *ast.SpawnExpression:
+		fmt.Fprintf(out, "%sSpawnExpression\n", indent)
+		child("Function", node.Function)
+	case *ast.FunctionLiteral:
+		fmt.Fprintf(out, "%sFunctionLiteral (%s)\n", indent, ast.ParameterList(node.Parameters, node.Defaults, node.Rest))
+		child("Body", node.Body)
+	case *ast.CallExpression:
+		fmt.Fprintf(out, "%sCallExpression\n", indent)
+		child("Function", node.Function)
+		for i, a := range node.Arguments {
+			child(fmt.Sprintf("Arguments[%d]", i), a)
+		}
+	case *ast.ArrayLiteral:
+		fmt.Fprintf(out, "%sArrayLiteral\n", indent)
+		for i, e := range node.Elements {
+			child(fmt.Sprintf("Elements[%d]", i), e)
+		}
+	case *ast.SpreadExpression:
+		fmt.Fprintf(out, "%sSpreadExpression\n", indent)
+		child("Value", node.Value)
+	case *ast.ImportExpression:
+		fmt.Fprintf(out, "%sImportExpression %q\n", indent, node.Path.Value)
+	case *ast.IndexExpression:
+		if node.Optional {
+			fmt.Fprintf(out, "%sIndexExpression ?\n", indent)
+		} else {
+			fmt.Fprintf(out, "%sIndexExpression\n", indent)
+		}
+		child("Left", node.Left)
+		child("Index", node.Index)
+	case *ast.DotExpression:
+		fmt.Fprintf(out, "%sDotExpression (%s)\n", indent, node.Name.Value)
+		child("Left", node.Left)
+	case *ast.SliceExpression:
+		if node.Optional {
+			fmt.Fprintf(out, "%sSliceExpression ?\n", indent)
+		} else {
+			fmt.Fprintf(out, "%sSliceExpression\n", indent)
+		}
+		child("Left", node.Left)
+		if node.Low != nil {
+			child("Low", node.Low)
+		}
+		if node.High != nil {
+			child("High", node.High)
+		}
+	case *ast.HashLiteral:
+		fmt.Fprintf(out, "%sHashLiteral\n", indent)
+		for _, key := range node.OrderedKeys() {
+			child("Key", key)
+			child("Value", node.Pairs[key])
+		}
+	case nil:
+		fmt.Fprintf(out, "%s<nil>\n", indent)
+	default:
+		fmt.Fprintf(out, "%s%T %s\n", indent, node, node.String())
+	}
+}
@@ -7,10 +7,29 @@ import (
 	"github.com/sean-d/sloth/lexer"
 	"github.com/sean-d/sloth/object"
 	"github.com/sean-d/sloth/parser"
+	"github.com/sean-d/sloth/token"
 	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
 )
 
 const PROMPT = ">>> "
+
+// CONTINUATION_PROMPT is shown instead of PROMPT while an input that leaves braces, brackets or parentheses open is
+// being continued on the next line.
+const CONTINUATION_PROMPT = "... "
+const (
+	LOAD_COMMAND   = ":load"
+	TOKENS_COMMAND = ":tokens"
+	AST_COMMAND    = ":ast"
+	TIME_COMMAND   = ":time"
+	SAVE_COMMAND   = ":save"
+	REPLAY_COMMAND = ":replay"
+	PASTE_COMMAND  = ":paste"
+	END_COMMAND    = ":end"
+)
 const WELCOME_SLOTH = `
 ⣴⣦⣤⣄⣀⣠⣄⠀⣰⡆⣰⡆⠀⠀
 sloth 0.000001⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀
@@ -39,43 +58,426 @@ const SAD_FACE = `
 (◞‸ ◟)💧
 `
 
+/*
+Config is how a session starts, for programs that set up the REPL from flags or settings of their own. Banner is written
+before anything else, when it isn't empty. The files in Load are then evaluated into the session's environment, the
+way :load would, so their bindings are there at the first prompt. Quiet leaves out the prompts and the messages
+saying what was loaded, for when the REPL is driven by another program rather than typed at, and NoColor turns off
+highlighting like the NoColor option does. MaxSteps, when it isn't zero, is how many steps, see object.Budget, each
+input may take before it is stopped with an error, so an accidental infinite loop doesn't take the session with it.
+*/
+type Config struct {
+	Banner   string
+	Load     []string
+	Quiet    bool
+	NoColor  bool
+	MaxSteps int64
+}
+
+// WithConfig starts the session the way config says.
+func WithConfig(config Config) Option {
+	return func(s *session) {
+		s.config = config
+		if config.NoColor {
+			s.color = false
+		}
+	}
+}
+
 // Start reads from the input source until encountering a newline.
 // It takes the just read line and pass it to an instance of our lexer.
-// Finally, it prints all the tokens the lexer gives us until we encounter EOF.
-func Start(in io.Reader, out io.Writer) {
+// Finally, it prints all the tokens the lexer gives us until we encounter EOF or a call to exit.
+func Start(in io.Reader, out io.Writer, options ...Option) {
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	s := &session{env: object.NewEnvironment(), color: true}
+	for _, option := range options {
+		option(s)
+	}
+	if s.config.MaxSteps != 0 {
+		s.env = object.NewBudgetedEnvironment(&object.Budget{MaxSteps: s.config.MaxSteps})
+	}
+
+	io.WriteString(out, s.config.Banner)
+	for _, path := range s.config.Load {
+		loadFile(out, path, s)
+	}
 
 	for {
-		fmt.Fprintf(out, PROMPT)
+		io.WriteString(out, s.prompt())
 		scanned := scanner.Scan()
 		if !scanned {
+			s.endPaste(out)
+			return
+		}
+
+		if exit := s.input(out, scanner.Text()); exit {
 			return
 		}
+	}
+}
+
+/*
+session is the state a REPL session keeps between inputs: the environment everything is evaluated in, whether every
+input is timed, every input that was evaluated without an error so far, for :save, whether a :replay is running,
+whether output is highlighted, the lines of an input that is still being continued, how many results have been bound
+so far, see remember, the lines pasted so far while a :paste is running, and the Config it was started with.
+*/
+type session struct {
+	env       *object.Environment
+	timing    bool
+	history   []string
+	replaying bool
+	color     bool
+	pending   []string
+	results   int
+	pasting   bool
+	pasted    []string
+	config    Config
+}
+
+/*
+input handles a single line typed at the prompt, either a command or code to evaluate. Code that leaves braces,
+brackets, parentheses or a string open is held on to, and the lines after it are added to it, each indented by how many
+are still open, until they are all closed and the whole input is evaluated at once. Lines inside a string are added as
+they are. A continuation line that starts by closing one is printed again at its proper level when the session is in
+color, as the terminal understands ANSI escapes then. input returns true if the line called exit, which ends the
+session.
+*/
+func (s *session) input(out io.Writer, line string) bool {
+	if s.pasting {
+		if strings.TrimSpace(line) == END_COMMAND {
+			return s.endPaste(out)
+		}
+		s.pasted = append(s.pasted, line)
+		return false
+	}
+
+	if len(s.pending) == 0 && strings.HasPrefix(line, ":") {
+		return runCommand(out, line, s)
+	}
+
+	depth, inString := s.open()
+	indented := indentLine(line, depth, inString)
+	if s.color && !s.config.Quiet && !s.replaying && len(s.pending) > 0 && !inString && leadingClosers(indented) > 0 {
+		io.WriteString(out, CLEAR_PREVIOUS_LINE+CONTINUATION_PROMPT+indented+"\n")
+	}
+
+	s.pending = append(s.pending, indented)
+	source := strings.Join(s.pending, "\n")
+	if depth, inString := openDepth(source); depth > 0 || inString {
+		return false
+	}
+	s.pending = nil
+
+	exit, ok := evalLine(out, source, s, s.timing)
+	if ok {
+		s.history = append(s.history, source)
+	}
+
+	return exit
+}
+
+// endPaste evaluates everything pasted since :paste as one input, exactly as it was pasted. It does nothing when no
+// :paste is running. endPaste returns true if the pasted input called exit.
+func (s *session) endPaste(out io.Writer) bool {
+	if !s.pasting {
+		return false
+	}
+
+	source := strings.Join(s.pasted, "\n")
+	s.pasting, s.pasted = false, nil
+	if strings.TrimSpace(source) == "" {
+		return false
+	}
+
+	// kept wrapped in :paste and :end, so :replay doesn't take it apart line by line either
+	exit, ok := evalLine(out, source, s, s.timing)
+	if ok {
+		s.history = append(s.history, PASTE_COMMAND+"\n"+source+"\n"+END_COMMAND)
+	}
+
+	return exit
+}
+
+// remember binds result to _ and to _1, _2, ... in the order results come in, so _3 is the third result of the
+// session. Later inputs can use them without typing the expression again.
+func (s *session) remember(result object.Object) {
+	s.results++
+	s.env.Set("_", result)
+	s.env.Set(fmt.Sprintf("_%d", s.results), result)
+}
+
+// open returns how many braces, brackets and parentheses the input being continued leaves open, and whether it leaves
+// a string open.
+func (s *session) open() (depth int, inString bool) {
+	if len(s.pending) == 0 {
+		return 0, false
+	}
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
+	return openDepth(strings.Join(s.pending, "\n"))
+}
+
+// prompt returns what to show before the next line is read: PROMPT, or CONTINUATION_PROMPT followed by the
+// indentation for the next line while an input is being continued. Nothing is shown while pasting, so the prompt
+// doesn't get in between the pasted lines, or in a quiet session.
+func (s *session) prompt() string {
+	if s.pasting || s.config.Quiet {
+		return ""
+	}
+	if len(s.pending) == 0 {
+		return PROMPT
+	}
+
+	// the line after an open string goes into the string as typed, so it isn't indented
+	depth, inString := s.open()
+	if inString {
+		return CONTINUATION_PROMPT
+	}
+
+	return CONTINUATION_PROMPT + strings.Repeat(INDENT, depth)
+}
+
+/*
+evalLine parses and evaluates one input, which may span several lines, in the session's environment and prints the
+result, highlighted if the session is in color, and remembers it as _. With timing set it also reports how long
+parsing and evaluating took and how many allocations evaluating made. It returns exit set if the input called exit,
+which ends the session, and ok set if the input parsed and evaluated without an error.
+*/
+func evalLine(out io.Writer, line string, s *session, timing bool) (exit bool, ok bool) {
+	start := time.Now()
+
+	l := lexer.New(line)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(out, line, p.ParseErrors(), s.color)
+		return false, false
+	}
+
+	parsed := time.Now()
+	var before, after runtime.MemStats
+	if timing {
+		runtime.ReadMemStats(&before)
+	}
 
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+	evalStart := time.Now()
+	s.env.Budget().Reset()
+	evaluated := evaluator.Eval(program, s.env)
+	evalTime := time.Since(evalStart)
+
+	if timing {
+		runtime.ReadMemStats(&after)
+	}
+
+	if _, isExit := evaluated.(*object.Exit); isExit {
+		return true, true
+	}
+	ok = true
+	if evaluated != nil {
+		io.WriteString(out, result(evaluated, s.color))
+		io.WriteString(out, "\n")
+		if err, isError := evaluated.(*object.Error); isError {
+			writeSnippet(out, line, err.Pos)
+			ok = false
+		} else {
+			s.remember(evaluated)
+		}
+	}
+
+	if timing {
+		fmt.Fprintf(out, "parse: %s, eval: %s, allocs: %d\n", parsed.Sub(start), evalTime, after.Mallocs-before.Mallocs)
+	}
+
+	return false, ok
+}
+
+// runCommand dispatches a line starting with ':' to the matching REPL command. The command name is everything up
+// to the first space and the rest of the line is handed to the command as its argument. It returns true if the
+// command ended up calling exit, which ends the session.
+func runCommand(out io.Writer, line string, s *session) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case LOAD_COMMAND:
+		loadFile(out, arg, s)
+	case TOKENS_COMMAND:
+		printTokens(out, arg)
+	case AST_COMMAND:
+		printAST(out, arg, s.color)
+	case TIME_COMMAND:
+		// with an input, time just that input; on its own, toggle timing every input
+		if arg != "" {
+			exit, _ := evalLine(out, arg, s, true)
+			return exit
+		}
+		s.timing = !s.timing
+		if s.timing {
+			io.WriteString(out, "timing on\n")
+		} else {
+			io.WriteString(out, "timing off\n")
+		}
+	case SAVE_COMMAND:
+		saveSession(out, arg, s)
+	case REPLAY_COMMAND:
+		return replaySession(out, arg, s)
+	case PASTE_COMMAND:
+		// the lines that follow are collected by session.input until a lone :end
+		s.pasting = true
+		io.WriteString(out, "pasting, end with "+END_COMMAND+" on a line of its own\n")
+	default:
+		io.WriteString(out, "unknown command: "+name+"\n")
+	}
+
+	return false
+}
+
+// saveSession writes every input of the session that was evaluated without an error to the file at path, each on its
+// own line or lines, so the session can be picked up again later with :replay. Inputs without a :paste in them also
+// run as a script.
+func saveSession(out io.Writer, path string, s *session) {
+	if path == "" {
+		io.WriteString(out, "usage: "+SAVE_COMMAND+" path/to/session.sloth\n")
+		return
+	}
+
+	var source strings.Builder
+	for _, input := range s.history {
+		source.WriteString(input + "\n")
+	}
+
+	if err := os.WriteFile(path, []byte(source.String()), 0644); err != nil {
+		io.WriteString(out, "could not save session: "+err.Error()+"\n")
+		return
+	}
+
+	fmt.Fprintf(out, "saved %d inputs to %s\n", len(s.history), path)
+}
+
+/*
+replaySession runs the file at path line by line as if each line had been typed at the prompt, echoing it along with
+its result. Unlike :load, which evaluates a file as one program, this shows how a saved session unfolds, and replayed
+inputs become part of this session's history. It returns true if a replayed input called exit.
+*/
+func replaySession(out io.Writer, path string, s *session) bool {
+	if path == "" {
+		io.WriteString(out, "usage: "+REPLAY_COMMAND+" path/to/session.sloth\n")
+		return false
+	}
+	if s.replaying {
+		io.WriteString(out, "can't "+REPLAY_COMMAND+" during a replay\n")
+		return false
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		io.WriteString(out, "could not replay session: "+err.Error()+"\n")
+		return false
+	}
+
+	s.replaying = true
+	defer func() { s.replaying = false }()
+
+	for _, line := range strings.Split(strings.TrimRight(string(source), "\n"), "\n") {
+		depth, inString := s.open()
+		if strings.TrimSpace(line) == "" && !s.pasting && !inString {
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		prompt, echoed := s.prompt(), line
+		if len(s.pending) > 0 && !s.pasting && !s.config.Quiet {
+			prompt, echoed = CONTINUATION_PROMPT, indentLine(line, depth, inString)
+		}
+		if s.color {
+			echoed = highlight(echoed)
 		}
+		io.WriteString(out, prompt+echoed+"\n")
+		if exit := s.input(out, line); exit {
+			return true
+		}
+	}
+
+	// a :paste without an :end runs to the end of the file, as it would to the end of the input at the prompt
+	return s.endPaste(out)
+}
+
+// loadFile reads the file at path, parses it, and evaluates it into the session's environment so that all of its
+// bindings stay around for the rest of the REPL session. Errors are reported to out rather than ending the session.
+func loadFile(out io.Writer, path string, s *session) {
+	if path == "" {
+		io.WriteString(out, "usage: "+LOAD_COMMAND+" path/to/script.sloth\n")
+		return
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		io.WriteString(out, "could not load file: "+err.Error()+"\n")
+		return
+	}
+
+	l := lexer.New(string(source))
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(out, string(source), p.ParseErrors(), s.color)
+		return
 	}
+
+	s.env.Budget().Reset()
+	evaluated := evaluator.Eval(program, s.env)
+	if err, ok := evaluated.(*object.Error); ok {
+		io.WriteString(out, result(err, s.color))
+		io.WriteString(out, "\n")
+		writeSnippet(out, string(source), err.Pos)
+		return
+	}
+
+	if !s.config.Quiet {
+		io.WriteString(out, "loaded "+path+"\n")
+	}
+}
+
+/*
+result prints the value an input evaluated to the way Pretty does. In color, errors are printed in red, strings in
+green, and anything else is highlighted as sloth source; a string is left out of that as its contents aren't source.
+*/
+func result(evaluated object.Object, color bool) string {
+	printed := object.Pretty(evaluated)
+	if !color {
+		return printed
+	}
+
+	switch evaluated.(type) {
+	case *object.Error:
+		return paint(color, COLOR_ERROR, printed)
+	case *object.String:
+		return paint(color, COLOR_STRING, printed)
+	}
+
+	return highlight(printed)
 }
 
-func printParserErrors(out io.Writer, errors []string) {
+// printParserErrors writes errors to out, in red if color is set, each followed by the line of source it was found on
+// with a caret under the spot.
+func printParserErrors(out io.Writer, source string, errors []*parser.Error, color bool) {
 	io.WriteString(out, SAD_FACE)
 	io.WriteString(out, "what'd you doooo?!\n")
 	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for _, err := range errors {
+		io.WriteString(out, "\t"+paint(color, COLOR_ERROR, err.Message)+"\n")
+		writeSnippet(out, source, err.Pos())
+	}
+}
+
+// writeSnippet writes the line of source pos is on with a caret under pos, indented to sit below an error message.
+// Nothing is written when pos isn't known.
+func writeSnippet(out io.Writer, source string, pos token.Position) {
+	snippet := token.Snippet(source, pos)
+	for _, line := range strings.SplitAfter(snippet, "\n") {
+		if line != "" {
+			io.WriteString(out, "\t"+line)
+		}
 	}
 }
@@ -0,0 +1,116 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// run starts a quiet session without color on the lines of input and returns everything it wrote.
+func run(input ...string) string {
+	var out bytes.Buffer
+	Start(strings.NewReader(strings.Join(input, "\n")+"\n"), &out, WithConfig(Config{Quiet: true, NoColor: true}))
+
+	return out.String()
+}
+
+func TestSaveAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.sloth")
+
+	out := run(
+		"let x = 20;",
+		"let add = fn(a, b) {",
+		"a + b",
+		"};",
+		"missing + 1",
+		":save "+path,
+	)
+	if want := "saved 2 inputs to " + path + "\n"; !strings.HasSuffix(out, want) {
+		t.Fatalf("output = %q, want it to end with %q", out, want)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "let x = 20;\nlet add = fn(a, b) {\n  a + b\n};\n"; string(saved) != want {
+		t.Errorf("saved session = %q, want %q", saved, want)
+	}
+
+	out = run(":replay "+path, "add(x, 2)")
+	if !strings.HasSuffix(out, "22\n") {
+		t.Errorf("output = %q, want the replayed bindings to be there afterwards", out)
+	}
+}
+
+func TestSaveAndReplayErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{":save", "usage: :save path/to/session.sloth\n"},
+		{":replay", "usage: :replay path/to/session.sloth\n"},
+		{":save " + filepath.Join(dir, "missing", "session.sloth"), "could not save session: "},
+		{":save " + dir, "could not save session: "},
+		{":replay " + filepath.Join(dir, "missing.sloth"), "could not replay session: "},
+	}
+
+	for _, tt := range tests {
+		if out := run(tt.input); !strings.HasPrefix(out, tt.want) {
+			t.Errorf("%s: output = %q, want it to start with %q", tt.input, out, tt.want)
+		}
+	}
+}
+
+func TestReplayDuringReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.sloth")
+	if err := os.WriteFile(path, []byte(":replay "+path+"\n1 + 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := run(":replay " + path)
+	if !strings.Contains(out, "can't :replay during a replay\n") || !strings.HasSuffix(out, "2\n") {
+		t.Errorf("output = %q, want the nested :replay refused and the rest replayed", out)
+	}
+}
+
+func TestPaste(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.sloth")
+
+	out := run(
+		":paste",
+		"let total = fn(xs) {",
+		"    len(xs) * 2",
+		"}",
+		"",
+		"total([1, 2, 3])",
+		"  :end  ",
+		":save "+path,
+	)
+	if !strings.Contains(out, "pasting, end with :end on a line of its own\n6\n") {
+		t.Errorf("output = %q, want the pasted lines evaluated as one input", out)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ":paste\nlet total = fn(xs) {\n    len(xs) * 2\n}\n\ntotal([1, 2, 3])\n:end\n"
+	if string(saved) != want {
+		t.Errorf("saved session = %q, want %q", saved, want)
+	}
+
+	if out := run(":replay "+path, "total([4, 5])"); !strings.HasSuffix(out, "4\n") {
+		t.Errorf("output = %q, want a replayed :paste to bind total", out)
+	}
+}
+
+func TestPasteWithoutEnd(t *testing.T) {
+	if out := run(":paste", "let a = 1;", "a + 1"); !strings.HasSuffix(out, "2\n") {
+		t.Errorf("output = %q, want what was pasted evaluated at the end of the input", out)
+	}
+}
@@ -1,13 +1,107 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
 type TokenType string
 
 // Token holds:
 // - the type of token: integer, right-bracket
 // - the literal value of the token: 5, ]
+// - where in the input the token starts
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position
+}
+
+// Position is a place in the input. Offset counts bytes from the start of the input and starts at 0; Line and Column
+// start at 1, so they can be shown to people as they are. A zero Position means the place isn't known.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position is known.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position as line:column, or "-" when it isn't known.
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+/*
+End returns the position just past the token's last character. It is worked out from the literal, so it accounts for
+the quotes around a string and for newlines inside one. End returns the zero Position when the token's own position
+isn't known.
+*/
+func (t Token) End() Position {
+	if !t.Pos.IsValid() {
+		return Position{}
+	}
+
+	text := t.Literal
+	if t.Type == STRING {
+		text = `"` + text + `"`
+	}
+
+	end := t.Pos
+	for i := 0; i < len(text); i++ {
+		end.Offset++
+		if text[i] == '\n' {
+			end.Line++
+			end.Column = 1
+		} else {
+			end.Column++
+		}
+	}
+
+	return end
+}
+
+/*
+Snippet returns the line of source that pos is on, with a caret under pos's column, ready to be printed below an
+error message:
+
+	3 |   puts(x +);
+	  |           ^
+
+Tabs before the column are kept in the caret line so the caret lines up however wide the terminal draws them. Snippet
+returns "" when pos isn't known or isn't in source.
+*/
+func Snippet(source string, pos Position) string {
+	if !pos.IsValid() {
+		return ""
+	}
+
+	lines := strings.Split(source, "\n")
+	if pos.Line > len(lines) {
+		return ""
+	}
+	line := strings.TrimSuffix(lines[pos.Line-1], "\r")
+
+	var caret strings.Builder
+	for i := 0; i < pos.Column-1 && i < len(line); i++ {
+		if line[i] == '\t' {
+			caret.WriteByte('\t')
+		} else {
+			caret.WriteByte(' ')
+		}
+	}
+	caret.WriteByte('^')
+
+	number := fmt.Sprint(pos.Line)
+	return fmt.Sprintf("%s | %s\n%s | %s\n", number, line, strings.Repeat(" ", len(number)), caret.String())
 }
 
 const (
@@ -21,6 +115,9 @@ const (
 	INT    = "INT"   // 0123456789
 	STRING = "STRING"
 
+	// DOC_COMMENT is a /// comment. Its literal is the text of the comment, without the slashes.
+	DOC_COMMENT = "DOC_COMMENT"
+
 	//operators
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -32,11 +129,17 @@ const (
 	GT       = ">"
 	EQ       = "=="
 	NOT_EQ   = "!="
+	NULLISH  = "??"
+	PIPELINE = "|>"
+	COMPOSE  = ">>"
 
 	//delimeters
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	ELLIPSIS  = "..."
+	DOT       = "."
+	PIPE      = "|"
 
 	//groupings
 	QUOTES   = "\""
@@ -47,32 +150,145 @@ const (
 	LBRACKET = "["
 	RBRACKET = "]"
 
+	OPTIONAL_LBRACKET = "?["
+
 	//keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	THROW    = "THROW"
+	SPAWN    = "SPAWN"
+	CLASS    = "CLASS"
+	IMPORT   = "IMPORT"
+	FROM     = "FROM"
+	AS       = "AS"
+	EXPORT   = "EXPORT"
 )
 
+// keywords maps every keyword to its TokenType. Lexers look words up in it concurrently, and RegisterKeyword may add to
+// it at any time, so it is only touched with keywordsMu held. The same goes for operators.
+var keywordsMu sync.RWMutex
+
 var keywords = map[string]TokenType{
 	"fn":     FUNCTION,
 	"let":    LET,
+	"const":  CONST,
 	"true":   TRUE,
 	"false":  FALSE,
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"try":    TRY,
+	"catch":  CATCH,
+	"throw":  THROW,
+	"spawn":  SPAWN,
+	"class":  CLASS,
+	"import": IMPORT,
+	"from":   FROM,
+	"as":     AS,
+	"export": EXPORT,
 }
 
 // LookupIdent checks the keywords table to see if a given identifier is a keyword.
 // If so, the TokeType of that keyword is returned. If not, token.IDENT is returned which is the
 // TokenType for all user-defined identifiers
 func LookupIdent(ident string) TokenType {
+	keywordsMu.RLock()
+	defer keywordsMu.RUnlock()
+
 	if tok, ok := keywords[ident]; ok {
 		return tok
 	}
 	return IDENT
 }
+
+/*
+RegisterKeyword makes word a keyword and returns its TokenType, so language extensions and programs embedding sloth
+can add keywords of their own without touching the lexer. The TokenType of a registered keyword is the word itself,
+the way operators are typed by their own literal, which keeps it from clashing with the built in types.
+
+Registering a word that already is a keyword returns the type it already has. RegisterKeyword panics if word isn't
+something the lexer would read as a single identifier.
+
+Registering a keyword takes the word away from every program lexed afterwards, so a script that used it as a name
+will no longer parse.
+*/
+func RegisterKeyword(word string) TokenType {
+	if word == "" {
+		panic("token: cannot register an empty keyword")
+	}
+	for i := 0; i < len(word); i++ {
+		if ch := word[i]; !('a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_') {
+			panic(fmt.Sprintf("token: cannot register %q as a keyword: keywords are made of letters and underscores", word))
+		}
+	}
+
+	keywordsMu.Lock()
+	defer keywordsMu.Unlock()
+
+	if tok, ok := keywords[word]; ok {
+		return tok
+	}
+
+	tok := TokenType(word)
+	keywords[word] = tok
+	return tok
+}
+
+// IsKeyword reports whether word is a keyword, built in or registered.
+func IsKeyword(word string) bool {
+	return LookupIdent(word) != IDENT
+}
+
+// operatorChars are the characters registered operators may be made of.
+const operatorChars = "!#$%&*+-./:<=>?@^|~"
+
+// operators holds the registered operators, longest first. It is replaced rather than changed when an operator is
+// registered, so a slice handed out by Operators stays valid however many are registered after.
+var operators []string
+
+/*
+RegisterOperator makes op an operator the lexer knows and returns its TokenType, which like that of the built in
+operators is op itself. It is how extensions get tokens for operators sloth doesn't have, like |> or ::, which the
+parser can then be taught to parse with Parser.RegisterInfix or Parser.RegisterPrefix.
+
+Operators are matched longest first, so registering "=>" doesn't stop "=" from being lexed on its own, but it does
+mean x=>y now lexes as x => y rather than x = >y. RegisterOperator panics if op contains anything other than the
+characters !#$%&*+-./:<=>?@^|~.
+*/
+func RegisterOperator(op string) TokenType {
+	if op == "" || strings.Trim(op, operatorChars) != "" {
+		panic(fmt.Sprintf("token: cannot register %q as an operator: operators are made of the characters %s", op, operatorChars))
+	}
+
+	keywordsMu.Lock()
+	defer keywordsMu.Unlock()
+
+	registered := []string{op}
+	for _, existing := range operators {
+		if existing == op {
+			return TokenType(op)
+		}
+		registered = append(registered, existing)
+	}
+
+	sort.SliceStable(registered, func(i, j int) bool { return len(registered[i]) > len(registered[j]) })
+	operators = registered
+
+	return TokenType(op)
+}
+
+// Operators returns the registered operators, longest first. The lexer tries them before the built in ones.
+func Operators() []string {
+	keywordsMu.RLock()
+	defer keywordsMu.RUnlock()
+
+	return operators
+}
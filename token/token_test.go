@@ -0,0 +1,86 @@
+package token
+
+import "testing"
+
+func TestSnippet(t *testing.T) {
+	source := "let x = 1;\n\tputs(x +);\r\nlet y = 2;"
+
+	tests := []struct {
+		pos      Position
+		expected string
+	}{
+		{Position{Offset: 4, Line: 1, Column: 5}, "1 | let x = 1;\n  |     ^\n"},
+		{Position{Offset: 20, Line: 2, Column: 10}, "2 | \tputs(x +);\n  | \t        ^\n"},
+		{Position{Offset: 34, Line: 3, Column: 11}, "3 | let y = 2;\n  |           ^\n"},
+		{Position{}, ""},
+		{Position{Line: 4, Column: 1}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := Snippet(source, tt.pos); got != tt.expected {
+			t.Errorf("Snippet at %s wrong. want=%q, got=%q", tt.pos, tt.expected, got)
+		}
+	}
+}
+
+func TestRegisterKeyword(t *testing.T) {
+	if IsKeyword("until") {
+		t.Fatalf("until is already a keyword")
+	}
+
+	tok := RegisterKeyword("until")
+	if tok != TokenType("until") {
+		t.Errorf("wrong type for until. got %q", tok)
+	}
+	if LookupIdent("until") != tok || !IsKeyword("until") {
+		t.Errorf("until not looked up as a keyword after registering it")
+	}
+	if again := RegisterKeyword("until"); again != tok {
+		t.Errorf("registering until twice gave different types: %q and %q", tok, again)
+	}
+	if existing := RegisterKeyword("fn"); existing != FUNCTION {
+		t.Errorf("registering fn wrong. got %q want %q", existing, FUNCTION)
+	}
+
+	for _, word := range []string{"", "two words", "x2", "|>"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected RegisterKeyword(%q) to panic", word)
+				}
+			}()
+			RegisterKeyword(word)
+		}()
+	}
+}
+
+func TestRegisterOperator(t *testing.T) {
+	for _, op := range []string{"::", "=>", "===", "::"} {
+		if tok := RegisterOperator(op); tok != TokenType(op) {
+			t.Errorf("wrong type for %q. got %q", op, tok)
+		}
+	}
+
+	expected := []string{"===", "=>", "::"}
+	got := Operators()
+	if len(got) != len(expected) {
+		t.Fatalf("wrong operators. got %q want %q", got, expected)
+	}
+	for i, op := range expected {
+		if got[i] != op {
+			t.Errorf("wrong operators. got %q want %q", got, expected)
+			break
+		}
+	}
+
+	for _, op := range []string{"", "a+", "+ +", "()", `"`} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected RegisterOperator(%q) to panic", op)
+				}
+			}()
+			RegisterOperator(op)
+		}()
+	}
+}
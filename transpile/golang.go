@@ -0,0 +1,393 @@
+package transpile
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sean-d/sloth/ast"
+	"github.com/sean-d/sloth/evaluator"
+	"go/format"
+	"strconv"
+	"strings"
+)
+
+// RUNTIME is the import path of the package transpiled programs run on.
+const RUNTIME = "github.com/sean-d/sloth/transpile/runtime"
+
+// goUnsupported are the builtins a compiled program can't call, with what to call them in the error. A coroutine's
+// yield is bound in the body of its function by the evaluator, and a compiled function has no body it could bind it in.
+var goUnsupported = map[string]string{"coroutine": "coroutines", "yield": "coroutines"}
+
+// goCompiler is the Go backend.
+type goCompiler struct {
+	generator
+}
+
+/*
+Go transpiles program into the source of a Go main package, formatted the way gofmt would. If parts of program
+can't be transpiled, the returned error joins an Error for every one of them and no source is returned.
+*/
+func Go(program *ast.Program) ([]byte, error) {
+	c := &goCompiler{}
+
+	// the program's bindings become package level variables, which Go doesn't mind going unused
+	global := c.push(true)
+	names := hoist(program.Statements, nil)
+	for _, name := range names {
+		global.declare(name)
+	}
+
+	body := c.capture(func() {
+		c.statements(program.Statements, false)
+		c.line("return runtime.Null")
+	})
+	c.pop()
+
+	if len(c.errors) != 0 {
+		return nil, errors.Join(c.errors...)
+	}
+
+	var out strings.Builder
+	out.WriteString("// Code generated by sloth build. DO NOT EDIT.\n\n")
+	out.WriteString("package main\n\n")
+	out.WriteString("import \"" + RUNTIME + "\"\n\n")
+	if len(names) != 0 {
+		out.WriteString("var " + goNames(names) + " runtime.Value\n\n")
+	}
+	out.WriteString("func main() {\n")
+	out.WriteString("runtime.Main(func() runtime.Value {\n")
+	out.WriteString(body)
+	out.WriteString("})\n")
+	out.WriteString("}\n")
+
+	formatted, err := format.Source([]byte(out.String()))
+	if err != nil {
+		return nil, fmt.Errorf("transpiled program doesn't parse as Go: %w", err)
+	}
+
+	return formatted, nil
+}
+
+// unread returns a line reading every name in s that the generated code doesn't read otherwise, or "" if it reads
+// them all, so Go doesn't refuse the variables as unused.
+func unread(s *scope) string {
+	var names []string
+	for _, name := range s.order {
+		if !s.bindings[name].read {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+
+	return strings.Repeat("_, ", len(names)-1) + "_ = " + goNames(names) + "\n"
+}
+
+// goName is the Go variable a sloth name becomes. The prefix keeps sloth names from clashing with Go's keywords and
+// with the runtime package.
+func goName(name string) string {
+	return "sl_" + name
+}
+
+func goNames(names []string) string {
+	var out []string
+	for _, name := range names {
+		out = append(out, goName(name))
+	}
+
+	return strings.Join(out, ", ")
+}
+
+/*
+statements writes stmts. With tail set, they are the body of a function, or of an if or try used as a value, and the
+value of the last one is returned; a body that ends in anything other than an expression returns null.
+*/
+func (c *goCompiler) statements(stmts []ast.Statement, tail bool) {
+	for i, s := range stmts {
+		if tail && i == len(stmts)-1 {
+			c.tailStatement(s)
+			return
+		}
+		c.statement(s)
+	}
+
+	if tail {
+		c.line("return runtime.Null")
+	}
+}
+
+// tailStatement writes the last statement of a body whose value is returned.
+func (c *goCompiler) tailStatement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.ExpressionStatement:
+		if ie, ok := s.Expression.(*ast.IfExpression); ok {
+			c.ifStatement(ie, true)
+			return
+		}
+		c.line("return %s", c.expression(s.Expression))
+	case *ast.ReturnStatement, *ast.ThrowStatement:
+		c.statement(s)
+	default:
+		c.statement(s)
+		c.line("return runtime.Null")
+	}
+}
+
+func (c *goCompiler) statement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		c.bind(s.Name, s.IsConst())
+		c.line("%s = %s", goName(s.Name.Value), c.expression(s.Value))
+	case *ast.FunctionStatement:
+		c.bind(s.Name, false)
+		c.line("%s = %s", goName(s.Name.Value), c.function(s.Function))
+	case *ast.ReturnStatement:
+		if c.valueDepth > 0 {
+			c.unsupported(s, "return inside an if or try expression used as a value", "Go")
+			return
+		}
+		if s.ReturnValue == nil {
+			c.line("return runtime.Null")
+			return
+		}
+		c.line("return %s", c.expression(s.ReturnValue))
+	case *ast.ThrowStatement:
+		c.line("panic(runtime.Throw(%s))", c.expression(s.Value))
+	case *ast.ExpressionStatement:
+		switch e := s.Expression.(type) {
+		case *ast.IfExpression:
+			c.ifStatement(e, false)
+		case *ast.Identifier, *ast.Boolean:
+			// everything else transpiles to a call, which Go lets stand on its own
+			c.line("_ = %s", c.expression(e))
+		default:
+			c.line("%s", c.expression(e))
+		}
+	case *ast.ClassStatement:
+		c.unsupported(s, "class", "Go")
+	case *ast.ImportStatement:
+		c.unsupported(s, "import", "Go")
+	case *ast.ExportStatement:
+		c.unsupported(s, "export", "Go")
+	default:
+		c.unsupported(s, fmt.Sprintf("%T", s), "Go")
+	}
+}
+
+// ifStatement writes an if expression as an if statement. With tail set, both of its branches return their value, and
+// a missing else branch returns null.
+func (c *goCompiler) ifStatement(ie *ast.IfExpression, tail bool) {
+	c.line("if runtime.Truthy(%s) {", c.expression(ie.Condition))
+	c.statements(ie.Consequence.Statements, tail)
+	if ie.Alternative != nil {
+		c.line("} else {")
+		c.statements(ie.Alternative.Statements, tail)
+	}
+	c.line("}")
+
+	if tail && ie.Alternative == nil {
+		c.line("return runtime.Null")
+	}
+}
+
+func (c *goCompiler) expression(e ast.Expression) string {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		return c.identifier(e)
+	case *ast.IntegerLiteral:
+		if e.Big != nil {
+			return fmt.Sprintf("runtime.BigInt(%q)", e.Big.String())
+		}
+		return fmt.Sprintf("runtime.Int(%d)", e.Value)
+	case *ast.StringLiteral:
+		return "runtime.String(" + strconv.Quote(e.Value) + ")"
+	case *ast.Boolean:
+		if e.Value {
+			return "runtime.True"
+		}
+		return "runtime.False"
+	case *ast.PrefixExpression:
+		return fmt.Sprintf("runtime.Prefix(%q, %s)", e.Operator, c.expression(e.Right))
+	case *ast.InfixExpression:
+		left := c.expression(e.Left)
+		if e.Operator == "??" {
+			return fmt.Sprintf("runtime.Nullish(%s, func() runtime.Value { return %s })", left, c.expression(e.Right))
+		}
+		return fmt.Sprintf("runtime.Infix(%q, %s, %s)", e.Operator, left, c.expression(e.Right))
+	case *ast.IfExpression:
+		body := c.value(func() { c.ifStatement(e, true) })
+		return "func() runtime.Value {\n" + body + "}()"
+	case *ast.TryExpression:
+		return c.try(e)
+	case *ast.FunctionLiteral:
+		return c.function(e)
+	case *ast.CallExpression:
+		if _, ok := e.Function.(*ast.DotExpression); ok {
+			return c.unsupported(e, "calling a method with the dot operator", "Go")
+		}
+		args := c.expression(e.Function)
+		if len(e.Arguments) > 0 {
+			args += ", " + c.list(e.Arguments)
+		}
+		return "runtime.Call(" + args + ")"
+	case *ast.ArrayLiteral:
+		return "runtime.Array(" + c.list(e.Elements) + ")"
+	case *ast.SpreadExpression:
+		return c.unsupported(e, "... outside of a call's arguments or an array's elements", "Go")
+	case *ast.ImportExpression:
+		return c.unsupported(e, "import", "Go")
+	case *ast.HashLiteral:
+		var pairs []string
+		for _, key := range e.OrderedKeys() {
+			pairs = append(pairs, c.expression(key), c.expression(e.Pairs[key]))
+		}
+		return "runtime.Hash(" + strings.Join(pairs, ", ") + ")"
+	case *ast.IndexExpression:
+		left := c.expression(e.Left)
+		if e.Optional {
+			return fmt.Sprintf("runtime.OptionalIndex(%s, func() runtime.Value { return %s })", left, c.expression(e.Index))
+		}
+		return fmt.Sprintf("runtime.Index(%s, %s)", left, c.expression(e.Index))
+	case *ast.SpawnExpression:
+		return c.unsupported(e, "spawn", "Go")
+	case *ast.DotExpression:
+		return c.unsupported(e, "the dot operator", "Go")
+	case *ast.SliceExpression:
+		return c.unsupported(e, "slicing", "Go")
+	}
+
+	return c.unsupported(e, fmt.Sprintf("%T", e), "Go")
+}
+
+/*
+list returns the Go for the arguments of a call or the elements of an array, to be passed on to a variadic function.
+Without a spread they are simply listed; with one, runs of plain values and the spread arrays are spliced into a
+single slice.
+*/
+func (c *goCompiler) list(exps []ast.Expression) string {
+	var values, parts []string
+	spread := false
+	flush := func() {
+		if len(values) > 0 {
+			parts = append(parts, "[]runtime.Value{"+strings.Join(values, ", ")+"}")
+			values = nil
+		}
+	}
+
+	for _, e := range exps {
+		if s, ok := e.(*ast.SpreadExpression); ok {
+			flush()
+			parts = append(parts, "runtime.Spread("+c.expression(s.Value)+")")
+			spread = true
+			continue
+		}
+		values = append(values, c.expression(e))
+	}
+
+	if !spread {
+		return strings.Join(values, ", ")
+	}
+	flush()
+	return "runtime.Splice(" + strings.Join(parts, ", ") + ")..."
+}
+
+// identifier returns the Go for reading a name: the variable it is bound to, or the builtin of that name.
+func (c *goCompiler) identifier(ident *ast.Identifier) string {
+	if b, ok := c.lookup(ident.Value); ok {
+		b.read = true
+		return goName(ident.Value)
+	}
+	if what, ok := goUnsupported[ident.Value]; ok {
+		return c.unsupported(ident, what, "Go")
+	}
+	if evaluator.IsBuiltin(ident.Value) {
+		return fmt.Sprintf("runtime.Builtin(%q)", ident.Value)
+	}
+
+	c.errorf(ident, "identifier not found: %s", ident.Value)
+	return "runtime.Null"
+}
+
+// try writes a try expression as a call to runtime.Try, with the try block and the catch block as closures returning
+// their values. The catch block gets a scope of its own, as it does in the evaluator: its parameter and every name it
+// binds are declared in its closure, so a let in it shadows a binding outside rather than assigning it.
+func (c *goCompiler) try(te *ast.TryExpression) string {
+	body := c.value(func() { c.statements(te.Body.Statements, true) })
+
+	s := c.push(false)
+	locals := catchLocals(s, te)
+	catch := c.value(func() { c.statements(te.Catch.Statements, true) })
+	c.pop()
+
+	var declared string
+	if len(locals) != 0 {
+		declared = fmt.Sprintf("var %s runtime.Value\n", goNames(locals))
+	}
+
+	return fmt.Sprintf("runtime.Try(func() runtime.Value {\n%s}, func(%s runtime.Value) runtime.Value {\n%s%s%s})",
+		body, goName(te.Param.Value), declared, unread(s), catch)
+}
+
+/*
+function writes a function literal as a call to runtime.Func wrapping a Go closure. The closure declares every name the
+body binds, then takes its parameters from the arguments, evaluating the defaults of the ones left out, and then runs
+the body, returning the value of its last statement.
+*/
+func (c *goCompiler) function(fl *ast.FunctionLiteral) string {
+	saved := c.valueDepth
+	c.valueDepth = 0
+	defer func() { c.valueDepth = saved }()
+
+	s := c.push(true)
+	defer c.pop()
+
+	var params []string
+	for _, p := range fl.Parameters {
+		params = append(params, p.Value)
+	}
+	if fl.Rest != nil {
+		params = append(params, fl.Rest.Value)
+	}
+	locals := hoist(fl.Body.Statements, params)
+
+	required := 0
+	for i := range fl.Parameters {
+		if i >= len(fl.Defaults) || fl.Defaults[i] == nil {
+			required = i + 1
+		}
+	}
+	max := len(fl.Parameters)
+	if fl.Rest != nil {
+		max = -1
+	}
+
+	for _, name := range locals {
+		s.declare(name)
+	}
+	prologue := c.capture(func() {
+		if len(locals) != 0 {
+			c.line("var %s runtime.Value", goNames(locals))
+		}
+		for i, p := range fl.Parameters {
+			s.declare(p.Value)
+			if i < required {
+				c.line("%s := args[%d]", goName(p.Value), i)
+				continue
+			}
+			c.line("var %s runtime.Value", goName(p.Value))
+			c.line("if len(args) > %d {", i)
+			c.line("%s = args[%d]", goName(p.Value), i)
+			c.line("} else {")
+			c.line("%s = %s", goName(p.Value), c.expression(fl.Defaults[i]))
+			c.line("}")
+		}
+		if fl.Rest != nil {
+			s.declare(fl.Rest.Value)
+			c.line("%s := runtime.Rest(args, %d)", goName(fl.Rest.Value), len(fl.Parameters))
+		}
+	})
+	body := c.capture(func() { c.statements(fl.Body.Statements, true) })
+
+	return fmt.Sprintf("runtime.Func(%d, %d, func(args []runtime.Value) runtime.Value {\n%s%s%s})",
+		required, max, prologue, unread(s), body)
+}
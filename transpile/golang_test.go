@@ -0,0 +1,200 @@
+package transpile
+
+import (
+	"github.com/sean-d/sloth/ast"
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/parser"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// transpile parses input and transpiles it with target, either Go or JavaScript.
+func transpile(t *testing.T, input string, target func(*ast.Program) ([]byte, error)) ([]byte, error) {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+
+	return target(program)
+}
+
+func TestGo(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let x = 5; puts(x);",
+			[]string{
+				"var sl_x runtime.Value",
+				"sl_x = runtime.Int(5)",
+				`runtime.Call(runtime.Builtin("puts"), sl_x)`,
+			},
+		},
+		{
+			"let add = fn(a, b = 2, ...rest) { a + b };",
+			[]string{
+				"sl_add = runtime.Func(1, -1, func(args []runtime.Value) runtime.Value {",
+				"sl_a := args[0]",
+				"if len(args) > 1 {",
+				"sl_b = runtime.Int(2)",
+				"sl_rest := runtime.Rest(args, 2)",
+				"_ = sl_rest",
+				`return runtime.Infix("+", sl_a, sl_b)`,
+			},
+		},
+		{
+			// the let inside the function is its own, the if blocks bind into the function's scope
+			"let f = fn(n) { if (n > 1) { let y = n; y } else { 0 } }; f(2);",
+			[]string{
+				"var sl_y runtime.Value",
+				`if runtime.Truthy(runtime.Infix(">", sl_n, runtime.Int(1))) {`,
+				"return sl_y",
+				"return runtime.Int(0)",
+			},
+		},
+		{
+			`let r = try { throw "x"; } catch (e) { 1 };`,
+			[]string{
+				`panic(runtime.Throw(runtime.String("x")))`,
+				"func(sl_e runtime.Value) runtime.Value {",
+				"_ = sl_e",
+			},
+		},
+		{
+			// a let in the catch block shadows the global rather than assigning it
+			`let x = 1; let r = try { throw "boom" } catch (e) { let x = 2; x };`,
+			[]string{
+				"var sl_x, sl_r runtime.Value",
+				"}, func(sl_e runtime.Value) runtime.Value {\n\t\t\tvar sl_x runtime.Value\n\t\t\t_ = sl_e\n",
+			},
+		},
+		{
+			`let v = if (true) { 1 }; let n = v ?? 2; n?[0];`,
+			[]string{
+				"sl_v = func() runtime.Value {",
+				"}()",
+				"runtime.Nullish(sl_v, func() runtime.Value { return runtime.Int(2) })",
+				"runtime.OptionalIndex(sl_n, func() runtime.Value { return runtime.Int(0) })",
+			},
+		},
+		{
+			"let xs = [2]; puts(1, ...xs); [...xs, 3, 4];",
+			[]string{
+				`runtime.Call(runtime.Builtin("puts"), runtime.Splice([]runtime.Value{runtime.Int(1)}, runtime.Spread(sl_xs))...)`,
+				"runtime.Array(runtime.Splice(runtime.Spread(sl_xs), []runtime.Value{runtime.Int(3), runtime.Int(4)})...)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		transpiled, err := transpile(t, tt.input, Go)
+		if err != nil {
+			t.Fatalf("Go(%q) failed: %s", tt.input, err)
+		}
+
+		for _, expected := range tt.expected {
+			if !strings.Contains(string(transpiled), expected) {
+				t.Errorf("Go(%q) is missing %q. got=\n%s", tt.input, expected, transpiled)
+			}
+		}
+	}
+}
+
+func TestGoErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const a = 1; let a = 2;", "1:18: cannot reassign constant a"},
+		{"puts(nope);", "1:6: identifier not found: nope"},
+		{"spawn fn() { 1 };", "1:1: spawn can't be transpiled to Go yet"},
+		{"class P { x; }", "1:1: class can't be transpiled to Go yet"},
+		{"let h = {}; h.x;", "1:13: the dot operator can't be transpiled to Go yet"},
+		{"[1, 2][0:1];", "1:1: slicing can't be transpiled to Go yet"},
+		{
+			"let co = coroutine(fn() { yield(1) });",
+			"1:10: coroutines can't be transpiled to Go yet\n1:27: coroutines can't be transpiled to Go yet",
+		},
+		{
+			"fn f() { let x = if (true) { return 1; }; x }",
+			"1:30: return inside an if or try expression used as a value can't be transpiled to Go yet",
+		},
+		{
+			"puts(a); puts(b);",
+			"1:6: identifier not found: a\n1:15: identifier not found: b",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := transpile(t, tt.input, Go)
+		if err == nil {
+			t.Errorf("Go(%q) should have failed", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
+
+// TestGoRuns builds a transpiled program with the go tool and runs it, checking it prints what the interpreter
+// would and exits with the code the script gives exit.
+func TestGoRuns(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a Go program")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	input := `
+let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) };
+fn greet(name = "world") { "hello " + name }
+puts(fib(15), map([1, 2, 3], |x| x * 2), greet(), greet("sloth"));
+puts(try { throw "boom"; } catch (e) { "caught " + e });
+puts(try { 1 / 0 } catch (e) { e["message"] });
+let h = {"b": 2, "a": 1};
+puts(h, h?["c"] ?? "none");
+exit(3);
+`
+	transpiled, err := transpile(t, input, Go)
+	if err != nil {
+		t.Fatalf("Go failed: %s", err)
+	}
+
+	// the program has to be inside the module to import the runtime
+	dir, err := os.MkdirTemp(".", "run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, transpiled, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	binary := filepath.Join(dir, "program")
+	if out, err := exec.Command(goTool, "build", "-o", binary, path).CombinedOutput(); err != nil {
+		t.Fatalf("transpiled program doesn't build: %s\n%s", err, out)
+	}
+
+	out, err := exec.Command(binary).Output()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 3 {
+		t.Fatalf("program should have exited with 3, got %v. output:\n%s", err, out)
+	}
+
+	expected := "610\n[2, 4, 6]\nhello world\nhello sloth\ncaught boom\ndivision by zero\n{b: 2, a: 1}\nnone\n"
+	if string(out) != expected {
+		t.Errorf("wrong output. expected=\n%s\ngot=\n%s", expected, out)
+	}
+}
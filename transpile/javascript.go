@@ -0,0 +1,418 @@
+package transpile
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sean-d/sloth/ast"
+	"github.com/sean-d/sloth/evaluator"
+	"strings"
+)
+
+// jsRuntime is put in front of every program transpiled to JavaScript. It holds the operators and the builtins the
+// program runs on, in a single object called $, which no sloth name can clash with.
+//
+//go:embed runtime.js
+var jsRuntime string
+
+// jsBuiltins are the builtins runtime.js implements. The others reach for things a browser doesn't have, such as
+// processes, environment variables and goroutines, and calling them is an error.
+var jsBuiltins = map[string]bool{
+	"puts": true, "len": true, "first": true, "last": true, "rest": true, "push": true, "map": true, "filter": true,
+	"chars": true, "ord": true, "chr": true, "format": true, "printf": true, "assert": true, "assert_eq": true, "deep_equal": true,
+	"exit": true, "compose": true, "partial": true, "curry": true,
+}
+
+// jsPrefix maps sloth's prefix operators to the runtime functions implementing them.
+var jsPrefix = map[string]string{"!": "not", "-": "neg", "+": "pos"}
+
+// jsInfix maps sloth's infix operators to the runtime functions implementing them.
+var jsInfix = map[string]string{
+	"+": "add", "-": "sub", "*": "mul", "/": "div", "<": "lt", ">": "gt", "==": "eq", "!=": "neq", ">>": "chain",
+}
+
+// jsReserved are the words JavaScript won't take as variable names, along with a few names it gives a meaning to.
+// sloth names that are one of them get a $ added to the end.
+var jsReserved = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`await break case catch class const continue debugger default delete do else
+		enum eval export extends false finally for function if implements import in instanceof interface let new null
+		package private protected public return static super switch this throw true try typeof var void while with
+		yield arguments undefined NaN Infinity`) {
+		jsReserved[word] = true
+	}
+}
+
+// JS_INDENT is what every level of nesting is indented by in the JavaScript output.
+const JS_INDENT = "  "
+
+// jsCompiler is the JavaScript backend. depth is how deeply nested the line being written is.
+type jsCompiler struct {
+	generator
+	depth int
+}
+
+/*
+JavaScript transpiles program into a JavaScript script that runs in browsers and under node, without the interpreter.
+The script starts with the runtime it needs, followed by the program itself, written to be read:
+
+	let fib;
+	fib = (n) => {
+	  if ($.truthy($.lt(n, 2n))) {
+	    return n;
+	  }
+	  return $.add(fib($.sub(n, 1n)), fib($.sub(n, 2n)));
+	};
+
+Integers are BigInts, so they never overflow, and hashes are Maps. Operators and builtins go through the runtime so
+they fail the way they do in the interpreter, but calls are plain JavaScript calls: the number of arguments isn't
+checked and calling something that isn't a function fails with JavaScript's own error. Only the builtins that make
+sense in a browser are available, see jsBuiltins; calling any other is reported as an Error, as are the parts of the
+language Go can't do either.
+*/
+func JavaScript(program *ast.Program) ([]byte, error) {
+	c := &jsCompiler{}
+
+	global := c.push(true)
+	names := hoist(program.Statements, nil)
+	for _, name := range names {
+		global.declare(name)
+	}
+
+	c.depth = 1
+	body := c.capture(func() {
+		if len(names) != 0 {
+			c.line("let %s;", jsNames(names))
+		}
+		c.statements(program.Statements, false)
+	})
+	c.pop()
+
+	if len(c.errors) != 0 {
+		return nil, errors.Join(c.errors...)
+	}
+
+	var out strings.Builder
+	out.WriteString("// Code generated by sloth build --target=js. DO NOT EDIT.\n\n")
+	out.WriteString(jsRuntime)
+	out.WriteString("\n$.main(() => {\n")
+	out.WriteString(body)
+	out.WriteString("});\n")
+
+	return []byte(out.String()), nil
+}
+
+func (c *jsCompiler) line(format string, args ...any) {
+	c.out.WriteString(strings.Repeat(JS_INDENT, c.depth))
+	c.generator.line(format, args...)
+}
+
+// nested writes the lines write writes one level deeper, for a body that ends up inside an expression, and returns
+// them along with the indentation of the line the expression is on, for the closing brace.
+func (c *jsCompiler) nested(write func()) (body string, indent string) {
+	c.depth++
+	body = c.capture(write)
+	c.depth--
+
+	return body, strings.Repeat(JS_INDENT, c.depth)
+}
+
+// jsName is the JavaScript variable a sloth name becomes: the name itself, unless JavaScript reserves it.
+func jsName(name string) string {
+	if jsReserved[name] {
+		return name + "$"
+	}
+
+	return name
+}
+
+func jsNames(names []string) string {
+	var out []string
+	for _, name := range names {
+		out = append(out, jsName(name))
+	}
+
+	return strings.Join(out, ", ")
+}
+
+// statements writes stmts, returning the value of the last one with tail set, the way goCompiler.statements does.
+func (c *jsCompiler) statements(stmts []ast.Statement, tail bool) {
+	for i, s := range stmts {
+		if tail && i == len(stmts)-1 {
+			c.tailStatement(s)
+			return
+		}
+		c.statement(s)
+	}
+
+	if tail {
+		c.line("return null;")
+	}
+}
+
+func (c *jsCompiler) tailStatement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.ExpressionStatement:
+		if ie, ok := s.Expression.(*ast.IfExpression); ok {
+			c.ifStatement(ie, true)
+			return
+		}
+		c.line("return %s;", c.expression(s.Expression))
+	case *ast.ReturnStatement, *ast.ThrowStatement:
+		c.statement(s)
+	default:
+		c.statement(s)
+		c.line("return null;")
+	}
+}
+
+func (c *jsCompiler) statement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		c.bind(s.Name, s.IsConst())
+		c.line("%s = %s;", jsName(s.Name.Value), c.expression(s.Value))
+	case *ast.FunctionStatement:
+		c.bind(s.Name, false)
+		c.line("%s = %s;", jsName(s.Name.Value), c.function(s.Function))
+	case *ast.ReturnStatement:
+		if c.valueDepth > 0 {
+			c.unsupported(s, "return inside an if or try expression used as a value", "JavaScript")
+			return
+		}
+		if s.ReturnValue == nil {
+			c.line("return null;")
+			return
+		}
+		c.line("return %s;", c.expression(s.ReturnValue))
+	case *ast.ThrowStatement:
+		c.line("throw $.thrown(%s);", c.expression(s.Value))
+	case *ast.ExpressionStatement:
+		if ie, ok := s.Expression.(*ast.IfExpression); ok {
+			c.ifStatement(ie, false)
+			return
+		}
+		c.line("%s;", c.expression(s.Expression))
+	case *ast.ClassStatement:
+		c.unsupported(s, "class", "JavaScript")
+	case *ast.ImportStatement:
+		c.unsupported(s, "import", "JavaScript")
+	case *ast.ExportStatement:
+		c.unsupported(s, "export", "JavaScript")
+	default:
+		c.unsupported(s, fmt.Sprintf("%T", s), "JavaScript")
+	}
+}
+
+func (c *jsCompiler) ifStatement(ie *ast.IfExpression, tail bool) {
+	c.line("if ($.truthy(%s)) {", c.expression(ie.Condition))
+	c.depth++
+	c.statements(ie.Consequence.Statements, tail)
+	c.depth--
+	if ie.Alternative != nil {
+		c.line("} else {")
+		c.depth++
+		c.statements(ie.Alternative.Statements, tail)
+		c.depth--
+	}
+	c.line("}")
+
+	if tail && ie.Alternative == nil {
+		c.line("return null;")
+	}
+}
+
+func (c *jsCompiler) expression(e ast.Expression) string {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		return c.identifier(e)
+	case *ast.IntegerLiteral:
+		if e.Big != nil {
+			return e.Big.String() + "n"
+		}
+		return fmt.Sprintf("%dn", e.Value)
+	case *ast.StringLiteral:
+		return jsString(e.Value)
+	case *ast.Boolean:
+		return fmt.Sprintf("%t", e.Value)
+	case *ast.PrefixExpression:
+		if op, ok := jsPrefix[e.Operator]; ok {
+			return fmt.Sprintf("$.%s(%s)", op, c.expression(e.Right))
+		}
+		return c.unsupported(e, "the prefix "+e.Operator+" operator", "JavaScript")
+	case *ast.InfixExpression:
+		left, right := c.expression(e.Left), c.expression(e.Right)
+		if e.Operator == "??" {
+			// sloth's null is JavaScript's, and nothing else in a transpiled program is null or undefined
+			return "(" + left + " ?? " + right + ")"
+		}
+		if op, ok := jsInfix[e.Operator]; ok {
+			return fmt.Sprintf("$.%s(%s, %s)", op, left, right)
+		}
+		return c.unsupported(e, "the "+e.Operator+" operator", "JavaScript")
+	case *ast.IfExpression:
+		body, indent := c.nested(func() {
+			c.valueDepth++
+			c.ifStatement(e, true)
+			c.valueDepth--
+		})
+		return "(() => {\n" + body + indent + "})()"
+	case *ast.TryExpression:
+		return c.try(e)
+	case *ast.FunctionLiteral:
+		return c.function(e)
+	case *ast.CallExpression:
+		if _, ok := e.Function.(*ast.DotExpression); ok {
+			return c.unsupported(e, "calling a method with the dot operator", "JavaScript")
+		}
+		callee := c.expression(e.Function)
+		if _, ok := e.Function.(*ast.FunctionLiteral); ok {
+			callee = "(" + callee + ")"
+		}
+		return callee + "(" + c.list(e.Arguments) + ")"
+	case *ast.ArrayLiteral:
+		return "[" + c.list(e.Elements) + "]"
+	case *ast.HashLiteral:
+		var pairs []string
+		for _, key := range e.OrderedKeys() {
+			pairs = append(pairs, "["+c.expression(key)+", "+c.expression(e.Pairs[key])+"]")
+		}
+		return "$.hash(" + strings.Join(pairs, ", ") + ")"
+	case *ast.IndexExpression:
+		left := c.expression(e.Left)
+		if e.Optional {
+			return fmt.Sprintf("$.optionalIndex(%s, () => %s)", left, c.expression(e.Index))
+		}
+		return fmt.Sprintf("$.index(%s, %s)", left, c.expression(e.Index))
+	case *ast.SpawnExpression:
+		return c.unsupported(e, "spawn", "JavaScript")
+	case *ast.DotExpression:
+		return c.unsupported(e, "the dot operator", "JavaScript")
+	case *ast.SliceExpression:
+		return c.unsupported(e, "slicing", "JavaScript")
+	case *ast.SpreadExpression:
+		return c.unsupported(e, "... outside of a call's arguments or an array's elements", "JavaScript")
+	case *ast.ImportExpression:
+		return c.unsupported(e, "import", "JavaScript")
+	}
+
+	return c.unsupported(e, fmt.Sprintf("%T", e), "JavaScript")
+}
+
+func (c *jsCompiler) list(exps []ast.Expression) string {
+	var out []string
+	for _, e := range exps {
+		if s, ok := e.(*ast.SpreadExpression); ok {
+			out = append(out, "...$.spread("+c.expression(s.Value)+")")
+			continue
+		}
+		out = append(out, c.expression(e))
+	}
+
+	return strings.Join(out, ", ")
+}
+
+// identifier returns the JavaScript for reading a name: the variable it is bound to, or the runtime's builtin of that
+// name.
+func (c *jsCompiler) identifier(ident *ast.Identifier) string {
+	if _, ok := c.lookup(ident.Value); ok {
+		return jsName(ident.Value)
+	}
+	if jsBuiltins[ident.Value] {
+		return "$." + ident.Value
+	}
+
+	if evaluator.IsBuiltin(ident.Value) {
+		c.errorf(ident, "builtin %s isn't available in JavaScript", ident.Value)
+		return ""
+	}
+
+	c.errorf(ident, "identifier not found: %s", ident.Value)
+	return ""
+}
+
+// try writes a try expression as a call to $.try, with the try block and the catch block as closures returning their
+// values. The names the catch block binds are declared in its closure, so they shadow the ones outside as they do in
+// the evaluator.
+func (c *jsCompiler) try(te *ast.TryExpression) string {
+	c.valueDepth++
+	defer func() { c.valueDepth-- }()
+
+	body, indent := c.nested(func() { c.statements(te.Body.Statements, true) })
+
+	s := c.push(false)
+	locals := catchLocals(s, te)
+	catch, _ := c.nested(func() {
+		if len(locals) != 0 {
+			c.line("let %s;", jsNames(locals))
+		}
+		c.statements(te.Catch.Statements, true)
+	})
+	c.pop()
+
+	return fmt.Sprintf("$.try(() => {\n%s%s}, (%s) => {\n%s%s})", body, indent, jsName(te.Param.Value), catch, indent)
+}
+
+/*
+function writes a function literal as an arrow function. Parameter defaults and a rest parameter map straight onto
+JavaScript's own. A function in the short form whose body binds nothing stays a single expression.
+*/
+func (c *jsCompiler) function(fl *ast.FunctionLiteral) string {
+	saved := c.valueDepth
+	c.valueDepth = 0
+	defer func() { c.valueDepth = saved }()
+
+	s := c.push(true)
+	defer c.pop()
+
+	var params []string
+	for _, p := range fl.Parameters {
+		params = append(params, p.Value)
+	}
+	if fl.Rest != nil {
+		params = append(params, fl.Rest.Value)
+	}
+	locals := hoist(fl.Body.Statements, params)
+	for _, name := range locals {
+		s.declare(name)
+	}
+
+	var signature []string
+	for i, p := range fl.Parameters {
+		s.declare(p.Value)
+		if i < len(fl.Defaults) && fl.Defaults[i] != nil {
+			signature = append(signature, jsName(p.Value)+" = "+c.expression(fl.Defaults[i]))
+			continue
+		}
+		signature = append(signature, jsName(p.Value))
+	}
+	if fl.Rest != nil {
+		s.declare(fl.Rest.Value)
+		signature = append(signature, "..."+jsName(fl.Rest.Value))
+	}
+	head := "(" + strings.Join(signature, ", ") + ") => "
+
+	if body := fl.ShortBody(); body != nil && len(locals) == 0 {
+		return head + c.expression(body)
+	}
+
+	body, indent := c.nested(func() {
+		if len(locals) != 0 {
+			c.line("let %s;", jsNames(locals))
+		}
+		c.statements(fl.Body.Statements, true)
+	})
+
+	return head + "{\n" + body + indent + "}"
+}
+
+// jsString returns s as a JavaScript string literal.
+func jsString(s string) string {
+	var out strings.Builder
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+
+	return strings.TrimSuffix(out.String(), "\n")
+}
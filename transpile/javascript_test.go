@@ -0,0 +1,167 @@
+package transpile
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJavaScript(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let x = 5; puts(x);",
+			[]string{
+				"let x;",
+				"x = 5n;",
+				"$.puts(x);",
+			},
+		},
+		{
+			"let add = fn(a, b = 2, ...rest) { a + b };",
+			[]string{"add = (a, b = 2n, ...rest) => {", "return $.add(a, b);"},
+		},
+		{
+			"let double = |x| x * 2;",
+			[]string{"double = (x) => $.mul(x, 2n);"},
+		},
+		{
+			// the let inside the function is its own, the if blocks bind into the function's scope
+			"let f = fn(n) { if (n > 1) { let y = n; y } else { 0 } }; f(2);",
+			[]string{
+				"let y;",
+				"if ($.truthy($.gt(n, 1n))) {",
+				"y = n;",
+				"return y;",
+				"return 0n;",
+				"f(2n);",
+			},
+		},
+		{
+			"let s = \"a & <c>\"; let h = {s: !true, 2: -s, 3: +s}; h?[s] ?? 0;",
+			[]string{
+				`s = "a & <c>";`,
+				"h = $.hash([s, $.not(true)], [2n, $.neg(s)], [3n, $.pos(s)]);",
+				"($.optionalIndex(h, () => s) ?? 0n);",
+			},
+		},
+		{
+			"let v = if (true) { 1 }; let w = try { throw [1]; } catch (e) { e[0] };",
+			[]string{
+				"v = (() => {",
+				"return 1n;",
+				"return null;",
+				"})();",
+				"w = $.try(() => {",
+				"throw $.thrown([1n]);",
+				"}, (e) => {",
+				"return $.index(e, 0n);",
+			},
+		},
+		{
+			`let x = 1; let r = try { throw "boom" } catch (e) { let x = 2; x };`,
+			[]string{"let x, r;", "}, (e) => {\n    let x;\n    x = 2n;\n    return x;"},
+		},
+		{
+			// names JavaScript reserves are renamed
+			"let new = |this| this; new(1);",
+			[]string{"new$ = (this$) => this$;", "new$(1n);"},
+		},
+		{
+			"let xs = [2]; puts(...xs, 3); [1, ...xs];",
+			[]string{"$.puts(...$.spread(xs), 3n);", "[1n, ...$.spread(xs)];"},
+		},
+		{
+			"(|x| x)(1);",
+			[]string{"((x) => x)(1n);"},
+		},
+	}
+
+	for _, tt := range tests {
+		transpiled, err := transpile(t, tt.input, JavaScript)
+		if err != nil {
+			t.Errorf("JavaScript(%q) failed: %s", tt.input, err)
+			continue
+		}
+		program := string(transpiled[strings.Index(string(transpiled), "$.main("):])
+		for _, want := range tt.expected {
+			if !strings.Contains(program, want) {
+				t.Errorf("JavaScript(%q) doesn't contain %q. got=\n%s", tt.input, want, program)
+			}
+		}
+	}
+}
+
+func TestJavaScriptErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const a = 1; let a = 2;", "1:18: cannot reassign constant a"},
+		{"puts(nope);", "1:6: identifier not found: nope"},
+		{"exec(\"ls\");", "1:1: builtin exec isn't available in JavaScript"},
+		{"spawn fn() { 1 };", "1:1: spawn can't be transpiled to JavaScript yet"},
+		{"class P { x; }", "1:1: class can't be transpiled to JavaScript yet"},
+		{"[1, 2][0:1];", "1:1: slicing can't be transpiled to JavaScript yet"},
+		{
+			"fn f() { let x = if (true) { return 1; }; x }",
+			"1:30: return inside an if or try expression used as a value can't be transpiled to JavaScript yet",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := transpile(t, tt.input, JavaScript)
+		if err == nil {
+			t.Errorf("JavaScript(%q) should have failed", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
+
+// TestJavaScriptRuns runs a transpiled program with node, checking it prints what the interpreter would and exits with
+// the code the script gives exit.
+func TestJavaScriptRuns(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+
+	input := `
+let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) };
+fn greet(name = "world") { "hello " + name }
+puts(fib(15), map([1, 2, 3], |x| x * 2), greet(), greet("sloth"));
+puts(try { throw "boom"; } catch (e) { "caught " + e });
+puts(try { 1 / 0 } catch (e) { e["message"] });
+let h = {"b": 2, "a": 1};
+puts(h, h?["c"] ?? "none", 99999999999999999999 * 2);
+exit(3);
+`
+	transpiled, err := transpile(t, input, JavaScript)
+	if err != nil {
+		t.Fatalf("JavaScript failed: %s", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "program.js")
+	if err := os.WriteFile(path, transpiled, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(node, path).Output()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 3 {
+		t.Fatalf("program should have exited with 3, got %v. output:\n%s", err, out)
+	}
+
+	expected := "610\n[2, 4, 6]\nhello world\nhello sloth\ncaught boom\ndivision by zero\n{b: 2, a: 1}\nnone\n" +
+		"199999999999999999998\n"
+	if string(out) != expected {
+		t.Errorf("wrong output. expected=\n%s\ngot=\n%s", expected, out)
+	}
+}
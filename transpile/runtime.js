@@ -0,0 +1,426 @@
+// The runtime of a sloth program transpiled to JavaScript. Integers are BigInts, strings and booleans are JavaScript's
+// own, null is null, arrays are arrays and hashes are Maps, which keep their keys in the order they were added just
+// like sloth's hashes do. Operators go through the functions below rather than JavaScript's own, so they fail with
+// the same errors the interpreter raises instead of quietly converting their operands.
+const $ = (() => {
+  // SlothError is a sloth runtime error. value is what a throw statement threw, undefined for errors raised here.
+  class SlothError extends Error {
+    constructor(message, value) {
+      super(message);
+      this.value = value;
+    }
+  }
+
+  // Exit is thrown by exit and ends the program with code, without being caught by a try expression.
+  class Exit {
+    constructor(code) {
+      this.code = code;
+    }
+  }
+
+  const fail = (message) => {
+    throw new SlothError(message);
+  };
+
+  // type returns the name sloth gives the type of v in error messages.
+  const type = (v) => {
+    switch (typeof v) {
+      case "bigint":
+        return "INTEGER";
+      case "string":
+        return "STRING";
+      case "boolean":
+        return "BOOLEAN";
+      case "function":
+        return "FUNCTION";
+    }
+    if (v === null) return "NULL";
+    if (Array.isArray(v)) return "ARRAY";
+    if (v instanceof Map) return "HASH";
+    return "UNKNOWN";
+  };
+
+  const inspect = (v) => {
+    if (Array.isArray(v)) return "[" + v.map(inspect).join(", ") + "]";
+    if (v instanceof Map) return "{" + [...v].map(([k, x]) => inspect(k) + ": " + inspect(x)).join(", ") + "}";
+    if (typeof v === "function") return "fn";
+    return String(v);
+  };
+
+  // equal compares the way sloth's == does: arrays element by element, hashes pair by pair, everything else by value.
+  const equal = (a, b) => {
+    if (Array.isArray(a) && Array.isArray(b)) {
+      return a.length === b.length && a.every((x, i) => equal(x, b[i]));
+    }
+    if (a instanceof Map && b instanceof Map) {
+      return a.size === b.size && [...a].every(([k, x]) => b.has(k) && equal(x, b.get(k)));
+    }
+    return a === b;
+  };
+
+  // keyName names a key by its type and value, arrays by the names of their elements, and is undefined for anything
+  // that can't be a key.
+  const keyName = (key) => {
+    switch (typeof key) {
+      case "bigint":
+        return "i" + key;
+      case "string":
+        return JSON.stringify(key);
+      case "boolean":
+        return "b" + key;
+    }
+    if (!Array.isArray(key)) return undefined;
+    const names = key.map(keyName);
+    return names.includes(undefined) ? undefined : "[" + names.join(",") + "]";
+  };
+
+  // arrayKeys holds the first array with each name used as a key, which Maps then file every array with that name
+  // under, so arrays with equal elements are the same key to them just like they are to sloth's hashes.
+  const arrayKeys = new Map();
+
+  // hashable returns what a Map files key under, failing for what sloth can't use as a hash key.
+  const hashable = (key) => {
+    const name = keyName(key);
+    if (name === undefined) fail(`unusable as hash key: ${type(key)}`);
+    if (!Array.isArray(key)) return key;
+    if (!arrayKeys.has(name)) arrayKeys.set(name, key);
+    return arrayKeys.get(name);
+  };
+
+  const truthy = (v) => v !== null && v !== false;
+
+  // operatorMethods maps the infix operators a hash can overload to the methods it overloads them with, the way the
+  // evaluator's operatorMethods does.
+  const operatorMethods = {
+    "+": "__add",
+    "-": "__sub",
+    "*": "__mul",
+    "/": "__div",
+    "==": "__eq",
+    "!=": "__eq",
+    "<": "__lt",
+    ">": "__gt",
+  };
+
+  // method returns the function v holds under name, if v is a hash with one.
+  const method = (v, name) => (v instanceof Map && typeof v.get(name) === "function" ? v.get(name) : undefined);
+
+  // overload wraps the implementation of op so that a hash on the left with a method for op has it called with the
+  // right operand instead. == and != turn what __eq returns into a boolean.
+  const overload = (op, fn) => (a, b) => {
+    const m = method(a, operatorMethods[op]);
+    if (m === undefined) return fn(a, b);
+    if (op === "==") return truthy(m(b));
+    if (op === "!=") return !truthy(m(b));
+    return m(b);
+  };
+
+  // infix applies an infix operator: ints to two integers, strings, if the operator has a meaning for them, to two
+  // strings. Anything else is an error.
+  const infix = (op, a, b, ints, strings) => {
+    if (typeof a === "bigint" && typeof b === "bigint") return ints(a, b);
+    if (type(a) !== type(b)) fail(`type mismatch: ${type(a)} ${op} ${type(b)}`);
+    if (strings && typeof a === "string") return strings(a, b);
+    return fail(`unknown operator: ${type(a)} ${op} ${type(b)}`);
+  };
+
+  // native holds the functions the runtime makes, the builtins among them. Like the interpreter's builtins, curry can't
+  // tell how many arguments they take, unless required has it. The program's own functions take as many as their
+  // length says: the parameters before the first one with a default.
+  const native = new WeakSet();
+  const required = new WeakMap();
+
+  const made = (fn) => {
+    native.add(fn);
+    return fn;
+  };
+
+  // arity returns how many arguments curry waits for before calling fn, undefined if it can't tell.
+  const arity = (fn) => {
+    if (required.has(fn)) return required.get(fn);
+    return native.has(fn) ? undefined : fn.length;
+  };
+
+  // composed returns a function that calls the last of fns with its arguments and then each of the others, from right
+  // to left, with what the one after it returned, the way compose(f, g)(x) is f(g(x)).
+  const composed = (fns) =>
+    made((...args) => fns.slice(0, -1).reduceRight((result, fn) => fn(result), fns[fns.length - 1](...args)));
+
+  // curried returns a function that collects arguments for fn, on top of those in bound, until it has n of them and
+  // then calls fn with them all.
+  const curried = (fn, n, bound) =>
+    made((...args) => {
+      const all = [...bound, ...args];
+      return all.length < n ? curried(fn, n, all) : fn(...all);
+    });
+
+  const want = (args, n) => {
+    if (args.length !== n) fail(`wrong number of arguments. got=${args.length}, want=${n}`);
+  };
+
+  const array = (name, v) => {
+    if (!Array.isArray(v)) fail(`argument to \`${name}\` must be ARRAY, got ${type(v)}`);
+    return v;
+  };
+
+  const string = (name, v) => {
+    if (typeof v !== "string") fail(`argument to \`${name}\` must be STRING, got ${type(v)}`);
+    return v;
+  };
+
+  // elements returns what map and filter walk: the elements of an array, the characters of a string, or the keys of a
+  // hash.
+  const elements = (name, v) => {
+    if (Array.isArray(v)) return v;
+    if (typeof v === "string") return [...v];
+    if (v instanceof Map) return [...v.keys()];
+    return fail(`argument to \`${name}\` must be iterable, got ${type(v)}`);
+  };
+
+  // format fills in the verbs of layout with values the way the interpreter's format builtin does.
+  const format = (name, layout, values) => {
+    string(name, layout);
+    let out = "";
+    let used = 0;
+    const chars = [...layout];
+    for (let i = 0; i < chars.length; i++) {
+      if (chars[i] !== "%") {
+        out += chars[i];
+        continue;
+      }
+
+      i++;
+      if (i === chars.length) fail(`format string of \`${name}\` ends with a lone %`);
+      const verb = chars[i];
+      if (verb === "%") {
+        out += "%";
+        continue;
+      }
+      if (verb !== "d" && verb !== "s" && verb !== "v") fail(`unknown verb %${verb} in format string of \`${name}\``);
+      if (used === values.length) fail(`missing value for %${verb} in format string of \`${name}\``);
+
+      const value = values[used++];
+      if (verb === "d" && typeof value !== "bigint") {
+        fail(`value for %d in \`${name}\` must be INTEGER, got ${type(value)}`);
+      }
+      if (verb === "s" && typeof value !== "string") {
+        fail(`value for %s in \`${name}\` must be STRING, got ${type(value)}`);
+      }
+      out += inspect(value);
+    }
+
+    if (used < values.length) {
+      fail(`too many values for format string of \`${name}\`. got=${values.length}, want=${used}`);
+    }
+    return out;
+  };
+
+  const runtime = {
+    SlothError,
+    Exit,
+    inspect,
+
+    // main runs program, reporting an error nothing caught, and ending the process with the code exit was given when
+    // running under node.
+    main(program) {
+      const hasProcess = typeof process !== "undefined";
+      try {
+        program();
+      } catch (err) {
+        if (err instanceof Exit) {
+          if (hasProcess) process.exit(Number(err.code));
+          return;
+        }
+        console.error("ERROR: " + err.message);
+        if (hasProcess) process.exitCode = 1;
+      }
+    },
+
+    truthy,
+    not: (v) => v === false || v === null,
+    neg: (v) => (typeof v === "bigint" ? -v : fail(`unknown operator: -${type(v)}`)),
+    pos: (v) => (typeof v === "bigint" ? v : fail(`unknown operator: +${type(v)}`)),
+
+    add: overload("+", (a, b) => infix("+", a, b, (x, y) => x + y, (x, y) => x + y)),
+    sub: overload("-", (a, b) => infix("-", a, b, (x, y) => x - y)),
+    mul: overload("*", (a, b) => infix("*", a, b, (x, y) => x * y)),
+    div: overload("/", (a, b) => infix("/", a, b, (x, y) => (y === 0n ? fail("division by zero") : x / y))),
+    lt: overload("<", (a, b) => infix("<", a, b, (x, y) => x < y)),
+    gt: overload(">", (a, b) => infix(">", a, b, (x, y) => x > y)),
+    eq: overload("==", (a, b) => equal(a, b)),
+    neq: overload("!=", (a, b) => !equal(a, b)),
+    // f >> g is the function calling f and then g on what f returned
+    chain: (a, b) =>
+      typeof a === "function" && typeof b === "function"
+        ? composed([b, a])
+        : infix(">>", a, b, () => fail("unknown operator: INTEGER >> INTEGER")),
+
+    spread: (v) => (Array.isArray(v) ? v : fail(`cannot spread ${type(v)}`)),
+
+    hash(...pairs) {
+      return new Map(pairs.map(([k, v]) => [hashable(k), v]));
+    },
+
+    index(left, index) {
+      if (Array.isArray(left) && typeof index === "bigint") {
+        const i = index < 0n ? index + BigInt(left.length) : index;
+        return i < 0n || i >= BigInt(left.length) ? null : left[Number(i)];
+      }
+      if (left instanceof Map) {
+        const m = method(left, "__index");
+        if (m !== undefined) return m(index);
+        const key = hashable(index);
+        return left.has(key) ? left.get(key) : null;
+      }
+      return fail(`index operator not supported: ${type(left)}`);
+    },
+
+    optionalIndex(left, index) {
+      return left === null ? null : this.index(left, index());
+    },
+
+    // thrown is the error a throw statement raises with v. A thrown string is the error's message.
+    thrown: (v) => new SlothError(typeof v === "string" ? v : inspect(v), v),
+
+    // try runs body, and handler with what the catch parameter is bound to if body fails: the thrown value, or a hash
+    // holding the message of any other error.
+    try(body, handler) {
+      try {
+        return body();
+      } catch (err) {
+        if (err instanceof Exit) throw err;
+        if (err instanceof SlothError && err.value !== undefined) return handler(err.value);
+        return handler(new Map([["message", err.message]]));
+      }
+    },
+
+    // the builtins programs can call; the transpiler rejects calls to any other
+    puts(...args) {
+      args.forEach((v) => console.log(inspect(v)));
+      return null;
+    },
+    printf(...args) {
+      if (args.length < 1) fail(`wrong number of arguments. got=${args.length}, want at least 1`);
+      const out = format("printf", args[0], args.slice(1));
+      // console.log always ends a line, so printf only leaves one open where there is a stdout to write to
+      if (typeof process !== "undefined") process.stdout.write(out);
+      else console.log(out);
+      return null;
+    },
+    format(...args) {
+      if (args.length < 1) fail(`wrong number of arguments. got=${args.length}, want at least 1`);
+      return format("format", args[0], args.slice(1));
+    },
+    len(...args) {
+      want(args, 1);
+      const [v] = args;
+      if (typeof v === "string") return BigInt([...v].length);
+      if (Array.isArray(v)) return BigInt(v.length);
+      return fail(`argument to \`len\` not supported, got ${type(v)}`);
+    },
+    first(...args) {
+      want(args, 1);
+      const arr = array("first", args[0]);
+      return arr.length > 0 ? arr[0] : null;
+    },
+    last(...args) {
+      want(args, 1);
+      const arr = array("last", args[0]);
+      return arr.length > 0 ? arr[arr.length - 1] : null;
+    },
+    rest(...args) {
+      want(args, 1);
+      const arr = array("rest", args[0]);
+      return arr.length > 0 ? arr.slice(1) : null;
+    },
+    push(...args) {
+      want(args, 2);
+      return [...array("push", args[0]), args[1]];
+    },
+    map(...args) {
+      want(args, 2);
+      return elements("map", args[0]).map((v) => args[1](v));
+    },
+    filter(...args) {
+      want(args, 2);
+      return elements("filter", args[0]).filter((v) => {
+        const keep = args[1](v);
+        return keep !== null && keep !== false;
+      });
+    },
+    chars(...args) {
+      want(args, 1);
+      return [...string("chars", args[0])];
+    },
+    ord(...args) {
+      want(args, 1);
+      const s = string("ord", args[0]);
+      if ([...s].length !== 1) fail(`argument to \`ord\` must be a single character, got ${JSON.stringify(s)}`);
+      return BigInt(s.codePointAt(0));
+    },
+    chr(...args) {
+      want(args, 1);
+      const code = args[0];
+      if (typeof code !== "bigint") fail(`argument to \`chr\` must be INTEGER, got ${type(code)}`);
+      if (code < 0n || code > 0x10ffffn || (code >= 0xd800n && code <= 0xdfffn)) {
+        fail(`${code} is not a valid character code`);
+      }
+      return String.fromCodePoint(Number(code));
+    },
+    assert(...args) {
+      if (args.length < 1 || args.length > 2) fail(`wrong number of arguments. got=${args.length}, want 1 to 2`);
+      if (args[0] !== null && args[0] !== false) return null;
+      return fail(args.length === 2 ? `assertion failed: ${inspect(args[1])}` : "assertion failed");
+    },
+    assert_eq(...args) {
+      want(args, 2);
+      if (!equal(args[0], args[1])) fail(`assertion failed: ${inspect(args[0])} != ${inspect(args[1])}`);
+      return null;
+    },
+    deep_equal(...args) {
+      want(args, 2);
+      return equal(args[0], args[1]);
+    },
+    exit(...args) {
+      if (args.length > 1) fail(`wrong number of arguments. got=${args.length}, want 0 to 1`);
+      if (args.length === 1 && typeof args[0] !== "bigint") {
+        fail(`argument to \`exit\` must be INTEGER, got ${type(args[0])}`);
+      }
+      throw new Exit(args.length === 1 ? args[0] : 0n);
+    },
+    compose(...args) {
+      if (args.length < 1) fail(`wrong number of arguments. got=${args.length}, want at least 1`);
+      for (const fn of args) {
+        if (typeof fn !== "function") fail(`arguments to \`compose\` must be FUNCTION, got ${type(fn)}`);
+      }
+      return composed(args);
+    },
+    partial(...args) {
+      if (args.length < 1) fail(`wrong number of arguments. got=${args.length}, want at least 1`);
+      const [fn, ...bound] = args;
+      if (typeof fn !== "function") fail(`argument to \`partial\` must be FUNCTION, got ${type(fn)}`);
+      const partial = made((...rest) => fn(...bound, ...rest));
+      if (arity(fn) !== undefined) required.set(partial, Math.max(arity(fn) - bound.length, 0));
+      return partial;
+    },
+    curry(...args) {
+      if (args.length < 1 || args.length > 2) fail(`wrong number of arguments. got=${args.length}, want 1 to 2`);
+      const [fn, n] = args;
+      if (args.length === 2) {
+        if (typeof n !== "bigint") fail(`second argument to \`curry\` must be INTEGER, got ${type(n)}`);
+        if (n < 0n) fail(`number of arguments to \`curry\` must not be negative, got ${n}`);
+      }
+      if (typeof fn !== "function") fail(`argument to \`curry\` must be FUNCTION, got ${type(fn)}`);
+      const wanted = args.length === 2 ? Number(n) : arity(fn);
+      if (wanted === undefined) {
+        fail("`curry` can't tell how many arguments a builtin takes, pass it as the second argument");
+      }
+      return curried(fn, wanted, []);
+    },
+  };
+
+  for (const v of Object.values(runtime)) {
+    if (typeof v === "function") native.add(v);
+  }
+  return runtime;
+})();
@@ -0,0 +1,237 @@
+/*
+Package runtime is what Go programs generated by the transpile package run on. Values are the interpreter's own
+objects, and every operator and builtin goes through the evaluator, so a compiled program computes the same things,
+and fails with the same errors, as it would when interpreted.
+
+Errors don't travel as values the way they do in the evaluator. Anything that produces an error panics with it instead,
+which unwinds the generated Go code until a try expression, see Try, or Main catches it.
+*/
+package runtime
+
+import (
+	"fmt"
+	"github.com/sean-d/sloth/evaluator"
+	"github.com/sean-d/sloth/object"
+	"math/big"
+	"os"
+)
+
+// Value is a sloth value.
+type Value = object.Object
+
+// The values there is only ever one of.
+var (
+	Null  Value = evaluator.NULL
+	True  Value = evaluator.TRUE
+	False Value = evaluator.FALSE
+)
+
+/*
+Main runs program, the body of a compiled script. A runtime error that nothing caught is printed to stderr and ends
+the process with exit code 1, the way running the script with sloth does; exit ends it with the code it was given.
+*/
+func Main(program func() Value) {
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case *object.Error:
+			fmt.Fprintln(os.Stderr, r.Inspect())
+			os.Exit(1)
+		case *object.Exit:
+			os.Exit(int(r.Code))
+		default:
+			panic(r)
+		}
+	}()
+
+	program()
+}
+
+// check panics with v if it is an error, or an exit, and returns it otherwise.
+func check(v Value) Value {
+	switch v.(type) {
+	case *object.Error, *object.Exit:
+		panic(v)
+	}
+
+	return v
+}
+
+// Int returns the integer v.
+func Int(v int64) Value {
+	return object.IntegerOf(v)
+}
+
+// BigInt returns the integer written out in decimal in digits, for literals too large for an int64.
+func BigInt(digits string) Value {
+	v, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		panic(fmt.Sprintf("runtime: invalid integer literal %q", digits))
+	}
+
+	return &object.BigInteger{Value: v}
+}
+
+// String returns the string v. It is only used for string literals, so v is interned the way the evaluator interns
+// them, see object.InternString.
+func String(v string) Value {
+	return object.InternString(v)
+}
+
+// Array returns an array of elements.
+func Array(elements ...Value) Value {
+	return &object.Array{Elements: elements}
+}
+
+// Spread returns the elements of v, which has to be an array, for ...v in a call's arguments or an array's elements.
+func Spread(v Value) []Value {
+	array, ok := v.(*object.Array)
+	if !ok {
+		panic(&object.Error{Message: fmt.Sprintf("cannot spread %s", v.Type())})
+	}
+
+	return array.Elements
+}
+
+// Splice joins parts into one list of values, for arguments or elements some of which are spread.
+func Splice(parts ...[]Value) []Value {
+	var values []Value
+	for _, part := range parts {
+		values = append(values, part...)
+	}
+
+	return values
+}
+
+// Hash returns a hash of keysAndValues, a key followed by its value, in the order they are given.
+func Hash(keysAndValues ...Value) Value {
+	hash := object.NewHash()
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		key, ok := object.HashKeyOf(keysAndValues[i])
+		if !ok {
+			panic(&object.Error{Message: fmt.Sprintf("unusable as hash key: %s", keysAndValues[i].Type())})
+		}
+		hash.Set(key, object.HashPair{Key: keysAndValues[i], Value: keysAndValues[i+1]})
+	}
+
+	return hash
+}
+
+// Truthy reports whether v counts as true in a condition.
+func Truthy(v Value) bool {
+	return evaluator.IsTruthy(v)
+}
+
+// Prefix applies the prefix operator to right.
+func Prefix(operator string, right Value) Value {
+	return check(evaluator.Prefix(operator, right))
+}
+
+// Infix applies the infix operator to left and right.
+func Infix(operator string, left Value, right Value) Value {
+	return check(evaluator.Infix(operator, left, right))
+}
+
+// Nullish is left ?? right: left, unless it is null, in which case right is called for the value instead.
+func Nullish(left Value, right func() Value) Value {
+	if left != Null {
+		return left
+	}
+
+	return right()
+}
+
+// Index is left[index].
+func Index(left Value, index Value) Value {
+	return check(evaluator.Index(left, index))
+}
+
+// OptionalIndex is left?[index]: null if left is null, left[index] otherwise. index is only called for when needed.
+func OptionalIndex(left Value, index func() Value) Value {
+	if left == Null {
+		return Null
+	}
+
+	return Index(left, index())
+}
+
+// Call calls fn with args.
+func Call(fn Value, args ...Value) Value {
+	return check(evaluator.Apply(fn, args))
+}
+
+// Builtin returns the builtin called name. The transpiler has already checked that there is one.
+func Builtin(name string) Value {
+	builtin, ok := evaluator.LookupBuiltin(name)
+	if !ok {
+		panic(&object.Error{Message: "identifier not found: " + name})
+	}
+
+	return builtin
+}
+
+/*
+Func turns a compiled function literal into a value sloth code can call, builtins like map included. body is handed
+the arguments once Func has checked there are at least required of them and, unless max is -1 for a function with a
+rest parameter, no more than max. The builtin records required, which curry goes by the way it goes by the parameters
+of an interpreted function.
+*/
+func Func(required int, max int, body func(args []Value) Value) Value {
+	return &object.Builtin{Required: &required, Fn: func(args ...object.Object) (result object.Object) {
+		switch {
+		case max < 0 && len(args) < required:
+			return &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want at least %d", len(args), required)}
+		case max >= 0 && required == max && len(args) != required:
+			return &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%d", len(args), required)}
+		case max >= 0 && (len(args) < required || len(args) > max):
+			return &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want %d to %d", len(args), required, max)}
+		}
+
+		// an error raised in the body is handed back as a value, which is how builtins calling the function expect it
+		defer func() {
+			if r := recover(); r != nil {
+				err, ok := r.(*object.Error)
+				if !ok {
+					panic(r)
+				}
+				result = err
+			}
+		}()
+
+		return body(args)
+	}}
+}
+
+// Rest collects the arguments from the from-th on into an array, for a rest parameter.
+func Rest(args []Value, from int) Value {
+	if from >= len(args) {
+		return Array()
+	}
+
+	return Array(append([]Value{}, args[from:]...)...)
+}
+
+// Throw returns the error a throw statement raises with v. Generated code panics with it, which Go knows ends the
+// block it is in.
+func Throw(v Value) *object.Error {
+	return evaluator.Throw(v)
+}
+
+// Try runs body and returns its value. If body raises an error, catch is run with what a catch parameter is bound to
+// for the error, and its value is returned instead.
+func Try(body func() Value, catch func(err Value) Value) (result Value) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		err, ok := r.(*object.Error)
+		if !ok {
+			panic(r)
+		}
+		result = catch(evaluator.Caught(err))
+	}()
+
+	return body()
+}
@@ -0,0 +1,249 @@
+/*
+Package transpile turns sloth programs into other languages, so they can run without the interpreter. Go writes a
+standalone main package that runs on the transpile/runtime package, so a small script can be built into a native
+binary with the go tool:
+
+	$ sloth build script.sloth > main.go
+	$ go build -o script main.go
+
+JavaScript writes a script for node or a browser, with the runtime it needs at the top:
+
+	$ sloth build --target=js script.sloth > script.js
+	$ node script.js
+
+The translation is direct in both. Bindings made with let, const and fn become variables, functions become closures,
+if expressions become if statements, and operators and builtins become calls into the runtime, which behave just like
+they do when the script is interpreted.
+
+Only part of the language is covered so far. spawn, classes, the dot operator, slices, coroutines, and a return inside
+an if or try expression that is used as a value can't be transpiled yet; both targets report each of them as an
+Error.
+*/
+package transpile
+
+import (
+	"fmt"
+	"github.com/sean-d/sloth/ast"
+	"github.com/sean-d/sloth/token"
+	"strings"
+)
+
+// Error is a part of a program that can't be transpiled, found at Pos in its source.
+type Error struct {
+	Pos     token.Position
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+/*
+binding is a name bound in a scope. read records whether the generated code reads the Go variable it becomes, as Go
+refuses to compile a function with a local variable that is never read. constant is set once a const statement has
+bound the name.
+*/
+type binding struct {
+	read     bool
+	constant bool
+}
+
+/*
+scope mirrors an object.Environment the way the linter's scopes do: the program, every function and every catch block
+get one, the blocks of if and try expressions don't. Every name a function binds anywhere in its body is declared up
+front, as the variable it becomes has to be declared before any of the Go blocks the body turns into can assign it.
+*/
+type scope struct {
+	bindings map[string]*binding
+	order    []string
+	function bool
+}
+
+func (s *scope) declare(name string) {
+	if _, ok := s.bindings[name]; ok {
+		return
+	}
+	s.bindings[name] = &binding{}
+	s.order = append(s.order, name)
+}
+
+/*
+generator is what the Go and JavaScript backends share: it writes their output one line at a time and keeps track of
+the scopes of the program being transpiled. valueDepth counts the if and try expressions used as values that the code
+being written is inside of: both backends turn those into closures, and a return in one of them would only return from
+the closure rather than from the sloth function around it.
+*/
+type generator struct {
+	out        *strings.Builder
+	scopes     []*scope
+	valueDepth int
+	errors     []error
+}
+
+func (g *generator) line(format string, args ...any) {
+	fmt.Fprintf(g.out, format+"\n", args...)
+}
+
+// capture returns the Go that write writes, rather than adding it to the output.
+func (g *generator) capture(write func()) string {
+	saved := g.out
+	g.out = &strings.Builder{}
+	write()
+
+	written := g.out.String()
+	g.out = saved
+
+	return written
+}
+
+func (g *generator) push(function bool) *scope {
+	s := &scope{bindings: map[string]*binding{}, function: function}
+	g.scopes = append(g.scopes, s)
+	return s
+}
+
+func (g *generator) pop() {
+	g.scopes = g.scopes[:len(g.scopes)-1]
+}
+
+func (g *generator) errorf(node ast.Node, format string, args ...any) {
+	g.errors = append(g.errors, &Error{Pos: node.Pos(), Message: fmt.Sprintf(format, args...)})
+}
+
+// unsupported reports node as a part of the language the backend doesn't handle, target naming the backend. It returns
+// "" for the missing output, which is never used as the backend fails.
+func (g *generator) unsupported(node ast.Node, what string, target string) string {
+	g.errorf(node, "%s can't be transpiled to %s yet", what, target)
+	return ""
+}
+
+// lookup finds the innermost binding of name.
+func (g *generator) lookup(name string) (*binding, bool) {
+	for i := len(g.scopes) - 1; i >= 0; i-- {
+		if b, ok := g.scopes[i].bindings[name]; ok {
+			return b, true
+		}
+	}
+
+	return nil, false
+}
+
+// bind checks that name may be bound by a let, const or fn statement, which it can't if it is a constant, and marks
+// it constant for a const statement.
+func (g *generator) bind(name *ast.Identifier, constant bool) {
+	b, ok := g.lookup(name.Value)
+	if !ok {
+		return
+	}
+
+	if b.constant {
+		g.errorf(name, "cannot reassign constant %s", name.Value)
+	}
+	b.constant = constant
+}
+
+// value writes a body that is used as a value, an if or try expression, as the body of a closure returning it.
+func (g *generator) value(write func()) string {
+	g.valueDepth++
+	defer func() { g.valueDepth-- }()
+
+	return g.capture(write)
+}
+
+/*
+hoist returns every name bound by a let, const or fn statement in stmts, including the blocks of if expressions and
+the bodies of try expressions in them, in the order they are first bound. Function literals and catch blocks are not
+looked into, as their bindings are their own. Names in skip, a function's parameters, are left out.
+*/
+func hoist(stmts []ast.Statement, skip []string) []string {
+	seen := map[string]bool{}
+	for _, name := range skip {
+		seen[name] = true
+	}
+
+	var names []string
+	var expression func(e ast.Expression)
+	var statements func(stmts []ast.Statement)
+
+	bind := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	block := func(b *ast.BlockStatement) {
+		if b != nil {
+			statements(b.Statements)
+		}
+	}
+
+	expression = func(e ast.Expression) {
+		switch e := e.(type) {
+		case *ast.PrefixExpression:
+			expression(e.Right)
+		case *ast.InfixExpression:
+			expression(e.Left)
+			expression(e.Right)
+		case *ast.IfExpression:
+			expression(e.Condition)
+			block(e.Consequence)
+			block(e.Alternative)
+		case *ast.TryExpression:
+			// the catch block binds into a scope of its own, so its names are hoisted separately, see catchLocals
+			block(e.Body)
+		case *ast.CallExpression:
+			expression(e.Function)
+			for _, arg := range e.Arguments {
+				expression(arg)
+			}
+		case *ast.ArrayLiteral:
+			for _, element := range e.Elements {
+				expression(element)
+			}
+		case *ast.SpreadExpression:
+			expression(e.Value)
+		case *ast.HashLiteral:
+			for _, key := range e.OrderedKeys() {
+				expression(key)
+				expression(e.Pairs[key])
+			}
+		case *ast.IndexExpression:
+			expression(e.Left)
+			expression(e.Index)
+		}
+	}
+
+	statements = func(stmts []ast.Statement) {
+		for _, s := range stmts {
+			switch s := s.(type) {
+			case *ast.LetStatement:
+				bind(s.Name.Value)
+				expression(s.Value)
+			case *ast.FunctionStatement:
+				bind(s.Name.Value)
+			case *ast.ReturnStatement:
+				expression(s.ReturnValue)
+			case *ast.ThrowStatement:
+				expression(s.Value)
+			case *ast.ExpressionStatement:
+				expression(s.Expression)
+			}
+		}
+	}
+
+	statements(stmts)
+	return names
+}
+
+// catchLocals declares the catch parameter of te and every name its catch block binds in s, the catch block's scope,
+// and returns the names bound in the block, which the backends declare at the top of it.
+func catchLocals(s *scope, te *ast.TryExpression) []string {
+	s.declare(te.Param.Value)
+
+	locals := hoist(te.Catch.Statements, []string{te.Param.Value})
+	for _, name := range locals {
+		s.declare(name)
+	}
+
+	return locals
+}
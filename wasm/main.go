@@ -0,0 +1,89 @@
+//go:build js && wasm
+
+/*
+Command wasm runs sloth in the browser. Built with
+
+	GOOS=js GOARCH=wasm go build -o sloth.wasm ./wasm
+
+and loaded with the wasm_exec.js that ships with Go, it exposes a single function to JavaScript:
+
+	const { output, result, error } = EvalString('puts("hi"); 1 + 2');
+
+output is everything the program printed, with puts, printf or input's prompt, result is what the program evaluated
+to, and error is set to the parser or runtime errors when there were any. Every call starts from a fresh environment.
+exec is taken away, as there are no processes to start in a browser, and there is no standard input either, so input
+always returns null.
+*/
+package main
+
+import (
+	"fmt"
+	"github.com/sean-d/sloth/evaluator"
+	"github.com/sean-d/sloth/lexer"
+	"github.com/sean-d/sloth/object"
+	"github.com/sean-d/sloth/parser"
+	"strings"
+	"syscall/js"
+)
+
+// output collects what a program prints during a call to EvalString, which hands it back to JavaScript instead of the
+// browser console.
+var output strings.Builder
+
+func main() {
+	setup()
+	js.Global().Set("EvalString", js.FuncOf(evalString))
+
+	// keep the Go side running so JavaScript can go on calling EvalString
+	select {}
+}
+
+// setup gets the interpreter ready to run what is typed into the playground: with none of the capabilities that reach
+// outside, printing into output and reading from nothing.
+func setup() {
+	for _, capability := range evaluator.Capabilities() {
+		evaluator.Deny(capability)
+	}
+	evaluator.SetOutput(&output)
+	evaluator.SetInput(strings.NewReader(""))
+}
+
+// evalString is EvalString as JavaScript sees it: it takes the source to run and returns an object holding its
+// output, result and error.
+func evalString(this js.Value, args []js.Value) any {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return map[string]any{"output": "", "result": "", "error": "EvalString takes the source to evaluate as a string"}
+	}
+
+	result, err := EvalString(args[0].String())
+	defer output.Reset()
+
+	return map[string]any{"output": output.String(), "result": result, "error": err}
+}
+
+// EvalString parses and evaluates source in a fresh environment and returns what it evaluated to, printed the way the
+// REPL prints it, or its errors, one per line, each with the position it was found at.
+func EvalString(source string) (result string, err string) {
+	p := parser.New(lexer.New(source))
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		var errs []string
+		for _, e := range p.ParseErrors() {
+			errs = append(errs, fmt.Sprintf("%s: parser error: %s", e.Pos(), e.Message))
+		}
+		return "", strings.Join(errs, "\n")
+	}
+
+	switch evaluated := evaluator.Eval(program, object.NewEnvironment()).(type) {
+	case nil:
+		return "", ""
+	case *object.Error:
+		if evaluated.Pos.IsValid() {
+			return "", fmt.Sprintf("%s: %s", evaluated.Pos, evaluated.Inspect())
+		}
+		return "", evaluated.Inspect()
+	default:
+		return object.Pretty(evaluated), ""
+	}
+}
@@ -0,0 +1,43 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	setup()
+	os.Exit(m.Run())
+}
+
+func TestEvalString(t *testing.T) {
+	tests := []struct {
+		input  string
+		output string
+		result string
+		err    string
+	}{
+		{`puts("hi"); 1 + 2`, "hi\n", "3", ""},
+		{`printf("%d and %s", 1, "two"); printf("!")`, "1 and two!", "null", ""},
+		{`input("name? ")`, "name? ", "null", ""},
+		{`exec("ls")`, "", "", "the exec capability has been denied"},
+		{`let = 1;`, "", "", "parser error"},
+	}
+
+	for _, tt := range tests {
+		output.Reset()
+		result, err := EvalString(tt.input)
+		if output.String() != tt.output {
+			t.Errorf("EvalString(%q) printed %q, want %q", tt.input, output.String(), tt.output)
+		}
+		if result != tt.result {
+			t.Errorf("EvalString(%q) = %q, want %q", tt.input, result, tt.result)
+		}
+		if (tt.err == "") != (err == "") || !strings.Contains(err, tt.err) {
+			t.Errorf("EvalString(%q) error = %q, want one containing %q", tt.input, err, tt.err)
+		}
+	}
+}